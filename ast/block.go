@@ -6,6 +6,7 @@ import (
 )
 
 type Block struct {
+	Location
 	Statements []Statement
 }
 
@@ -32,6 +33,7 @@ func (b *Block) Equals(other Expression) bool {
 	return true
 }
 
+// a block has no position of its own, it starts at its '{'
 func (b *Block) GetPosition() shared.Position {
-	return shared.Position{}
+	return b.Span.Start
 }
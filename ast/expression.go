@@ -37,11 +37,13 @@ var ValidTypeAnnotation = map[lexer.TokenType]shared.TypeAnnotation{
 
 type Expression interface {
 	Node
+	Spanned
 	Equals(Expression) bool
 	GetPosition() shared.Position
 }
 
 type OrExpression struct {
+	Location
 	LeftExpression  Expression
 	RightExpression Expression
 	Position        shared.Position
@@ -68,6 +70,7 @@ func (e *OrExpression) Accept(v Visitor) {
 }
 
 type AndExpression struct {
+	Location
 	LeftExpression  Expression
 	RightExpression Expression
 	Position        shared.Position
@@ -94,6 +97,7 @@ func (e *AndExpression) Accept(v Visitor) {
 }
 
 type EqualsExpression struct {
+	Location
 	LeftExpression  Expression
 	RightExpression Expression
 	Position        shared.Position
@@ -120,6 +124,7 @@ func (e *EqualsExpression) Accept(v Visitor) {
 }
 
 type NotEqualsExpression struct {
+	Location
 	LeftExpression  Expression
 	RightExpression Expression
 	Position        shared.Position
@@ -146,6 +151,7 @@ func (e *NotEqualsExpression) Accept(v Visitor) {
 }
 
 type GreaterThanExpression struct {
+	Location
 	LeftExpression  Expression
 	RightExpression Expression
 	Position        shared.Position
@@ -172,6 +178,7 @@ func (e *GreaterThanExpression) Accept(v Visitor) {
 }
 
 type LessThanExpression struct {
+	Location
 	LeftExpression  Expression
 	RightExpression Expression
 	Position        shared.Position
@@ -198,6 +205,7 @@ func (e *LessThanExpression) Accept(v Visitor) {
 }
 
 type GreaterOrEqualExpression struct {
+	Location
 	LeftExpression  Expression
 	RightExpression Expression
 	Position        shared.Position
@@ -224,6 +232,7 @@ func (e *GreaterOrEqualExpression) Accept(v Visitor) {
 }
 
 type LessOrEqualExpression struct {
+	Location
 	LeftExpression  Expression
 	RightExpression Expression
 	Position        shared.Position
@@ -250,6 +259,7 @@ func (e *LessOrEqualExpression) Accept(v Visitor) {
 }
 
 type SumExpression struct {
+	Location
 	LeftExpression  Expression
 	RightExpression Expression
 	Position        shared.Position
@@ -276,6 +286,7 @@ func (e *SumExpression) Accept(v Visitor) {
 }
 
 type SubstractExpression struct {
+	Location
 	LeftExpression  Expression
 	RightExpression Expression
 	Position        shared.Position
@@ -302,6 +313,7 @@ func (e *SubstractExpression) Accept(v Visitor) {
 }
 
 type MultiplyExpression struct {
+	Location
 	LeftExpression  Expression
 	RightExpression Expression
 	Position        shared.Position
@@ -328,6 +340,7 @@ func (e *MultiplyExpression) Accept(v Visitor) {
 }
 
 type DivideExpression struct {
+	Location
 	LeftExpression  Expression
 	RightExpression Expression
 	Position        shared.Position
@@ -354,6 +367,7 @@ func (e *DivideExpression) Accept(v Visitor) {
 }
 
 type CastExpression struct {
+	Location
 	LeftExpression Expression
 	TypeAnnotation shared.TypeAnnotation
 	Position       shared.Position
@@ -380,6 +394,7 @@ func (e *CastExpression) Accept(v Visitor) {
 }
 
 type NegateExpression struct {
+	Location
 	Expression Expression
 	Position   shared.Position
 }
@@ -404,6 +419,7 @@ func (e *NegateExpression) Accept(v Visitor) {
 }
 
 type IntExpression struct {
+	Location
 	Value    int
 	Position shared.Position
 }
@@ -428,6 +444,7 @@ func (e *IntExpression) Accept(v Visitor) {
 }
 
 type FloatExpression struct {
+	Location
 	Value    float64
 	Position shared.Position
 }
@@ -452,6 +469,7 @@ func (e *FloatExpression) Accept(v Visitor) {
 }
 
 type BoolExpression struct {
+	Location
 	Value    bool
 	Position shared.Position
 }
@@ -475,6 +493,7 @@ func (e *BoolExpression) Accept(v Visitor) {
 }
 
 type StringExpression struct {
+	Location
 	Value    string
 	Position shared.Position
 }
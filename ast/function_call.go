@@ -6,9 +6,13 @@ import (
 )
 
 type FunctionCall struct {
+	Location
 	Name      string
 	Arguments []Expression
 	Position  shared.Position
+	// set by the resolver for a call of the enclosing function whose
+	// result is returned right away, it reuses the frame of the caller
+	Tail bool
 }
 
 func NewFunctionCall(name string, position shared.Position, arguments []Expression) *FunctionCall {
@@ -1,10 +1,13 @@
 package ast
 
 import (
+	"fmt"
+	"strings"
 	"tkom/shared"
 )
 
 type FunctionDefinition struct {
+	Location
 	Name       string
 	Block      *Block
 	Parameters []*Variable
@@ -35,3 +38,29 @@ func (f *FunctionDefinition) IsVariadic() bool {
 func (f *FunctionDefinition) Accept(v Visitor) {
 	v.VisitFunctionDefinition(f)
 }
+
+// signature of the function like in its definition, e.g. add(a int, b int) int
+func Signature(function Function) string {
+	switch function := function.(type) {
+	case *FunctionDefinition:
+		parameters := make([]string, len(function.Parameters))
+		for i, param := range function.Parameters {
+			parameters[i] = param.Name + " " + param.Type.String()
+		}
+		signature := function.Name + "(" + strings.Join(parameters, ", ") + ")"
+		if function.Type != shared.VOID {
+			signature += " " + function.Type.String()
+		}
+		return signature
+	case *EmbeddedFunction:
+		if function.Variadic {
+			return function.Name + "(...)"
+		}
+		parameters := make([]string, len(function.Parameters))
+		for i, param := range function.Parameters {
+			parameters[i] = fmt.Sprintf("%v", param)
+		}
+		return function.Name + "(" + strings.Join(parameters, ", ") + ")"
+	}
+	return fmt.Sprintf("%v", function)
+}
@@ -3,8 +3,11 @@ package ast
 import "tkom/shared"
 
 type Identifier struct {
+	Location
 	Name     string
 	Position shared.Position
+	// nil when the variable has to be looked up by name
+	Slot *Slot
 }
 
 func NewIdentifier(name string, position shared.Position) *Identifier {
@@ -1,6 +1,7 @@
 package ast
 
 type IfStatement struct {
+	Location
 	Condition             Expression
 	InstructionsBlock     *Block
 	ElseInstructionsBlock *Block
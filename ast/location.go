@@ -0,0 +1,22 @@
+package ast
+
+import "tkom/shared"
+
+// part of the source a node was parsed from, embedded in every node,
+// nodes built without a source, e.g. by the interpreter, have a zero span
+type Location struct {
+	Span shared.Span
+}
+
+func (l *Location) GetSpan() shared.Span {
+	return l.Span
+}
+
+func (l *Location) SetSpan(span shared.Span) {
+	l.Span = span
+}
+
+type Spanned interface {
+	GetSpan() shared.Span
+	SetSpan(shared.Span)
+}
@@ -1,7 +1,12 @@
 package ast
 
+import "tkom/shared"
+
 type Program struct {
+	Location
 	Functions map[string]*FunctionDefinition
+	// comments of the source in the order they appear, they do not affect the program
+	Comments []*Comment
 }
 
 func NewProgram(functions map[string]*FunctionDefinition) *Program {
@@ -11,3 +16,14 @@ func NewProgram(functions map[string]*FunctionDefinition) *Program {
 func (p *Program) Accept(v Visitor) {
 	v.VisitProgram(p)
 }
+
+// comment of the source, from the '#' to the end of the line
+type Comment struct {
+	Location
+	Text     string
+	Position shared.Position
+}
+
+func NewComment(text string, position shared.Position) *Comment {
+	return &Comment{Text: text, Position: position}
+}
@@ -1,6 +1,7 @@
 package ast
 
 type ReturnStatement struct {
+	Location
 	Value Expression
 
 }
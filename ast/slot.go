@@ -0,0 +1,10 @@
+package ast
+
+// place of a variable in the chain of scopes, filled in by the resolver
+//
+// Depth is the number of scopes between the use and the declaration,
+// Index is the position of the variable among the variables of its scope
+type Slot struct {
+	Depth int
+	Index int
+}
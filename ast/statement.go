@@ -2,5 +2,6 @@ package ast
 
 type Statement interface{
     Node
+    Spanned
 }
 
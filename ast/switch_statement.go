@@ -6,10 +6,12 @@ import (
 
 type Case interface {
 	Node
+	Spanned
 	GetPosition() shared.Position
 }
 
 type SwitchStatement struct {
+	Location
 	Variables []*Variable
 	Cases     []Case
 	Position  shared.Position
@@ -47,6 +49,7 @@ func (s *SwitchStatement) GetPosition() shared.Position {
 }
 
 type SwitchCase struct {
+	Location
 	Condition        Expression
 	OutputExpression Expression
 	Position         shared.Position
@@ -69,6 +72,7 @@ func NewSwitchCase(condition Expression, outputExpression Expression, position s
 }
 
 type DefaultSwitchCase struct {
+	Location
 	OutputExpression Expression
 	Position         shared.Position
 }
@@ -7,10 +7,13 @@ import (
 const ERROR_WRONG_VALUE_IN_DECLARATION = "cannot use \"%s\", as %s value in variable declaration"
 
 type Variable struct {
+	Location
 	Value    Expression
 	Name     string
 	Type     shared.TypeAnnotation
 	Position shared.Position
+	// nil when the variable has to be declared by name
+	Slot *Slot
 }
 
 func NewVariable(variableType shared.TypeAnnotation, name string, value Expression, position shared.Position) *Variable {
@@ -27,6 +30,7 @@ func (a *Variable) Accept(v Visitor) {
 }
 
 type Assignment struct {
+	Location
 	Value      Expression
 	Identifier *Identifier
 }
@@ -1,6 +1,7 @@
 package ast
 
 type WhileStatement struct {
+	Location
 	Condition    Expression
 	InstructionsBlock *Block
 }
@@ -0,0 +1,226 @@
+package astdump
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"tkom/ast"
+	"tkom/shared"
+	"unicode"
+)
+
+// every node of a program is written with its kind, the name of its type in ast,
+// followed by its fields, named like in ast starting with a lower case letter
+const (
+	KIND_FIELD      = "kind"
+	FUNCTIONS_FIELD = "functions"
+)
+
+// fields filled in by the resolver are not written, a loaded program is resolved again
+var resolverFields = map[string]bool{
+	"Slot": true,
+	"Tail": true,
+}
+
+var (
+	programType    = reflect.TypeOf(ast.Program{})
+	positionType   = reflect.TypeOf(shared.Position{})
+	spanType       = reflect.TypeOf(shared.Span{})
+	annotationType = reflect.TypeOf(shared.TypeAnnotation(0))
+)
+
+// writes the program as JSON, functions are in the order of the source
+//
+// e.g. {"kind": "IntExpression", "span": {...}, "value": 1, "position": {"line": 2, "column": 14}}
+func WriteJSON(w io.Writer, program *ast.Program) error {
+	var compact bytes.Buffer
+	if err := encode(&compact, reflect.ValueOf(program)); err != nil {
+		return err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, compact.Bytes(), "", "  "); err != nil {
+		return err
+	}
+	indented.WriteByte('\n')
+	_, err := w.Write(indented.Bytes())
+	return err
+}
+
+func encode(out *bytes.Buffer, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			out.WriteString("null")
+			return nil
+		}
+		return encode(out, v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			out.WriteString("null")
+			return nil
+		}
+		out.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			if err := encode(out, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		out.WriteByte(']')
+		return nil
+	case reflect.Struct:
+		out.WriteByte('{')
+		if isNode(v.Type()) {
+			fmt.Fprintf(out, "%q:%q,", KIND_FIELD, v.Type().Name())
+		}
+		for i, field := range fields(v) {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			fmt.Fprintf(out, "%q:", field.name)
+			if err := encode(out, field.value); err != nil {
+				return err
+			}
+		}
+		out.WriteByte('}')
+		return nil
+	}
+	if v.Type() == annotationType {
+		v = reflect.ValueOf(v.Interface().(shared.TypeAnnotation).String())
+	}
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	out.Write(data)
+	return nil
+}
+
+type field struct {
+	name  string
+	value reflect.Value
+}
+
+// positions and spans are written without a kind
+func isNode(t reflect.Type) bool {
+	return t != positionType && t != spanType
+}
+
+// written fields of the struct, the functions of a program are a list instead of a map
+// and the span of the embedded ast.Location is a field of the node
+func fields(v reflect.Value) []field {
+	fs := []field{}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if resolverFields[f.Name] {
+			continue
+		}
+		if f.Anonymous {
+			fs = append(fs, fields(v.Field(i))...)
+			continue
+		}
+		value := v.Field(i)
+		if v.Type() == programType && f.Name == "Functions" {
+			value = reflect.ValueOf(functions(v.Addr().Interface().(*ast.Program)))
+		}
+		fs = append(fs, field{fieldName(f.Name), value})
+	}
+	return fs
+}
+
+// functions of the program in the order of the source
+func functions(program *ast.Program) []*ast.FunctionDefinition {
+	fds := make([]*ast.FunctionDefinition, 0, len(program.Functions))
+	for _, fd := range program.Functions {
+		fds = append(fds, fd)
+	}
+	sort.Slice(fds, func(i, j int) bool {
+		a, b := fds[i].Position, fds[j].Position
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return fds[i].Name < fds[j].Name
+	})
+	return fds
+}
+
+func fieldName(name string) string {
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
+
+// writes the program as an indented tree, a node per line with its kind, its position,
+// its span and its values, followed by the nodes of its fields
+//
+//	FunctionDefinition 1:1 [1:1-3:2] name="main" type=void
+//	  block: Block [1:8-3:2]
+//	    ReturnStatement [2:5-2:13]
+//	      value: IntExpression 2:12 [2:12-2:13] value=1
+func WriteTree(w io.Writer, program *ast.Program) error {
+	var out bytes.Buffer
+	tree(&out, reflect.ValueOf(program).Elem(), "", 0)
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func tree(out *bytes.Buffer, v reflect.Value, label string, depth int) {
+	indent := strings.Repeat("  ", depth)
+	line := []string{v.Type().Name()}
+	span := []string{}
+	values := []string{}
+	children := []field{}
+	for _, f := range fields(v) {
+		value := f.value
+		for value.Kind() == reflect.Interface && !value.IsNil() {
+			value = value.Elem()
+		}
+		switch {
+		case value.Type() == positionType:
+			line = append(line, fmt.Sprintf("%d:%d", value.FieldByName("Line").Int(), value.FieldByName("Column").Int()))
+		case value.Type() == spanType:
+			if s := value.Interface().(shared.Span); !s.IsZero() {
+				span = append(span, fmt.Sprintf("[%d:%d-%d:%d]", s.Start.Line, s.Start.Column, s.End.Line, s.End.Column))
+			}
+		case value.Kind() == reflect.String:
+			values = append(values, fmt.Sprintf("%s=%q", f.name, value.String()))
+		case value.Kind() == reflect.Pointer || value.Kind() == reflect.Slice || value.Kind() == reflect.Interface:
+			if !value.IsNil() && !(value.Kind() == reflect.Slice && value.Len() == 0) {
+				children = append(children, field{f.name, value})
+			}
+		default:
+			values = append(values, fmt.Sprintf("%s=%v", f.name, value.Interface()))
+		}
+	}
+	line = append(line, span...)
+	line = append(line, values...)
+	fmt.Fprintf(out, "%s%s%s\n", indent, label, strings.Join(line, " "))
+
+	for _, child := range children {
+		if child.value.Kind() == reflect.Pointer {
+			tree(out, child.value.Elem(), child.name+": ", depth+1)
+			continue
+		}
+		fmt.Fprintf(out, "%s  %s:\n", indent, child.name)
+		for i := 0; i < child.value.Len(); i++ {
+			item := child.value.Index(i)
+			for (item.Kind() == reflect.Interface || item.Kind() == reflect.Pointer) && !item.IsNil() {
+				item = item.Elem()
+			}
+			if item.Kind() != reflect.Struct {
+				fmt.Fprintf(out, "%s    nil\n", indent)
+				continue
+			}
+			tree(out, item, "", depth+2)
+		}
+	}
+}
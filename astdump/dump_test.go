@@ -0,0 +1,289 @@
+package astdump
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"tkom/ast"
+	"tkom/interpreter"
+	"tkom/lexer"
+	"tkom/parser"
+)
+
+func parse(t *testing.T, source string) *ast.Program {
+	t.Helper()
+	scanner, _ := lexer.NewScanner(strings.NewReader(source))
+	lex := lexer.NewLexer(scanner, 500, 1000, math.MaxInt)
+	errorHandler := func(err error) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lex.ErrorHandler = errorHandler
+	return parser.NewParser(lex, errorHandler).ParseProgram()
+}
+
+func writeJSON(t *testing.T, program *ast.Program) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := WriteJSON(&out, program); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+// every example is loaded back to the program it was written from
+func TestRoundTrip(t *testing.T) {
+	files, err := filepath.Glob("../example_codes/*.fl")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no example codes found: %v", err)
+	}
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		program := parse(t, string(source))
+		written := writeJSON(t, program)
+
+		loaded, err := Load(strings.NewReader(written))
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if !reflect.DeepEqual(loaded, program) {
+			t.Errorf("%s: the loaded program differs from the written one", file)
+		}
+		if rewritten := writeJSON(t, loaded); rewritten != written {
+			t.Errorf("%s: expected the same JSON after loading, got:\n%s", file, rewritten)
+		}
+	}
+}
+
+const source = `# squares
+square(x int) int {
+    return x * x
+}
+
+main() {
+    int a := square(2) as int
+    if a > 3 {
+        print("big")
+    }
+    switch {
+        a == 4 => print(-1.5),
+        default => {
+            return
+        }
+    }
+}
+`
+
+func TestWriteTree(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteTree(&out, parse(t, source)); err != nil {
+		t.Fatal(err)
+	}
+	expected := `Program [1:1-18:1]
+  functions:
+    FunctionDefinition 2:1 [2:1-4:2] name="square" type=int
+      block: Block [2:19-4:2]
+        statements:
+          ReturnStatement [3:5-3:17]
+            value: MultiplyExpression 3:14 [3:12-3:17]
+              leftExpression: Identifier 3:12 [3:12-3:13] name="x"
+              rightExpression: Identifier 3:16 [3:16-3:17] name="x"
+      parameters:
+        Variable 2:8 [2:8-2:9] name="x" type=int
+    FunctionDefinition 6:1 [6:1-17:2] name="main" type=void
+      block: Block [6:8-17:2]
+        statements:
+          Variable 7:9 [7:5-7:30] name="a" type=int
+            value: CastExpression 7:24 [7:14-7:30] typeAnnotation=int
+              leftExpression: FunctionCall 7:14 [7:14-7:23] name="square"
+                arguments:
+                  IntExpression 7:21 [7:21-7:22] value=2
+          IfStatement [8:5-10:6]
+            condition: GreaterThanExpression 8:10 [8:8-8:13]
+              leftExpression: Identifier 8:8 [8:8-8:9] name="a"
+              rightExpression: IntExpression 8:12 [8:12-8:13] value=3
+            instructionsBlock: Block [8:14-10:6]
+              statements:
+                FunctionCall 9:9 [9:9-9:21] name="print"
+                  arguments:
+                    StringExpression 9:15 [9:15-9:20] value="big"
+          SwitchStatement 11:5 [11:5-16:6]
+            cases:
+              SwitchCase 12:16 [12:9-12:30]
+                condition: EqualsExpression 12:11 [12:9-12:15]
+                  leftExpression: Identifier 12:9 [12:9-12:10] name="a"
+                  rightExpression: IntExpression 12:14 [12:14-12:15] value=4
+                outputExpression: FunctionCall 12:19 [12:19-12:30] name="print"
+                  arguments:
+                    NegateExpression 12:25 [12:25-12:29]
+                      expression: FloatExpression 12:26 [12:26-12:29] value=1.5
+              DefaultSwitchCase 13:17 [13:9-15:10]
+                outputExpression: Block [13:20-15:10]
+                  statements:
+                    ReturnStatement [14:13-14:19]
+  comments:
+    Comment 1:1 [1:1-1:10] text="# squares"
+`
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	written := writeJSON(t, parse(t, "main() {\n    return\n}\n"))
+	expected := `{
+  "kind": "Program",
+  "span": {
+    "file": "",
+    "start": {
+      "line": 1,
+      "column": 1
+    },
+    "end": {
+      "line": 4,
+      "column": 1
+    },
+    "startOffset": 0,
+    "endOffset": 22
+  },
+  "functions": [
+    {
+      "kind": "FunctionDefinition",
+      "span": {
+        "file": "",
+        "start": {
+          "line": 1,
+          "column": 1
+        },
+        "end": {
+          "line": 3,
+          "column": 2
+        },
+        "startOffset": 0,
+        "endOffset": 21
+      },
+      "name": "main",
+      "block": {
+        "kind": "Block",
+        "span": {
+          "file": "",
+          "start": {
+            "line": 1,
+            "column": 8
+          },
+          "end": {
+            "line": 3,
+            "column": 2
+          },
+          "startOffset": 7,
+          "endOffset": 21
+        },
+        "statements": [
+          {
+            "kind": "ReturnStatement",
+            "span": {
+              "file": "",
+              "start": {
+                "line": 2,
+                "column": 5
+              },
+              "end": {
+                "line": 2,
+                "column": 11
+              },
+              "startOffset": 13,
+              "endOffset": 19
+            },
+            "value": null
+          }
+        ]
+      },
+      "parameters": null,
+      "type": "void",
+      "position": {
+        "line": 1,
+        "column": 1
+      }
+    }
+  ],
+  "comments": null
+}
+`
+	if written != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, written)
+	}
+}
+
+// a program made by another tool runs like a parsed one
+func TestLoadAndRun(t *testing.T) {
+	program, err := Load(strings.NewReader(`{
+  "kind": "Program",
+  "functions": [{
+    "kind": "FunctionDefinition",
+    "name": "main",
+    "type": "void",
+    "block": {"kind": "Block", "statements": [
+      {"kind": "Variable", "name": "a", "type": "int", "value": {"kind": "IntExpression", "value": 40}},
+      {"kind": "FunctionCall", "name": "print", "arguments": [
+        {"kind": "SumExpression",
+         "leftExpression": {"kind": "Identifier", "name": "a"},
+         "rightExpression": {"kind": "IntExpression", "value": 2}}
+      ]}
+    ]}
+  }]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	interpreter.Output = &output
+	defer func() { interpreter.Output = nil }()
+	interpreter.ResolveProgram(program)
+	visitor := interpreter.NewCodeVisitor(200)
+	if err := interpreter.RunProgram(visitor, program, &ast.FunctionCall{Name: "main"}); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "42\n" {
+		t.Errorf("expected 42, got %q", output.String())
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	function := func(statement string) string {
+		return `{"kind": "Program", "functions": [{"kind": "FunctionDefinition", "name": "main", "type": "void",
+			"block": {"kind": "Block", "statements": [` + statement + `]}}]}`
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`null`, "program: missing node"},
+		{`[]`, "program: expected a node, got []"},
+		{`{"kind": "Block"}`, "program: Block cannot be used as Program"},
+		{function(`{"kind": "Loop"}`), `program.functions[0].block.statements[0]: unknown node kind "Loop"`},
+		{function(`{"kind": "SumExpression", "leftExpression": {"kind": "IntExpression", "value": 1}}`), "program.functions[0].block.statements[0].rightExpression: missing node"},
+		{function(`{"kind": "IntExpression", "value": 1.5}`), "program.functions[0].block.statements[0].value: expected an integer, got 1.5"},
+		{function(`{"kind": "Variable", "name": "a", "type": "char"}`), "program.functions[0].block.statements[0].type: unknown type char"},
+		{function(`{"kind": "ReturnStatement", "value": {"kind": "Block", "statements": []}, "line": 1}`), `program.functions[0].block.statements[0]: unknown field "line" of ReturnStatement`},
+		{function(`{"kind": "Assignment", "identifier": {"kind": "IntExpression"}, "value": {"kind": "IntExpression"}}`), "program.functions[0].block.statements[0].identifier: IntExpression cannot be used as Identifier"},
+		{`{"kind": "Program", "functions": [{"kind": "FunctionDefinition", "name": "f", "block": {"kind": "Block"}}, {"kind": "FunctionDefinition", "name": "f", "block": {"kind": "Block"}}]}`, "program.functions[1]: function f defined twice"},
+	}
+	for _, test := range tests {
+		_, err := Load(strings.NewReader(test.input))
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("%s: expected error %q, got %v", test.input, test.expected, err)
+		}
+	}
+
+	if _, err := Load(io.MultiReader()); err == nil {
+		t.Errorf("expected an error of empty input")
+	}
+}
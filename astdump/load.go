@@ -0,0 +1,217 @@
+package astdump
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"tkom/ast"
+	"tkom/shared"
+)
+
+// nodes that can be loaded, by their kind
+var nodeTypes = map[string]reflect.Type{}
+
+func init() {
+	for _, node := range []ast.Node{
+		&ast.Program{}, &ast.FunctionDefinition{}, &ast.Block{},
+		&ast.Variable{}, &ast.Assignment{}, &ast.IfStatement{}, &ast.WhileStatement{}, &ast.ReturnStatement{},
+		&ast.SwitchStatement{}, &ast.SwitchCase{}, &ast.DefaultSwitchCase{},
+		&ast.FunctionCall{}, &ast.Identifier{},
+		&ast.OrExpression{}, &ast.AndExpression{},
+		&ast.EqualsExpression{}, &ast.NotEqualsExpression{},
+		&ast.GreaterThanExpression{}, &ast.LessThanExpression{},
+		&ast.GreaterOrEqualExpression{}, &ast.LessOrEqualExpression{},
+		&ast.SumExpression{}, &ast.SubstractExpression{}, &ast.MultiplyExpression{}, &ast.DivideExpression{},
+		&ast.CastExpression{}, &ast.NegateExpression{},
+		&ast.IntExpression{}, &ast.FloatExpression{}, &ast.BoolExpression{}, &ast.StringExpression{},
+	} {
+		t := reflect.TypeOf(node)
+		nodeTypes[t.Elem().Name()] = t
+	}
+	nodeTypes["Comment"] = reflect.TypeOf(&ast.Comment{})
+}
+
+// fields of nodes that can be null, every other node has to be given
+var nullableFields = map[string]bool{
+	"ReturnStatement.value":             true,
+	"IfStatement.elseInstructionsBlock": true,
+	// parameters of functions have no value
+	"Variable.value": true,
+}
+
+var annotations = map[string]shared.TypeAnnotation{}
+
+func init() {
+	for _, t := range []shared.TypeAnnotation{shared.INT, shared.FLOAT, shared.BOOL, shared.STRING, shared.VOID} {
+		annotations[t.String()] = t
+	}
+}
+
+// reads a program written by WriteJSON, e.g. by another tool, the program is not resolved
+//
+// values that are not given are zero, nodes have to be given unless they are optional
+// in the language, like the value of a return statement
+func Load(r io.Reader) (program *ast.Program, err error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	var data any
+	if err := decoder.Decode(&data); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*LoadError)
+			if !ok {
+				panic(r)
+			}
+			program, err = nil, e
+		}
+	}()
+	if data == nil {
+		fail("program", "missing node")
+	}
+	return load("program", data, reflect.TypeOf(program)).Interface().(*ast.Program), nil
+}
+
+// part of the JSON that does not make a program, Path leads to it, e.g. functions[0].block
+type LoadError struct {
+	Path    string
+	Message string
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+func fail(path string, format string, args ...any) {
+	panic(&LoadError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func load(path string, data any, t reflect.Type) reflect.Value {
+	if t == annotationType {
+		name, _ := data.(string)
+		annotation, ok := annotations[name]
+		if !ok {
+			fail(path, "unknown type %v", data)
+		}
+		return reflect.ValueOf(annotation)
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if data == nil {
+			return reflect.Zero(t)
+		}
+		object, ok := data.(map[string]any)
+		if !ok {
+			fail(path, "expected a node, got %v", data)
+		}
+		kind, _ := object[KIND_FIELD].(string)
+		nodeType, ok := nodeTypes[kind]
+		if !ok {
+			fail(path, "unknown node kind %q", kind)
+		}
+		if !nodeType.AssignableTo(t) {
+			expected := t.Name()
+			if t.Kind() == reflect.Pointer {
+				expected = t.Elem().Name()
+			}
+			fail(path, "%s cannot be used as %s", kind, expected)
+		}
+		node := reflect.New(nodeType.Elem())
+		loadFields(path, object, node.Elem())
+		return node
+	case reflect.Struct:
+		object, ok := data.(map[string]any)
+		if !ok {
+			fail(path, "expected an object, got %v", data)
+		}
+		value := reflect.New(t).Elem()
+		loadFields(path, object, value)
+		return value
+	case reflect.Slice:
+		if data == nil {
+			return reflect.Zero(t)
+		}
+		items, ok := data.([]any)
+		if !ok {
+			fail(path, "expected a list, got %v", data)
+		}
+		list := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			if item == nil {
+				fail(itemPath, "missing node")
+			}
+			list.Index(i).Set(load(itemPath, item, t.Elem()))
+		}
+		return list
+	case reflect.Int:
+		number, ok := data.(json.Number)
+		n, err := number.Int64()
+		if !ok || err != nil {
+			fail(path, "expected an integer, got %v", data)
+		}
+		return reflect.ValueOf(int(n))
+	case reflect.Float64:
+		number, ok := data.(json.Number)
+		f, err := number.Float64()
+		if !ok || err != nil {
+			fail(path, "expected a number, got %v", data)
+		}
+		return reflect.ValueOf(f)
+	case reflect.Bool, reflect.String:
+		value := reflect.ValueOf(data)
+		if data == nil || value.Kind() != t.Kind() {
+			fail(path, "expected a %s, got %v", t.Kind(), data)
+		}
+		return value
+	}
+	fail(path, "cannot load %s", t)
+	return reflect.Value{}
+}
+
+func loadFields(path string, object map[string]any, value reflect.Value) {
+	known := map[string]bool{}
+	if isNode(value.Type()) {
+		known[KIND_FIELD] = true
+	}
+	for _, f := range fields(value) {
+		known[f.name] = true
+		fieldPath := path + "." + f.name
+		data := object[f.name]
+		if value.Type() == programType && f.name == FUNCTIONS_FIELD {
+			value.FieldByName("Functions").Set(reflect.ValueOf(loadFunctions(fieldPath, data)))
+			continue
+		}
+
+		kind := f.value.Kind()
+		if data == nil && (kind == reflect.Pointer || kind == reflect.Interface) && !nullableFields[value.Type().Name()+"."+f.name] {
+			fail(fieldPath, "missing node")
+		}
+		if _, ok := object[f.name]; !ok && kind != reflect.Pointer && kind != reflect.Interface {
+			continue
+		}
+		f.value.Set(load(fieldPath, data, f.value.Type()))
+	}
+	for name := range object {
+		if !known[name] {
+			fail(path, "unknown field %q of %s", name, value.Type().Name())
+		}
+	}
+}
+
+// the functions are a list, like the program defines them
+func loadFunctions(path string, data any) map[string]*ast.FunctionDefinition {
+	list := load(path, data, reflect.TypeOf([]*ast.FunctionDefinition{})).Interface().([]*ast.FunctionDefinition)
+	functions := map[string]*ast.FunctionDefinition{}
+	for i, fd := range list {
+		if _, ok := functions[fd.Name]; ok {
+			fail(fmt.Sprintf("%s[%d]", path, i), "function %s defined twice", fd.Name)
+		}
+		functions[fd.Name] = fd
+	}
+	return functions
+}
@@ -0,0 +1,200 @@
+package dap
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"tkom/ast"
+	"tkom/interpreter"
+	"tkom/shared"
+)
+
+// how the stopped program goes on
+type step int
+
+const (
+	CONTINUE step = iota
+	STEP_IN
+	STEP_OVER
+	STEP_OUT
+	// stops before the first statement
+	ENTRY
+	TERMINATE
+)
+
+var errNotStopped = errors.New("the program is not stopped")
+
+// panicked with to unwind the program when the client disconnects
+type terminated struct{}
+
+type breakpoint struct {
+	id int
+	// nil when the breakpoint has no condition
+	condition ast.Expression
+}
+
+// state of the program stopped before the statement at the position,
+// it does not change until the program is resumed
+type stop struct {
+	visitor  *interpreter.CodeVisitor
+	position shared.Position
+	frames   []interpreter.Frame
+	scopes   []*interpreter.Scope
+}
+
+// stops the program running in its own goroutine, Pause blocks it
+// until the client resumes it from the goroutine serving the requests
+type debugger struct {
+	server  *Server
+	resumed chan step
+	// guards the fields below, shared by both goroutines
+	mutex          sync.Mutex
+	breakpoints    map[int]*breakpoint
+	step           step
+	depth          int
+	pauseRequested bool
+	terminate      bool
+	stopped        *stop
+	references     []*interpreter.Scope
+}
+
+func newDebugger(server *Server) *debugger {
+	return &debugger{
+		server:      server,
+		resumed:     make(chan step),
+		breakpoints: map[int]*breakpoint{},
+	}
+}
+
+func (d *debugger) Pause(v *interpreter.CodeVisitor, position shared.Position) {
+	d.mutex.Lock()
+	if d.terminate {
+		d.mutex.Unlock()
+		panic(terminated{})
+	}
+	reason := d.reason(v.CallStack.Depth())
+	b := d.breakpoints[position.Line]
+	d.mutex.Unlock()
+
+	var hit []int
+	if b != nil && d.hit(v, b, position) {
+		reason, hit = STOP_BREAKPOINT, []int{b.id}
+	}
+	if reason == "" {
+		return
+	}
+
+	d.mutex.Lock()
+	if d.terminate {
+		d.mutex.Unlock()
+		panic(terminated{})
+	}
+	d.stopped = &stop{visitor: v, position: position, frames: v.CallStack.Frames(), scopes: v.FrameScopes()}
+	d.pauseRequested = false
+	d.mutex.Unlock()
+
+	d.server.event("stopped", StoppedEvent{Reason: reason, ThreadID: THREAD_ID, AllThreadsStopped: true, HitBreakpointIDs: hit})
+	if <-d.resumed == TERMINATE {
+		panic(terminated{})
+	}
+}
+
+// stepping over stops at the next statement that is not deeper in the
+// call stack, stepping out at the next one in a caller
+func (d *debugger) reason(depth int) string {
+	switch {
+	case d.pauseRequested:
+		return STOP_PAUSE
+	case d.step == ENTRY:
+		return STOP_ENTRY
+	case d.step == STEP_IN,
+		d.step == STEP_OVER && depth <= d.depth,
+		d.step == STEP_OUT && depth < d.depth:
+		return STOP_STEP
+	}
+	return ""
+}
+
+// conditions that cannot be evaluated stop the program like true ones
+func (d *debugger) hit(v *interpreter.CodeVisitor, b *breakpoint, position shared.Position) bool {
+	if b.condition == nil {
+		return true
+	}
+	value, err := v.Evaluate(b.condition, v.CurrentScope)
+	if err == nil {
+		if condition, ok := value.(bool); ok {
+			return condition
+		}
+		err = fmt.Errorf("expected bool, got %s", v.DetermineType(value))
+	}
+	d.server.event("output", OutputEvent{
+		Category: OUTPUT_CONSOLE,
+		Output:   fmt.Sprintf("condition of the breakpoint at line %d: %v\n", position.Line, err),
+	})
+	return true
+}
+
+func (d *debugger) setBreakpoints(breakpoints map[int]*breakpoint) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.breakpoints = breakpoints
+}
+
+// returns the state of the stopped program or nil while it runs
+func (d *debugger) current() *stop {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.stopped
+}
+
+func (d *debugger) resume(s step) error {
+	d.mutex.Lock()
+	stopped := d.stopped
+	if stopped == nil {
+		d.mutex.Unlock()
+		return errNotStopped
+	}
+	d.step, d.depth = s, stopped.visitor.CallStack.Depth()
+	d.stopped, d.references = nil, nil
+	d.mutex.Unlock()
+
+	d.resumed <- s
+	return nil
+}
+
+// the program stops before its next statement
+func (d *debugger) requestPause() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.pauseRequested = true
+}
+
+// the program unwinds before its next statement, or at once when it is stopped
+func (d *debugger) terminateProgram() {
+	d.mutex.Lock()
+	d.terminate = true
+	stopped := d.stopped
+	d.stopped = nil
+	d.mutex.Unlock()
+
+	if stopped != nil {
+		d.resumed <- TERMINATE
+	}
+}
+
+// references of the scopes shown to the client are valid until the program is resumed
+func (d *debugger) reference(scope *interpreter.Scope) int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.references = append(d.references, scope)
+	return len(d.references)
+}
+
+func (d *debugger) scope(reference int) *interpreter.Scope {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if reference < 1 || reference > len(d.references) {
+		return nil
+	}
+	return d.references[reference-1]
+}
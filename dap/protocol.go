@@ -0,0 +1,222 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+// types of messages
+const (
+	REQUEST  = "request"
+	RESPONSE = "response"
+	EVENT    = "event"
+)
+
+// a request has a command with its arguments, a response answers the request
+// of its request_seq and an event only has a name and a body
+type Message struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command,omitempty"`
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	RequestSeq int             `json:"request_seq,omitempty"`
+	Success    *bool           `json:"success,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	Event      string          `json:"event,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+}
+
+// the body of the message is not valid JSON, the stream can still be read
+type InvalidMessageError struct {
+	Err error
+}
+
+func (e *InvalidMessageError) Error() string {
+	return "invalid message: " + e.Err.Error()
+}
+
+// reads a message framed by the Content-Length header
+func ReadMessage(r *bufio.Reader) (*Message, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length: %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	message := &Message{}
+	if err := json.Unmarshal(body, message); err != nil {
+		return nil, &InvalidMessageError{Err: err}
+	}
+	return message, nil
+}
+
+func WriteMessage(w io.Writer, message *Message) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+// the program runs in a single thread
+const THREAD_ID = 1
+
+// reasons of the stopped event
+const (
+	STOP_ENTRY      = "entry"
+	STOP_STEP       = "step"
+	STOP_BREAKPOINT = "breakpoint"
+	STOP_PAUSE      = "pause"
+)
+
+type Capabilities struct {
+	SupportsConfigurationDoneRequest bool `json:"supportsConfigurationDoneRequest"`
+	SupportsConditionalBreakpoints   bool `json:"supportsConditionalBreakpoints"`
+	SupportsEvaluateForHovers        bool `json:"supportsEvaluateForHovers"`
+}
+
+// arguments of the main function are converted like on the command line
+type LaunchArguments struct {
+	Program     string   `json:"program"`
+	Args        []string `json:"args"`
+	StopOnEntry bool     `json:"stopOnEntry"`
+}
+
+type Source struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+// lines and columns start at 1
+type SourceBreakpoint struct {
+	Line      int    `json:"line"`
+	Condition string `json:"condition,omitempty"`
+}
+
+type SetBreakpointsArguments struct {
+	Source      Source             `json:"source"`
+	Breakpoints []SourceBreakpoint `json:"breakpoints"`
+}
+
+type Breakpoint struct {
+	ID       int     `json:"id"`
+	Verified bool    `json:"verified"`
+	Message  string  `json:"message,omitempty"`
+	Source   *Source `json:"source,omitempty"`
+	Line     int     `json:"line,omitempty"`
+}
+
+type SetBreakpointsResponse struct {
+	Breakpoints []Breakpoint `json:"breakpoints"`
+}
+
+type Thread struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type ThreadsResponse struct {
+	Threads []Thread `json:"threads"`
+}
+
+type StackTraceArguments struct {
+	ThreadID   int `json:"threadId"`
+	StartFrame int `json:"startFrame"`
+	Levels     int `json:"levels"`
+}
+
+type StackFrame struct {
+	ID     int     `json:"id"`
+	Name   string  `json:"name"`
+	Source *Source `json:"source,omitempty"`
+	Line   int     `json:"line"`
+	Column int     `json:"column"`
+}
+
+type StackTraceResponse struct {
+	StackFrames []StackFrame `json:"stackFrames"`
+	TotalFrames int          `json:"totalFrames"`
+}
+
+type ScopesArguments struct {
+	FrameID int `json:"frameId"`
+}
+
+type Scope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type ScopesResponse struct {
+	Scopes []Scope `json:"scopes"`
+}
+
+type VariablesArguments struct {
+	VariablesReference int `json:"variablesReference"`
+}
+
+type Variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+type VariablesResponse struct {
+	Variables []Variable `json:"variables"`
+}
+
+type ContinueResponse struct {
+	AllThreadsContinued bool `json:"allThreadsContinued"`
+}
+
+// the expression is evaluated in the innermost frame when there is no frame id
+type EvaluateArguments struct {
+	Expression string `json:"expression"`
+	FrameID    *int   `json:"frameId,omitempty"`
+	Context    string `json:"context,omitempty"`
+}
+
+type EvaluateResponse struct {
+	Result             string `json:"result"`
+	Type               string `json:"type"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+type StoppedEvent struct {
+	Reason            string `json:"reason"`
+	ThreadID          int    `json:"threadId"`
+	AllThreadsStopped bool   `json:"allThreadsStopped"`
+	HitBreakpointIDs  []int  `json:"hitBreakpointIds,omitempty"`
+}
+
+// categories of the output event
+const (
+	OUTPUT_CONSOLE = "console"
+	OUTPUT_STDOUT  = "stdout"
+	OUTPUT_STDERR  = "stderr"
+)
+
+type OutputEvent struct {
+	Category string `json:"category"`
+	Output   string `json:"output"`
+}
+
+type ExitedEvent struct {
+	ExitCode int `json:"exitCode"`
+}
@@ -0,0 +1,532 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"tkom/ast"
+	"tkom/diagnostics"
+	"tkom/interpreter"
+	"tkom/lexer"
+	"tkom/parser"
+)
+
+const (
+	IDENTIFIER_LIMIT    = 500
+	STRING_LIMIT        = 1000
+	INT_LIMIT           = math.MaxInt
+	MAX_RECURSION_DEPTH = 200
+)
+
+var errNotLaunched = errors.New("no program is launched")
+
+// debug adapter of one client talking over a pair of streams, requests are
+// handled one by one while the program runs in its own goroutine
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+	// guards the stream written by both goroutines
+	writing sync.Mutex
+	seq     int
+
+	launch  LaunchArguments
+	source  *diagnostics.Source
+	program *ast.Program
+	// sorted lines of the statements and switch cases the program can stop at
+	lines          []int
+	nextBreakpoint int
+	debugger       *debugger
+	// closed when the program ends, nil before it starts
+	done chan struct{}
+	// runs after the response to the current request is sent
+	after func()
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	s := &Server{
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+	s.debugger = newDebugger(s)
+	return s
+}
+
+type handler func(s *Server, arguments json.RawMessage) (any, error)
+
+var requests = map[string]handler{
+	"initialize":        (*Server).initialize,
+	"launch":            (*Server).launchRequest,
+	"setBreakpoints":    (*Server).setBreakpoints,
+	"configurationDone": (*Server).configurationDone,
+	"threads":           (*Server).threads,
+	"stackTrace":        (*Server).stackTrace,
+	"scopes":            (*Server).scopes,
+	"variables":         (*Server).variables,
+	"continue":          (*Server).continueRequest,
+	"next":              (*Server).next,
+	"stepIn":            (*Server).stepIn,
+	"stepOut":           (*Server).stepOut,
+	"pause":             (*Server).pause,
+	"evaluate":          (*Server).evaluate,
+	"disconnect":        (*Server).disconnect,
+}
+
+// serves the client until it disconnects or closes the stream,
+// the program still running is terminated
+func (s *Server) Serve() error {
+	defer s.terminate()
+	for {
+		message, err := ReadMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		var invalid *InvalidMessageError
+		if errors.As(err, &invalid) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if message.Type != REQUEST {
+			continue
+		}
+
+		handle, ok := requests[message.Command]
+		if !ok {
+			s.respond(message, nil, fmt.Errorf("unknown command: %s", message.Command))
+			continue
+		}
+		body, err := handle(s, message.Arguments)
+		s.respond(message, body, err)
+		if s.after != nil {
+			s.after()
+			s.after = nil
+		}
+		if message.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+func (s *Server) send(message *Message, body any) {
+	s.writing.Lock()
+	defer s.writing.Unlock()
+	s.seq++
+	message.Seq = s.seq
+	if body != nil {
+		message.Body, _ = json.Marshal(body)
+	}
+	WriteMessage(s.out, message)
+}
+
+func (s *Server) respond(request *Message, body any, err error) {
+	success := err == nil
+	response := &Message{Type: RESPONSE, RequestSeq: request.Seq, Command: request.Command, Success: &success}
+	if err != nil {
+		response.Message = err.Error()
+	}
+	s.send(response, body)
+}
+
+func (s *Server) event(name string, body any) {
+	s.send(&Message{Type: EVENT, Event: name}, body)
+}
+
+// sends what the program prints as output events
+type output struct {
+	server   *Server
+	category string
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.server.event("output", OutputEvent{Category: o.category, Output: string(p)})
+	return len(p), nil
+}
+
+func (s *Server) report(r any, category string) string {
+	var b strings.Builder
+	diagnostic := diagnostics.FromPanic(r)
+	diagnostics.NewRenderer(&b, false).Emit(diagnostic, s.source)
+	s.event("output", OutputEvent{Category: category, Output: b.String()})
+	return diagnostic.Message
+}
+
+func (s *Server) initialize(arguments json.RawMessage) (any, error) {
+	return Capabilities{
+		SupportsConfigurationDoneRequest: true,
+		SupportsConditionalBreakpoints:   true,
+		SupportsEvaluateForHovers:        true,
+	}, nil
+}
+
+// the initialized event is sent once the program is loaded,
+// so the breakpoints the client sends next can be checked against it
+func (s *Server) launchRequest(arguments json.RawMessage) (any, error) {
+	if s.program != nil {
+		return nil, errors.New("a program is already launched")
+	}
+	if err := json.Unmarshal(arguments, &s.launch); err != nil {
+		return nil, err
+	}
+	text, err := os.ReadFile(s.launch.Program)
+	if err != nil {
+		return nil, err
+	}
+	s.source = diagnostics.NewSource(s.launch.Program, string(text))
+
+	program, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	s.program = program
+	s.lines = interpreter.StatementLines(program)
+	s.after = func() {
+		s.event("initialized", nil)
+	}
+	return nil, nil
+}
+
+// parses and resolves the program, syntax and semantic errors are printed
+// and returned, warnings are only printed
+func (s *Server) load() (program *ast.Program, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			program, err = nil, errors.New(s.report(r, OUTPUT_STDERR))
+		}
+	}()
+	scanner, _ := lexer.NewScanner(strings.NewReader(s.source.Text))
+	lex := lexer.NewLexer(scanner, IDENTIFIER_LIMIT, STRING_LIMIT, INT_LIMIT)
+	lex.File = s.source.Path
+	errorHandler := func(err error) {
+		panic(err)
+	}
+	lex.ErrorHandler = errorHandler
+	program = parser.NewParser(lex, errorHandler).ParseProgram()
+
+	for _, warning := range interpreter.ResolveProgram(program) {
+		s.report(warning, OUTPUT_CONSOLE)
+	}
+	return program, nil
+}
+
+func (s *Server) sourceReference() *Source {
+	return &Source{Name: filepath.Base(s.launch.Program), Path: s.launch.Program}
+}
+
+func samePath(a, b string) bool {
+	a, errA := filepath.Abs(a)
+	b, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && a == b
+}
+
+// breakpoints of lines without a statement are moved to the next statement,
+// all breakpoints of the program are replaced
+func (s *Server) setBreakpoints(arguments json.RawMessage) (any, error) {
+	var a SetBreakpointsArguments
+	if err := json.Unmarshal(arguments, &a); err != nil {
+		return nil, err
+	}
+	if s.program == nil {
+		return nil, errNotLaunched
+	}
+
+	result := SetBreakpointsResponse{Breakpoints: []Breakpoint{}}
+	breakpoints := map[int]*breakpoint{}
+	for _, requested := range a.Breakpoints {
+		s.nextBreakpoint++
+		b := Breakpoint{ID: s.nextBreakpoint, Line: requested.Line}
+		i := sort.SearchInts(s.lines, requested.Line)
+		switch {
+		case !samePath(a.Source.Path, s.launch.Program):
+			b.Message = "the source is not the launched program"
+		case i == len(s.lines):
+			b.Message = "no statement at or after the line"
+		default:
+			condition, err := parseCondition(requested.Condition)
+			if err != nil {
+				b.Message = "invalid condition: " + err.Error()
+				break
+			}
+			b.Verified, b.Line, b.Source = true, s.lines[i], s.sourceReference()
+			breakpoints[b.Line] = &breakpoint{id: b.ID, condition: condition}
+		}
+		result.Breakpoints = append(result.Breakpoints, b)
+	}
+	s.debugger.setBreakpoints(breakpoints)
+	return result, nil
+}
+
+// a condition is a Flux expression, nil when there is none
+func parseCondition(text string) (ast.Expression, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	return parseExpression(text)
+}
+
+func parseExpression(text string) (expression ast.Expression, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			expression, err = nil, errors.New(diagnostics.FromPanic(r).Message)
+		}
+	}()
+	scanner, _ := lexer.NewScanner(strings.NewReader(text))
+	lex := lexer.NewLexer(scanner, IDENTIFIER_LIMIT, STRING_LIMIT, INT_LIMIT)
+	errorHandler := func(err error) {
+		panic(err)
+	}
+	lex.ErrorHandler = errorHandler
+	expression = parser.NewParser(lex, errorHandler).ParseExpressionInput()
+	if expression == nil {
+		return nil, fmt.Errorf("%q is not an expression", text)
+	}
+	return expression, nil
+}
+
+func (s *Server) configurationDone(arguments json.RawMessage) (any, error) {
+	if s.program == nil {
+		return nil, errNotLaunched
+	}
+	if s.done != nil {
+		return nil, errors.New("the program is already running")
+	}
+	s.after = s.start
+	return nil, nil
+}
+
+// runs main with the arguments of the launch converted like on the command line
+func (s *Server) start() {
+	args := make([]ast.Expression, len(s.launch.Args))
+	for i, arg := range s.launch.Args {
+		if intValue, err := strconv.Atoi(arg); err == nil {
+			args[i] = &ast.IntExpression{Value: intValue}
+		} else {
+			args[i] = &ast.StringExpression{Value: arg}
+		}
+	}
+	call := &ast.FunctionCall{Name: "main", Arguments: args}
+
+	visitor := interpreter.NewCodeVisitor(MAX_RECURSION_DEPTH)
+	visitor.Debugger = s.debugger
+	if s.launch.StopOnEntry {
+		s.debugger.step = ENTRY
+	}
+
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		interpreter.Output = &output{server: s, category: OUTPUT_STDOUT}
+		defer func() { interpreter.Output = nil }()
+
+		r := run(visitor, s.program, call)
+		if _, ok := r.(terminated); ok {
+			return
+		}
+		exitCode := 0
+		if r != nil {
+			s.report(r, OUTPUT_STDERR)
+			exitCode = 1
+		}
+		s.event("exited", ExitedEvent{ExitCode: exitCode})
+		s.event("terminated", nil)
+	}()
+}
+
+// returns what the program panicked with
+func run(visitor *interpreter.CodeVisitor, program *ast.Program, call *ast.FunctionCall) (r any) {
+	defer func() {
+		r = recover()
+	}()
+	visitor.Run(program, call)
+	return nil
+}
+
+func (s *Server) terminate() {
+	if s.done == nil {
+		return
+	}
+	s.debugger.terminateProgram()
+	<-s.done
+}
+
+func (s *Server) threads(arguments json.RawMessage) (any, error) {
+	return ThreadsResponse{Threads: []Thread{{ID: THREAD_ID, Name: "main"}}}, nil
+}
+
+// frames of builtins and of calls still evaluating their arguments are left out,
+// frame ids are the positions on the call stack counted from 1
+func (s *Server) stackTrace(arguments json.RawMessage) (any, error) {
+	var a StackTraceArguments
+	if err := json.Unmarshal(arguments, &a); err != nil {
+		return nil, err
+	}
+	stopped := s.debugger.current()
+	if stopped == nil {
+		return nil, errNotStopped
+	}
+
+	frames := []StackFrame{}
+	for i := len(stopped.frames) - 1; i >= 0; i-- {
+		if stopped.scopes[i] == nil {
+			continue
+		}
+		// callers are at the call of the next frame
+		position := stopped.position
+		if i < len(stopped.frames)-1 {
+			position = stopped.frames[i+1].CallSite
+		}
+		frames = append(frames, StackFrame{
+			ID:     i + 1,
+			Name:   stopped.frames[i].Call(),
+			Source: s.sourceReference(),
+			Line:   position.Line,
+			Column: position.Column,
+		})
+	}
+
+	total := len(frames)
+	frames = frames[min(a.StartFrame, total):]
+	if a.Levels > 0 && a.Levels < len(frames) {
+		frames = frames[:a.Levels]
+	}
+	return StackTraceResponse{StackFrames: frames, TotalFrames: total}, nil
+}
+
+func (s *Server) frameScope(stopped *stop, id int) (*interpreter.Scope, error) {
+	if id < 1 || id > len(stopped.scopes) || stopped.scopes[id-1] == nil {
+		return nil, fmt.Errorf("invalid frame: %d", id)
+	}
+	return stopped.scopes[id-1], nil
+}
+
+// the scope chain of the frame, the innermost block first and the function last
+func (s *Server) scopes(arguments json.RawMessage) (any, error) {
+	var a ScopesArguments
+	if err := json.Unmarshal(arguments, &a); err != nil {
+		return nil, err
+	}
+	stopped := s.debugger.current()
+	if stopped == nil {
+		return nil, errNotStopped
+	}
+	scope, err := s.frameScope(stopped, a.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []Scope{}
+	for ; scope != nil; scope = scope.Parent {
+		name := "Block"
+		if scope.Parent == nil {
+			name = "Function"
+		}
+		scopes = append(scopes, Scope{Name: name, VariablesReference: s.debugger.reference(scope)})
+	}
+	return ScopesResponse{Scopes: scopes}, nil
+}
+
+func (s *Server) variables(arguments json.RawMessage) (any, error) {
+	var a VariablesArguments
+	if err := json.Unmarshal(arguments, &a); err != nil {
+		return nil, err
+	}
+	stopped := s.debugger.current()
+	if stopped == nil {
+		return nil, errNotStopped
+	}
+	scope := s.debugger.scope(a.VariablesReference)
+	if scope == nil {
+		return nil, fmt.Errorf("invalid variables reference: %d", a.VariablesReference)
+	}
+
+	variables := []Variable{}
+	names, values := scope.Variables()
+	for i, name := range names {
+		variables = append(variables, Variable{
+			Name:  name,
+			Value: format(values[i]),
+			Type:  stopped.visitor.DetermineType(values[i]).String(),
+		})
+	}
+	return VariablesResponse{Variables: variables}, nil
+}
+
+// strings are quoted like in the arguments of the frames
+func format(value any) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func (s *Server) continueRequest(arguments json.RawMessage) (any, error) {
+	if err := s.debugger.resume(CONTINUE); err != nil {
+		return nil, err
+	}
+	return ContinueResponse{AllThreadsContinued: true}, nil
+}
+
+func (s *Server) next(arguments json.RawMessage) (any, error) {
+	return nil, s.debugger.resume(STEP_OVER)
+}
+
+func (s *Server) stepIn(arguments json.RawMessage) (any, error) {
+	return nil, s.debugger.resume(STEP_IN)
+}
+
+func (s *Server) stepOut(arguments json.RawMessage) (any, error) {
+	return nil, s.debugger.resume(STEP_OUT)
+}
+
+func (s *Server) pause(arguments json.RawMessage) (any, error) {
+	if s.done == nil {
+		return nil, errors.New("the program is not running")
+	}
+	s.debugger.requestPause()
+	return nil, nil
+}
+
+// the expression is evaluated in the scope of the frame,
+// the functions it calls run without stopping at breakpoints
+func (s *Server) evaluate(arguments json.RawMessage) (any, error) {
+	var a EvaluateArguments
+	if err := json.Unmarshal(arguments, &a); err != nil {
+		return nil, err
+	}
+	stopped := s.debugger.current()
+	if stopped == nil {
+		return nil, errNotStopped
+	}
+	id := len(stopped.frames)
+	if a.FrameID != nil {
+		id = *a.FrameID
+	}
+	scope, err := s.frameScope(stopped, id)
+	if err != nil {
+		return nil, err
+	}
+	expression, err := parseExpression(a.Expression)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := stopped.visitor.Evaluate(expression, scope)
+	if err != nil {
+		return nil, errors.New(diagnostics.FromPanic(err).Message)
+	}
+	return EvaluateResponse{Result: format(value), Type: stopped.visitor.DetermineType(value).String()}, nil
+}
+
+func (s *Server) disconnect(arguments json.RawMessage) (any, error) {
+	s.terminate()
+	return nil, nil
+}
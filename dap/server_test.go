@@ -0,0 +1,467 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const source = `sign(x int) int {
+    if x > 0 {
+        int doubled := x * 2
+        return doubled
+    }
+    switch {
+        x == 0 => 0,
+        default => -1
+    }
+}
+
+main() {
+    int i := 0
+    int total := 0
+    while i < 5 {
+        total = total + sign(i)
+        i = i + 1
+    }
+
+    print("total ", total)
+}
+`
+
+// client talking to a server running in the same process
+type client struct {
+	t        *testing.T
+	in       io.WriteCloser
+	messages chan *Message
+	done     chan error
+	seq      int
+	// events received while waiting for a response
+	pending []*Message
+	output  strings.Builder
+	program string
+}
+
+func newClient(t *testing.T, text string) *client {
+	program := filepath.Join(t.TempDir(), "main.fl")
+	if err := os.WriteFile(program, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{t: t, in: clientOut, messages: make(chan *Message, 100), done: make(chan error, 1), program: program}
+
+	go func() {
+		c.done <- NewServer(serverIn, serverOut).Serve()
+		serverOut.Close()
+	}()
+	go func() {
+		reader := bufio.NewReader(clientIn)
+		for {
+			message, err := ReadMessage(reader)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			c.messages <- message
+		}
+	}()
+	t.Cleanup(func() { clientOut.Close() })
+
+	var capabilities Capabilities
+	c.call("initialize", map[string]any{"adapterID": "flux"}, &capabilities)
+	if !capabilities.SupportsConfigurationDoneRequest || !capabilities.SupportsConditionalBreakpoints {
+		t.Fatalf("unexpected capabilities: %+v", capabilities)
+	}
+	return c
+}
+
+func (c *client) next() *Message {
+	c.t.Helper()
+	select {
+	case message, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("server closed the connection")
+		}
+		if message.Event == "output" {
+			var output OutputEvent
+			json.Unmarshal(message.Body, &output)
+			c.output.WriteString(output.Output)
+		}
+		return message
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("no message from the server")
+	}
+	return nil
+}
+
+// sends the request and decodes the body of its response into body,
+// returns the message of a failed response
+func (c *client) request(command string, arguments any, body any) string {
+	c.t.Helper()
+	c.seq++
+	message := &Message{Seq: c.seq, Type: REQUEST, Command: command}
+	message.Arguments, _ = json.Marshal(arguments)
+	if err := WriteMessage(c.in, message); err != nil {
+		c.t.Fatal(err)
+	}
+
+	for {
+		response := c.next()
+		if response.Type == EVENT {
+			c.pending = append(c.pending, response)
+			continue
+		}
+		if response.RequestSeq != c.seq || response.Command != command {
+			c.t.Fatalf("unexpected response: %+v", response)
+		}
+		if response.Success == nil || !*response.Success {
+			return response.Message
+		}
+		if body != nil {
+			if err := json.Unmarshal(response.Body, body); err != nil {
+				c.t.Fatalf("cannot decode the body of %s: %v", command, err)
+			}
+		}
+		return ""
+	}
+}
+
+func (c *client) call(command string, arguments any, body any) {
+	c.t.Helper()
+	if message := c.request(command, arguments, body); message != "" {
+		c.t.Fatalf("%s failed: %s", command, message)
+	}
+}
+
+// waits for the event and decodes its body into body, other events are skipped
+func (c *client) event(name string, body any) {
+	c.t.Helper()
+	for {
+		var message *Message
+		if len(c.pending) > 0 {
+			message, c.pending = c.pending[0], c.pending[1:]
+		} else {
+			message = c.next()
+		}
+		if message.Type != EVENT || message.Event != name {
+			continue
+		}
+		if body != nil {
+			if err := json.Unmarshal(message.Body, body); err != nil {
+				c.t.Fatalf("cannot decode the body of %s: %v", name, err)
+			}
+		}
+		return
+	}
+}
+
+func (c *client) launch(stopOnEntry bool) {
+	c.t.Helper()
+	c.call("launch", LaunchArguments{Program: c.program, StopOnEntry: stopOnEntry}, nil)
+	c.event("initialized", nil)
+}
+
+func (c *client) setBreakpoints(breakpoints ...SourceBreakpoint) []Breakpoint {
+	c.t.Helper()
+	var response SetBreakpointsResponse
+	c.call("setBreakpoints", SetBreakpointsArguments{Source: Source{Path: c.program}, Breakpoints: breakpoints}, &response)
+	return response.Breakpoints
+}
+
+// waits until the program stops and returns the reason and the line of the innermost frame
+func (c *client) stopped() (string, int) {
+	c.t.Helper()
+	var stopped StoppedEvent
+	c.event("stopped", &stopped)
+	frames := c.stackTrace()
+	return stopped.Reason, frames[0].Line
+}
+
+func (c *client) stackTrace() []StackFrame {
+	c.t.Helper()
+	var response StackTraceResponse
+	c.call("stackTrace", StackTraceArguments{ThreadID: THREAD_ID}, &response)
+	return response.StackFrames
+}
+
+// variables of the scope chain of the frame, a string per scope
+func (c *client) variables(frame int) []string {
+	c.t.Helper()
+	var scopes ScopesResponse
+	c.call("scopes", ScopesArguments{FrameID: frame}, &scopes)
+	chain := []string{}
+	for _, scope := range scopes.Scopes {
+		var response VariablesResponse
+		c.call("variables", VariablesArguments{VariablesReference: scope.VariablesReference}, &response)
+		variables := []string{}
+		for _, v := range response.Variables {
+			variables = append(variables, v.Type+" "+v.Name+" = "+v.Value)
+		}
+		chain = append(chain, scope.Name+": "+strings.Join(variables, ", "))
+	}
+	return chain
+}
+
+func (c *client) resume(command string) {
+	c.t.Helper()
+	c.call(command, map[string]any{"threadId": THREAD_ID}, nil)
+}
+
+func (c *client) exited() int {
+	c.t.Helper()
+	var exited ExitedEvent
+	c.event("exited", &exited)
+	c.event("terminated", nil)
+	return exited.ExitCode
+}
+
+func (c *client) disconnect() {
+	c.t.Helper()
+	c.call("disconnect", map[string]any{}, nil)
+	select {
+	case err := <-c.done:
+		if err != nil {
+			c.t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("the server did not stop")
+	}
+}
+
+func TestRunWithoutBreakpoints(t *testing.T) {
+	c := newClient(t, source)
+	c.launch(false)
+	c.call("configurationDone", nil, nil)
+	if code := c.exited(); code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+	if c.output.String() != "total 20\n" {
+		t.Errorf("expected the output of print, got %q", c.output.String())
+	}
+	c.disconnect()
+}
+
+func TestBreakpoints(t *testing.T) {
+	c := newClient(t, source)
+	c.launch(false)
+	breakpoints := c.setBreakpoints(
+		SourceBreakpoint{Line: 3},
+		SourceBreakpoint{Line: 11},
+		SourceBreakpoint{Line: 30},
+		SourceBreakpoint{Line: 17, Condition: "i =="},
+	)
+	verified := []bool{}
+	lines := []int{}
+	for _, b := range breakpoints {
+		verified = append(verified, b.Verified)
+		lines = append(lines, b.Line)
+	}
+	// the breakpoint of the empty line is moved to the first statement of main
+	if !reflect.DeepEqual(verified, []bool{true, true, false, false}) || !reflect.DeepEqual(lines, []int{3, 13, 30, 17}) {
+		t.Errorf("unexpected breakpoints: %+v", breakpoints)
+	}
+	if !strings.HasPrefix(breakpoints[3].Message, "invalid condition") {
+		t.Errorf("expected the invalid condition reported, got %q", breakpoints[3].Message)
+	}
+
+	c.call("configurationDone", nil, nil)
+	if reason, line := c.stopped(); reason != STOP_BREAKPOINT || line != 13 {
+		t.Errorf("expected breakpoint at line 13, got %s at %d", reason, line)
+	}
+	c.resume("continue")
+	if reason, line := c.stopped(); reason != STOP_BREAKPOINT || line != 3 {
+		t.Errorf("expected breakpoint at line 3, got %s at %d", reason, line)
+	}
+
+	frames := c.stackTrace()
+	names, lines := []string{}, []int{}
+	for _, frame := range frames {
+		names = append(names, frame.Name)
+		lines = append(lines, frame.Line)
+	}
+	if !reflect.DeepEqual(names, []string{"sign(1)", "main()"}) || !reflect.DeepEqual(lines, []int{3, 16}) {
+		t.Errorf("unexpected stack trace: %+v", frames)
+	}
+
+	expected := []string{"Block: ", "Function: int x = 1"}
+	if variables := c.variables(frames[0].ID); !reflect.DeepEqual(variables, expected) {
+		t.Errorf("expected variables %q, got %q", expected, variables)
+	}
+	expected = []string{"Block: ", "Function: int i = 1, int total = 0"}
+	if variables := c.variables(frames[1].ID); !reflect.DeepEqual(variables, expected) {
+		t.Errorf("expected variables %q, got %q", expected, variables)
+	}
+
+	c.setBreakpoints()
+	c.resume("continue")
+	if code := c.exited(); code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+	c.disconnect()
+}
+
+func TestConditionalBreakpoint(t *testing.T) {
+	c := newClient(t, source)
+	c.launch(false)
+	c.setBreakpoints(SourceBreakpoint{Line: 17, Condition: "total == 6"}, SourceBreakpoint{Line: 3, Condition: "x == 4"})
+	c.call("configurationDone", nil, nil)
+
+	if reason, line := c.stopped(); reason != STOP_BREAKPOINT || line != 17 {
+		t.Errorf("expected breakpoint at line 17, got %s at %d", reason, line)
+	}
+	expected := []string{"Block: ", "Function: int i = 2, int total = 6"}
+	if variables := c.variables(c.stackTrace()[0].ID); !reflect.DeepEqual(variables, expected) {
+		t.Errorf("expected variables %q, got %q", expected, variables)
+	}
+
+	c.resume("continue")
+	if reason, line := c.stopped(); reason != STOP_BREAKPOINT || line != 3 {
+		t.Errorf("expected breakpoint at line 3, got %s at %d", reason, line)
+	}
+	if frames := c.stackTrace(); frames[0].Name != "sign(4)" {
+		t.Errorf("expected to stop in sign(4), got %s", frames[0].Name)
+	}
+	c.disconnect()
+}
+
+// a condition that cannot be evaluated stops the program and is reported
+func TestInvalidConditionStops(t *testing.T) {
+	c := newClient(t, source)
+	c.launch(false)
+	c.setBreakpoints(SourceBreakpoint{Line: 13, Condition: "missing > 1"})
+	c.call("configurationDone", nil, nil)
+
+	if reason, line := c.stopped(); reason != STOP_BREAKPOINT || line != 13 {
+		t.Errorf("expected breakpoint at line 13, got %s at %d", reason, line)
+	}
+	if !strings.Contains(c.output.String(), "condition of the breakpoint at line 13") {
+		t.Errorf("expected the condition reported, got %q", c.output.String())
+	}
+	c.disconnect()
+}
+
+func TestStepping(t *testing.T) {
+	c := newClient(t, source)
+	c.launch(true)
+	c.call("configurationDone", nil, nil)
+	if reason, line := c.stopped(); reason != STOP_ENTRY || line != 13 {
+		t.Fatalf("expected entry at line 13, got %s at %d", reason, line)
+	}
+
+	steps := []struct {
+		command string
+		line    int
+		depth   int
+	}{
+		{"next", 14, 1},
+		{"next", 15, 1},
+		{"next", 16, 1},
+		{"stepIn", 2, 2},
+		{"next", 6, 2},
+		{"next", 7, 2},
+		{"stepOut", 17, 1},
+		{"next", 16, 1},
+		// sign(1) is stepped over
+		{"next", 17, 1},
+	}
+	for _, step := range steps {
+		c.resume(step.command)
+		reason, line := c.stopped()
+		if depth := len(c.stackTrace()); reason != STOP_STEP || line != step.line || depth != step.depth {
+			t.Fatalf("%s: expected step to line %d at depth %d, got %s to line %d at depth %d", step.command, step.line, step.depth, reason, line, depth)
+		}
+	}
+	c.disconnect()
+}
+
+func TestEvaluate(t *testing.T) {
+	c := newClient(t, source)
+	c.launch(false)
+	c.setBreakpoints(SourceBreakpoint{Line: 4})
+	c.call("configurationDone", nil, nil)
+	c.stopped()
+	frames := c.stackTrace()
+
+	tests := []struct {
+		expression string
+		frame      *int
+		result     string
+		kind       string
+	}{
+		{"doubled + x", nil, "3", "int"},
+		{"x as string + \"!\"", &frames[0].ID, `"1!"`, "string"},
+		{"sign(i + 1) == 4", &frames[1].ID, "true", "bool"},
+	}
+	for _, test := range tests {
+		var response EvaluateResponse
+		c.call("evaluate", EvaluateArguments{Expression: test.expression, FrameID: test.frame}, &response)
+		if response.Result != test.result || response.Type != test.kind {
+			t.Errorf("%s: expected %s %s, got %s %s", test.expression, test.kind, test.result, response.Type, response.Result)
+		}
+	}
+
+	if message := c.request("evaluate", EvaluateArguments{Expression: "total"}, nil); !strings.Contains(message, "total") {
+		t.Errorf("expected total undefined in sign, got %q", message)
+	}
+	if message := c.request("evaluate", EvaluateArguments{Expression: "x +"}, nil); message == "" {
+		t.Errorf("expected a syntax error")
+	}
+	c.disconnect()
+}
+
+func TestPause(t *testing.T) {
+	// every statement is on the line the program is paused at
+	c := newClient(t, "main() {\n    int i := 0 while true { i = i + 1 }\n}\n")
+	c.launch(false)
+	c.call("configurationDone", nil, nil)
+	c.call("pause", map[string]any{"threadId": THREAD_ID}, nil)
+	if reason, line := c.stopped(); reason != STOP_PAUSE || line != 2 {
+		t.Errorf("expected pause at line 2, got %s at %d", reason, line)
+	}
+	// the running program is terminated
+	c.resume("continue")
+	c.disconnect()
+}
+
+func TestRuntimeError(t *testing.T) {
+	c := newClient(t, "main() {\n    int zero := 0\n    print(1 / zero)\n}\n")
+	c.launch(false)
+	c.call("configurationDone", nil, nil)
+	if code := c.exited(); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(c.output.String(), "main.fl:3:") {
+		t.Errorf("expected the error reported at line 3, got %q", c.output.String())
+	}
+	c.disconnect()
+}
+
+func TestLaunchSyntaxError(t *testing.T) {
+	c := newClient(t, "main() {\n    int x := \n}\n")
+	message := c.request("launch", LaunchArguments{Program: c.program}, nil)
+	if message == "" {
+		t.Fatal("expected the launch to fail")
+	}
+	if !strings.Contains(c.output.String(), "error[E02") {
+		t.Errorf("expected the syntax error printed, got %q", c.output.String())
+	}
+	if message := c.request("configurationDone", nil, nil); message != errNotLaunched.Error() {
+		t.Errorf("expected %q, got %q", errNotLaunched, message)
+	}
+	c.disconnect()
+}
+
+func TestUnknownCommand(t *testing.T) {
+	c := newClient(t, source)
+	if message := c.request("restartFrame", map[string]any{}, nil); message != "unknown command: restartFrame" {
+		t.Errorf("unexpected message: %q", message)
+	}
+	c.disconnect()
+}
@@ -0,0 +1,396 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"tkom/ast"
+	"tkom/diagnostics"
+	"tkom/interpreter"
+	"tkom/lexer"
+	"tkom/parser"
+	"tkom/shared"
+)
+
+const (
+	IDENTIFIER_LIMIT = 500
+	STRING_LIMIT     = 1000
+	INT_LIMIT        = math.MaxInt
+
+	PROMPT = "(flux) "
+	// name of the source errors of typed expressions are reported in
+	SOURCE_NAME = "<expression>"
+)
+
+const HELP = `The program stops before its first statement, then after every step
+and at breakpoints and watched variables.
+
+  break fn | line   stops at the first statement of the function or at the line
+  watch var         stops when the variable visible here changes
+  delete n          removes the breakpoint or the watch of the number
+  info              lists the breakpoints and the watches
+  step              runs to the next statement, stopping in called functions
+  next              runs to the next statement of this function or its callers
+  continue          runs to the next breakpoint or change of a watched variable
+  print expr        prints the value of the expression in the current scope
+  locals            prints the variables of the scopes of the current function
+  backtrace         prints the calls running, the innermost first
+  help              prints this help
+  quit              stops the program
+
+Commands can be shortened to b, w, d, s, n, c, p, bt and q.
+`
+
+var aliases = map[string]string{
+	"b":  "break",
+	"w":  "watch",
+	"d":  "delete",
+	"s":  "step",
+	"n":  "next",
+	"c":  "continue",
+	"p":  "print",
+	"bt": "backtrace",
+	"q":  "quit",
+}
+
+// how the stopped program goes on
+type step int
+
+const (
+	CONTINUE step = iota
+	STEP
+	NEXT
+)
+
+// panicked with to unwind the program on quit
+type quit struct{}
+
+type breakpoint struct {
+	number int
+	line   int
+}
+
+type watch struct {
+	number  int
+	name    string
+	unwatch func()
+}
+
+// command-line debugger, the program runs in the goroutine reading the
+// commands and they are read whenever it stops
+type Debugger struct {
+	Program *ast.Program
+	Source  *diagnostics.Source
+	Emitter diagnostics.Emitter
+
+	in  *bufio.Scanner
+	out io.Writer
+	// sorted lines the program can stop at
+	lines             []int
+	breakpoints       []*breakpoint
+	watches           []*watch
+	number            int
+	maxRecursionDepth int
+	step              step
+	// depth of the call stack the step started at
+	depth int
+	// the statement about to run, updated before every statement
+	visitor  *interpreter.CodeVisitor
+	position shared.Position
+	// the statement running in every call, by the depth of the call stack
+	positions []shared.Position
+}
+
+func NewDebugger(program *ast.Program, source *diagnostics.Source, in io.Reader, out io.Writer, emitter diagnostics.Emitter, maxRecursionDepth int) *Debugger {
+	return &Debugger{
+		Program:           program,
+		Source:            source,
+		Emitter:           emitter,
+		in:                bufio.NewScanner(in),
+		out:               out,
+		lines:             interpreter.StatementLines(program),
+		maxRecursionDepth: maxRecursionDepth,
+		// stops before the first statement
+		step: STEP,
+	}
+}
+
+// runs the call under the debugger until the program ends or quit,
+// returns the exit code of the program
+func (d *Debugger) Run(call *ast.FunctionCall) (exitCode int) {
+	visitor := interpreter.NewCodeVisitor(d.maxRecursionDepth)
+	visitor.Debugger = d
+	interpreter.Output = d.out
+	defer func() {
+		interpreter.Output = nil
+		r := recover()
+		switch r.(type) {
+		case nil:
+			fmt.Fprintln(d.out, "program exited with code 0")
+		case quit:
+			exitCode = 0
+		default:
+			d.Emitter.Emit(diagnostics.FromPanic(r), d.Source)
+			fmt.Fprintln(d.out, "program exited with code 1")
+			exitCode = 1
+		}
+	}()
+	visitor.Run(d.Program, call)
+	return 0
+}
+
+func (d *Debugger) Pause(v *interpreter.CodeVisitor, position shared.Position) {
+	d.visitor, d.position = v, position
+	depth := v.CallStack.Depth()
+	for len(d.positions) < depth {
+		d.positions = append(d.positions, shared.Position{})
+	}
+	d.positions = d.positions[:depth]
+	d.positions[depth-1] = position
+	for _, b := range d.breakpoints {
+		if b.line == position.Line {
+			fmt.Fprintf(d.out, "breakpoint %d, ", b.number)
+			d.stop()
+			return
+		}
+	}
+	if d.step == STEP || d.step == NEXT && depth <= d.depth {
+		d.stop()
+	}
+}
+
+// prints where the program stopped and reads the commands until one resumes it
+func (d *Debugger) stop() {
+	d.printLocation()
+	for {
+		fmt.Fprint(d.out, PROMPT)
+		if !d.in.Scan() {
+			fmt.Fprintln(d.out)
+			panic(quit{})
+		}
+		if d.execute(strings.TrimSpace(d.in.Text())) {
+			return
+		}
+	}
+}
+
+func (d *Debugger) printLocation() {
+	fmt.Fprintf(d.out, "%s at %s:%d\n", d.frames()[0].Call(), filepath.Base(d.Source.Path), d.position.Line)
+	if d.position.Line <= len(d.Source.Lines) {
+		fmt.Fprintf(d.out, "%d\t%s\n", d.position.Line, d.Source.Lines[d.position.Line-1])
+	}
+}
+
+// runs the command, returns true when it resumes the program
+func (d *Debugger) execute(input string) bool {
+	command, argument, _ := strings.Cut(input, " ")
+	argument = strings.TrimSpace(argument)
+	if alias, ok := aliases[command]; ok {
+		command = alias
+	}
+	switch command {
+	case "":
+	case "break":
+		d.setBreakpoint(argument)
+	case "watch":
+		d.setWatch(argument)
+	case "delete":
+		d.delete(argument)
+	case "info":
+		d.info()
+	case "step":
+		d.resume(STEP)
+		return true
+	case "next":
+		d.resume(NEXT)
+		return true
+	case "continue":
+		d.resume(CONTINUE)
+		return true
+	case "print":
+		d.print(argument)
+	case "locals":
+		d.locals()
+	case "backtrace":
+		d.backtrace()
+	case "help":
+		fmt.Fprint(d.out, HELP)
+	case "quit":
+		panic(quit{})
+	default:
+		fmt.Fprintf(d.out, "unknown command: %s, type help for the list of commands\n", command)
+	}
+	return false
+}
+
+func (d *Debugger) resume(s step) {
+	d.step, d.depth = s, d.visitor.CallStack.Depth()
+}
+
+// a line without a statement stops at the next one
+func (d *Debugger) setBreakpoint(argument string) {
+	b := &breakpoint{}
+	if line, err := strconv.Atoi(argument); err == nil {
+		i := sort.SearchInts(d.lines, line)
+		if i == len(d.lines) {
+			fmt.Fprintf(d.out, "no statement at or after line %d\n", line)
+			return
+		}
+		b.line = d.lines[i]
+	} else {
+		function, ok := d.Program.Functions[argument]
+		if !ok {
+			fmt.Fprintf(d.out, "no function %s in the program\n", argument)
+			return
+		}
+		b.line = firstLine(function.Block)
+		if b.line == 0 {
+			fmt.Fprintf(d.out, "function %s has no statement to stop at\n", argument)
+			return
+		}
+	}
+	d.number++
+	b.number = d.number
+	d.breakpoints = append(d.breakpoints, b)
+	fmt.Fprintf(d.out, "breakpoint %d at %s:%d\n", b.number, filepath.Base(d.Source.Path), b.line)
+}
+
+func firstLine(block *ast.Block) int {
+	for _, statement := range block.Statements {
+		if position := interpreter.StatementPosition(statement); position.Line > 0 {
+			return position.Line
+		}
+	}
+	return 0
+}
+
+// the variable is the one visible in the current scope, a variable of the same
+// name declared later, e.g. in another call of the function, is not watched
+func (d *Debugger) setWatch(name string) {
+	d.number++
+	number := d.number
+	unwatch, ok := d.visitor.CurrentScope.Watch(name, func(old, value any) {
+		fmt.Fprintf(d.out, "watch %d: %s\nold value: %s\nnew value: %s\n", number, name, format(old), format(value))
+		// the change is made by the statement running in the innermost call
+		d.position = d.positions[d.visitor.CallStack.Depth()-1]
+		d.stop()
+	})
+	if !ok {
+		d.number--
+		fmt.Fprintf(d.out, "no variable %s in the current scope\n", name)
+		return
+	}
+	d.watches = append(d.watches, &watch{number: number, name: name, unwatch: unwatch})
+	fmt.Fprintf(d.out, "watch %d: %s\n", number, name)
+}
+
+func (d *Debugger) delete(argument string) {
+	number, err := strconv.Atoi(argument)
+	if err != nil {
+		fmt.Fprintf(d.out, "expected the number of a breakpoint or a watch, got %q\n", argument)
+		return
+	}
+	for i, b := range d.breakpoints {
+		if b.number == number {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return
+		}
+	}
+	for i, w := range d.watches {
+		if w.number == number {
+			w.unwatch()
+			d.watches = append(d.watches[:i], d.watches[i+1:]...)
+			return
+		}
+	}
+	fmt.Fprintf(d.out, "no breakpoint or watch %d\n", number)
+}
+
+func (d *Debugger) info() {
+	for _, b := range d.breakpoints {
+		fmt.Fprintf(d.out, "breakpoint %d at %s:%d\n", b.number, filepath.Base(d.Source.Path), b.line)
+	}
+	for _, w := range d.watches {
+		fmt.Fprintf(d.out, "watch %d: %s\n", w.number, w.name)
+	}
+}
+
+// the functions the expression calls run without stopping
+func (d *Debugger) print(text string) {
+	defer func() {
+		if r := recover(); r != nil {
+			d.Emitter.Emit(diagnostics.FromPanic(r), diagnostics.NewSource(SOURCE_NAME, text))
+		}
+	}()
+	scanner, _ := lexer.NewScanner(strings.NewReader(text))
+	lex := lexer.NewLexer(scanner, IDENTIFIER_LIMIT, STRING_LIMIT, INT_LIMIT)
+	errorHandler := func(err error) {
+		panic(err)
+	}
+	lex.ErrorHandler = errorHandler
+	expression := parser.NewParser(lex, errorHandler).ParseExpressionInput()
+	if expression == nil {
+		fmt.Fprintf(d.out, "expected an expression, got %q\n", text)
+		return
+	}
+	value, err := d.visitor.Evaluate(expression, d.visitor.CurrentScope)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintln(d.out, format(value))
+}
+
+// the scope chain of the running function, the innermost block first
+func (d *Debugger) locals() {
+	for scope := d.visitor.CurrentScope; scope != nil; scope = scope.Parent {
+		names, values := scope.Variables()
+		for i, name := range names {
+			fmt.Fprintf(d.out, "%s %s = %s\n", d.visitor.DetermineType(values[i]), name, format(values[i]))
+		}
+	}
+}
+
+// running call and the line it is at
+type location struct {
+	interpreter.Frame
+	line int
+}
+
+// frames of builtins and of calls still evaluating their arguments are left out,
+// the innermost call first
+func (d *Debugger) frames() []location {
+	frames := d.visitor.CallStack.Frames()
+	scopes := d.visitor.FrameScopes()
+	locations := []location{}
+	for i := len(frames) - 1; i >= 0; i-- {
+		if scopes[i] == nil {
+			continue
+		}
+		// callers are at the call of the next frame
+		line := d.position.Line
+		if i < len(frames)-1 {
+			line = frames[i+1].CallSite.Line
+		}
+		locations = append(locations, location{Frame: frames[i], line: line})
+	}
+	return locations
+}
+
+func (d *Debugger) backtrace() {
+	for i, l := range d.frames() {
+		fmt.Fprintf(d.out, "#%d  %s at %s:%d\n", i, l.Call(), filepath.Base(d.Source.Path), l.line)
+	}
+}
+
+// strings are quoted like in the arguments of the frames
+func format(value any) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprintf("%v", value)
+}
@@ -0,0 +1,158 @@
+package debugger
+
+import (
+	"strings"
+	"testing"
+	"tkom/ast"
+	"tkom/diagnostics"
+	"tkom/interpreter"
+	"tkom/lexer"
+	"tkom/parser"
+)
+
+const source = `sign(x int) int {
+    if x > 0 {
+        int doubled := x * 2
+        return doubled
+    }
+    switch {
+        x == 0 => 0,
+        default => -1
+    }
+}
+
+main() {
+    int i := 0
+    int total := 0
+    while i < 5 {
+        total = total + sign(i)
+        i = i + 1
+    }
+
+    print("total ", total)
+}
+`
+
+// runs the program with the commands typed one per line and returns the session
+func debug(t *testing.T, text string, commands ...string) (string, int) {
+	t.Helper()
+	scanner, _ := lexer.NewScanner(strings.NewReader(text))
+	lex := lexer.NewLexer(scanner, IDENTIFIER_LIMIT, STRING_LIMIT, INT_LIMIT)
+	errorHandler := func(err error) {
+		t.Fatalf("unexpected syntax error: %v", err)
+	}
+	lex.ErrorHandler = errorHandler
+	program := parser.NewParser(lex, errorHandler).ParseProgram()
+	interpreter.ResolveProgram(program)
+
+	var out strings.Builder
+	in := strings.NewReader(strings.Join(commands, "\n") + "\n")
+	d := NewDebugger(program, diagnostics.NewSource("main.fl", text), in, &out, diagnostics.NewRenderer(&out, false), 200)
+	code := d.Run(&ast.FunctionCall{Name: "main"})
+	return out.String(), code
+}
+
+func expectSession(t *testing.T, session, expected string) {
+	t.Helper()
+	if session != expected {
+		t.Errorf("expected session:\n%s\ngot:\n%s", expected, session)
+	}
+}
+
+func TestBreakAndBacktrace(t *testing.T) {
+	session, code := debug(t, source, "break sign", "break 19", "continue", "backtrace", "locals", "print x * 10", "delete 1", "continue", "continue")
+	if code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+	// the breakpoint of the empty line is moved to the next statement
+	expectSession(t, session, `main() at main.fl:13
+13	    int i := 0
+(flux) breakpoint 1 at main.fl:2
+(flux) breakpoint 2 at main.fl:20
+(flux) breakpoint 1, sign(0) at main.fl:2
+2	    if x > 0 {
+(flux) #0  sign(0) at main.fl:2
+#1  main() at main.fl:16
+(flux) int x = 0
+(flux) 0
+(flux) (flux) breakpoint 2, main() at main.fl:20
+20	    print("total ", total)
+(flux) total 20
+program exited with code 0
+`)
+}
+
+func TestStepAndNext(t *testing.T) {
+	session, _ := debug(t, source, "n", "n", "n", "s", "s", "locals", "n", "n", "n", "quit")
+	expectSession(t, session, `main() at main.fl:13
+13	    int i := 0
+(flux) main() at main.fl:14
+14	    int total := 0
+(flux) main() at main.fl:15
+15	    while i < 5 {
+(flux) main() at main.fl:16
+16	        total = total + sign(i)
+(flux) sign(0) at main.fl:2
+2	    if x > 0 {
+(flux) sign(0) at main.fl:6
+6	    switch {
+(flux) int x = 0
+(flux) sign(0) at main.fl:7
+7	        x == 0 => 0,
+(flux) main() at main.fl:17
+17	        i = i + 1
+(flux) main() at main.fl:16
+16	        total = total + sign(i)
+(flux) `)
+}
+
+// the watch stops at the statement making the change, after the calls it made returned
+func TestWatch(t *testing.T) {
+	session, _ := debug(t, source, "watch total", "n", "n", "watch total", "c", "locals", "info", "d 1", "c")
+	expectSession(t, session, `main() at main.fl:13
+13	    int i := 0
+(flux) no variable total in the current scope
+(flux) main() at main.fl:14
+14	    int total := 0
+(flux) main() at main.fl:15
+15	    while i < 5 {
+(flux) watch 1: total
+(flux) watch 1: total
+old value: 0
+new value: 2
+main() at main.fl:16
+16	        total = total + sign(i)
+(flux) int i = 1
+int total = 2
+(flux) watch 1: total
+(flux) (flux) total 20
+program exited with code 0
+`)
+}
+
+func TestErrors(t *testing.T) {
+	session, code := debug(t, "main() {\n    int zero := 0\n    print(1 / zero)\n}\n", "print zero +", "print missing", "break nowhere", "jump", "c")
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	for _, expected := range []string{
+		"error[E02",
+		"undefined: missing",
+		"no function nowhere in the program",
+		"unknown command: jump",
+		"--> main.fl:3:",
+		"program exited with code 1",
+	} {
+		if !strings.Contains(session, expected) {
+			t.Errorf("expected %q in the session:\n%s", expected, session)
+		}
+	}
+}
+
+// the end of the input quits the program
+func TestEndOfInput(t *testing.T) {
+	session, code := debug(t, source)
+	if code != 0 || strings.Contains(session, "total") {
+		t.Errorf("expected the program stopped, got %d:\n%s", code, session)
+	}
+}
@@ -0,0 +1,146 @@
+package diagnostics
+
+import (
+	"fmt"
+	"os"
+	"tkom/shared"
+)
+
+type Severity int
+
+const (
+	ERROR Severity = iota
+	WARNING
+	NOTE
+)
+
+func (s Severity) String() string {
+	switch s {
+	case ERROR:
+		return "error"
+	case WARNING:
+		return "warning"
+	case NOTE:
+		return "note"
+	default:
+		return fmt.Sprintf("Unknown Severity: %d", int(s))
+	}
+}
+
+// Diagnostic is a compiler style report about a problem in the source code,
+// every error type of the lexer, parser and interpreter can be turned into one
+type Diagnostic struct {
+	Severity Severity
+	Code     string
+	Message  string
+	Position shared.Position
+	// number of characters underlined starting from Position
+	Length int
+	// part of the source the diagnostic is about, e.g. the whole expression,
+	// it is underlined instead of Length when it is known
+	Span    shared.Span
+	Notes   []string
+	Help    []string
+	Related []Related
+}
+
+// Related points at another place in the source that explains the diagnostic,
+// e.g. the previous definition of a function or the call site of a frame
+type Related struct {
+	Message  string
+	Position shared.Position
+	// source at the position, zero when only the position is known
+	Span shared.Span
+}
+
+func NewDiagnostic(severity Severity, code, message string, position shared.Position) *Diagnostic {
+	return &Diagnostic{
+		Severity: severity,
+		Code:     code,
+		Message:  message,
+		Position: position,
+		Length:   1,
+	}
+}
+
+// the span is used when it is around the position, its end included
+// for errors reported right after the source they are about
+func (d *Diagnostic) spanned() bool {
+	if d.Span.IsZero() {
+		return false
+	}
+	start, end, p := d.Span.Start, d.Span.End, d.Position
+	afterStart := p.Line > start.Line || p.Line == start.Line && p.Column >= start.Column
+	beforeEnd := p.Line < end.Line || p.Line == end.Line && p.Column <= end.Column
+	return afterStart && beforeEnd
+}
+
+// Start returns position of the first underlined character
+func (d *Diagnostic) Start() shared.Position {
+	if d.spanned() {
+		return d.Span.Start
+	}
+	return d.Position
+}
+
+// End returns position right after the underlined part of the source
+func (d *Diagnostic) End() shared.Position {
+	if d.spanned() && d.Span.End != d.Span.Start {
+		return d.Span.End
+	}
+	if d.Position.Line == 0 {
+		return d.Position
+	}
+	length := d.Length
+	if length < 1 {
+		length = 1
+	}
+	return shared.NewPosition(d.Position.Line, d.Position.Column+length)
+}
+
+// Location returns position of the first underlined character of the quoted
+// line, the line of the position is quoted and the span is underlined from
+// its start only when it starts on that line
+func (d *Diagnostic) Location() shared.Position {
+	if !d.spanned() || d.Span.Start.Line != d.Position.Line {
+		return d.Position
+	}
+	return d.Span.Start
+}
+
+// Emitter writes diagnostics in one of the supported output formats
+type Emitter interface {
+	Emit(d *Diagnostic, source *Source)
+}
+
+// NewEmitter creates emitter for the format given on the command line,
+// "text" output gets colors only when written to the terminal
+func NewEmitter(format string, out *os.File) (Emitter, error) {
+	switch format {
+	case "text":
+		return NewRenderer(out, IsTerminal(out)), nil
+	case "json":
+		return NewJSONEmitter(out), nil
+	default:
+		return nil, fmt.Errorf("unknown diagnostics format: %s, expected text or json", format)
+	}
+}
+
+// Diagnosable is implemented by errors that know how to describe themselves
+// as a Diagnostic
+type Diagnosable interface {
+	Diagnostic() *Diagnostic
+}
+
+// FromPanic converts a value recovered from panic into a Diagnostic,
+// values that are not Diagnosable become a diagnostic without position
+func FromPanic(r any) *Diagnostic {
+	switch value := r.(type) {
+	case Diagnosable:
+		return value.Diagnostic()
+	case error:
+		return NewDiagnostic(ERROR, "", value.Error(), shared.Position{})
+	default:
+		return NewDiagnostic(ERROR, "", fmt.Sprintf("%v", value), shared.Position{})
+	}
+}
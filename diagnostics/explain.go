@@ -0,0 +1,56 @@
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Explanation is the longer description of an error code printed by `flux explain`
+type Explanation struct {
+	Code string
+	// message template of the error, as reported in diagnostics
+	Message string
+	Text    string
+	// erroneous Flux code that triggers the error
+	Example string
+}
+
+var explanations = map[string]*Explanation{}
+
+// RegisterExplanation adds explanation to the registry, the lexer, parser and
+// interpreter register their codes on initialization
+func RegisterExplanation(e *Explanation) {
+	if _, ok := explanations[e.Code]; ok {
+		panic(fmt.Sprintf("explanation for %s already registered", e.Code))
+	}
+	explanations[e.Code] = e
+}
+
+// Explain returns explanation registered for the code, codes are case insensitive
+func Explain(code string) (*Explanation, bool) {
+	e, ok := explanations[strings.ToUpper(code)]
+	return e, ok
+}
+
+// ExplainedCodes returns all registered codes in ascending order
+func ExplainedCodes() []string {
+	codes := make([]string, 0, len(explanations))
+	for code := range explanations {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func (e *Explanation) Write(out io.Writer) {
+	fmt.Fprintf(out, "%s: %s\n\n", e.Code, e.Message)
+	fmt.Fprintf(out, "%s\n", e.Text)
+	if e.Example != "" {
+		fmt.Fprintf(out, "\nErroneous code example:\n\n")
+		for _, line := range strings.Split(strings.TrimRight(e.Example, "\n"), "\n") {
+			fmt.Fprintf(out, "    %s\n", line)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package diagnostics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExplanationWrite(t *testing.T) {
+	e := &Explanation{
+		Code:    "E0999",
+		Message: "undefined: %s",
+		Text:    "A variable was used before it was declared.",
+		Example: "main() {\n    print(a)\n}\n",
+	}
+
+	var out bytes.Buffer
+	e.Write(&out)
+
+	expected := "E0999: undefined: %s\n\n" +
+		"A variable was used before it was declared.\n\n" +
+		"Erroneous code example:\n\n" +
+		"    main() {\n" +
+		"        print(a)\n" +
+		"    }\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestExplainIsCaseInsensitive(t *testing.T) {
+	RegisterExplanation(&Explanation{Code: "E0998", Message: "test"})
+	defer delete(explanations, "E0998")
+
+	if _, ok := Explain("e0998"); !ok {
+		t.Errorf("expected explanation for e0998")
+	}
+	if _, ok := Explain("E0997"); ok {
+		t.Errorf("expected no explanation for E0997")
+	}
+}
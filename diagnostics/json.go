@@ -0,0 +1,103 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"io"
+	"tkom/shared"
+)
+
+// JSON schema of a single diagnostic, every diagnostic is written
+// as one object on its own line:
+//
+//	{
+//	  "severity": "error",
+//	  "code": "E0105",
+//	  "message": "String not closed, perhaps you forgot \"",
+//	  "file": "main.fl",
+//	  "start": {"line": 3, "column": 21},
+//	  "end": {"line": 3, "column": 22},
+//	  "related": [{"message": "...", "file": "main.fl", "start": {...}, "end": {...}}],
+//	  "notes": [],
+//	  "help": []
+//	}
+//
+// positions are 1-based, a position of {"line": 0, "column": 0} means
+// that the location is unknown, start and end are around the whole span
+// of the diagnostic when it has one
+type jsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type jsonRelated struct {
+	Message string       `json:"message"`
+	File    string       `json:"file"`
+	Start   jsonPosition `json:"start"`
+	End     jsonPosition `json:"end"`
+}
+
+type jsonDiagnostic struct {
+	Severity string        `json:"severity"`
+	Code     string        `json:"code"`
+	Message  string        `json:"message"`
+	File     string        `json:"file"`
+	Start    jsonPosition  `json:"start"`
+	End      jsonPosition  `json:"end"`
+	Related  []jsonRelated `json:"related"`
+	Notes    []string      `json:"notes"`
+	Help     []string      `json:"help"`
+}
+
+type JSONEmitter struct {
+	encoder *json.Encoder
+}
+
+func NewJSONEmitter(out io.Writer) *JSONEmitter {
+	return &JSONEmitter{encoder: json.NewEncoder(out)}
+}
+
+func (e *JSONEmitter) Emit(d *Diagnostic, source *Source) {
+	e.encoder.Encode(toJSON(d, source))
+}
+
+func toJSONPosition(position shared.Position) jsonPosition {
+	return jsonPosition{Line: position.Line, Column: position.Column}
+}
+
+func toJSON(d *Diagnostic, source *Source) jsonDiagnostic {
+	file := ""
+	if source != nil {
+		file = source.Path
+	}
+
+	related := []jsonRelated{}
+	for _, r := range d.Related {
+		start, end := r.Position, r.Position
+		if !r.Span.IsZero() {
+			start, end = r.Span.Start, r.Span.End
+		} else if end.Line != 0 {
+			end.Column++
+		}
+		related = append(related, jsonRelated{
+			Message: r.Message,
+			File:    file,
+			Start:   toJSONPosition(start),
+			End:     toJSONPosition(end),
+		})
+	}
+
+	notes := append([]string{}, d.Notes...)
+	help := append([]string{}, d.Help...)
+
+	return jsonDiagnostic{
+		Severity: d.Severity.String(),
+		Code:     d.Code,
+		Message:  d.Message,
+		File:     file,
+		Start:    toJSONPosition(d.Start()),
+		End:      toJSONPosition(d.End()),
+		Related:  related,
+		Notes:    notes,
+		Help:     help,
+	}
+}
@@ -0,0 +1,78 @@
+package diagnostics
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"tkom/shared"
+)
+
+func TestJSONEmitterSchema(t *testing.T) {
+	source := NewSource("main.fl", "f() {}\nf() {}\n")
+	diagnostic := NewDiagnostic(ERROR, "E0202", "redefinition of function", shared.NewPosition(2, 1))
+	diagnostic.Related = []Related{{Message: "previous definition of f", Position: shared.NewPosition(1, 1)}}
+	diagnostic.Help = []string{"rename one of the functions"}
+
+	var out bytes.Buffer
+	NewJSONEmitter(&out).Emit(diagnostic, source)
+
+	expected := `{"severity":"error","code":"E0202","message":"redefinition of function","file":"main.fl",` +
+		`"start":{"line":2,"column":1},"end":{"line":2,"column":2},` +
+		`"related":[{"message":"previous definition of f","file":"main.fl","start":{"line":1,"column":1},"end":{"line":1,"column":2}}],` +
+		`"notes":[],"help":["rename one of the functions"]}` + "\n"
+
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+// related locations with a span are around it, like the diagnostic itself
+func TestJSONEmitterRelatedSpan(t *testing.T) {
+	diagnostic := NewDiagnostic(ERROR, "", "error", shared.NewPosition(2, 12))
+	diagnostic.Related = []Related{{
+		Message:  "in call to divide(10, 0)",
+		Position: shared.NewPosition(6, 5),
+		Span:     shared.NewSpan("main.fl", shared.NewPosition(6, 5), shared.NewPosition(6, 18), 60, 73),
+	}}
+
+	related := toJSON(diagnostic, nil).Related
+	if len(related) != 1 || related[0].Start != (jsonPosition{Line: 6, Column: 5}) || related[0].End != (jsonPosition{Line: 6, Column: 18}) {
+		t.Errorf("expected the related span 6:5-6:18, got %v", related)
+	}
+}
+
+func TestJSONEmitterOneObjectPerLine(t *testing.T) {
+	var out bytes.Buffer
+	emitter := NewJSONEmitter(&out)
+	emitter.Emit(NewDiagnostic(ERROR, "", "first", shared.Position{}), nil)
+	emitter.Emit(NewDiagnostic(WARNING, "", "second", shared.NewPosition(3, 4)), nil)
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines of output, got %d", len(lines))
+	}
+
+	keys := []string{"code", "end", "file", "help", "message", "notes", "related", "severity", "start"}
+	for _, line := range lines {
+		var object map[string]any
+		if err := json.Unmarshal(line, &object); err != nil {
+			t.Fatalf("invalid json %s: %v", line, err)
+		}
+		actualKeys := []string{}
+		for _, key := range keys {
+			if _, ok := object[key]; ok {
+				actualKeys = append(actualKeys, key)
+			}
+		}
+		if !reflect.DeepEqual(actualKeys, keys) || len(object) != len(keys) {
+			t.Errorf("expected keys %v, got object %v", keys, object)
+		}
+	}
+}
+
+func TestNewEmitterUnknownFormat(t *testing.T) {
+	if _, err := NewEmitter("xml", nil); err == nil {
+		t.Errorf("expected error for unknown diagnostics format")
+	}
+}
@@ -0,0 +1,170 @@
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[1;31m"
+	colorYellow = "\x1b[1;33m"
+	colorBlue   = "\x1b[1;34m"
+	colorCyan   = "\x1b[1;36m"
+)
+
+// Renderer prints diagnostics in the rustc style:
+//
+//	error[E0105]: String not closed, perhaps you forgot "
+//	 --> main.fl:2:17
+//	  |
+//	2 |     print("hello
+//	  |                 ^
+//	  = help: ...
+type Renderer struct {
+	out   io.Writer
+	color bool
+}
+
+func NewRenderer(out io.Writer, color bool) *Renderer {
+	return &Renderer{
+		out:   out,
+		color: color,
+	}
+}
+
+// IsTerminal reports whether the file is attached to a terminal
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func (r *Renderer) paint(color, text string) string {
+	if !r.color {
+		return text
+	}
+	return color + text + colorReset
+}
+
+func (r *Renderer) severityColor(severity Severity) string {
+	switch severity {
+	case WARNING:
+		return colorYellow
+	case NOTE:
+		return colorCyan
+	default:
+		return colorRed
+	}
+}
+
+func (r *Renderer) Emit(d *Diagnostic, source *Source) {
+	var b strings.Builder
+	color := r.severityColor(d.Severity)
+
+	header := d.Severity.String()
+	if d.Code != "" {
+		header += "[" + d.Code + "]"
+	}
+	b.WriteString(r.paint(color, header))
+	b.WriteString(r.paint(colorBold, ": "+d.Message))
+	b.WriteString("\n")
+
+	line, hasLine := source.Line(d.Position.Line)
+	gutter := strings.Repeat(" ", len(strconv.Itoa(d.Position.Line)))
+
+	if location := r.location(d, source); location != "" {
+		fmt.Fprintf(&b, "%s%s %s\n", gutter, r.paint(colorBlue, "-->"), location)
+	}
+
+	if hasLine {
+		bar := r.paint(colorBlue, "|")
+		fmt.Fprintf(&b, "%s %s\n", gutter, bar)
+		fmt.Fprintf(&b, "%s %s %s\n", r.paint(colorBlue, strconv.Itoa(d.Position.Line)), bar, line)
+		column, length := underlined(d, line)
+		fmt.Fprintf(&b, "%s %s %s%s\n", gutter, bar, caretPadding(line, column), r.paint(color, underline(length)))
+	}
+
+	for _, note := range d.Notes {
+		r.writeFootnote(&b, gutter, "note", note)
+	}
+	for _, help := range d.Help {
+		r.writeFootnote(&b, gutter, "help", help)
+	}
+
+	io.WriteString(r.out, b.String())
+}
+
+// multi-line footnotes have their continuation lines aligned with the first one
+func (r *Renderer) writeFootnote(b *strings.Builder, gutter, label, text string) {
+	lines := strings.Split(text, "\n")
+	fmt.Fprintf(b, "%s %s %s: %s\n", gutter, r.paint(colorBlue, "="), r.paint(colorBold, label), lines[0])
+
+	indent := gutter + "   " + strings.Repeat(" ", len(label)+2)
+	for _, line := range lines[1:] {
+		fmt.Fprintf(b, "%s%s\n", indent, line)
+	}
+}
+
+func (r *Renderer) location(d *Diagnostic, source *Source) string {
+	path := ""
+	if source != nil {
+		path = source.Path
+	}
+	if d.Position.Line == 0 {
+		return path
+	}
+	start := d.Location()
+	if path == "" {
+		return fmt.Sprintf("%d:%d", start.Line, start.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", path, start.Line, start.Column)
+}
+
+// keeps tabs from the quoted line so that the caret stays aligned
+func caretPadding(line string, column int) string {
+	var b strings.Builder
+	i := 1
+	for _, r := range line {
+		if i >= column {
+			break
+		}
+		if r == '\t' {
+			b.WriteRune('\t')
+		} else {
+			b.WriteRune(' ')
+		}
+		i++
+	}
+	for ; i < column; i++ {
+		b.WriteRune(' ')
+	}
+	return b.String()
+}
+
+// column and length of the underlined part of the quoted line, the span
+// starting on the line is underlined up to its end or to the end of the line
+func underlined(d *Diagnostic, line string) (int, int) {
+	if !d.spanned() || d.Span.Start.Line != d.Position.Line {
+		return d.Position.Column, d.Length
+	}
+	start := d.Span.Start.Column
+	if d.Span.End.Line == d.Span.Start.Line {
+		return start, d.Span.End.Column - start
+	}
+	return start, utf8.RuneCountInString(line) - start + 1
+}
+
+func underline(length int) string {
+	if length <= 1 {
+		return "^"
+	}
+	return strings.Repeat("^", length)
+}
@@ -0,0 +1,140 @@
+package diagnostics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"tkom/shared"
+)
+
+func TestRenderWithSourceLine(t *testing.T) {
+	source := NewSource("main.fl", "main() {\n    int a := 1 / 0\n}\n")
+	diagnostic := NewDiagnostic(ERROR, "E0301", "Division by zero", shared.NewPosition(2, 16))
+	diagnostic.Notes = []string{"right operand evaluates to 0"}
+	diagnostic.Help = []string{"check the divisor before dividing"}
+
+	var out bytes.Buffer
+	NewRenderer(&out, false).Emit(diagnostic, source)
+
+	expected := "error[E0301]: Division by zero\n" +
+		" --> main.fl:2:16\n" +
+		"  |\n" +
+		"2 |     int a := 1 / 0\n" +
+		"  |                ^\n" +
+		"  = note: right operand evaluates to 0\n" +
+		"  = help: check the divisor before dividing\n"
+
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestRenderUnderlineKeepsTabs(t *testing.T) {
+	source := NewSource("tabs.fl", "\tprint(x)")
+	diagnostic := NewDiagnostic(WARNING, "", "unused", shared.NewPosition(1, 2))
+	diagnostic.Length = 5
+
+	var out bytes.Buffer
+	NewRenderer(&out, false).Emit(diagnostic, source)
+
+	expected := "warning: unused\n" +
+		" --> tabs.fl:1:2\n" +
+		"  |\n" +
+		"1 | \tprint(x)\n" +
+		"  | \t^^^^^\n"
+
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+// the whole expression is underlined, the location stays at the operator
+func TestRenderUnderlinesSpan(t *testing.T) {
+	source := NewSource("main.fl", "main() {\n    int a := 1 / 0\n}\n")
+	diagnostic := NewDiagnostic(ERROR, "E0330", "Division by zero", shared.NewPosition(2, 16))
+	diagnostic.Span = shared.NewSpan("main.fl", shared.NewPosition(2, 14), shared.NewPosition(2, 19), 22, 27)
+
+	var out bytes.Buffer
+	NewRenderer(&out, false).Emit(diagnostic, source)
+
+	expected := "error[E0330]: Division by zero\n" +
+		" --> main.fl:2:14\n" +
+		"  |\n" +
+		"2 |     int a := 1 / 0\n" +
+		"  |              ^^^^^\n"
+
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+// a span going on past the line is underlined up to the end of the line
+func TestRenderUnderlinesMultilineSpan(t *testing.T) {
+	source := NewSource("main.fl", "main() {\n    while x {\n    }\n}\n")
+	diagnostic := NewDiagnostic(ERROR, "", "bad loop", shared.NewPosition(2, 5))
+	diagnostic.Span = shared.NewSpan("main.fl", shared.NewPosition(2, 5), shared.NewPosition(3, 6), 13, 29)
+
+	var out bytes.Buffer
+	NewRenderer(&out, false).Emit(diagnostic, source)
+
+	expected := "error: bad loop\n" +
+		" --> main.fl:2:5\n" +
+		"  |\n" +
+		"2 |     while x {\n" +
+		"  |     ^^^^^^^^^\n"
+
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+// the location is where the underline starts, a span starting on an
+// earlier line leaves the position in the header
+func TestRenderLocationOfSpan(t *testing.T) {
+	source := NewSource("main.fl", "main() {\n    print(1 +\n        true)\n}\n")
+	tests := []struct {
+		name     string
+		span     shared.Span
+		expected string
+	}{
+		{"span on the line", shared.NewSpan("main.fl", shared.NewPosition(3, 9), shared.NewPosition(3, 13), 24, 28), " --> main.fl:3:9\n"},
+		{"span from an earlier line", shared.NewSpan("main.fl", shared.NewPosition(2, 11), shared.NewPosition(3, 13), 19, 28), " --> main.fl:3:10\n"},
+		{"span around other source", shared.NewSpan("main.fl", shared.NewPosition(2, 5), shared.NewPosition(2, 10), 13, 18), " --> main.fl:3:10\n"},
+	}
+	for _, test := range tests {
+		diagnostic := NewDiagnostic(ERROR, "", "error", shared.NewPosition(3, 10))
+		diagnostic.Span = test.span
+
+		var out bytes.Buffer
+		NewRenderer(&out, false).Emit(diagnostic, source)
+		if !strings.Contains(out.String(), test.expected) {
+			t.Errorf("%s: expected location %q, got:\n%s", test.name, test.expected, out.String())
+		}
+	}
+}
+
+func TestRenderWithoutPosition(t *testing.T) {
+	diagnostic := NewDiagnostic(ERROR, "", "function main expects 2 arguments but got: 0", shared.Position{})
+
+	var out bytes.Buffer
+	NewRenderer(&out, false).Emit(diagnostic, NewSource("call_args.fl", ""))
+
+	expected := "error: function main expects 2 arguments but got: 0\n" +
+		" --> call_args.fl\n"
+
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestRenderColors(t *testing.T) {
+	diagnostic := NewDiagnostic(ERROR, "", "boom", shared.Position{})
+
+	var out bytes.Buffer
+	NewRenderer(&out, true).Emit(diagnostic, nil)
+
+	expected := colorRed + "error" + colorReset + colorBold + ": boom" + colorReset + "\n"
+	if out.String() != expected {
+		t.Errorf("expected: %q, got: %q", expected, out.String())
+	}
+}
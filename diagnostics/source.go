@@ -0,0 +1,26 @@
+package diagnostics
+
+import "strings"
+
+// Source keeps the program text so diagnostics can quote the offending line
+type Source struct {
+	Path  string
+	Text  string
+	Lines []string
+}
+
+func NewSource(path string, text string) *Source {
+	return &Source{
+		Path:  path,
+		Text:  text,
+		Lines: strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n"),
+	}
+}
+
+// Line returns the line with the given 1-based number
+func (s *Source) Line(number int) (string, bool) {
+	if s == nil || number < 1 || number > len(s.Lines) {
+		return "", false
+	}
+	return s.Lines[number-1], true
+}
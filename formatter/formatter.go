@@ -0,0 +1,544 @@
+package formatter
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"tkom/ast"
+	"tkom/lexer"
+	"tkom/parser"
+	"tkom/shared"
+	"unicode"
+)
+
+const (
+	IDENTIFIER_LIMIT = 500
+	STRING_LIMIT     = 1000
+	INT_LIMIT        = math.MaxInt
+	INDENT           = "    "
+)
+
+// binding strength of the expressions, operands binding weaker than
+// their operator are put in parentheses
+const (
+	PRECEDENCE_OR = iota + 1
+	PRECEDENCE_AND
+	PRECEDENCE_RELATION
+	PRECEDENCE_ADDITIVE
+	PRECEDENCE_MULTIPLICATIVE
+	PRECEDENCE_CAST
+	PRECEDENCE_NEGATE
+	PRECEDENCE_TERM
+)
+
+// prints the program of the source in the canonical style, the source has to be free of syntax errors
+//
+// functions are separated by a blank line, blocks are indented with four spaces, parameters
+// of the same type are grouped, arrows of switch cases are aligned and comments are kept
+func Format(source []byte) (formatted []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(error)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+
+	text := string(source)
+	program := parse(text)
+	p := newPrinter(text, program.Comments)
+	p.program(program)
+	return []byte(p.out.String()), nil
+}
+
+func newLexer(text string) *lexer.Lexer {
+	scanner, _ := lexer.NewScanner(strings.NewReader(text))
+	lex := lexer.NewLexer(scanner, IDENTIFIER_LIMIT, STRING_LIMIT, INT_LIMIT)
+	lex.ErrorHandler = func(err error) {
+		panic(err)
+	}
+	return lex
+}
+
+func parse(text string) *ast.Program {
+	errorHandler := func(err error) {
+		panic(err)
+	}
+	return parser.NewParser(newLexer(text), errorHandler).ParseProgram()
+}
+
+// lines of the opening and the closing brace of a block
+type braces struct {
+	open  int
+	close int
+}
+
+type printer struct {
+	out   strings.Builder
+	depth int
+	// source split into lines of runes, columns of positions count runes
+	lines    [][]rune
+	tokens   map[shared.Position]lexer.TokenType
+	keywords map[lexer.TokenType][]shared.Position
+	// in the order of the opening braces, which is the order the blocks are printed in
+	braces   []braces
+	comments []*ast.Comment
+	// source line of the last printed line
+	last int
+	// nothing was printed in the block yet, blank lines are not kept at its start
+	fresh bool
+}
+
+// statements without a position are found by their keywords and blocks by their
+// braces, both are taken in the order of the source as the program is printed
+func newPrinter(text string, comments []*ast.Comment) *printer {
+	p := &printer{
+		tokens:   map[shared.Position]lexer.TokenType{},
+		keywords: map[lexer.TokenType][]shared.Position{},
+		comments: comments,
+		fresh:    true,
+	}
+	for _, line := range strings.Split(text, "\n") {
+		p.lines = append(p.lines, []rune(strings.TrimSuffix(line, "\r")))
+	}
+
+	lex := newLexer(text)
+	open := []int{}
+	for token := lex.GetNextToken(); token.Type != lexer.ETX; token = lex.GetNextToken() {
+		p.tokens[token.Position] = token.Type
+		switch token.Type {
+		case lexer.IF, lexer.WHILE, lexer.RETURN:
+			p.keywords[token.Type] = append(p.keywords[token.Type], token.Position)
+		case lexer.LEFT_BRACE:
+			open = append(open, len(p.braces))
+			p.braces = append(p.braces, braces{open: token.Position.Line})
+		case lexer.RIGHT_BRACE:
+			p.braces[open[len(open)-1]].close = token.Position.Line
+			open = open[:len(open)-1]
+		}
+	}
+	return p
+}
+
+func (p *printer) keyword(tokenType lexer.TokenType) shared.Position {
+	position := p.keywords[tokenType][0]
+	p.keywords[tokenType] = p.keywords[tokenType][1:]
+	return position
+}
+
+func (p *printer) block() braces {
+	b := p.braces[0]
+	p.braces = p.braces[1:]
+	return b
+}
+
+// a comment is trailing when code precedes it on its line
+func (p *printer) trailing(c *ast.Comment) bool {
+	line := p.lines[c.Position.Line-1]
+	return strings.TrimSpace(string(line[:c.Position.Column-1])) != ""
+}
+
+// keeps one blank line where the source had at least one
+func (p *printer) gap(line int) {
+	if !p.fresh && line > p.last+1 {
+		p.out.WriteString("\n")
+	}
+}
+
+func (p *printer) write(text string) {
+	p.out.WriteString(strings.Repeat(INDENT, p.depth))
+	p.out.WriteString(text)
+	p.out.WriteString("\n")
+}
+
+// prints the comments placed before the line on their own lines
+func (p *printer) flush(before int) {
+	for len(p.comments) > 0 && p.comments[0].Position.Line < before {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		p.gap(c.Position.Line)
+		p.write(c.Text)
+		p.last = c.Position.Line
+		p.fresh = false
+	}
+}
+
+// prints a line made of the source lines from and to, the first trailing comment of them
+// is kept at the end of the line and the other comments follow on their own lines
+func (p *printer) line(text string, from, to int) {
+	rest := []*ast.Comment{}
+	attached := false
+	for len(p.comments) > 0 && p.comments[0].Position.Line <= to {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		if !attached && c.Position.Line >= from && p.trailing(c) {
+			text += " " + c.Text
+			attached = true
+		} else {
+			rest = append(rest, c)
+		}
+	}
+	p.write(text)
+	for _, c := range rest {
+		p.write(c.Text)
+	}
+	p.last = max(p.last, to)
+	p.fresh = false
+}
+
+func (p *printer) program(program *ast.Program) {
+	functions := []*ast.FunctionDefinition{}
+	for _, f := range program.Functions {
+		functions = append(functions, f)
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		a, b := functions[i].Position, functions[j].Position
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+
+	for i, f := range functions {
+		if i > 0 {
+			p.out.WriteString("\n")
+			p.fresh = true
+		}
+		start := f.Position.Line
+		p.flush(start)
+		p.gap(start)
+
+		b := p.block()
+		header := f.Name + "(" + parameters(f.Parameters) + ")"
+		if f.Type != shared.VOID {
+			header += " " + f.Type.String()
+		}
+		p.line(header+" {", start, b.open)
+		p.body(f.Block, b)
+		p.line("}", b.close, b.close)
+	}
+	p.flush(math.MaxInt)
+}
+
+// following parameters of the same type share it
+func parameters(variables []*ast.Variable) string {
+	groups := []string{}
+	for i, v := range variables {
+		if i+1 < len(variables) && variables[i+1].Type == v.Type {
+			groups = append(groups, v.Name+",")
+			continue
+		}
+		groups = append(groups, v.Name+" "+v.Type.String()+",")
+	}
+	return strings.TrimSuffix(strings.Join(groups, " "), ",")
+}
+
+// prints the statements of the block and the comments before its closing brace
+func (p *printer) body(block *ast.Block, b braces) {
+	p.depth++
+	p.fresh = true
+	p.last = b.open
+	for _, statement := range block.Statements {
+		p.statement(statement)
+	}
+	p.flush(b.close)
+	p.depth--
+}
+
+func (p *printer) statement(statement ast.Statement) {
+	var start int
+	switch s := statement.(type) {
+	case *ast.IfStatement:
+		start = p.keyword(lexer.IF).Line
+	case *ast.WhileStatement:
+		start = p.keyword(lexer.WHILE).Line
+	case *ast.ReturnStatement:
+		start = p.keyword(lexer.RETURN).Line
+	case *ast.Assignment:
+		start = s.Identifier.Position.Line
+	case *ast.Variable:
+		start = s.Position.Line
+	default:
+		start = statement.(interface{ GetPosition() shared.Position }).GetPosition().Line
+	}
+	p.flush(start)
+	p.gap(start)
+
+	switch s := statement.(type) {
+	case *ast.Variable:
+		p.line(s.Type.String()+" "+s.Name+" := "+p.expression(s.Value), start, lastLine(s, start))
+	case *ast.Assignment:
+		p.line(s.Identifier.Name+" = "+p.expression(s.Value), start, lastLine(s, start))
+	case *ast.ReturnStatement:
+		if s.Value == nil {
+			p.line("return", start, start)
+		} else {
+			p.line("return "+p.expression(s.Value), start, lastLine(s, start))
+		}
+	case *ast.IfStatement:
+		b := p.block()
+		p.line("if "+p.expression(s.Condition)+" {", start, b.open)
+		p.body(s.InstructionsBlock, b)
+		if s.ElseInstructionsBlock != nil {
+			e := p.block()
+			p.line("} else {", b.close, e.open)
+			p.body(s.ElseInstructionsBlock, e)
+			b = e
+		}
+		p.line("}", b.close, b.close)
+	case *ast.WhileStatement:
+		b := p.block()
+		p.line("while "+p.expression(s.Condition)+" {", start, b.open)
+		p.body(s.InstructionsBlock, b)
+		p.line("}", b.close, b.close)
+	case *ast.SwitchStatement:
+		p.switchStatement(s, start)
+	case ast.Expression:
+		p.line(p.expression(s), start, lastLine(s, start))
+	default:
+		panic(fmt.Sprintf("formatter: unexpected statement %T", statement))
+	}
+}
+
+type switchArm struct {
+	condition string
+	output    ast.Expression
+	start     int
+	end       int
+}
+
+// arrows are aligned in runs of cases with an expression, a case with a block
+// or a blank line before a case starts a new run
+func (p *printer) switchStatement(s *ast.SwitchStatement, start int) {
+	header := "switch"
+	if len(s.Variables) > 0 {
+		variables := []string{}
+		for _, v := range s.Variables {
+			variables = append(variables, v.Type.String()+" "+v.Name+" := "+p.expression(v.Value))
+		}
+		header += " " + strings.Join(variables, ", ")
+	}
+	b := p.block()
+	p.line(header+" {", start, b.open)
+
+	arms := []switchArm{}
+	for _, c := range s.Cases {
+		arm := switchArm{start: c.GetPosition().Line}
+		switch c := c.(type) {
+		case *ast.SwitchCase:
+			arm.condition = p.expression(c.Condition)
+			arm.output = c.OutputExpression
+			arm.start = firstLine(c.Condition, arm.start)
+		case *ast.DefaultSwitchCase:
+			arm.condition = "default"
+			arm.output = c.OutputExpression
+		}
+		arm.end = lastLine(arm.output, c.GetPosition().Line)
+		arms = append(arms, arm)
+	}
+
+	width := make([]int, len(arms))
+	for i := 0; i < len(arms); {
+		j := i
+		runWidth := 0
+		for ; j < len(arms); j++ {
+			if _, ok := arms[j].output.(*ast.Block); ok || j > i && arms[j].start > arms[j-1].end+1 {
+				break
+			}
+			runWidth = max(runWidth, len([]rune(arms[j].condition)))
+		}
+		for k := i; k < j; k++ {
+			width[k] = runWidth
+		}
+		i = max(j, i+1)
+	}
+
+	p.depth++
+	p.fresh = true
+	p.last = b.open
+	for i, arm := range arms {
+		comma := ","
+		if i == len(arms)-1 {
+			comma = ""
+		}
+		p.flush(arm.start)
+		p.gap(arm.start)
+		if block, ok := arm.output.(*ast.Block); ok {
+			a := p.block()
+			p.line(arm.condition+" => {", arm.start, a.open)
+			p.body(block, a)
+			p.line("}"+comma, a.close, a.close)
+			continue
+		}
+		condition := arm.condition + strings.Repeat(" ", width[i]-len([]rune(arm.condition)))
+		p.line(condition+" => "+p.expression(arm.output)+comma, arm.start, arm.end)
+	}
+	p.flush(b.close)
+	p.depth--
+	p.line("}", b.close, b.close)
+}
+
+func precedence(e ast.Expression) int {
+	switch e.(type) {
+	case *ast.OrExpression:
+		return PRECEDENCE_OR
+	case *ast.AndExpression:
+		return PRECEDENCE_AND
+	case *ast.EqualsExpression, *ast.NotEqualsExpression, *ast.GreaterThanExpression,
+		*ast.LessThanExpression, *ast.GreaterOrEqualExpression, *ast.LessOrEqualExpression:
+		return PRECEDENCE_RELATION
+	case *ast.SumExpression, *ast.SubstractExpression:
+		return PRECEDENCE_ADDITIVE
+	case *ast.MultiplyExpression, *ast.DivideExpression:
+		return PRECEDENCE_MULTIPLICATIVE
+	case *ast.CastExpression:
+		return PRECEDENCE_CAST
+	case *ast.NegateExpression:
+		return PRECEDENCE_NEGATE
+	default:
+		return PRECEDENCE_TERM
+	}
+}
+
+// prints the expression in parentheses when it binds weaker than needed
+func (p *printer) operand(e ast.Expression, needed int) string {
+	if precedence(e) < needed {
+		return "(" + p.expression(e) + ")"
+	}
+	return p.expression(e)
+}
+
+// operators are left associative, relations can not be chained at all
+func (p *printer) binary(left ast.Expression, operator string, right ast.Expression, operatorPrecedence int) string {
+	leftNeeded := operatorPrecedence
+	if operatorPrecedence == PRECEDENCE_RELATION {
+		leftNeeded++
+	}
+	return p.operand(left, leftNeeded) + " " + operator + " " + p.operand(right, operatorPrecedence+1)
+}
+
+func (p *printer) expression(expression ast.Expression) string {
+	switch e := expression.(type) {
+	case *ast.OrExpression:
+		return p.binary(e.LeftExpression, "or", e.RightExpression, PRECEDENCE_OR)
+	case *ast.AndExpression:
+		return p.binary(e.LeftExpression, "and", e.RightExpression, PRECEDENCE_AND)
+	case *ast.EqualsExpression:
+		return p.binary(e.LeftExpression, "==", e.RightExpression, PRECEDENCE_RELATION)
+	case *ast.NotEqualsExpression:
+		return p.binary(e.LeftExpression, "!=", e.RightExpression, PRECEDENCE_RELATION)
+	case *ast.GreaterThanExpression:
+		return p.binary(e.LeftExpression, ">", e.RightExpression, PRECEDENCE_RELATION)
+	case *ast.LessThanExpression:
+		return p.binary(e.LeftExpression, "<", e.RightExpression, PRECEDENCE_RELATION)
+	case *ast.GreaterOrEqualExpression:
+		return p.binary(e.LeftExpression, ">=", e.RightExpression, PRECEDENCE_RELATION)
+	case *ast.LessOrEqualExpression:
+		return p.binary(e.LeftExpression, "<=", e.RightExpression, PRECEDENCE_RELATION)
+	case *ast.SumExpression:
+		return p.binary(e.LeftExpression, "+", e.RightExpression, PRECEDENCE_ADDITIVE)
+	case *ast.SubstractExpression:
+		return p.binary(e.LeftExpression, "-", e.RightExpression, PRECEDENCE_ADDITIVE)
+	case *ast.MultiplyExpression:
+		return p.binary(e.LeftExpression, "*", e.RightExpression, PRECEDENCE_MULTIPLICATIVE)
+	case *ast.DivideExpression:
+		return p.binary(e.LeftExpression, "/", e.RightExpression, PRECEDENCE_MULTIPLICATIVE)
+	case *ast.CastExpression:
+		return p.operand(e.LeftExpression, PRECEDENCE_NEGATE) + " as " + e.TypeAnnotation.String()
+	case *ast.NegateExpression:
+		operator := "-"
+		if p.tokens[e.Position] == lexer.NEGATE {
+			operator = "!"
+		}
+		return operator + p.operand(e.Expression, PRECEDENCE_TERM)
+	case *ast.IntExpression:
+		return strconv.Itoa(e.Value)
+	case *ast.FloatExpression:
+		return p.float(e)
+	case *ast.BoolExpression:
+		return strconv.FormatBool(e.Value)
+	case *ast.StringExpression:
+		return quote(e.Value)
+	case *ast.Identifier:
+		return e.Name
+	case *ast.FunctionCall:
+		arguments := []string{}
+		for _, argument := range e.Arguments {
+			arguments = append(arguments, p.expression(argument))
+		}
+		return e.Name + "(" + strings.Join(arguments, ", ") + ")"
+	default:
+		panic(fmt.Sprintf("formatter: unexpected expression %T", expression))
+	}
+}
+
+// floats are copied from the source, printing the value could change its digits
+func (p *printer) float(e *ast.FloatExpression) string {
+	if e.Position.Line > 0 && e.Position.Line <= len(p.lines) {
+		line := p.lines[e.Position.Line-1]
+		end := e.Position.Column - 1
+		for end < len(line) && (unicode.IsDigit(line[end]) || line[end] == '.') {
+			end++
+		}
+		if end > e.Position.Column-1 {
+			return string(line[e.Position.Column-1 : end])
+		}
+	}
+	text := strconv.FormatFloat(e.Value, 'f', -1, 64)
+	if !strings.Contains(text, ".") {
+		text += ".0"
+	}
+	return text
+}
+
+var escapes = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+
+func quote(value string) string {
+	return `"` + escapes.Replace(value) + `"`
+}
+
+// first and last source line of the node, by the positions of the nodes it is made of
+func firstLine(node any, line int) int {
+	first, _ := lines(reflect.ValueOf(node), line, line)
+	return first
+}
+
+func lastLine(node any, line int) int {
+	_, last := lines(reflect.ValueOf(node), line, line)
+	return last
+}
+
+var (
+	positionType = reflect.TypeOf(shared.Position{})
+	spanType     = reflect.TypeOf(shared.Span{})
+)
+
+func lines(v reflect.Value, first, last int) (int, int) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			return lines(v.Elem(), first, last)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			first, last = lines(v.Index(i), first, last)
+		}
+	case reflect.Struct:
+		// spans reach past the nodes they are made of, e.g. to the '}' of a block
+		if v.Type() == spanType {
+			return first, last
+		}
+		if v.Type() == positionType {
+			if line := int(v.FieldByName("Line").Int()); line > 0 {
+				return min(first, line), max(last, line)
+			}
+			return first, last
+		}
+		for i := 0; i < v.NumField(); i++ {
+			first, last = lines(v.Field(i), first, last)
+		}
+	}
+	return first, last
+}
@@ -0,0 +1,194 @@
+package formatter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"tkom/ast"
+	"tkom/shared"
+)
+
+func format(t *testing.T, source string) string {
+	t.Helper()
+	formatted, err := Format([]byte(source))
+	if err != nil {
+		t.Fatalf("unexpected error: %v\nsource:\n%s", err, source)
+	}
+	return string(formatted)
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{
+			name:     "spacing and indentation",
+			source:   "main(){\n  int a:=1+2*3\n if a>2{\n print(a,\"x\")\n  }else{\n a=a-1 }\n}\n",
+			expected: "main() {\n    int a := 1 + 2 * 3\n    if a > 2 {\n        print(a, \"x\")\n    } else {\n        a = a - 1\n    }\n}\n",
+		},
+		{
+			name:     "grouped parameters",
+			source:   "f(a int, b int, c string, d,e float) float {\n    return d\n}\n",
+			expected: "f(a, b int, c string, d, e float) float {\n    return d\n}\n",
+		},
+		{
+			name:     "empty blocks and one blank line between functions",
+			source:   "f(){}\n\n\n\ng() {\n    while true {}\n}",
+			expected: "f() {\n}\n\ng() {\n    while true {\n    }\n}\n",
+		},
+		{
+			name: "aligned switch cases",
+			source: "f(a int) string {\n    switch int c := a, int d := 2 {\n" +
+				"    c>2 and c<=4 => \"A\",\n  c==5 => \"B\",\n\n    c>100 => \"C\",\n    default => \"D\"\n    }\n}\n",
+			expected: "f(a int) string {\n    switch int c := a, int d := 2 {\n" +
+				"        c > 2 and c <= 4 => \"A\",\n        c == 5           => \"B\",\n\n        c > 100 => \"C\",\n        default => \"D\"\n    }\n}\n",
+		},
+		{
+			name:     "switch cases with blocks",
+			source:   "f(a int) {\n    switch {\n        a == 1 => {\n print(1)\n },\n        a == 22 => 2,\n        default => 3\n    }\n}\n",
+			expected: "f(a int) {\n    switch {\n        a == 1 => {\n            print(1)\n        },\n        a == 22 => 2,\n        default => 3\n    }\n}\n",
+		},
+		{
+			name:     "minimal parentheses",
+			source:   "f(a, b int) {\n    a = ((a + b)) * (a - (b - 1)) - (a * b) / (2)\n    bool c := !(a > b) or (a == b and -a < (b))\n    string s := (-a) as string + (a + b) as string\n}\n",
+			expected: "f(a, b int) {\n    a = (a + b) * (a - (b - 1)) - a * b / 2\n    bool c := !(a > b) or a == b and -a < b\n    string s := -a as string + (a + b) as string\n}\n",
+		},
+		{
+			name:     "literals",
+			source:   "f() {\n    print(1.50, 7, true, \"a\\t\\\"b\\\"\\\\\")\n}\n",
+			expected: "f() {\n    print(1.50, 7, true, \"a\\t\\\"b\\\"\\\\\")\n}\n",
+		},
+		{
+			name: "comments",
+			source: "# header\n\n# about f\nf() {   # opening\n    # own line\n    int a := 1    # trailing\n\n\n" +
+				"    a = 2\n    # before the brace\n}\n# about g\ng() { return }\n# end",
+			expected: "# header\n\n# about f\nf() { # opening\n    # own line\n    int a := 1 # trailing\n\n" +
+				"    a = 2\n    # before the brace\n}\n\n# about g\ng() {\n    return\n}\n# end\n",
+		},
+		{
+			name:     "no blank line at the start of a block",
+			source:   "f() {\n\n    int a := 1\n\n    if a > 0 {\n\n        a = 2\n\n    }\n\n}\n",
+			expected: "f() {\n    int a := 1\n\n    if a > 0 {\n        a = 2\n    }\n}\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := format(t, test.source); got != test.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestFormatSyntaxError(t *testing.T) {
+	for _, source := range []string{"main() {\n    int a := \n}\n", "main() {\n    string s := \"a\n}\n"} {
+		if formatted, err := Format([]byte(source)); err == nil {
+			t.Errorf("expected an error for %q, got:\n%s", source, formatted)
+		}
+	}
+}
+
+func examples(t *testing.T) map[string]string {
+	files, err := filepath.Glob("../example_codes/*.fl")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no example codes: %v", err)
+	}
+	sources := map[string]string{}
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[filepath.Base(file)] = string(source)
+	}
+	return sources
+}
+
+// the examples are kept formatted, formatting them again changes nothing
+func TestFormatIsIdempotent(t *testing.T) {
+	for name, source := range examples(t) {
+		t.Run(name, func(t *testing.T) {
+			formatted := format(t, source)
+			if formatted != source {
+				t.Errorf("example is not formatted, expected:\n%s\ngot:\n%s", formatted, source)
+			}
+			if again := format(t, formatted); again != formatted {
+				t.Errorf("formatting twice changed the code:\n%s\nto:\n%s", formatted, again)
+			}
+		})
+	}
+}
+
+// sets every position to zero, formatting moves the code around
+func clearPositions(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			clearPositions(v.Elem())
+		}
+	case reflect.Map, reflect.Slice:
+		for _, element := range elements(v) {
+			clearPositions(element)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Field(i); field.Type() == reflect.TypeOf(shared.Position{}) || field.Type() == reflect.TypeOf(shared.Span{}) {
+				field.Set(reflect.Zero(field.Type()))
+			} else {
+				clearPositions(field)
+			}
+		}
+	}
+}
+
+func elements(v reflect.Value) []reflect.Value {
+	values := []reflect.Value{}
+	if v.Kind() == reflect.Map {
+		for _, key := range v.MapKeys() {
+			values = append(values, v.MapIndex(key))
+		}
+		return values
+	}
+	for i := 0; i < v.Len(); i++ {
+		values = append(values, v.Index(i))
+	}
+	return values
+}
+
+func parseWithoutPositions(t *testing.T, source string) *ast.Program {
+	t.Helper()
+	program := parse(source)
+	clearPositions(reflect.ValueOf(program))
+	return program
+}
+
+// formatting changes only how the program is written, not the program
+func TestFormatKeepsProgram(t *testing.T) {
+	sources := examples(t)
+	sources["comments"] = "# a\nf(a int, b int) bool {   # b\n    # c\n    return !(a > b) or -a == (b) # d\n}\n# e\n"
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			formatted := format(t, source)
+			expected := parseWithoutPositions(t, source)
+			got := parseWithoutPositions(t, formatted)
+			if !reflect.DeepEqual(expected.Functions, got.Functions) {
+				t.Errorf("formatting changed the program:\n%s", formatted)
+			}
+			if len(expected.Comments) != len(got.Comments) {
+				t.Fatalf("expected %d comments, got %d:\n%s", len(expected.Comments), len(got.Comments), formatted)
+			}
+			for i, c := range expected.Comments {
+				if got.Comments[i].Text != c.Text {
+					t.Errorf("expected comment %q, got %q", c.Text, got.Comments[i].Text)
+				}
+			}
+			if strings.Count(formatted, "\t") != 0 {
+				t.Errorf("expected spaces only:\n%s", formatted)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the .out and .err files of example_codes with the current results")
+
+// set in the environment of the test binary started to run as flux
+const GOLDEN_RUN = "FLUX_GOLDEN_RUN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(GOLDEN_RUN) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// every example is run like flux <file.fl> [arguments...] from example_codes,
+// with the arguments from the .args file next to it, its standard output is
+// compared with the .out file and its standard error followed by the exit status,
+// when it is not 0, with the .err file, a missing file expects nothing
+func TestGoldenExamples(t *testing.T) {
+	files, err := filepath.Glob("example_codes/*.fl")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no example codes found: %v", err)
+	}
+	flux, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		name := filepath.Base(file)
+		base := strings.TrimSuffix(file, ".fl")
+		t.Run(name, func(t *testing.T) {
+			args := []string{name}
+			if data, err := os.ReadFile(base + ".args"); err == nil {
+				args = append(args, strings.Fields(string(data))...)
+			}
+
+			var stdout, stderr bytes.Buffer
+			cmd := exec.Command(flux, args...)
+			cmd.Dir = filepath.Dir(file)
+			cmd.Env = append(os.Environ(), GOLDEN_RUN+"=1")
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			err := cmd.Run()
+			var exitErr *exec.ExitError
+			if err != nil && !errors.As(err, &exitErr) {
+				t.Fatal(err)
+			}
+			if exitErr != nil {
+				fmt.Fprintf(&stderr, "exit status %d\n", exitErr.ExitCode())
+			}
+
+			compareGolden(t, base+".out", stdout.String())
+			compareGolden(t, base+".err", stderr.String())
+		})
+	}
+}
+
+// with -update the file is rewritten, or removed when nothing is expected
+func compareGolden(t *testing.T, fileName, actual string) {
+	t.Helper()
+	if *update {
+		var err error
+		if actual == "" {
+			err = os.Remove(fileName)
+			if errors.Is(err, os.ErrNotExist) {
+				err = nil
+			}
+		} else {
+			err = os.WriteFile(fileName, []byte(actual), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(fileName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	if string(expected) != actual {
+		t.Errorf("%s: expected:\n%s\ngot:\n%s\nrun go test -run TestGoldenExamples -update to accept the change", fileName, expected, actual)
+	}
+}
+
+// a file with another extension is reported like any other error, also as json
+func TestWrongExtension(t *testing.T) {
+	flux, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(flux, "--diagnostics=json", "program.txt")
+	cmd.Env = append(os.Environ(), GOLDEN_RUN+"=1")
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("expected exit status 1, got %v", err)
+	}
+	if !strings.HasPrefix(stderr.String(), "{") || !strings.Contains(stderr.String(), "'.fl' or '.json' extension") {
+		t.Errorf("expected a json diagnostic, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,74 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"tkom/shared"
+)
+
+// limits the work of untrusted programs, every iteration of a loop
+// and every function call takes a step and checks the deadline
+//
+// a budget is shared by every run of the engine it is given to,
+// steps taken by one run are not available to the next one
+type Budget struct {
+	// steps the program can take, zero for no limit
+	MaxSteps int
+	// the program stops when the context is done, nil for no deadline
+	Context context.Context
+	steps   int
+}
+
+func NewBudget(ctx context.Context, maxSteps int) *Budget {
+	return &Budget{MaxSteps: maxSteps, Context: ctx}
+}
+
+// steps taken so far
+func (b *Budget) Steps() int {
+	return b.steps
+}
+
+// takes a step at the position, returns the error the program stops with
+// when the budget is exhausted or the context is done
+func (b *Budget) step(position shared.Position) *InterruptedError {
+	if b == nil {
+		return nil
+	}
+	b.steps++
+	if b.MaxSteps > 0 && b.steps > b.MaxSteps {
+		return newInterruptedError(NewSemanticErrorWithCode(ERR_STEP_LIMIT_EXCEEDED, position, b.MaxSteps), b.steps, nil)
+	}
+	if b.Context != nil {
+		select {
+		case <-b.Context.Done():
+			cause := b.Context.Err()
+			return newInterruptedError(NewSemanticErrorWithCode(ERR_EXECUTION_INTERRUPTED, position, cause), b.steps, cause)
+		default:
+		}
+	}
+	return nil
+}
+
+// error of a program stopped by its budget, unlike errors of the program
+// itself it is not caused by the code, so embedders can tell it apart with
+// errors.As and e.g. report a timeout, the position is where the program stopped
+type InterruptedError struct {
+	*SemantciError
+	// steps taken when the program was stopped
+	Steps int
+	// error of the context, nil when the steps ran out
+	Cause error
+}
+
+func newInterruptedError(err *SemantciError, steps int, cause error) *InterruptedError {
+	return &InterruptedError{SemantciError: err, Steps: steps, Cause: cause}
+}
+
+func (err *InterruptedError) Unwrap() error {
+	return err.Cause
+}
+
+// reports whether the program was stopped because the context deadline passed
+func (err *InterruptedError) Timeout() bool {
+	return errors.Is(err.Cause, context.DeadlineExceeded)
+}
@@ -0,0 +1,152 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"tkom/ast"
+	"tkom/shared"
+)
+
+const budgetSource = `
+spin(n int) int {
+    while true {
+        n = n + 1
+    }
+    return n
+}
+
+fib(n int) int {
+    if n < 2 {
+        return n
+    }
+    return fib(n - 1) + fib(n - 2)
+}
+
+count(n int) int {
+    int i := 0
+    while i < n {
+        i = i + 1
+    }
+    return i
+}
+`
+
+func budgetVisitor(t *testing.T, budget *Budget) *CodeVisitor {
+	program := parseProgram(t, budgetSource)
+	ResolveProgram(program)
+	visitor := NewCodeVisitor(MAX_RECURSION_DEPTH * 10)
+	visitor.FunctionsMap = map[string]ast.Function{}
+	for name, fd := range program.Functions {
+		visitor.FunctionsMap[name] = fd
+	}
+	visitor.Budget = budget
+	return visitor
+}
+
+func budgetCall(name string, n int) *ast.FunctionCall {
+	return &ast.FunctionCall{Name: name, Arguments: []ast.Expression{&ast.IntExpression{Value: n}}, Position: shared.NewPosition(1, 1)}
+}
+
+// runs the call expecting the budget to stop it
+func interrupted(t *testing.T, run evaluate, visitor *CodeVisitor, fc *ast.FunctionCall) (err *InterruptedError) {
+	t.Helper()
+	defer func() {
+		r := recover()
+		var ok bool
+		if err, ok = r.(*InterruptedError); !ok {
+			t.Fatalf("expected *InterruptedError, got: %v", r)
+		}
+	}()
+	run(visitor, fc)
+	return nil
+}
+
+func TestStepLimit(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, run evaluate) {
+		visitor := budgetVisitor(t, NewBudget(nil, 100))
+		err := interrupted(t, run, visitor, budgetCall("spin", 0))
+
+		if err.Code != ERR_STEP_LIMIT_EXCEEDED {
+			t.Errorf("expected %v, got %v", ERR_STEP_LIMIT_EXCEEDED, err.Code)
+		}
+		if err.Steps != 101 || err.Cause != nil {
+			t.Errorf("expected to stop at step 101 without a cause, got %d, %v", err.Steps, err.Cause)
+		}
+		if err.Position != shared.NewPosition(3, 11) {
+			t.Errorf("expected the loop to be stopped at [3, 11], got %v", err.Position)
+		}
+		if len(err.Traceback) != 1 || err.Traceback[0].Function != "spin" {
+			t.Errorf("expected traceback of spin, got: %v", err.Traceback)
+		}
+	})
+}
+
+// both engines count loop iterations and calls the same way
+func TestStepsTaken(t *testing.T) {
+	tests := []struct {
+		call     *ast.FunctionCall
+		expected int
+		result   int
+	}{
+		// one call and an iteration for every loop run
+		{call: budgetCall("count", 10), expected: 11, result: 10},
+		// fib(n) makes fib(n+1) * 2 - 1 calls
+		{call: budgetCall("fib", 10), expected: 177, result: 55},
+	}
+	forEachEngine(t, func(t *testing.T, run evaluate) {
+		for _, tt := range tests {
+			budget := NewBudget(context.Background(), 0)
+			visitor := budgetVisitor(t, budget)
+			run(visitor, tt.call)
+			if visitor.LastResult != tt.result {
+				t.Errorf("expected %v, got %v", tt.result, visitor.LastResult)
+			}
+			if budget.Steps() != tt.expected {
+				t.Errorf("expected %s to take %d steps, got %d", tt.call.Name, tt.expected, budget.Steps())
+			}
+		}
+	})
+}
+
+func TestContextStopsProgram(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, run evaluate) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := interrupted(t, run, budgetVisitor(t, NewBudget(ctx, 0)), budgetCall("spin", 0))
+		if err.Code != ERR_EXECUTION_INTERRUPTED || !err.Timeout() {
+			t.Errorf("expected timeout, got: %v", err)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected error to wrap %v", context.DeadlineExceeded)
+		}
+
+		ctx, cancel = context.WithCancel(context.Background())
+		cancel()
+		err = interrupted(t, run, budgetVisitor(t, NewBudget(ctx, 0)), budgetCall("fib", 3))
+		if err.Timeout() || err.Steps != 1 || err.Position != shared.NewPosition(1, 1) {
+			t.Errorf("expected cancelled context to stop the first call, got: %v after %d steps", err, err.Steps)
+		}
+	})
+}
+
+func TestRunProgramReturnsInterruptedError(t *testing.T) {
+	program := parseProgram(t, budgetSource+"\nmain() {\n    print(spin(0))\n}\n")
+	ResolveProgram(program)
+	for _, name := range []string{ENGINE_INTERPRETER, ENGINE_VM} {
+		t.Run(name, func(t *testing.T) {
+			engine, _ := NewEngine(name, MAX_RECURSION_DEPTH)
+			engine.SetBudget(NewBudget(nil, 50))
+
+			err := RunProgram(engine, program, &ast.FunctionCall{Name: "main"})
+			var interrupted *InterruptedError
+			if !errors.As(err, &interrupted) {
+				t.Fatalf("expected *InterruptedError, got: %v", err)
+			}
+			if interrupted.Code != ERR_STEP_LIMIT_EXCEEDED {
+				t.Errorf("expected %v, got %v", ERR_STEP_LIMIT_EXCEEDED, interrupted.Code)
+			}
+		})
+	}
+}
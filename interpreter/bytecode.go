@@ -0,0 +1,233 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+	"tkom/ast"
+	"tkom/shared"
+)
+
+// instructions of the virtual machine, each opcode takes one byte
+// followed by its operands, every operand is an unsigned 16 bit number
+type Opcode byte
+
+const (
+	OP_CONSTANT             Opcode = iota // constant: pushes a constant
+	OP_NIL                                // pushes nil, the value of a bare return
+	OP_LOAD_LOCAL                         // slot, name: pushes a variable with a single possible slot
+	OP_LOAD                               // name: pushes a variable looked up in every enclosing scope
+	OP_STORE                              // name: assigns the popped value to a variable
+	OP_DECLARE                            // declaration: declares a variable with the popped value
+	OP_ENTER_SCOPE                        // first, count: opens a scope and clears its slots
+	OP_LEAVE_SCOPE                        // closes the scope of an if, while or switch statement
+	OP_NEGATE                             // negates the value on the top
+	OP_CAST                               // constant: casts the value on the top to the type
+	OP_MULTIPLY                           // pops two values, pushes the result
+	OP_DIVIDE                             // pops two values, pushes the result
+	OP_SUM                                // pops two values, pushes the result
+	OP_SUBSTRACT                          // pops two values, pushes the result
+	OP_EQUALS                             // pops two values, pushes the result
+	OP_NOT_EQUALS                         // pops two values, pushes the result
+	OP_GREATER_THAN                       // pops two values, pushes the result
+	OP_GREATER_OR_EQUAL                   // pops two values, pushes the result
+	OP_LESS_THAN                          // pops two values, pushes the result
+	OP_LESS_OR_EQUAL                      // pops two values, pushes the result
+	OP_AND                                // target: jumps keeping false on the top, otherwise pops
+	OP_OR                                 // target: jumps keeping true on the top, otherwise pops
+	OP_EXPECT_BOOL                        // checks the right operand of 'and' and 'or'
+	OP_JUMP                               // target
+	OP_JUMP_IF_FALSE                      // target, code: pops a condition, reports the code when it is not bool
+	OP_SET_RESULT                         // pops a value into the result register
+	OP_CLEAR_RESULT                       // clears the result register
+	OP_ENTER                              // call: checks the call and pushes its frame on the call stack
+	OP_CALL                               // call: pops the arguments and runs the function
+	OP_TAIL_CALL                          // call, reset: pops the arguments and runs the function again in the running frame
+	OP_RETURN                             // reset: returns the popped value, reset clears the switch flag
+	OP_END                                // end of a function body that did not return
+	OP_ARM_END                            // end of a switch arm, returns the result register when it is set
+	OP_JUMP_IF_SWITCH_ENDED               // target: skips the default case after an executed arm
+	OP_SWITCH_END                         // clears the switch flag
+	OP_FAIL                               // code: reports an error without arguments
+	OP_STEP                               // takes a step of the budget on every iteration of a loop
+)
+
+var opcodeNames = map[Opcode]string{
+	OP_CONSTANT:             "CONSTANT",
+	OP_NIL:                  "NIL",
+	OP_LOAD_LOCAL:           "LOAD_LOCAL",
+	OP_LOAD:                 "LOAD",
+	OP_STORE:                "STORE",
+	OP_DECLARE:              "DECLARE",
+	OP_ENTER_SCOPE:          "ENTER_SCOPE",
+	OP_LEAVE_SCOPE:          "LEAVE_SCOPE",
+	OP_NEGATE:               "NEGATE",
+	OP_CAST:                 "CAST",
+	OP_MULTIPLY:             "MULTIPLY",
+	OP_DIVIDE:               "DIVIDE",
+	OP_SUM:                  "SUM",
+	OP_SUBSTRACT:            "SUBSTRACT",
+	OP_EQUALS:               "EQUALS",
+	OP_NOT_EQUALS:           "NOT_EQUALS",
+	OP_GREATER_THAN:         "GREATER_THAN",
+	OP_GREATER_OR_EQUAL:     "GREATER_OR_EQUAL",
+	OP_LESS_THAN:            "LESS_THAN",
+	OP_LESS_OR_EQUAL:        "LESS_OR_EQUAL",
+	OP_AND:                  "AND",
+	OP_OR:                   "OR",
+	OP_EXPECT_BOOL:          "EXPECT_BOOL",
+	OP_JUMP:                 "JUMP",
+	OP_JUMP_IF_FALSE:        "JUMP_IF_FALSE",
+	OP_SET_RESULT:           "SET_RESULT",
+	OP_CLEAR_RESULT:         "CLEAR_RESULT",
+	OP_ENTER:                "ENTER",
+	OP_CALL:                 "CALL",
+	OP_TAIL_CALL:            "TAIL_CALL",
+	OP_RETURN:               "RETURN",
+	OP_END:                  "END",
+	OP_ARM_END:              "ARM_END",
+	OP_JUMP_IF_SWITCH_ENDED: "JUMP_IF_SWITCH_ENDED",
+	OP_SWITCH_END:           "SWITCH_END",
+	OP_FAIL:                 "FAIL",
+	OP_STEP:                 "STEP",
+}
+
+// number of operands following the opcode
+var operandCounts = map[Opcode]int{
+	OP_CONSTANT:             1,
+	OP_LOAD_LOCAL:           2,
+	OP_LOAD:                 1,
+	OP_STORE:                1,
+	OP_DECLARE:              1,
+	OP_ENTER_SCOPE:          2,
+	OP_CAST:                 1,
+	OP_AND:                  1,
+	OP_OR:                   1,
+	OP_JUMP:                 1,
+	OP_JUMP_IF_FALSE:        2,
+	OP_ENTER:                1,
+	OP_CALL:                 1,
+	OP_TAIL_CALL:            2,
+	OP_RETURN:               1,
+	OP_JUMP_IF_SWITCH_ENDED: 1,
+	OP_FAIL:                 1,
+}
+
+const MAX_OPERAND = 1<<16 - 1
+
+func (op Opcode) String() string {
+	if name, ok := opcodeNames[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("OP_%d", byte(op))
+}
+
+// variable referenced by name, slots of every enclosing scope
+// declaring it are tried in order, the innermost first
+type nameRef struct {
+	Name  string
+	Slots []int
+	Scope int
+}
+
+// declared variable, slot -1 declares it in the scope the
+// code is evaluated in
+type declaration struct {
+	Name     string
+	Type     shared.TypeAnnotation
+	Slot     int
+	Position shared.Position
+	Span     shared.Span
+}
+
+// function call, the called function is resolved by name on the first call
+type callSite struct {
+	Call   *ast.FunctionCall
+	Target *Function
+	Scope  int
+	Tail   bool
+}
+
+// names declared in a scope, used for suggestions
+type scopeInfo struct {
+	Parent int
+	Names  map[string]int
+}
+
+type Chunk struct {
+	Code         []byte
+	Positions    []shared.Position
+	Spans        []shared.Span
+	Constants    []any
+	Names        []*nameRef
+	Declarations []*declaration
+	Calls        []*callSite
+	Scopes       []*scopeInfo
+}
+
+// compiled function, builtins have no chunk and are called directly
+type Function struct {
+	Name        string
+	Declaration ast.Function
+	Chunk       *Chunk
+	Locals      int
+	Parameters  []int
+}
+
+func (f *Function) IsFragment() bool {
+	return f.Declaration == nil
+}
+
+func readOperand(code []byte, offset int) int {
+	return int(code[offset])<<8 | int(code[offset+1])
+}
+
+// human readable listing of the instructions
+func (f *Function) Disassemble() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "== %s ==\n", f.Name)
+	if f.Chunk == nil {
+		return sb.String()
+	}
+
+	code := f.Chunk.Code
+	for offset := 0; offset < len(code); {
+		op := Opcode(code[offset])
+		position := f.Chunk.Positions[offset]
+		fmt.Fprintf(&sb, "%04d %4d:%-3d %-20s", offset, position.Line, position.Column, op)
+
+		operands := make([]int, operandCounts[op])
+		for i := range operands {
+			operands[i] = readOperand(code, offset+1+2*i)
+		}
+		for _, operand := range operands {
+			fmt.Fprintf(&sb, " %d", operand)
+		}
+		if comment := f.Chunk.comment(op, operands); comment != "" {
+			fmt.Fprintf(&sb, " ; %s", comment)
+		}
+		sb.WriteString("\n")
+		offset += 1 + 2*len(operands)
+	}
+	return sb.String()
+}
+
+func (c *Chunk) comment(op Opcode, operands []int) string {
+	switch op {
+	case OP_CONSTANT, OP_CAST:
+		if s, ok := c.Constants[operands[0]].(string); ok {
+			return fmt.Sprintf("%q", s)
+		}
+		return fmt.Sprintf("%v", c.Constants[operands[0]])
+	case OP_LOAD_LOCAL:
+		return c.Names[operands[1]].Name
+	case OP_LOAD, OP_STORE:
+		return c.Names[operands[0]].Name
+	case OP_DECLARE:
+		return c.Declarations[operands[0]].Type.String() + " " + c.Declarations[operands[0]].Name
+	case OP_ENTER, OP_CALL, OP_TAIL_CALL:
+		return c.Calls[operands[0]].Call.Name
+	case OP_JUMP_IF_FALSE, OP_FAIL:
+		return ErrorCode(operands[len(operands)-1]).String()
+	}
+	return ""
+}
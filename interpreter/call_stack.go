@@ -1,18 +1,74 @@
 package interpreter
 
+import (
+	"fmt"
+	"strings"
+	"tkom/shared"
+)
+
+// single function invocation, kept for building tracebacks
+type Frame struct {
+	Function string
+	CallSite shared.Position
+	// source of the call, errors without a position underline it
+	CallSiteSpan shared.Span
+	Arguments    []any
+}
+
+func NewFrame(function string, callSite shared.Position, callSiteSpan shared.Span) *Frame {
+	return &Frame{
+		Function:     function,
+		CallSite:     callSite,
+		CallSiteSpan: callSiteSpan,
+	}
+}
+
+// formats the call with its argument values, e.g. divide(10, 0)
+func (f Frame) Call() string {
+	if f.Arguments == nil {
+		// arguments are not evaluated yet
+		return f.Function + "(...)"
+	}
+	args := make([]string, len(f.Arguments))
+	for i, arg := range f.Arguments {
+		if s, ok := arg.(string); ok {
+			args[i] = fmt.Sprintf("%q", s)
+		} else {
+			args[i] = fmt.Sprintf("%v", arg)
+		}
+	}
+	return fmt.Sprintf("%s(%s)", f.Function, strings.Join(args, ", "))
+}
+
+func (f Frame) String() string {
+	call := f.Call()
+	if f.CallSite == (shared.Position{}) {
+		return call
+	}
+	return fmt.Sprintf("%s called at [%v, %v]", call, f.CallSite.Line, f.CallSite.Column)
+}
+
 type CallStack struct {
-	elem map[string]int
+	elem   map[string]int
+	frames []*Frame
 }
 
-func (cs *CallStack) Push(funcName string) {
-	if count, exists := cs.elem[funcName]; exists {
-		cs.elem[funcName] = count + 1
+func (cs *CallStack) Push(frame *Frame) {
+	if count, exists := cs.elem[frame.Function]; exists {
+		cs.elem[frame.Function] = count + 1
 	} else {
-		cs.elem[funcName] = 1
+		cs.elem[frame.Function] = 1
 	}
+	cs.frames = append(cs.frames, frame)
 }
 
-func (cs *CallStack) Pop(funcName string) {
+func (cs *CallStack) Pop() {
+	if len(cs.frames) == 0 {
+		return
+	}
+	funcName := cs.frames[len(cs.frames)-1].Function
+	cs.frames = cs.frames[:len(cs.frames)-1]
+
 	if count, exists := cs.elem[funcName]; exists {
 		if count > 1 {
 			cs.elem[funcName] = count - 1
@@ -22,9 +78,42 @@ func (cs *CallStack) Pop(funcName string) {
 	}
 }
 
+// returns the innermost frame or nil when no function is running
+func (cs *CallStack) Top() *Frame {
+	if len(cs.frames) == 0 {
+		return nil
+	}
+	return cs.frames[len(cs.frames)-1]
+}
+
+func (cs *CallStack) Depth() int {
+	return len(cs.frames)
+}
+
 func (cs *CallStack) RecursionDepth(funcName string) int {
 	if count, exists := cs.elem[funcName]; exists {
 		return count
 	}
 	return 0
 }
+
+// returns copy of the frames, the outermost call first
+func (cs *CallStack) Frames() []Frame {
+	frames := make([]Frame, len(cs.frames))
+	for i, frame := range cs.frames {
+		frames[i] = *frame
+	}
+	return frames
+}
+
+// returns copy of the frames of the calls that happened, calls still
+// evaluating their arguments are left out
+func (cs *CallStack) Traceback() []Frame {
+	frames := []Frame{}
+	for _, frame := range cs.frames {
+		if frame.Arguments != nil {
+			frames = append(frames, *frame)
+		}
+	}
+	return frames
+}
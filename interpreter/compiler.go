@@ -0,0 +1,457 @@
+package interpreter
+
+import (
+	"fmt"
+	"tkom/ast"
+	"tkom/shared"
+)
+
+// compiles the ast to bytecode of the VirtualMachine,
+// like the CodeVisitor it walks the tree as a visitor
+//
+// every scope gets its slots of locals at compile time, a variable
+// can still be referenced before its declaration in the scope,
+// so the machine tries the slots of all enclosing scopes declaring it
+type Compiler struct {
+	function    *Function
+	chunk       *Chunk
+	scope       *compilerScope
+	nextSlot    int
+	switchDepth int
+}
+
+type compilerScope struct {
+	parent *compilerScope
+	index  int
+	first  int
+	names  map[string]int
+	// declarations go to the scope the fragment is evaluated in
+	outer bool
+}
+
+func newCompiler(function *Function) *Compiler {
+	function.Chunk = &Chunk{}
+	return &Compiler{
+		function: function,
+		chunk:    function.Chunk,
+	}
+}
+
+// compiles the body of a user function
+func Compile(fd *ast.FunctionDefinition) *Function {
+	c := newCompiler(&Function{Name: fd.Name, Declaration: fd})
+
+	names := []string{}
+	for _, param := range fd.Parameters {
+		names = append(names, param.Name)
+	}
+	c.openScope(false, append(names, DeclaredNames(fd.Block)...))
+	for _, param := range fd.Parameters {
+		c.function.Parameters = append(c.function.Parameters, c.scope.names[param.Name])
+	}
+
+	fd.Block.Accept(c)
+	c.emit(fd.Position, fd.Span, OP_END)
+	return c.function
+}
+
+// compiles a single node evaluated in a scope, e.g. a call of main
+// or a statement of a test, variables it declares on the top level
+// are added to that scope
+func CompileFragment(node ast.Node) *Function {
+	c := newCompiler(&Function{Name: "<fragment>"})
+	c.openScope(true, nil)
+
+	switch node := node.(type) {
+	case *ast.SwitchCase, *ast.DefaultSwitchCase:
+		node.Accept(c)
+	default:
+		c.statement(node)
+	}
+	c.emit(shared.Position{}, shared.Span{}, OP_END)
+	return c.function
+}
+
+func (c *Compiler) emit(position shared.Position, span shared.Span, op Opcode, operands ...int) int {
+	offset := len(c.chunk.Code)
+	c.chunk.Code = append(c.chunk.Code, byte(op))
+	for _, operand := range operands {
+		c.chunk.Code = append(c.chunk.Code, 0, 0)
+		c.setOperand(len(c.chunk.Code)-2, operand)
+	}
+	for len(c.chunk.Positions) < len(c.chunk.Code) {
+		c.chunk.Positions = append(c.chunk.Positions, position)
+		c.chunk.Spans = append(c.chunk.Spans, span)
+	}
+	return offset
+}
+
+func (c *Compiler) setOperand(offset int, operand int) {
+	if operand < 0 || operand > MAX_OPERAND {
+		panic(fmt.Errorf("function %s is too large to be compiled", c.function.Name))
+	}
+	c.chunk.Code[offset] = byte(operand >> 8)
+	c.chunk.Code[offset+1] = byte(operand)
+}
+
+// points the first operand of the jump at the end of the code
+func (c *Compiler) patchJump(offset int) {
+	c.setOperand(offset+1, len(c.chunk.Code))
+}
+
+func (c *Compiler) constant(value any) int {
+	c.chunk.Constants = append(c.chunk.Constants, value)
+	return len(c.chunk.Constants) - 1
+}
+
+func (c *Compiler) openScope(outer bool, names []string) *compilerScope {
+	scope := &compilerScope{
+		parent: c.scope,
+		index:  len(c.chunk.Scopes),
+		first:  c.nextSlot,
+		names:  map[string]int{},
+		outer:  outer,
+	}
+	info := &scopeInfo{Parent: -1, Names: scope.names}
+	if c.scope != nil {
+		info.Parent = c.scope.index
+	}
+	c.chunk.Scopes = append(c.chunk.Scopes, info)
+
+	if !outer {
+		for _, name := range names {
+			if _, ok := scope.names[name]; !ok {
+				scope.names[name] = c.nextSlot
+				c.nextSlot++
+			}
+		}
+	}
+	c.function.Locals = max(c.function.Locals, c.nextSlot)
+	c.scope = scope
+	return scope
+}
+
+// scopes of if, while and switch statements start empty every time they are entered,
+// they are counted by the machine like the scopes of the CodeVisitor
+func (c *Compiler) enterScope(position shared.Position, span shared.Span, names []string) {
+	scope := c.openScope(false, names)
+	c.emit(position, span, OP_ENTER_SCOPE, scope.first, c.nextSlot-scope.first)
+}
+
+// slots of a closed scope are reused by the next one
+func (c *Compiler) closeScope(position shared.Position, span shared.Span) {
+	c.nextSlot = c.scope.first
+	c.scope = c.scope.parent
+	c.emit(position, span, OP_LEAVE_SCOPE)
+}
+
+// names of variables declared directly in the blocks, nested
+// statements declare their variables in scopes of their own
+func DeclaredNames(blocks ...*ast.Block) []string {
+	names := []string{}
+	for _, block := range blocks {
+		if block == nil {
+			continue
+		}
+		for _, statement := range block.Statements {
+			switch statement := statement.(type) {
+			case *ast.Variable:
+				names = append(names, statement.Name)
+			case *ast.Block:
+				names = append(names, DeclaredNames(statement)...)
+			}
+		}
+	}
+	return names
+}
+
+func (c *Compiler) resolve(name string) int {
+	ref := &nameRef{Name: name, Scope: c.scope.index}
+	for scope := c.scope; scope != nil; scope = scope.parent {
+		if slot, ok := scope.names[name]; ok {
+			ref.Slots = append(ref.Slots, slot)
+		}
+	}
+	c.chunk.Names = append(c.chunk.Names, ref)
+	return len(c.chunk.Names) - 1
+}
+
+// statements leave the result register the way the CodeVisitor leaves LastResult
+func (c *Compiler) statement(statement ast.Node) {
+	switch statement.(type) {
+	case *ast.Variable, *ast.Assignment, *ast.Block, *ast.IfStatement,
+		*ast.WhileStatement, *ast.SwitchStatement, *ast.ReturnStatement:
+		statement.Accept(c)
+	default:
+		statement.Accept(c)
+		c.emit(shared.Position{}, shared.Span{}, OP_SET_RESULT)
+	}
+}
+
+func (c *Compiler) binary(left, right ast.Expression, position shared.Position, span shared.Span, op Opcode) {
+	left.Accept(c)
+	right.Accept(c)
+	c.emit(position, span, op)
+}
+
+func (c *Compiler) VisitIntExpression(intExp *ast.IntExpression) {
+	c.emit(intExp.Position, intExp.Span, OP_CONSTANT, c.constant(intExp.Value))
+}
+
+func (c *Compiler) VisitFloatExpression(floatExp *ast.FloatExpression) {
+	c.emit(floatExp.Position, floatExp.Span, OP_CONSTANT, c.constant(floatExp.Value))
+}
+
+func (c *Compiler) VisitStringExpression(strExp *ast.StringExpression) {
+	c.emit(strExp.Position, strExp.Span, OP_CONSTANT, c.constant(strExp.Value))
+}
+
+func (c *Compiler) VisitBoolExpression(boolExp *ast.BoolExpression) {
+	c.emit(boolExp.Position, boolExp.Span, OP_CONSTANT, c.constant(boolExp.Value))
+}
+
+func (c *Compiler) VisitIdentifier(idExp *ast.Identifier) {
+	ref := c.resolve(idExp.Name)
+	if slots := c.chunk.Names[ref].Slots; len(slots) == 1 && !c.function.IsFragment() {
+		c.emit(idExp.Position, idExp.Span, OP_LOAD_LOCAL, slots[0], ref)
+	} else {
+		c.emit(idExp.Position, idExp.Span, OP_LOAD, ref)
+	}
+}
+
+func (c *Compiler) VisitNegateExpression(negateExp *ast.NegateExpression) {
+	negateExp.Expression.Accept(c)
+	c.emit(negateExp.Position, negateExp.Span, OP_NEGATE)
+}
+
+func (c *Compiler) VisitCastExpression(castExp *ast.CastExpression) {
+	castExp.LeftExpression.Accept(c)
+	c.emit(castExp.Position, castExp.Span, OP_CAST, c.constant(castExp.TypeAnnotation))
+}
+
+func (c *Compiler) VisitMultiplyExpression(mulExp *ast.MultiplyExpression) {
+	c.binary(mulExp.LeftExpression, mulExp.RightExpression, mulExp.Position, mulExp.Span, OP_MULTIPLY)
+}
+
+func (c *Compiler) VisitDivideExpression(divExp *ast.DivideExpression) {
+	c.binary(divExp.LeftExpression, divExp.RightExpression, divExp.Position, divExp.Span, OP_DIVIDE)
+}
+
+func (c *Compiler) VisitSumExpression(sumExp *ast.SumExpression) {
+	c.binary(sumExp.LeftExpression, sumExp.RightExpression, sumExp.Position, sumExp.Span, OP_SUM)
+}
+
+func (c *Compiler) VisitSubstractExpression(subExp *ast.SubstractExpression) {
+	c.binary(subExp.LeftExpression, subExp.RightExpression, subExp.Position, subExp.Span, OP_SUBSTRACT)
+}
+
+func (c *Compiler) VisitEqualsExpression(eqExp *ast.EqualsExpression) {
+	c.binary(eqExp.LeftExpression, eqExp.RightExpression, eqExp.Position, eqExp.Span, OP_EQUALS)
+}
+
+func (c *Compiler) VisitNotEqualsExpression(neExp *ast.NotEqualsExpression) {
+	c.binary(neExp.LeftExpression, neExp.RightExpression, neExp.Position, neExp.Span, OP_NOT_EQUALS)
+}
+
+func (c *Compiler) VisitGreaterThanExpression(gtExp *ast.GreaterThanExpression) {
+	c.binary(gtExp.LeftExpression, gtExp.RightExpression, gtExp.Position, gtExp.Span, OP_GREATER_THAN)
+}
+
+func (c *Compiler) VisitGreaterOrEqualExpression(geExp *ast.GreaterOrEqualExpression) {
+	c.binary(geExp.LeftExpression, geExp.RightExpression, geExp.Position, geExp.Span, OP_GREATER_OR_EQUAL)
+}
+
+func (c *Compiler) VisitLessThanExpression(ltExp *ast.LessThanExpression) {
+	c.binary(ltExp.LeftExpression, ltExp.RightExpression, ltExp.Position, ltExp.Span, OP_LESS_THAN)
+}
+
+func (c *Compiler) VisitLessOrEqualExpression(leExp *ast.LessOrEqualExpression) {
+	c.binary(leExp.LeftExpression, leExp.RightExpression, leExp.Position, leExp.Span, OP_LESS_OR_EQUAL)
+}
+
+func (c *Compiler) VisitOrExpression(orExp *ast.OrExpression) {
+	orExp.LeftExpression.Accept(c)
+	jump := c.emit(orExp.Position, orExp.Span, OP_OR, 0)
+	orExp.RightExpression.Accept(c)
+	c.emit(orExp.Position, orExp.Span, OP_EXPECT_BOOL)
+	c.patchJump(jump)
+}
+
+func (c *Compiler) VisitAndExpression(andExp *ast.AndExpression) {
+	andExp.LeftExpression.Accept(c)
+	jump := c.emit(andExp.Position, andExp.Span, OP_AND, 0)
+	andExp.RightExpression.Accept(c)
+	c.emit(andExp.Position, andExp.Span, OP_EXPECT_BOOL)
+	c.patchJump(jump)
+}
+
+func (c *Compiler) VisitAssignement(assignment *ast.Assignment) {
+	assignment.Value.Accept(c)
+	c.emit(assignment.Identifier.Position, assignment.Identifier.Span, OP_STORE, c.resolve(assignment.Identifier.Name))
+}
+
+func (c *Compiler) VisitVariable(varDecl *ast.Variable) {
+	varDecl.Value.Accept(c)
+
+	decl := &declaration{Name: varDecl.Name, Type: varDecl.Type, Slot: -1, Position: varDecl.Position, Span: varDecl.Span}
+	if !c.scope.outer {
+		decl.Slot = c.scope.names[varDecl.Name]
+	}
+	c.chunk.Declarations = append(c.chunk.Declarations, decl)
+	c.emit(varDecl.Position, varDecl.Span, OP_DECLARE, len(c.chunk.Declarations)-1)
+}
+
+func (c *Compiler) VisitBlock(block *ast.Block) {
+	for _, statement := range block.Statements {
+		c.statement(statement)
+	}
+}
+
+func (c *Compiler) VisitIfStatement(ifStmt *ast.IfStatement) {
+	position := ifStmt.Condition.GetPosition()
+	span := ifStmt.Condition.GetSpan()
+	c.enterScope(position, span, DeclaredNames(ifStmt.InstructionsBlock, ifStmt.ElseInstructionsBlock))
+
+	ifStmt.Condition.Accept(c)
+	elseJump := c.emit(position, span, OP_JUMP_IF_FALSE, 0, int(ERR_EXPECTED_BOOLEAN_EXPRESSION))
+	ifStmt.InstructionsBlock.Accept(c)
+	if ifStmt.ElseInstructionsBlock != nil {
+		endJump := c.emit(position, span, OP_JUMP, 0)
+		c.patchJump(elseJump)
+		ifStmt.ElseInstructionsBlock.Accept(c)
+		c.patchJump(endJump)
+	} else {
+		c.patchJump(elseJump)
+	}
+
+	c.closeScope(position, span)
+	c.emit(position, span, OP_CLEAR_RESULT)
+}
+
+func (c *Compiler) VisitReturnStatement(returnStmt *ast.ReturnStatement) {
+	if returnStmt.Value != nil {
+		returnStmt.Value.Accept(c)
+	} else {
+		c.emit(shared.Position{}, shared.Span{}, OP_NIL)
+	}
+	c.emitReturn(shared.Position{}, shared.Span{})
+}
+
+// returning from inside of a switch clears the switch flag,
+// like leaving every switch statement on the way out does
+func (c *Compiler) emitReturn(position shared.Position, span shared.Span) {
+	c.emit(position, span, OP_RETURN, c.resetSwitch())
+}
+
+func (c *Compiler) resetSwitch() int {
+	if c.switchDepth > 0 {
+		return 1
+	}
+	return 0
+}
+
+func (c *Compiler) VisitWhileStatement(whileStmt *ast.WhileStatement) {
+	position := whileStmt.Condition.GetPosition()
+	span := whileStmt.Condition.GetSpan()
+	c.enterScope(position, span, DeclaredNames(whileStmt.InstructionsBlock))
+
+	loop := len(c.chunk.Code)
+	whileStmt.Condition.Accept(c)
+	exitJump := c.emit(position, span, OP_JUMP_IF_FALSE, 0, int(ERR_INVALID_WHILE_CONDITION))
+	c.emit(position, span, OP_STEP)
+	whileStmt.InstructionsBlock.Accept(c)
+	c.emit(position, span, OP_JUMP, loop)
+	c.patchJump(exitJump)
+
+	c.closeScope(position, span)
+	c.emit(position, span, OP_CLEAR_RESULT)
+}
+
+// the arm of a case that was met ends the switch, an arm
+// leaving a value in the result register returns it
+func (c *Compiler) arm(output ast.Expression, position shared.Position, span shared.Span) {
+	c.statement(output)
+	c.emit(position, span, OP_ARM_END)
+}
+
+func (c *Compiler) VisitSwitchStatement(s *ast.SwitchStatement) {
+	names := []string{}
+	for _, variable := range s.Variables {
+		names = append(names, variable.Name)
+	}
+	for _, switchCase := range s.Cases {
+		names = append(names, CaseDeclaredNames(switchCase)...)
+	}
+	c.enterScope(s.Position, s.Span, names)
+	c.switchDepth++
+
+	for _, variable := range s.Variables {
+		variable.Accept(c)
+	}
+
+	var defaultCase *ast.DefaultSwitchCase
+	for _, switchCase := range s.Cases {
+		switch caseStmt := switchCase.(type) {
+		case *ast.SwitchCase:
+			caseStmt.Accept(c)
+		case *ast.DefaultSwitchCase:
+			if defaultCase != nil {
+				c.emit(defaultCase.GetPosition(), defaultCase.GetSpan(), OP_FAIL, int(ERR_MULTIPLE_DEFAULT_CASES))
+			}
+			defaultCase = caseStmt
+		default:
+			c.emit(caseStmt.GetPosition(), caseStmt.GetSpan(), OP_FAIL, int(ERR_INVALID_CASE_TYPE))
+		}
+	}
+
+	// run default only after cases did not get executed
+	if defaultCase != nil {
+		skip := c.emit(defaultCase.Position, defaultCase.Span, OP_JUMP_IF_SWITCH_ENDED, 0)
+		defaultCase.Accept(c)
+		c.patchJump(skip)
+	}
+
+	c.switchDepth--
+	c.closeScope(s.Position, s.Span)
+	c.emit(s.Position, s.Span, OP_SWITCH_END)
+	c.emit(s.Position, s.Span, OP_CLEAR_RESULT)
+}
+
+func (c *Compiler) VisitSwitchCase(sc *ast.SwitchCase) {
+	position := sc.Condition.GetPosition()
+	span := sc.Condition.GetSpan()
+	sc.Condition.Accept(c)
+	skip := c.emit(position, span, OP_JUMP_IF_FALSE, 0, int(ERR_EXPECTED_BOOLEAN_EXPRESSION))
+	c.arm(sc.OutputExpression, sc.Position, sc.Span)
+	c.patchJump(skip)
+	c.emit(sc.Position, sc.Span, OP_CLEAR_RESULT)
+}
+
+func (c *Compiler) VisitDefaultSwitchCase(dsc *ast.DefaultSwitchCase) {
+	c.arm(dsc.OutputExpression, dsc.Position, dsc.Span)
+}
+
+// calls in tail position of the compiled function return from it
+// and run its code again, see OP_TAIL_CALL
+func (c *Compiler) VisitFunctionCall(fc *ast.FunctionCall) {
+	tail := fc.Tail && !c.function.IsFragment() && fc.Name == c.function.Name
+	c.chunk.Calls = append(c.chunk.Calls, &callSite{Call: fc, Scope: c.scope.index, Tail: tail})
+	call := len(c.chunk.Calls) - 1
+
+	c.emit(fc.Position, fc.Span, OP_ENTER, call)
+	for _, arg := range fc.Arguments {
+		arg.Accept(c)
+	}
+	if tail {
+		c.emit(fc.Position, fc.Span, OP_TAIL_CALL, call, c.resetSwitch())
+	} else {
+		c.emit(fc.Position, fc.Span, OP_CALL, call)
+	}
+}
+
+// functions are compiled on their first call, see Compile
+func (c *Compiler) VisitFunctionDefinition(fd *ast.FunctionDefinition) {}
+
+func (c *Compiler) VisitEmbeddedFunction(ef *ast.EmbeddedFunction) {}
+
+func (c *Compiler) VisitProgram(e *ast.Program) {}
@@ -0,0 +1,345 @@
+package interpreter
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"tkom/ast"
+	"tkom/shared"
+)
+
+// kinds of branches, the branches of an if are taken when its condition is
+// true and false, also without an else block, a while has the branch of running
+// its body and the one of its condition becoming false
+const (
+	BRANCH_IF         = "if branch"
+	BRANCH_ELSE       = "else branch"
+	BRANCH_WHILE_BODY = "while body"
+	BRANCH_WHILE_EXIT = "while exit"
+	BRANCH_CASE       = "switch case"
+	BRANCH_DEFAULT    = "default case"
+)
+
+// records the statements run and the branches taken by a program of one source
+// file, set as CodeVisitor.Coverage
+//
+// a return without a value has no position and is not counted
+type Coverage struct {
+	Path string
+	// statements of the program in the order of the source
+	Statements []*CoveredStatement
+	// branches of the if, while and switch statements in the order of the source
+	Branches []*CoveredBranch
+
+	statements map[ast.Statement]*CoveredStatement
+	branches   map[branchKey]*CoveredBranch
+}
+
+type CoveredStatement struct {
+	Position shared.Position
+	Hits     int
+}
+
+type CoveredBranch struct {
+	Kind string
+	// condition of the if or while, or the switch case
+	Position shared.Position
+	// number of the if, while or switch in the order of the source,
+	// shared by its branches
+	Decision int
+	// number of the branch in its decision
+	Index int
+	Hits  int
+}
+
+type branchKey struct {
+	node  ast.Node
+	index int
+}
+
+func NewCoverage(program *ast.Program, path string) *Coverage {
+	c := &Coverage{
+		Path:       path,
+		statements: map[ast.Statement]*CoveredStatement{},
+		branches:   map[branchKey]*CoveredBranch{},
+	}
+	type decision struct {
+		position  shared.Position
+		statement ast.Statement
+	}
+	var decisions []decision
+	for _, f := range program.Functions {
+		walkStatements(f.Block, func(statement ast.Statement) {
+			if position := StatementPosition(statement); position.Line > 0 {
+				covered := &CoveredStatement{Position: position}
+				c.statements[statement] = covered
+				c.Statements = append(c.Statements, covered)
+			}
+			switch s := statement.(type) {
+			case *ast.IfStatement:
+				decisions = append(decisions, decision{s.Condition.GetPosition(), s})
+			case *ast.WhileStatement:
+				decisions = append(decisions, decision{s.Condition.GetPosition(), s})
+			case *ast.SwitchStatement:
+				decisions = append(decisions, decision{s.Position, s})
+			}
+		})
+	}
+	sort.Slice(c.Statements, func(i, j int) bool {
+		return before(c.Statements[i].Position, c.Statements[j].Position)
+	})
+	sort.Slice(decisions, func(i, j int) bool {
+		return before(decisions[i].position, decisions[j].position)
+	})
+
+	for number, d := range decisions {
+		add := func(key branchKey, kind string, position shared.Position, index int) {
+			branch := &CoveredBranch{Kind: kind, Position: position, Decision: number, Index: index}
+			c.branches[key] = branch
+			c.Branches = append(c.Branches, branch)
+		}
+		switch s := d.statement.(type) {
+		case *ast.IfStatement:
+			add(branchKey{s, 0}, BRANCH_IF, d.position, 0)
+			add(branchKey{s, 1}, BRANCH_ELSE, d.position, 1)
+		case *ast.WhileStatement:
+			add(branchKey{s, 0}, BRANCH_WHILE_BODY, d.position, 0)
+			add(branchKey{s, 1}, BRANCH_WHILE_EXIT, d.position, 1)
+		case *ast.SwitchStatement:
+			for i, switchCase := range s.Cases {
+				kind := BRANCH_CASE
+				if _, ok := switchCase.(*ast.DefaultSwitchCase); ok {
+					kind = BRANCH_DEFAULT
+				}
+				add(branchKey{switchCase, 0}, kind, switchCase.GetPosition(), i)
+			}
+		}
+	}
+	return c
+}
+
+func before(a, b shared.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+func (c *Coverage) statement(statement ast.Statement) {
+	if c == nil {
+		return
+	}
+	if covered, ok := c.statements[statement]; ok {
+		covered.Hits++
+	}
+}
+
+// takes the branch of the index of the if or while, a switch case is the branch 0 of itself
+func (c *Coverage) branch(node ast.Node, index int) {
+	if c == nil {
+		return
+	}
+	if branch, ok := c.branches[branchKey{node, index}]; ok {
+		branch.Hits++
+	}
+}
+
+// numbers of the statements run and of all of them
+func (c *Coverage) StatementsRun() (int, int) {
+	run := 0
+	for _, s := range c.Statements {
+		if s.Hits > 0 {
+			run++
+		}
+	}
+	return run, len(c.Statements)
+}
+
+// numbers of the branches taken and of all of them
+func (c *Coverage) BranchesTaken() (int, int) {
+	taken := 0
+	for _, b := range c.Branches {
+		if b.Hits > 0 {
+			taken++
+		}
+	}
+	return taken, len(c.Branches)
+}
+
+// percent of the part of the total, everything is covered when there is nothing to cover
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(part) / float64(total)
+}
+
+func (c *Coverage) summary() string {
+	run, statements := c.StatementsRun()
+	taken, branches := c.BranchesTaken()
+	return fmt.Sprintf("%.1f%% of statements (%d/%d), %.1f%% of branches (%d/%d)",
+		percent(run, statements), run, statements, percent(taken, branches), taken, branches)
+}
+
+// prints the percents of the statements run and the branches taken,
+// followed by the statements not run and the branches not taken
+func (c *Coverage) WriteSummary(w io.Writer) error {
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "%s: %s\n", c.Path, c.summary())
+	for _, s := range c.Statements {
+		if s.Hits == 0 {
+			fmt.Fprintf(&summary, "%s:%d:%d: statement not run\n", c.Path, s.Position.Line, s.Position.Column)
+		}
+	}
+	for _, b := range c.Branches {
+		if b.Hits == 0 {
+			fmt.Fprintf(&summary, "%s:%d:%d: %s not taken\n", c.Path, b.Position.Line, b.Position.Column, b.Kind)
+		}
+	}
+	_, err := io.WriteString(w, summary.String())
+	return err
+}
+
+// hits of every line with statements, the most run statement of the line counts
+func (c *Coverage) lineHits() map[int]int {
+	lines := map[int]int{}
+	for _, s := range c.Statements {
+		if hits, ok := lines[s.Position.Line]; !ok || s.Hits > hits {
+			lines[s.Position.Line] = s.Hits
+		}
+	}
+	return lines
+}
+
+// writes the record of the source file in the LCOV tracefile format, records of
+// many files can be written one after another
+func (c *Coverage) WriteLcov(w io.Writer) error {
+	fmt.Fprintf(w, "TN:\nSF:%s\n", c.Path)
+
+	// a branch of a decision that never ran is reported as -
+	decisionHits := map[int]int{}
+	for _, b := range c.Branches {
+		decisionHits[b.Decision] += b.Hits
+	}
+	taken, branches := c.BranchesTaken()
+	for _, b := range c.Branches {
+		hits := "-"
+		if decisionHits[b.Decision] > 0 {
+			hits = fmt.Sprint(b.Hits)
+		}
+		fmt.Fprintf(w, "BRDA:%d,%d,%d,%s\n", b.Position.Line, b.Decision, b.Index, hits)
+	}
+	fmt.Fprintf(w, "BRF:%d\nBRH:%d\n", branches, taken)
+
+	lines := c.lineHits()
+	numbers := make([]int, 0, len(lines))
+	hit := 0
+	for line, hits := range lines {
+		numbers = append(numbers, line)
+		if hits > 0 {
+			hit++
+		}
+	}
+	sort.Ints(numbers)
+	for _, line := range numbers {
+		fmt.Fprintf(w, "DA:%d,%d\n", line, lines[line])
+	}
+	_, err := fmt.Fprintf(w, "LF:%d\nLH:%d\nend_of_record\n", len(numbers), hit)
+	return err
+}
+
+type htmlLine struct {
+	Number int
+	Hits   string
+	// covered, partial or uncovered for lines with statements or branches
+	Class string
+	// branches of the line with their hits
+	Title string
+	Code  string
+}
+
+var htmlReport = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Path}} coverage</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; font-family: monospace; }
+td { padding: 0 8px; white-space: pre; }
+.number, .hits { text-align: right; color: #888; }
+.covered { background: #dfd; }
+.partial { background: #ffd; }
+.uncovered { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>{{.Path}}</h1>
+<p>{{.Summary}}</p>
+<table>
+{{range .Lines}}<tr{{if .Class}} class="{{.Class}}"{{end}}{{if .Title}} title="{{.Title}}"{{end}}><td class="number">{{.Number}}</td><td class="hits">{{.Hits}}</td><td>{{.Code}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// writes the page of the source with every line marked covered when all of its
+// statements ran and branches were taken, partial when some of them and
+// uncovered when none
+func (c *Coverage) WriteHTML(w io.Writer, source []string) error {
+	lines := make([]htmlLine, len(source))
+	run := make([]int, len(source))
+	total := make([]int, len(source))
+	for i, code := range source {
+		lines[i] = htmlLine{Number: i + 1, Code: code}
+	}
+	// lines of switch cases have no statements, they show the hits of their branches
+	hits := map[int]int{}
+	for _, b := range c.Branches {
+		hits[b.Position.Line] += b.Hits
+	}
+	for line, statementHits := range c.lineHits() {
+		hits[line] = statementHits
+	}
+	for line, lineHits := range hits {
+		if line > 0 && line <= len(lines) {
+			lines[line-1].Hits = fmt.Sprint(lineHits)
+		}
+	}
+	for _, s := range c.Statements {
+		if i := s.Position.Line - 1; i >= 0 && i < len(lines) {
+			total[i]++
+			if s.Hits > 0 {
+				run[i]++
+			}
+		}
+	}
+	for _, b := range c.Branches {
+		if i := b.Position.Line - 1; i >= 0 && i < len(lines) {
+			total[i]++
+			if b.Hits > 0 {
+				run[i]++
+			}
+			if lines[i].Title != "" {
+				lines[i].Title += ", "
+			}
+			lines[i].Title += fmt.Sprintf("%s taken %d times", b.Kind, b.Hits)
+		}
+	}
+	for i := range lines {
+		switch {
+		case total[i] == 0:
+		case run[i] == total[i]:
+			lines[i].Class = "covered"
+		case run[i] > 0:
+			lines[i].Class = "partial"
+		default:
+			lines[i].Class = "uncovered"
+		}
+	}
+
+	return htmlReport.Execute(w, struct {
+		Path    string
+		Summary string
+		Lines   []htmlLine
+	}{c.Path, c.summary(), lines})
+}
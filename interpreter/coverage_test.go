@@ -0,0 +1,173 @@
+package interpreter
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"tkom/ast"
+	"tkom/shared"
+)
+
+const coverageSource = `main() {
+    int i := 0
+    while i < 2 {
+        i = i + 1
+    }
+    if i > 5 {
+        print("big")
+    }
+    print(size(i))
+}
+
+size(n int) string {
+    switch {
+        n == 0 => "none",
+        n < 5  => "few",
+        default => "many"
+    }
+}
+`
+
+func coverageRun(t *testing.T) *Coverage {
+	t.Helper()
+	program := parseProgram(t, coverageSource)
+	ResolveProgram(program)
+	visitor := NewCodeVisitor(MAX_RECURSION_DEPTH)
+	coverage := NewCoverage(program, "main.fl")
+	visitor.Coverage = coverage
+	Output = io.Discard
+	defer func() { Output = nil }()
+	visitor.Run(program, &ast.FunctionCall{Name: "main", Position: shared.NewPosition(1, 1)})
+	return coverage
+}
+
+func TestCoverageStatements(t *testing.T) {
+	coverage := coverageRun(t)
+
+	expected := map[int]int{2: 1, 3: 1, 4: 2, 6: 1, 7: 0, 9: 1, 13: 1}
+	if len(coverage.Statements) != len(expected) {
+		t.Errorf("expected %d statements, got %d", len(expected), len(coverage.Statements))
+	}
+	for i, s := range coverage.Statements {
+		if hits, ok := expected[s.Position.Line]; !ok || hits != s.Hits {
+			t.Errorf("expected %d hits of the statement at line %d, got %d", hits, s.Position.Line, s.Hits)
+		}
+		if i > 0 && !before(coverage.Statements[i-1].Position, s.Position) {
+			t.Errorf("expected statements in the order of the source")
+		}
+	}
+	if run, total := coverage.StatementsRun(); run != 6 || total != 7 {
+		t.Errorf("expected 6 of 7 statements run, got %d of %d", run, total)
+	}
+}
+
+func TestCoverageBranches(t *testing.T) {
+	coverage := coverageRun(t)
+
+	expected := []CoveredBranch{
+		{Kind: BRANCH_WHILE_BODY, Decision: 0, Index: 0, Hits: 2},
+		{Kind: BRANCH_WHILE_EXIT, Decision: 0, Index: 1, Hits: 1},
+		{Kind: BRANCH_IF, Decision: 1, Index: 0, Hits: 0},
+		{Kind: BRANCH_ELSE, Decision: 1, Index: 1, Hits: 1},
+		{Kind: BRANCH_CASE, Decision: 2, Index: 0, Hits: 0},
+		{Kind: BRANCH_CASE, Decision: 2, Index: 1, Hits: 1},
+		{Kind: BRANCH_DEFAULT, Decision: 2, Index: 2, Hits: 0},
+	}
+	if len(coverage.Branches) != len(expected) {
+		t.Fatalf("expected %d branches, got %d", len(expected), len(coverage.Branches))
+	}
+	for i, b := range coverage.Branches {
+		got := *b
+		got.Position = shared.Position{}
+		if got != expected[i] {
+			t.Errorf("expected branch %+v, got %+v", expected[i], got)
+		}
+	}
+	if taken, total := coverage.BranchesTaken(); taken != 4 || total != 7 {
+		t.Errorf("expected 4 of 7 branches taken, got %d of %d", taken, total)
+	}
+}
+
+// a return in the body of a while leaves it without taking its exit
+func TestCoverageWhileLeftByReturn(t *testing.T) {
+	program := parseProgram(t, "main() int {\n    while true {\n        return 1\n    }\n    return 0\n}\n")
+	ResolveProgram(program)
+	visitor := NewCodeVisitor(MAX_RECURSION_DEPTH)
+	coverage := NewCoverage(program, "main.fl")
+	visitor.Coverage = coverage
+	visitor.Run(program, &ast.FunctionCall{Name: "main"})
+
+	if coverage.Branches[0].Hits != 1 || coverage.Branches[1].Hits != 0 {
+		t.Errorf("expected the body taken and the exit not, got %d and %d", coverage.Branches[0].Hits, coverage.Branches[1].Hits)
+	}
+}
+
+func TestCoverageSummary(t *testing.T) {
+	coverage := coverageRun(t)
+	var out strings.Builder
+	if err := coverage.WriteSummary(&out); err != nil {
+		t.Fatal(err)
+	}
+	expected := `main.fl: 85.7% of statements (6/7), 57.1% of branches (4/7)
+main.fl:7:9: statement not run
+main.fl:6:10: if branch not taken
+main.fl:14:16: switch case not taken
+main.fl:16:17: default case not taken
+`
+	if out.String() != expected {
+		t.Errorf("expected summary:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestCoverageLcov(t *testing.T) {
+	coverage := coverageRun(t)
+	var out strings.Builder
+	if err := coverage.WriteLcov(&out); err != nil {
+		t.Fatal(err)
+	}
+	expected := `TN:
+SF:main.fl
+BRDA:3,0,0,2
+BRDA:3,0,1,1
+BRDA:6,1,0,0
+BRDA:6,1,1,1
+BRDA:14,2,0,0
+BRDA:15,2,1,1
+BRDA:16,2,2,0
+BRF:7
+BRH:4
+DA:2,1
+DA:3,1
+DA:4,2
+DA:6,1
+DA:7,0
+DA:9,1
+DA:13,1
+LF:7
+LH:6
+end_of_record
+`
+	if out.String() != expected {
+		t.Errorf("expected LCOV:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestCoverageHTML(t *testing.T) {
+	coverage := coverageRun(t)
+	var out strings.Builder
+	if err := coverage.WriteHTML(&out, strings.Split(coverageSource, "\n")); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"<title>main.fl coverage</title>",
+		`<tr class="covered"><td class="number">4</td><td class="hits">2</td><td>        i = i &#43; 1</td></tr>`,
+		`<tr class="partial" title="if branch taken 0 times, else branch taken 1 times"><td class="number">6</td>`,
+		`<tr class="uncovered"><td class="number">7</td><td class="hits">0</td>`,
+		`<tr class="uncovered" title="default case taken 0 times"><td class="number">16</td><td class="hits">0</td>`,
+		`<tr><td class="number">10</td><td class="hits"></td><td>}</td></tr>`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in the report:\n%s", expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"tkom/ast"
+	"tkom/shared"
+)
+
+// pauses the running program, e.g. on breakpoints, set as CodeVisitor.Debugger
+type Debugger interface {
+	// called before the statement or the switch case at the position runs,
+	// the visitor can be inspected until Pause returns
+	Pause(v *CodeVisitor, position shared.Position)
+}
+
+func (v *CodeVisitor) pause(position shared.Position) {
+	if v.Debugger != nil && position != (shared.Position{}) {
+		v.Debugger.Pause(v, position)
+	}
+}
+
+// position the debugger pauses at before the statement runs,
+// a return without a value has none and is never paused at
+func StatementPosition(statement ast.Statement) shared.Position {
+	switch s := statement.(type) {
+	case *ast.Variable:
+		return s.Position
+	case *ast.Assignment:
+		return s.Identifier.Position
+	case *ast.IfStatement:
+		return s.Condition.GetPosition()
+	case *ast.WhileStatement:
+		return s.Condition.GetPosition()
+	case *ast.ReturnStatement:
+		if s.Value != nil {
+			return s.Value.GetPosition()
+		}
+	case ast.Expression:
+		return s.GetPosition()
+	}
+	return shared.Position{}
+}
+
+// sorted lines of the statements and switch cases the program can pause at
+func StatementLines(program *ast.Program) []int {
+	found := map[int]bool{}
+	for _, f := range program.Functions {
+		walkStatements(f.Block, func(statement ast.Statement) {
+			if position := StatementPosition(statement); position.Line > 0 {
+				found[position.Line] = true
+			}
+			if s, ok := statement.(*ast.SwitchStatement); ok {
+				for _, c := range s.Cases {
+					found[c.GetPosition().Line] = true
+				}
+			}
+		})
+	}
+
+	lines := []int{}
+	for line := range found {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// calls visit with every statement of the block and of the blocks nested in it,
+// a statement comes before the statements of its blocks
+func walkStatements(b *ast.Block, visit func(statement ast.Statement)) {
+	for _, statement := range b.Statements {
+		visit(statement)
+		switch s := statement.(type) {
+		case *ast.IfStatement:
+			walkStatements(s.InstructionsBlock, visit)
+			if s.ElseInstructionsBlock != nil {
+				walkStatements(s.ElseInstructionsBlock, visit)
+			}
+		case *ast.WhileStatement:
+			walkStatements(s.InstructionsBlock, visit)
+		case *ast.SwitchStatement:
+			for _, c := range s.Cases {
+				var output ast.Expression
+				switch c := c.(type) {
+				case *ast.SwitchCase:
+					output = c.OutputExpression
+				case *ast.DefaultSwitchCase:
+					output = c.OutputExpression
+				}
+				if b, ok := output.(*ast.Block); ok {
+					walkStatements(b, visit)
+				}
+			}
+		}
+	}
+}
+
+// returns the variables declared in the scope in the order of declaration
+func (s *Scope) Variables() ([]string, []any) {
+	names, values := []string{}, []any{}
+	for i, name := range s.names {
+		if s.values[i] != nil {
+			names = append(names, name)
+			values = append(values, s.values[i])
+		}
+	}
+	return names, values
+}
+
+// returns the innermost scope of every frame of the call stack, in the order
+// of CallStack.Frames, builtins and calls still evaluating their arguments
+// have no scope and get nil
+func (v *CodeVisitor) FrameScopes() []*Scope {
+	// a scope without parent starts the body of a function,
+	// the last scope before the next one is the innermost of the call
+	var innermost []*Scope
+	for _, scope := range append(append([]*Scope{}, v.ScopeStack.elem...), v.CurrentScope) {
+		if scope == nil {
+			continue
+		}
+		if scope.Parent == nil {
+			innermost = append(innermost, scope)
+		} else if len(innermost) > 0 {
+			innermost[len(innermost)-1] = scope
+		}
+	}
+
+	frames := v.CallStack.Frames()
+	scopes := make([]*Scope, len(frames))
+	for i := len(frames) - 1; i >= 0 && len(innermost) > 0; i-- {
+		if _, builtin := v.FunctionsMap[frames[i].Function].(*ast.EmbeddedFunction); builtin || frames[i].Arguments == nil {
+			continue
+		}
+		scopes[i] = innermost[len(innermost)-1]
+		innermost = innermost[:len(innermost)-1]
+	}
+	return scopes
+}
+
+// evaluates the expression in the scope without changing the state of the
+// running program, functions it calls run without the debugger
+func (v *CodeVisitor) Evaluate(expression ast.Expression, scope *Scope) (value any, err error) {
+	evaluator := NewCodeVisitor(v.MaxRecursionDepth)
+	evaluator.FunctionsMap = v.FunctionsMap
+	evaluator.CurrentScope = scope
+	evaluator.Budget = v.Budget
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+	expression.Accept(evaluator)
+	return evaluator.LastResult, nil
+}
@@ -0,0 +1,184 @@
+package interpreter
+
+import (
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"tkom/ast"
+	"tkom/lexer"
+	"tkom/parser"
+	"tkom/shared"
+)
+
+const debuggerSource = `
+main() {
+    int n := 2
+    int r := sign(n)
+    print(r)
+}
+
+sign(x int) int {
+    if x > 0 {
+        int y := x * 2
+        return y
+    }
+    switch {
+        x == 0 => 0,
+        default => -1
+    }
+}
+`
+
+type pause struct {
+	line  int
+	depth int
+}
+
+// records the pauses and the variables of the frames at one line
+type recordingDebugger struct {
+	pauses    []pause
+	line      int
+	variables [][]string
+}
+
+func (d *recordingDebugger) Pause(v *CodeVisitor, position shared.Position) {
+	d.pauses = append(d.pauses, pause{position.Line, v.CallStack.Depth()})
+	if position.Line != d.line {
+		return
+	}
+	for _, scope := range v.FrameScopes() {
+		var names []string
+		for s := scope; s != nil; s = s.Parent {
+			variables, _ := s.Variables()
+			names = append(names, variables...)
+		}
+		d.variables = append(d.variables, names)
+	}
+}
+
+func debuggerVisitor(t *testing.T, debugger Debugger) (*CodeVisitor, *ast.Program) {
+	program := parseProgram(t, debuggerSource)
+	ResolveProgram(program)
+	visitor := NewCodeVisitor(MAX_RECURSION_DEPTH)
+	visitor.Debugger = debugger
+	return visitor, program
+}
+
+func TestDebuggerPausesBeforeStatements(t *testing.T) {
+	debugger := &recordingDebugger{line: 11}
+	visitor, program := debuggerVisitor(t, debugger)
+	Output = io.Discard
+	defer func() { Output = nil }()
+	visitor.Run(program, &ast.FunctionCall{Name: "main", Position: shared.NewPosition(1, 1)})
+
+	expected := []pause{{3, 1}, {4, 1}, {9, 2}, {10, 2}, {11, 2}, {5, 1}}
+	if !reflect.DeepEqual(debugger.pauses, expected) {
+		t.Errorf("expected pauses %v, got %v", expected, debugger.pauses)
+	}
+	// print has no scope of its own
+	expectedVariables := [][]string{{"n"}, {"y", "x"}}
+	if !reflect.DeepEqual(debugger.variables, expectedVariables) {
+		t.Errorf("expected variables %v, got %v", expectedVariables, debugger.variables)
+	}
+}
+
+func TestDebuggerPausesBeforeSwitchCases(t *testing.T) {
+	debugger := &recordingDebugger{}
+	visitor, program := debuggerVisitor(t, debugger)
+	visitor.Run(program, &ast.FunctionCall{Name: "sign", Arguments: []ast.Expression{&ast.IntExpression{Value: -1}}, Position: shared.NewPosition(1, 1)})
+
+	expected := []pause{{9, 1}, {13, 1}, {14, 1}, {15, 1}}
+	if !reflect.DeepEqual(debugger.pauses, expected) {
+		t.Errorf("expected pauses %v, got %v", expected, debugger.pauses)
+	}
+}
+
+func TestFrameScopesOfArgumentsBeingEvaluated(t *testing.T) {
+	debugger := &recordingDebugger{line: 11}
+	visitor, program := debuggerVisitor(t, debugger)
+	// sign runs while the arguments of print are evaluated
+	call := &ast.FunctionCall{Name: "print", Arguments: []ast.Expression{
+		&ast.FunctionCall{Name: "sign", Arguments: []ast.Expression{&ast.IntExpression{Value: 1}}},
+	}}
+	Output = io.Discard
+	defer func() { Output = nil }()
+	visitor.Run(program, call)
+
+	expected := [][]string{nil, {"y", "x"}}
+	if !reflect.DeepEqual(debugger.variables, expected) {
+		t.Errorf("expected variables %v, got %v", expected, debugger.variables)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	visitor, program := debuggerVisitor(t, nil)
+	for name, fd := range program.Functions {
+		visitor.FunctionsMap[name] = fd
+	}
+	scope := NewScope(nil, nil)
+	scope.AddVariable("n", 3, shared.INT, shared.Position{})
+
+	tests := []struct {
+		expression string
+		expected   any
+	}{
+		{"n * 2", 6},
+		{"sign(n) == 6", true},
+		{"n as string + \"!\"", "3!"},
+	}
+	for _, test := range tests {
+		expression := parseExpression(t, test.expression)
+		value, err := visitor.Evaluate(expression, scope)
+		if err != nil || value != test.expected {
+			t.Errorf("%s: expected %v, got %v, %v", test.expression, test.expected, value, err)
+		}
+	}
+
+	if _, err := visitor.Evaluate(parseExpression(t, "m + 1"), scope); err == nil {
+		t.Errorf("expected an error of the undefined variable")
+	}
+	if visitor.LastResult != nil || visitor.CallStack.Depth() != 0 {
+		t.Errorf("expected the state of the visitor unchanged")
+	}
+}
+
+func parseExpression(t *testing.T, source string) ast.Expression {
+	t.Helper()
+	scanner, _ := lexer.NewScanner(strings.NewReader(source))
+	lex := lexer.NewLexer(scanner, 500, 1000, math.MaxInt)
+	errorHandler := func(err error) {
+		t.Fatalf("unexpected syntax error: %v", err)
+	}
+	lex.ErrorHandler = errorHandler
+	return parser.NewParser(lex, errorHandler).ParseExpressionInput()
+}
+
+func TestWatch(t *testing.T) {
+	outer := NewScope(nil, nil)
+	outer.AddVariable("n", 1, shared.INT, shared.Position{})
+	inner := NewScope(outer, nil)
+
+	var changes [][2]any
+	unwatch, ok := inner.Watch("n", func(old, value any) {
+		changes = append(changes, [2]any{old, value})
+	})
+	if !ok {
+		t.Fatal("expected n visible in the inner scope")
+	}
+	inner.SetValue("n", 2)
+	// setting the same value is not a change
+	inner.SetValue("n", 2)
+	inner.SetValueAt(ast.Slot{Depth: 1, Index: 0}, "n", 3)
+	unwatch()
+	outer.SetValue("n", 4)
+
+	expected := [][2]any{{1, 2}, {2, 3}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected changes %v, got %v", expected, changes)
+	}
+	if _, ok := inner.Watch("m", func(old, value any) {}); ok {
+		t.Errorf("expected m not visible")
+	}
+}
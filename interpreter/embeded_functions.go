@@ -2,20 +2,32 @@ package interpreter
 
 import (
 	"fmt"
+	"io"
 	"math"
+	"os"
 	"reflect"
 	"tkom/ast"
 	"tkom/shared"
 )
 
+// writer of print and println, nil for the standard output
+var Output io.Writer
+
+func output() io.Writer {
+	if Output == nil {
+		return os.Stdout
+	}
+	return Output
+}
+
 var PrintFunction = &ast.EmbeddedFunction{
-	Name: "println",
+	Name: "print",
 	Func: func(args ...any) any {
-		var output string
+		var text string
 		for _, arg := range args {
-			output += fmt.Sprintf("%v", arg)
+			text += fmt.Sprintf("%v", arg)
 		}
-		fmt.Println(output)
+		fmt.Fprintln(output(), text)
 		return nil
 	},
 	Parameters: []any{},
@@ -26,7 +38,7 @@ var PrintlnFunction = &ast.EmbeddedFunction{
 	Name: "println",
 	Func: func(args ...any) any {
 		for _, arg := range args {
-			fmt.Println(arg)
+			fmt.Fprintln(output(), arg)
 		}
 		return nil
 	},
@@ -40,11 +52,14 @@ var ModuloFunction = &ast.EmbeddedFunction{
 	Func: func(args ...any) any {
 		a := args[0].(int)
 		b := args[1].(int)
+		if b == 0 {
+			panic(NewSemanticErrorWithCode(ERR_DIVISION_BY_ZERO, shared.Position{}))
+		}
 		return a%b == 0
 	},
 	Parameters: []any{
-		shared.FLOAT,
-		shared.FLOAT,
+		shared.INT,
+		shared.INT,
 	},
 	Variadic: false,
 }
@@ -56,7 +71,7 @@ var SquareRootFunction = &ast.EmbeddedFunction{
 		if a, ok := args[0].(float64); ok {
 			return math.Sqrt(a)
 		} else {
-			panic(fmt.Errorf(INVALID_ARGUMENTS_TYPE, reflect.TypeOf(args[0])))
+			panic(NewSemanticErrorWithCode(ERR_INVALID_ARGUMENTS_TYPE, shared.Position{}, reflect.TypeOf(args[0])))
 		}
 	},
 	Parameters: []any{
@@ -81,10 +96,60 @@ var PowerFunction = &ast.EmbeddedFunction{
 	Variadic: false,
 }
 
+// fails the test calling it when the condition is false
+var AssertFunction = &ast.EmbeddedFunction{
+	Name: "assert",
+	Func: func(args ...any) any {
+		if !args[0].(bool) {
+			panic(NewSemanticErrorWithCode(ERR_ASSERTION_FAILED, shared.Position{}))
+		}
+		return nil
+	},
+	Parameters: []any{
+		shared.BOOL,
+	},
+	Variadic: false,
+}
+
+// fails the test calling it when the values differ, the values can be of any type
+var AssertEqualFunction = &ast.EmbeddedFunction{
+	Name: "assert_eq",
+	Func: func(args ...any) any {
+		if len(args) != 2 {
+			panic(NewSemanticErrorWithCode(ERR_WRONG_NUMBER_OF_ARGUMENTS, shared.Position{}, "assert_eq", 2, len(args)))
+		}
+		if args[0] != args[1] {
+			panic(NewSemanticErrorWithCode(ERR_ASSERTION_NOT_EQUAL, shared.Position{}, describeValue(args[0]), describeValue(args[1])))
+		}
+		return nil
+	},
+	Parameters: []any{},
+	Variadic:   true,
+}
+
+// value with its type, strings are quoted
+func describeValue(value any) string {
+	if s, ok := value.(string); ok {
+		return fmt.Sprintf("%q (%v)", s, determineType(value))
+	}
+	return fmt.Sprintf("%v (%v)", value, determineType(value))
+}
+
 var embeddedFunctions = map[string]ast.Function{
-	"print":   PrintFunction,
-	"println": PrintlnFunction,
-	"modulo":  ModuloFunction,
-	"sqrt":    SquareRootFunction,
-	"power":   PowerFunction,
+	"print":     PrintFunction,
+	"println":   PrintlnFunction,
+	"modulo":    ModuloFunction,
+	"sqrt":      SquareRootFunction,
+	"power":     PowerFunction,
+	"assert":    AssertFunction,
+	"assert_eq": AssertEqualFunction,
+}
+
+// copy of the builtins, for tools that list them without running a program
+func EmbeddedFunctions() map[string]ast.Function {
+	functions := make(map[string]ast.Function, len(embeddedFunctions))
+	for name, function := range embeddedFunctions {
+		functions[name] = function
+	}
+	return functions
 }
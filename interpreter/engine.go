@@ -0,0 +1,73 @@
+package interpreter
+
+import (
+	"fmt"
+	"tkom/ast"
+)
+
+const (
+	ENGINE_INTERPRETER = "interpreter"
+	ENGINE_VM          = "vm"
+)
+
+// runs a parsed program starting from the call,
+// errors are reported by panicking like in the rest of the interpreter
+type Engine interface {
+	Run(program *ast.Program, call *ast.FunctionCall)
+	// limits the steps and the time of the following runs, nil removes the limits
+	SetBudget(budget *Budget)
+	// limits the memory of the following runs, nil removes the limits
+	SetLimits(limits *Limits)
+}
+
+func NewEngine(name string, maxRecursionDepth int) (Engine, error) {
+	switch name {
+	case ENGINE_INTERPRETER:
+		return NewCodeVisitor(maxRecursionDepth), nil
+	case ENGINE_VM:
+		return NewVirtualMachine(maxRecursionDepth), nil
+	default:
+		return nil, fmt.Errorf("unknown engine: %s, expected %s or %s", name, ENGINE_INTERPRETER, ENGINE_VM)
+	}
+}
+
+// runs the program like Engine.Run, but returns the error it stopped with,
+// a program stopped by its budget returns *InterruptedError,
+// a program exceeding its limits returns *LimitError
+func RunProgram(engine Engine, program *ast.Program, call *ast.FunctionCall) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+	engine.Run(program, call)
+	return nil
+}
+
+func (v *CodeVisitor) SetBudget(budget *Budget) {
+	v.Budget = budget
+}
+
+func (m *VirtualMachine) SetBudget(budget *Budget) {
+	m.Budget = budget
+}
+
+func (v *CodeVisitor) SetLimits(limits *Limits) {
+	v.Limits = limits
+}
+
+func (m *VirtualMachine) SetLimits(limits *Limits) {
+	m.Limits = limits
+}
+
+// registers the functions of the program and runs the call by walking the tree
+func (v *CodeVisitor) Run(program *ast.Program, call *ast.FunctionCall) {
+	for name, fd := range program.Functions {
+		v.FunctionsMap[name] = fd
+	}
+	v.VisitFunctionCall(call)
+}
@@ -0,0 +1,163 @@
+package interpreter
+
+import "tkom/diagnostics"
+
+type explanation struct {
+	text    string
+	example string
+}
+
+// codes without an example are internal errors of the interpreter,
+// they cannot be triggered by a program that passed the parser
+var explanations = map[ErrorCode]explanation{
+	ERR_UNDEFINED_VARIABLE: {
+		text:    "A variable was used before it was declared, or outside of the block it was declared in.\nVariables live until the end of the block that declares them.",
+		example: "main() {\n    if true {\n        int a := 1\n    }\n    print(a)\n}",
+	},
+	ERR_UNDEFINED_FUNCTION: {
+		text:    "A function was called that is neither defined in the program nor built in.",
+		example: "main() {\n    printt(\"hello\")\n}",
+	},
+	ERR_REDECLARED_VARIABLE: {
+		text:    "A variable with the same name was already declared in this block.\nTo change its value use assignment with '='.",
+		example: "main() {\n    int a := 1\n    int a := 2\n}",
+	},
+	ERR_TYPE_MISMATCH: {
+		text:    "The value does not match the type of the variable it is stored in.\nUse the 'as' operator to convert the value first.",
+		example: "main() {\n    int a := \"five\"\n}",
+	},
+	ERR_WRONG_NUMBER_OF_ARGUMENTS: {
+		text:    "A function was called with a different number of arguments than it declares.",
+		example: "add(a, b int) int {\n    return a + b\n}\n\nmain() {\n    print(add(1))\n}",
+	},
+	ERR_INVALID_NEGATE_EXPRESSION: {
+		text:    "Only int, float and bool values can be negated with '-' or '!'.",
+		example: "main() {\n    string s := -\"text\"\n}",
+	},
+	ERR_INVALID_MULTIPLY_EXPRESSION: {
+		text:    "The '*' operator works on numbers, and on a string multiplied by an int.",
+		example: "main() {\n    bool b := true * 2\n}",
+	},
+	ERR_INVALID_DIVISION_EXPRESSION: {
+		text:    "The '/' operator works only on int and float values.",
+		example: "main() {\n    int a := \"ten\" / 2\n}",
+	},
+	ERR_INVALID_SUM_EXPRESSION: {
+		text:    "The '+' operator adds numbers or concatenates strings, bool values cannot be added.",
+		example: "main() {\n    int a := true + 1\n}",
+	},
+	ERR_INVALID_SUBSTRACT_EXPRESSION: {
+		text:    "The '-' operator works only on int and float values.",
+		example: "main() {\n    int a := \"ten\" - 1\n}",
+	},
+	ERR_INVALID_EQUALS_MISSMATCH: {
+		text:    "Both sides of '==' must have the same type.",
+		example: "main() {\n    bool b := 1 == \"1\"\n}",
+	},
+	ERR_INVALID_NOT_EQUALS_MISSMATCH: {
+		text:    "Both sides of '!=' must have the same type.",
+		example: "main() {\n    bool b := 1 != \"1\"\n}",
+	},
+	ERR_INVALID_GREATER_THAN_MISSMATCH: {
+		text:    "Both sides of '>' must be numbers or both must be strings.",
+		example: "main() {\n    bool b := 1 > \"1\"\n}",
+	},
+	ERR_INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH: {
+		text:    "Both sides of '>=' and '<=' must be numbers or both must be strings.",
+		example: "main() {\n    bool b := 1 >= \"1\"\n}",
+	},
+	ERR_INVALID_LESS_THAN_MISSMATCH: {
+		text: "Both sides of '<' must be numbers or both must be strings.",
+	},
+	ERR_INVALID_LESS_OR_EQUALS_THAN_MISSMATCH: {
+		text:    "Both sides of '<' and '<=' must be numbers or both must be strings.",
+		example: "main() {\n    bool b := 1 < \"1\"\n}",
+	},
+	ERR_INVALID_ASSIGNMENT_TYPES: {
+		text: "The assigned value does not match the type of the variable.",
+	},
+	ERR_INVALID_TYPE_ANNOTATION: {
+		text: "The type is not one of int, float, bool or string.",
+	},
+	ERR_INVALID_RETURN_TYPE: {
+		text:    "The function returned a value of a different type than declared in its definition.",
+		example: "name() int {\n    return \"flux\"\n}\n\nmain() {\n    print(name())\n}",
+	},
+	ERR_MISSING_RETURN: {
+		text:    "A function with a return type finished without returning a value.\nEvery path through the function has to end with a return statement.",
+		example: "sign(a int) int {\n    if a > 0 {\n        return 1\n    }\n}\n\nmain() {\n    print(sign(-1))\n}",
+	},
+	ERR_FUNCTION_REDEFINITION: {
+		text: "A function with the same name was already defined.",
+	},
+	ERR_MULTIPLE_DEFAULT_CASES: {
+		text: "A switch statement can have at most one default case.",
+	},
+	ERR_INVALID_CASE_TYPE: {
+		text: "The switch statement contains a case of unknown kind.",
+	},
+	ERR_INVALID_WHILE_CONDITION: {
+		text:    "The condition of a while loop must evaluate to a bool value.",
+		example: "main() {\n    int i := 3\n    while i {\n        i = i - 1\n    }\n}",
+	},
+	ERR_ERROR_ARGUMENTS_NOT_FOUND: {
+		text: "The arguments of a call were not evaluated before the function body started.",
+	},
+	ERR_INVALID_ARGUMENTS_TYPE: {
+		text: "A built-in function received arguments it cannot handle.",
+	},
+	ERR_WRONG_ARGUMENT_TYPE: {
+		text:    "The argument does not match the type of the parameter it is passed to.",
+		example: "double(a int) int {\n    return a * 2\n}\n\nmain() {\n    print(double(\"two\"))\n}",
+	},
+	ERR_MAX_RECURSION_DEPTH_EXCEEDED: {
+		text:    "A function called itself more times than the recursion limit allows.\nMake sure that the recursion has a base case that is reached.",
+		example: "loop(a int) int {\n    return loop(a + 1)\n}\n\nmain() {\n    print(loop(0))\n}",
+	},
+	ERR_EXPECTED_BOOLEAN_EXPRESSION: {
+		text:    "Conditions of if statements and switch cases, and operands of 'and' and 'or', must be bool values.",
+		example: "main() {\n    if 1 {\n        print(\"one\")\n    }\n}",
+	},
+	ERR_DIVISION_BY_ZERO: {
+		text:    "The right operand of '/' evaluated to zero.",
+		example: "main() {\n    int zero := 0\n    print(1 / zero)\n}",
+	},
+	ERR_INVALID_CAST_EXPRESSION: {
+		text:    "The value cannot be converted to the requested type, e.g. a string that is not a number cast to int.",
+		example: "main() {\n    int a := \"five\" as int\n}",
+	},
+	ERR_SHADOWED_VARIABLE: {
+		text:    "A variable declared in a block of an if, while or switch statement has the same name as a variable\nof an enclosing block. Shadowing is allowed, this is only a warning reported before the program runs.",
+		example: "main() {\n    int a := 1\n    if a > 0 {\n        int a := 2\n    }\n}",
+	},
+	ERR_STEP_LIMIT_EXCEEDED: {
+		text:    "The program took more steps than it is allowed to, e.g. with the --max-steps flag.\nEvery iteration of a loop and every function call is a step, a loop that never ends is stopped this way.",
+		example: "main() {\n    while true {\n    }\n}",
+	},
+	ERR_EXECUTION_INTERRUPTED: {
+		text: "The program was stopped before it finished, because it ran longer than allowed with the --timeout flag\nor the program embedding the interpreter cancelled it.",
+	},
+	ERR_MEMORY_LIMIT_EXCEEDED: {
+		text:    "The program used more memory than it is allowed to, e.g. with the --max-string-size flag.\nThe limits cover the size of a single string, the size of all strings the program created,\nthe number of open scopes and the number of calls running at once.",
+		example: "main() {\n    string s := \"a\"\n    while true {\n        s = s + s\n    }\n}",
+	},
+	ERR_ASSERTION_FAILED: {
+		text:    "The condition passed to assert was false. flux test reports the test calling it as failed.",
+		example: "test_positive() {\n    assert(1 - 2 > 0)\n}",
+	},
+	ERR_ASSERTION_NOT_EQUAL: {
+		text:    "The values passed to assert_eq differ. Values of different types are never equal, e.g. 1 and 1.0.\nflux test reports the test calling it as failed.",
+		example: "test_sum() {\n    assert_eq(2 + 2, 5)\n}",
+	},
+}
+
+func init() {
+	for code, e := range explanations {
+		diagnostics.RegisterExplanation(&diagnostics.Explanation{
+			Code:    code.String(),
+			Message: errorMessage[code],
+			Text:    e.text,
+			Example: e.example,
+		})
+	}
+}
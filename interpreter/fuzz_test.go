@@ -0,0 +1,67 @@
+package interpreter
+
+import (
+	"context"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tkom/ast"
+	"tkom/diagnostics"
+	"tkom/lexer"
+	"tkom/parser"
+)
+
+// programs that parse run with a budget and limits, so every program ends,
+// and stop with errors of the language, never with runtime errors of Go
+func FuzzRun(f *testing.F) {
+	files, err := filepath.Glob("../example_codes/*.fl")
+	if err != nil || len(files) == 0 {
+		f.Fatalf("no example codes found: %v", err)
+	}
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(source))
+	}
+	for _, source := range []string{
+		"main() {\n    print(modulo(7, 2), sqrt(2.0), power(2.0, 3.0))\n}\n",
+		"main() {\n    print(modulo(7, 0))\n}\n",
+		"main() {\n    print(modulo(7.0, 2.0), modulo(7.0, 0.0))\n}\n",
+		"main() {\n    print(1 / 0, 1.0 / 0.0, -\"a\")\n}\n",
+		"main() {\n    string s := \"a\"\n    while true {\n        s = s + s\n    }\n}\n",
+		"f(n int) int {\n    return f(n + 1)\n}\n\nmain() {\n    f(0)\n}\n",
+		"main() int {\n    switch int x := 1 {\n        x > 0 => \"a\",\n        default => 2\n    }\n}\n",
+		"main() {\n    assert_eq(1 as string, \"1\")\n    assert(\"1\" as int == 1)\n}\n",
+	} {
+		f.Add(source)
+	}
+
+	Output = io.Discard
+	f.Fuzz(func(t *testing.T, source string) {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(diagnostics.Diagnosable); !ok {
+					t.Fatalf("unexpected panic %T: %v", r, r)
+				}
+			}
+		}()
+		scanner, _ := lexer.NewScanner(strings.NewReader(source))
+		lex := lexer.NewLexer(scanner, 500, 1000, math.MaxInt)
+		errorHandler := func(err error) {
+			panic(err)
+		}
+		lex.ErrorHandler = errorHandler
+		program := parser.NewParser(lex, errorHandler).ParseProgram()
+		ResolveProgram(program)
+
+		visitor := NewCodeVisitor(MAX_RECURSION_DEPTH)
+		visitor.Budget = NewBudget(context.Background(), 10000)
+		visitor.Limits = &Limits{MaxStringSize: 1 << 16, MaxStringBytes: 1 << 20, MaxScopes: 1000, MaxCallDepth: 1000}
+		visitor.Run(program, &ast.FunctionCall{Name: "main"})
+	})
+}
@@ -1,9 +1,8 @@
 package interpreter
 
 import (
-	"fmt"
 	"reflect"
-	"strconv"
+	"runtime"
 	"tkom/ast"
 	"tkom/shared"
 )
@@ -22,13 +21,30 @@ type CodeVisitor struct {
 	SwitchEndFlag     bool
 	CurrentReturnType shared.TypeAnnotation
 	MaxRecursionDepth int
+	// stops the program when it runs too long, nil for no limit
+	Budget *Budget
+	// stops the program when it uses too much memory, nil for no limit
+	Limits *Limits
+	// paused before every statement and switch case, nil when not debugging
+	Debugger Debugger
+	// measures the calls and counts the statements run, nil when not profiling
+	Profile *Profile
+	// records the statements run and the branches taken, nil when not measuring coverage
+	Coverage *Coverage
+	// call in tail position run by the function it returns from
+	pendingTailCall *tailCall
+}
+
+type tailCall struct {
+	call   *ast.FunctionCall
+	values []any
 }
 
 func NewCodeVisitor(maxRecursionDepth int) *CodeVisitor {
 	return &CodeVisitor{
-		FunctionsMap:      embeddedFunctions,
+		FunctionsMap:      EmbeddedFunctions(),
 		ScopeStack:        Stack{elem: []*Scope{}},
-		CallStack:         CallStack{elem: map[string]int{}},
+		CallStack:         CallStack{elem: map[string]int{}, frames: []*Frame{}},
 		LastResult:        nil,
 		CurrentScope:      nil,
 		ReturnFlag:        false,
@@ -38,93 +54,6 @@ func NewCodeVisitor(maxRecursionDepth int) *CodeVisitor {
 	}
 }
 
-// helper function for visiting CastExpression
-func (c *CodeVisitor) tryCastToInt(value any) (int, error) {
-	switch val := value.(type) {
-	case int:
-		return val, nil
-	case float64:
-		return int(val), nil
-	case bool:
-		if val {
-			return 1, nil
-		}
-		return 0, nil
-	case string:
-		return strconv.Atoi(val)
-	default:
-		return 0, fmt.Errorf("invalid cast expression: %v to int", value)
-	}
-}
-
-// helper function for visiting CastExpression
-func (v *CodeVisitor) tryCastToFloat(value any) (float64, error) {
-	switch val := value.(type) {
-	case int:
-		return float64(val), nil
-	case float64:
-		return val, nil
-	case bool:
-		if val {
-			return 1.0, nil
-		}
-		return 0.0, nil
-	case string:
-		return strconv.ParseFloat(val, 64)
-	default:
-		return 0, fmt.Errorf("invalid cast expression: %v to float", value)
-	}
-}
-
-// helper function for visiting CastExpression
-func (v *CodeVisitor) tryCastToBool(value any) (bool, error) {
-	switch val := value.(type) {
-	case int:
-		return val != 0, nil
-	case float64:
-		return val != 0.0, nil
-	case bool:
-		return val, nil
-	case string:
-		if value == "" {
-			return false, nil
-		} else {
-			return true, nil
-		}
-	default:
-		return false, fmt.Errorf("invalid cast expression: %v to bool", value)
-	}
-}
-
-// helper function for visiting CastExpression
-func (v *CodeVisitor) tryCastToString(value any) (string, error) {
-	return fmt.Sprintf("%v", value), nil
-}
-
-func (v *CodeVisitor) checkType(value any, expectedType shared.TypeAnnotation, pos shared.Position) error {
-	switch expectedType {
-	case shared.INT:
-		if _, ok := value.(int); !ok {
-			return NewSemanticError(fmt.Sprintf(TYPE_MISMATCH, expectedType, reflect.TypeOf(value)), pos)
-		}
-	case shared.FLOAT:
-		if _, ok := value.(float64); !ok {
-			return NewSemanticError(fmt.Sprintf(TYPE_MISMATCH, expectedType, reflect.TypeOf(value)), pos)
-		}
-	case shared.BOOL:
-		if _, ok := value.(bool); !ok {
-			return NewSemanticError(fmt.Sprintf(TYPE_MISMATCH, expectedType, reflect.TypeOf(value)), pos)
-		}
-	case shared.STRING:
-		if _, ok := value.(string); !ok {
-			return NewSemanticError(fmt.Sprintf(TYPE_MISMATCH, expectedType, reflect.TypeOf(value)), pos)
-		}
-	default:
-		return fmt.Errorf("unknown type: %v", expectedType)
-	}
-	return nil
-}
-
 func (v *CodeVisitor) VisitIntExpression(intExp *ast.IntExpression) {
 	v.LastResult = intExp.Value
 }
@@ -142,9 +71,15 @@ func (v *CodeVisitor) VisitBoolExpression(boolExp *ast.BoolExpression) {
 }
 
 func (v *CodeVisitor) VisitIdentifier(idExp *ast.Identifier) {
-	sc, err := v.CurrentScope.GetVariable(idExp.Name)
+	var sc any
+	var err error
+	if idExp.Slot != nil {
+		sc, err = v.CurrentScope.GetVariableAt(*idExp.Slot, idExp.Name)
+	} else {
+		sc, err = v.CurrentScope.GetVariable(idExp.Name)
+	}
 	if err != nil {
-		panic(NewSemanticError(err.Error(), idExp.Position))
+		panic(v.undefinedName(errorAt(err, idExp.Position, idExp.Span), idExp.Name))
 	}
 	v.LastResult = sc
 }
@@ -153,42 +88,21 @@ func (v *CodeVisitor) VisitNegateExpression(negateExp *ast.NegateExpression) {
 	negateExp.Expression.Accept(v)
 	ne := v.LastResult
 
-	switch value := ne.(type) {
-	case int:
-		v.LastResult = -value
-	case float64:
-		v.LastResult = -value
-	case bool:
-		v.LastResult = !value
-	default:
-		panic(NewSemanticError(fmt.Sprintf(INVALID_NEGATE_EXPRESSION, ne, "string"), negateExp.Position))
+	result, valid := negate(ne)
+	if !valid {
+		panic(NewSemanticErrorWithCode(ERR_INVALID_NEGATE_EXPRESSION, negateExp.Position, ne, "string").WithSpan(negateExp.Span))
 	}
+	v.LastResult = result
 }
 
 func (v *CodeVisitor) VisitCastExpression(castExp *ast.CastExpression) {
 	castExp.LeftExpression.Accept(v)
-	leftExpValue := v.LastResult
-
-	var result any
-	var err error
-
-	switch castExp.TypeAnnotation {
-	case shared.INT:
-		result, err = v.tryCastToInt(leftExpValue)
-	case shared.FLOAT:
-		result, err = v.tryCastToFloat(leftExpValue)
-	case shared.BOOL:
-		result, err = v.tryCastToBool(leftExpValue)
-	case shared.STRING:
-		result, err = v.tryCastToString(leftExpValue)
-	default:
-		panic(NewSemanticError(fmt.Sprintf(INVALID_TYPE_ANNOTATION, castExp.TypeAnnotation), castExp.Position))
-	}
 
+	result, err := castValue(v.LastResult, castExp.TypeAnnotation, castExp.Position)
 	if err != nil {
-		panic(NewSemanticError(err.Error(), castExp.Position))
+		panic(err.WithSpan(castExp.Span))
 	}
-
+	v.allocate(result, castExp.Position, castExp.Span)
 	v.LastResult = result
 }
 
@@ -199,30 +113,10 @@ func (v *CodeVisitor) VisitMultiplyExpression(mulExp *ast.MultiplyExpression) {
 	mulExp.RightExpression.Accept(v)
 	rightValue := v.LastResult
 
-	performMultiplication := func(left, right any) (result any, valid bool) {
-		switch l := left.(type) {
-		case int:
-			switch r := right.(type) {
-			case int:
-				return l * r, true
-			case float64:
-				return float64(l) * r, true
-			}
-		case float64:
-			switch r := right.(type) {
-			case float64:
-				return l * r, true
-			case int:
-				return l * float64(r), true
-			}
-		}
-		return nil, false
-	}
-
-	if result, valid := performMultiplication(leftValue, rightValue); valid {
+	if result, valid := multiply(leftValue, rightValue); valid {
 		v.LastResult = result
 	} else {
-		panic(NewSemanticError(fmt.Sprintf(INVALID_MULTIPLY_EXPRESSION, reflect.TypeOf(leftValue), reflect.TypeOf(rightValue)), mulExp.Position))
+		panic(operandsError(ERR_INVALID_MULTIPLY_EXPRESSION, mulExp.Position, leftValue, rightValue).WithSpan(mulExp.Span))
 	}
 }
 
@@ -233,37 +127,14 @@ func (v *CodeVisitor) VisitDivideExpression(divExp *ast.DivideExpression) {
 	divExp.RightExpression.Accept(v)
 	rightResult := v.LastResult
 
-	// check for zero
-	if right, ok := rightResult.(int); ok && right == 0 {
-		panic(NewSemanticError("Division by zero", divExp.Position))
-	} else if right, ok := rightResult.(float64); ok && right == 0.0 {
-		panic(NewSemanticError("Division by zero", divExp.Position))
-	}
-
-	performDivision := func(left, right any) (result any, valid bool) {
-		switch l := left.(type) {
-		case int:
-			if r, ok := right.(int); ok {
-				return l / r, true
-			}
-			if r, ok := right.(float64); ok {
-				return float64(l) / r, true
-			}
-		case float64:
-			switch r := right.(type) {
-			case float64:
-				return l / r, true
-			case int:
-				return l / float64(r), true
-			}
-		}
-		return nil, false
+	if isZero(rightResult) {
+		panic(NewSemanticErrorWithCode(ERR_DIVISION_BY_ZERO, divExp.Position).WithSpan(divExp.Span))
 	}
 
-	if result, valid := performDivision(leftResult, rightResult); valid {
+	if result, valid := divide(leftResult, rightResult); valid {
 		v.LastResult = result
 	} else {
-		panic(NewSemanticError(fmt.Sprintf(INVALID_DIVISION_EXPRESSION, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), divExp.Position))
+		panic(operandsError(ERR_INVALID_DIVISION_EXPRESSION, divExp.Position, leftResult, rightResult).WithSpan(divExp.Span))
 	}
 }
 
@@ -274,69 +145,15 @@ func (v *CodeVisitor) VisitSumExpression(sumExp *ast.SumExpression) {
 	sumExp.RightExpression.Accept(v)
 	rightResult := v.LastResult
 
-	var result any
-	var err error
-
-	switch left := leftResult.(type) {
-	case int:
-		result, err = sumInt(left, rightResult)
-	case float64:
-		result, err = sumFloat64(left, rightResult)
-	case string:
-		result, err = sumString(left, rightResult)
-	default:
-		err = fmt.Errorf("invalid left operand type: %T", leftResult)
-	}
-
-	if err != nil {
-		panic(NewSemanticError(fmt.Sprintf(INVALID_SUM_EXPRESSION, leftResult, rightResult), sumExp.Position))
+	result, valid := sum(leftResult, rightResult)
+	if !valid {
+		panic(NewSemanticErrorWithCode(ERR_INVALID_SUM_EXPRESSION, sumExp.Position, leftResult, rightResult).WithSpan(sumExp.Span))
 	}
+	v.allocate(result, sumExp.Position, sumExp.Span)
 
 	v.LastResult = result
 }
 
-// helper function for the sum expression function
-func sumInt(left int, right any) (any, error) {
-	switch right := right.(type) {
-	case int:
-		return left + right, nil
-	case float64:
-		return float64(left) + right, nil
-	case string:
-		return fmt.Sprintf("%d%s", left, right), nil
-	default:
-		return nil, fmt.Errorf("invalid right operand type: %T", right)
-	}
-}
-
-// helper function for the sum expression function
-func sumFloat64(left float64, right any) (any, error) {
-	switch right := right.(type) {
-	case int:
-		return left + float64(right), nil
-	case float64:
-		return left + right, nil
-	case string:
-		return fmt.Sprintf("%f%s", left, right), nil
-	default:
-		return nil, fmt.Errorf("invalid right operand type: %T", right)
-	}
-}
-
-// helper function for the sum expression function
-func sumString(left string, right any) (any, error) {
-	switch right := right.(type) {
-	case int:
-		return left + fmt.Sprintf("%d", right), nil
-	case float64:
-		return left + fmt.Sprintf("%f", right), nil
-	case string:
-		return left + right, nil
-	default:
-		return nil, fmt.Errorf("invalid right operand type: %T", right)
-	}
-}
-
 func (v *CodeVisitor) VisitSubstractExpression(subExp *ast.SubstractExpression) {
 	subExp.LeftExpression.Accept(v)
 	leftResult := v.LastResult
@@ -344,22 +161,11 @@ func (v *CodeVisitor) VisitSubstractExpression(subExp *ast.SubstractExpression)
 	subExp.RightExpression.Accept(v)
 	rightResult := v.LastResult
 
-	switch leftVal := leftResult.(type) {
-	case int:
-		switch rightVal := rightResult.(type) {
-		case int:
-			v.LastResult = leftVal - rightVal
-		}
-	case float64:
-		switch rightVal := rightResult.(type) {
-		case float64:
-			v.LastResult = leftVal - rightVal
-		case int:
-			v.LastResult = leftVal - float64(rightVal)
-		}
-	default:
-		panic(NewSemanticError(fmt.Sprintf(INVALID_SUBSTRACT_EXPRESSION, leftResult, rightResult), subExp.Position))
+	result, valid := subtract(leftResult, rightResult)
+	if !valid {
+		panic(NewSemanticErrorWithCode(ERR_INVALID_SUBSTRACT_EXPRESSION, subExp.Position, leftResult, rightResult).WithSpan(subExp.Span))
 	}
+	v.LastResult = result
 }
 
 func (v *CodeVisitor) VisitEqualsExpression(eqExp *ast.EqualsExpression) {
@@ -370,7 +176,7 @@ func (v *CodeVisitor) VisitEqualsExpression(eqExp *ast.EqualsExpression) {
 	rightResult := v.LastResult
 
 	if reflect.TypeOf(leftResult) != reflect.TypeOf(rightResult) {
-		panic(NewSemanticError(fmt.Sprintf(INVALID_EQUALS_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), eqExp.Position))
+		panic(operandsError(ERR_INVALID_EQUALS_MISSMATCH, eqExp.Position, leftResult, rightResult).WithSpan(eqExp.Span))
 	}
 
 	v.LastResult = leftResult == rightResult
@@ -384,7 +190,7 @@ func (v *CodeVisitor) VisitNotEqualsExpression(neExp *ast.NotEqualsExpression) {
 	rightResult := v.LastResult
 
 	if reflect.TypeOf(leftResult) != reflect.TypeOf(rightResult) {
-		panic(NewSemanticError(fmt.Sprintf(INVALID_NOT_EQUALS_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), neExp.Position))
+		panic(operandsError(ERR_INVALID_NOT_EQUALS_MISSMATCH, neExp.Position, leftResult, rightResult).WithSpan(neExp.Span))
 	}
 
 	v.LastResult = leftResult != rightResult
@@ -397,26 +203,11 @@ func (v *CodeVisitor) VisitGreaterThanExpression(gtExp *ast.GreaterThanExpressio
 	gtExp.RightExpression.Accept(v)
 	rightResult := v.LastResult
 
-	if reflect.TypeOf(leftResult) != reflect.TypeOf(rightResult) {
-		panic(NewSemanticError(fmt.Sprintf(INVALID_GREATER_THAN_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), gtExp.Position))
-	}
-
-	switch left := leftResult.(type) {
-	case int:
-		right, ok := rightResult.(int)
-		if !ok {
-			panic(NewSemanticError(fmt.Sprintf(INVALID_GREATER_THAN_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), gtExp.Position))
-		}
-		v.LastResult = left > right
-	case float64:
-		right, ok := rightResult.(float64)
-		if !ok {
-			panic(NewSemanticError(fmt.Sprintf(INVALID_GREATER_THAN_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), gtExp.Position))
-		}
-		v.LastResult = left > right
-	default:
-		panic(NewSemanticError(fmt.Sprintf(INVALID_GREATER_THAN_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), gtExp.Position))
+	result, valid := greaterThan(leftResult, rightResult)
+	if !valid {
+		panic(operandsError(ERR_INVALID_GREATER_THAN_MISSMATCH, gtExp.Position, leftResult, rightResult).WithSpan(gtExp.Span))
 	}
+	v.LastResult = result
 }
 
 func (v *CodeVisitor) VisitGreaterOrEqualExpression(geExp *ast.GreaterOrEqualExpression) {
@@ -426,26 +217,11 @@ func (v *CodeVisitor) VisitGreaterOrEqualExpression(geExp *ast.GreaterOrEqualExp
 	geExp.RightExpression.Accept(v)
 	rightResult := v.LastResult
 
-	if reflect.TypeOf(leftResult) != reflect.TypeOf(rightResult) {
-		panic(NewSemanticError(fmt.Sprintf(INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), geExp.Position))
-	}
-
-	switch left := leftResult.(type) {
-	case int:
-		right, ok := rightResult.(int)
-		if !ok {
-			panic(NewSemanticError(fmt.Sprintf(INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), geExp.Position))
-		}
-		v.LastResult = left >= right
-	case float64:
-		right, ok := rightResult.(float64)
-		if !ok {
-			panic(NewSemanticError(fmt.Sprintf(INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), geExp.Position))
-		}
-		v.LastResult = left >= right
-	default:
-		panic(NewSemanticError(fmt.Sprintf(INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), geExp.Position))
+	result, valid := greaterOrEqual(leftResult, rightResult)
+	if !valid {
+		panic(operandsError(ERR_INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH, geExp.Position, leftResult, rightResult).WithSpan(geExp.Span))
 	}
+	v.LastResult = result
 }
 
 func (v *CodeVisitor) VisitLessThanExpression(ltExp *ast.LessThanExpression) {
@@ -455,26 +231,11 @@ func (v *CodeVisitor) VisitLessThanExpression(ltExp *ast.LessThanExpression) {
 	ltExp.RightExpression.Accept(v)
 	rightResult := v.LastResult
 
-	if reflect.TypeOf(leftResult) != reflect.TypeOf(rightResult) {
-		panic(NewSemanticError(fmt.Sprintf(INVALID_LESS_OR_EQUALS_THAN_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), ltExp.Position))
-	}
-
-	switch left := leftResult.(type) {
-	case int:
-		right, ok := rightResult.(int)
-		if !ok {
-			panic(NewSemanticError(fmt.Sprintf(INVALID_LESS_OR_EQUALS_THAN_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), ltExp.Position))
-		}
-		v.LastResult = left < right
-	case float64:
-		right, ok := rightResult.(float64)
-		if !ok {
-			panic(NewSemanticError(fmt.Sprintf(INVALID_LESS_OR_EQUALS_THAN_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), ltExp.Position))
-		}
-		v.LastResult = left < right
-	default:
-		panic(NewSemanticError(fmt.Sprintf(INVALID_LESS_OR_EQUALS_THAN_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), ltExp.Position))
+	result, valid := lessThan(leftResult, rightResult)
+	if !valid {
+		panic(operandsError(ERR_INVALID_LESS_THAN_MISSMATCH, ltExp.Position, leftResult, rightResult).WithSpan(ltExp.Span))
 	}
+	v.LastResult = result
 }
 
 func (v *CodeVisitor) VisitLessOrEqualExpression(leExp *ast.LessOrEqualExpression) {
@@ -484,26 +245,11 @@ func (v *CodeVisitor) VisitLessOrEqualExpression(leExp *ast.LessOrEqualExpressio
 	leExp.RightExpression.Accept(v)
 	rightResult := v.LastResult
 
-	if reflect.TypeOf(leftResult) != reflect.TypeOf(rightResult) {
-		panic(NewSemanticError(fmt.Sprintf(INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), leExp.Position))
-	}
-
-	switch left := leftResult.(type) {
-	case int:
-		right, ok := rightResult.(int)
-		if !ok {
-			panic(NewSemanticError(fmt.Sprintf(INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), leExp.Position))
-		}
-		v.LastResult = left <= right
-	case float64:
-		right, ok := rightResult.(float64)
-		if !ok {
-			panic(NewSemanticError(fmt.Sprintf(INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), leExp.Position))
-		}
-		v.LastResult = left <= right
-	default:
-		panic(NewSemanticError(fmt.Sprintf(INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH, reflect.TypeOf(leftResult), reflect.TypeOf(rightResult)), leExp.Position))
+	result, valid := lessOrEqual(leftResult, rightResult)
+	if !valid {
+		panic(operandsError(ERR_INVALID_LESS_OR_EQUALS_THAN_MISSMATCH, leExp.Position, leftResult, rightResult).WithSpan(leExp.Span))
 	}
+	v.LastResult = result
 }
 
 func (v *CodeVisitor) VisitOrExpression(orExp *ast.OrExpression) {
@@ -512,7 +258,7 @@ func (v *CodeVisitor) VisitOrExpression(orExp *ast.OrExpression) {
 
 	leftBool, ok := leftResult.(bool)
 	if !ok {
-		panic(NewSemanticError(fmt.Sprintf(EXPECTED_BOOLEAN_EXPRESSION, reflect.TypeOf(leftResult)), orExp.Position))
+		panic(NewSemanticErrorWithCode(ERR_EXPECTED_BOOLEAN_EXPRESSION, orExp.Position, reflect.TypeOf(leftResult)).WithSpan(orExp.Span))
 	}
 
 	// If the left expression is true, return true
@@ -527,7 +273,7 @@ func (v *CodeVisitor) VisitOrExpression(orExp *ast.OrExpression) {
 	// Check if the right result is a boolean
 	rightBool, ok := rightResult.(bool)
 	if !ok {
-		panic(NewSemanticError(fmt.Sprintf(EXPECTED_BOOLEAN_EXPRESSION, reflect.TypeOf(rightResult)), orExp.Position))
+		panic(NewSemanticErrorWithCode(ERR_EXPECTED_BOOLEAN_EXPRESSION, orExp.Position, reflect.TypeOf(rightResult)).WithSpan(orExp.Span))
 	}
 
 	v.LastResult = rightBool
@@ -539,7 +285,7 @@ func (v *CodeVisitor) VisitAndExpression(andExp *ast.AndExpression) {
 
 	leftBool, ok := leftResult.(bool)
 	if !ok {
-		panic(NewSemanticError(fmt.Sprintf(EXPECTED_BOOLEAN_EXPRESSION, reflect.TypeOf(leftResult)), andExp.Position))
+		panic(NewSemanticErrorWithCode(ERR_EXPECTED_BOOLEAN_EXPRESSION, andExp.Position, reflect.TypeOf(leftResult)).WithSpan(andExp.Span))
 	}
 
 	// If the left expression is false, return false
@@ -553,7 +299,7 @@ func (v *CodeVisitor) VisitAndExpression(andExp *ast.AndExpression) {
 
 	rightBool, ok := rightResult.(bool)
 	if !ok {
-		panic(NewSemanticError(fmt.Sprintf(EXPECTED_BOOLEAN_EXPRESSION, reflect.TypeOf(rightResult)), andExp.Position))
+		panic(NewSemanticErrorWithCode(ERR_EXPECTED_BOOLEAN_EXPRESSION, andExp.Position, reflect.TypeOf(rightResult)).WithSpan(andExp.Span))
 	}
 
 	v.LastResult = rightBool
@@ -563,9 +309,14 @@ func (v *CodeVisitor) VisitAssignement(assignment *ast.Assignment) {
 	assignment.Value.Accept(v)
 	value := v.LastResult
 
-	err := v.CurrentScope.SetValue(assignment.Identifier.Name, value)
+	var err error
+	if slot := assignment.Identifier.Slot; slot != nil {
+		err = v.CurrentScope.SetValueAt(*slot, assignment.Identifier.Name, value)
+	} else {
+		err = v.CurrentScope.SetValue(assignment.Identifier.Name, value)
+	}
 	if err != nil {
-		panic(NewSemanticError(err.Error(), assignment.Identifier.Position))
+		panic(v.undefinedName(errorAt(err, assignment.Identifier.Position, assignment.Identifier.Span), assignment.Identifier.Name))
 	}
 
 	v.LastResult = nil
@@ -575,21 +326,33 @@ func (v *CodeVisitor) VisitVariable(varDecl *ast.Variable) {
 	varDecl.Value.Accept(v)
 	value := v.LastResult
 
-	err := v.checkType(value, varDecl.Type, varDecl.Position)
+	err := checkType(value, varDecl.Type, varDecl.Position)
 	if err != nil {
-		panic(err)
+		panic(err.(*SemantciError).WithSpan(varDecl.Span))
 	}
 
-	err = v.CurrentScope.AddVariable(varDecl.Name, v.LastResult, varDecl.Type, varDecl.Position)
+	err = v.declare(varDecl, v.LastResult)
 	if err != nil {
-		panic(err)
+		panic(errorAt(err, varDecl.Position, varDecl.Span))
 	}
 
 	v.LastResult = nil
 }
 
+// variables bound by the resolver are declared at their slot
+func (v *CodeVisitor) declare(variable *ast.Variable, value any) error {
+	if variable.Slot != nil {
+		return v.CurrentScope.DeclareAt(variable.Slot.Index, variable.Name, value, variable.Position)
+	}
+	return v.CurrentScope.AddVariable(variable.Name, value, variable.Type, variable.Position)
+}
+
 func (v *CodeVisitor) VisitBlock(block *ast.Block) {
 	for _, statement := range block.Statements {
+		position := StatementPosition(statement)
+		v.Profile.hit(position)
+		v.Coverage.statement(statement)
+		v.pause(position)
 		statement.Accept(v)
 		if v.ReturnFlag {
 			break
@@ -599,19 +362,22 @@ func (v *CodeVisitor) VisitBlock(block *ast.Block) {
 
 func (v *CodeVisitor) VisitIfStatement(ifStmt *ast.IfStatement) {
 	newScope := NewScope(v.CurrentScope, nil)
-	v.ScopeStack.Push(v.CurrentScope)
-	v.CurrentScope = newScope
+	v.pushScope(newScope, ifStmt.Condition.GetPosition(), ifStmt.Condition.GetSpan())
 
 	ifStmt.Condition.Accept(v)
 	conditionResult, ok := v.LastResult.(bool)
 	if !ok {
-		panic(NewSemanticError(fmt.Sprintf(EXPECTED_BOOLEAN_EXPRESSION, reflect.TypeOf(v.LastResult)), ifStmt.Condition.GetPosition()))
+		panic(NewSemanticErrorWithCode(ERR_EXPECTED_BOOLEAN_EXPRESSION, ifStmt.Condition.GetPosition(), reflect.TypeOf(v.LastResult)).WithSpan(ifStmt.Condition.GetSpan()))
 	}
 
 	if conditionResult {
+		v.Coverage.branch(ifStmt, 0)
 		ifStmt.InstructionsBlock.Accept(v)
-	} else if ifStmt.ElseInstructionsBlock != nil {
-		ifStmt.ElseInstructionsBlock.Accept(v)
+	} else {
+		v.Coverage.branch(ifStmt, 1)
+		if ifStmt.ElseInstructionsBlock != nil {
+			ifStmt.ElseInstructionsBlock.Accept(v)
+		}
 	}
 
 	prevScope, err := v.ScopeStack.Pop()
@@ -637,38 +403,31 @@ func (v *CodeVisitor) VisitReturnStatement(returnStmt *ast.ReturnStatement) {
 
 // helper function for determining the type of a value for return
 func (v *CodeVisitor) DetermineType(value any) shared.TypeAnnotation {
-	switch value.(type) {
-	case int:
-		return shared.INT
-	case float64:
-		return shared.FLOAT
-	case bool:
-		return shared.BOOL
-	case string:
-		return shared.STRING
-	default:
-		return shared.VOID
-	}
+	return determineType(value)
 }
 
 func (v *CodeVisitor) VisitWhileStatement(whileStmt *ast.WhileStatement) {
 	newScope := NewScope(v.CurrentScope, nil)
-	v.ScopeStack.Push(v.CurrentScope)
-	v.CurrentScope = newScope
+	v.pushScope(newScope, whileStmt.Condition.GetPosition(), whileStmt.Condition.GetSpan())
 
 	whileStmt.Condition.Accept(v)
 
 	if _, ok := v.LastResult.(bool); !ok {
-		panic(NewSemanticError(fmt.Sprintf(INVALID_WHILE_CONDITION, reflect.TypeOf(v.LastResult)), whileStmt.Condition.GetPosition()))
+		panic(NewSemanticErrorWithCode(ERR_INVALID_WHILE_CONDITION, whileStmt.Condition.GetPosition(), reflect.TypeOf(v.LastResult)).WithSpan(whileStmt.Condition.GetSpan()))
 	}
 
 	for v.LastResult.(bool) {
+		v.step(whileStmt.Condition.GetPosition(), whileStmt.Condition.GetSpan())
+		v.Coverage.branch(whileStmt, 0)
 		whileStmt.InstructionsBlock.Accept(v)
 		if v.ReturnFlag {
 			break
 		}
 		whileStmt.Condition.Accept(v)
 	}
+	if !v.ReturnFlag {
+		v.Coverage.branch(whileStmt, 1)
+	}
 
 	prevScope, err := v.ScopeStack.Pop()
 	if err != nil {
@@ -683,20 +442,39 @@ func (v *CodeVisitor) VisitWhileStatement(whileStmt *ast.WhileStatement) {
 }
 
 func (v *CodeVisitor) VisitFunctionCall(fc *ast.FunctionCall) {
+	v.step(fc.Position, fc.Span)
+
 	functionDef := v.FunctionsMap[fc.Name]
 	if functionDef == nil {
-		panic(NewSemanticError(fmt.Sprintf(UNDEFINED_FUNCTION, fc.Name), fc.Position))
+		panic(v.withSuggestions(NewSemanticErrorWithCode(ERR_UNDEFINED_FUNCTION, fc.Position, fc.Name).WithSpan(fc.Span), fc.Name))
 	}
 
-	if v.CallStack.RecursionDepth(fc.Name) >= v.MaxRecursionDepth {
-		panic(NewSemanticError(fmt.Sprintf(MAX_RECURSION_DEPTH_EXCEEDED, fc.Name), fc.Position))
+	// a tail call replaces the running call, so it does not go deeper
+	tail := fc.Tail && v.CallStack.Top() != nil && v.CallStack.Top().Function == fc.Name
+	if !tail && v.CallStack.RecursionDepth(fc.Name) >= v.MaxRecursionDepth {
+		panic(NewSemanticErrorWithCode(ERR_MAX_RECURSION_DEPTH_EXCEEDED, fc.Position, fc.Name).WithSpan(fc.Span))
+	}
+	if !tail {
+		if err := v.Limits.checkCallDepth(v.CallStack.Depth(), fc.Position); err != nil {
+			err.Span = fc.Span
+			panic(err)
+		}
 	}
 
-	v.CallStack.Push(fc.Name)
-	defer v.CallStack.Pop(fc.Name)
+	v.CallStack.Push(NewFrame(fc.Name, fc.Position, fc.Span))
+	defer v.popFrame()
 
 	if len(fc.Arguments) != functionDef.GetParametersLen() && !functionDef.IsVariadic() {
-		panic(NewSemanticError(fmt.Sprintf(WRONG_NUMBER_OF_ARGUMENTS, fc.Name, functionDef.GetParametersLen(), len(fc.Arguments)), fc.Position))
+		panic(NewSemanticErrorWithCode(ERR_WRONG_NUMBER_OF_ARGUMENTS, fc.Position, fc.Name, functionDef.GetParametersLen(), len(fc.Arguments)).WithSpan(fc.Span))
+	}
+
+	if tail {
+		// the arguments are evaluated in the frame of the call, then
+		// the running call returns and its definition runs again with them
+		v.pendingTailCall = &tailCall{call: fc, values: v.evaluateArguments(fc.Arguments)}
+		v.LastResult = nil
+		v.ReturnFlag = true
+		return
 	}
 
 	v.LastResult = fc.Arguments
@@ -704,39 +482,173 @@ func (v *CodeVisitor) VisitFunctionCall(fc *ast.FunctionCall) {
 	functionDef.Accept(v)
 }
 
+func (v *CodeVisitor) step(position shared.Position, span shared.Span) {
+	if err := v.Budget.step(position); err != nil {
+		err.Span = span
+		panic(err)
+	}
+}
+
+func (v *CodeVisitor) allocate(value any, position shared.Position, span shared.Span) {
+	if err := v.Limits.allocate(value, position); err != nil {
+		err.Span = span
+		panic(err)
+	}
+}
+
+// starts profiling the call on the top of the call stack, its arguments are evaluated
+func (v *CodeVisitor) profileCall(name string, line int) {
+	if frame := v.CallStack.Top(); v.Profile != nil && frame != nil {
+		v.Profile.enter(name, line, frame.CallSite, v.CallStack.Depth())
+	}
+}
+
+// makes the scope current, the enclosing one is restored when it is popped
+func (v *CodeVisitor) pushScope(scope *Scope, position shared.Position, span shared.Span) {
+	v.ScopeStack.Push(v.CurrentScope)
+	v.CurrentScope = scope
+	if err := v.Limits.checkScopes(v.ScopeStack.Size(), position); err != nil {
+		err.Span = span
+		panic(err)
+	}
+}
+
+// pops the frame of the finished call, errors that pass through
+// get the traceback of the moment they were raised attached
+func (v *CodeVisitor) popFrame() {
+	v.Profile.exit(v.CallStack.Depth())
+	r := recover()
+	if r != nil {
+		r = attachTraceback(r, v.CallStack.Top(), &v.CallStack)
+	}
+	v.CallStack.Pop()
+	if r != nil {
+		panic(r)
+	}
+}
+
+// errors returned by the scope carry no position, they are reported
+// at the node that caused them
+func errorAt(err error, position shared.Position, span shared.Span) *SemantciError {
+	if semanticError, ok := err.(*SemantciError); ok {
+		return semanticError.At(position, span)
+	}
+	return NewSemanticError(err.Error(), position).WithSpan(span)
+}
+
+// only errors about undefined names get suggestions, type mismatches pass unchanged
+func (v *CodeVisitor) undefinedName(err *SemantciError, name string) *SemantciError {
+	if err.Code != ERR_UNDEFINED_VARIABLE {
+		return err
+	}
+	return v.withSuggestions(err, name)
+}
+
+// errors raised inside a call get the frames of the call stack attached,
+// errors without a position are reported at the call site of the frame
+func attachTraceback(r any, frame *Frame, callStack *CallStack) any {
+	switch err := r.(type) {
+	case *SemantciError:
+		if err.Position == (shared.Position{}) {
+			err = err.At(frame.CallSite, frame.CallSiteSpan)
+		}
+		if err.Traceback == nil {
+			err.Traceback = callStack.Traceback()
+		}
+		return err
+	case *InterruptedError:
+		if err.Traceback == nil {
+			err.Traceback = callStack.Traceback()
+		}
+		return err
+	case *LimitError:
+		if err.Traceback == nil {
+			err.Traceback = callStack.Traceback()
+		}
+		return err
+	case runtime.Error:
+		return err
+	case error:
+		semanticError := NewSemanticError(err.Error(), frame.CallSite).WithSpan(frame.CallSiteSpan)
+		semanticError.Traceback = callStack.Traceback()
+		return semanticError
+	default:
+		return r
+	}
+}
+
 func (v *CodeVisitor) VisitFunctionDefinition(fd *ast.FunctionDefinition) {
 	if _, ok := v.LastResult.([]ast.Expression); !ok {
-		panic(NewSemanticError(fmt.Sprintf(ERROR_ARGUMENTS_NOT_FOUND, reflect.TypeOf(v.LastResult)), fd.Position))
+		panic(NewSemanticErrorWithCode(ERR_ERROR_ARGUMENTS_NOT_FOUND, fd.Position, reflect.TypeOf(v.LastResult)).WithSpan(fd.Span))
 	}
 	args := v.LastResult.([]ast.Expression)
+	values := v.evaluateArguments(args)
+	v.profileCall(fd.Name, fd.Position.Line)
+
+	for {
+		v.runFunctionBody(fd, args, values)
+		if v.pendingTailCall == nil {
+			break
+		}
 
+		// the frame of the finished call is reused by the tail call
+		next := v.pendingTailCall
+		v.pendingTailCall = nil
+		v.ReturnFlag = false
+		args, values = next.call.Arguments, next.values
+		if frame := v.CallStack.Top(); frame != nil {
+			frame.CallSite = next.call.Position
+			frame.CallSiteSpan = next.call.Span
+			frame.Arguments = values
+		}
+		v.Profile.restart(v.CallStack.Depth())
+	}
+
+	if v.ReturnFlag {
+		returnType := v.DetermineType(v.LastResult)
+		if returnType != fd.Type {
+			panic(NewSemanticErrorWithCode(ERR_INVALID_RETURN_TYPE, fd.Position, returnType, fd.Type).WithSpan(fd.Span))
+		}
+		v.ReturnFlag = false
+	} else {
+		v.LastResult = nil
+	}
+}
+
+// evaluates the arguments of the call on the top of the call stack
+func (v *CodeVisitor) evaluateArguments(args []ast.Expression) []any {
 	values := []any{}
 	for _, arg := range args {
 		arg.Accept(v)
 		values = append(values, v.LastResult)
 	}
+	if frame := v.CallStack.Top(); frame != nil {
+		frame.Arguments = values
+	}
+	return values
+}
 
+func (v *CodeVisitor) runFunctionBody(fd *ast.FunctionDefinition, args []ast.Expression, values []any) {
 	newScope := NewScope(nil, &fd.Type)
-	v.ScopeStack.Push(v.CurrentScope)
-	v.CurrentScope = newScope
+	v.pushScope(newScope, fd.Position, fd.Span)
 
 	for i, param := range fd.Parameters {
 		argValue := values[i]
 		argType := v.DetermineType(argValue)
-		err := v.checkType(argValue, param.Type, args[i].GetPosition())
+		err := checkType(argValue, param.Type, args[i].GetPosition())
 		if err != nil {
-			panic(NewSemanticError(fmt.Sprintf(WRONG_ARGUMENT_TYPE, argType, param.Type), args[i].GetPosition()))
+			panic(NewSemanticErrorWithCode(ERR_WRONG_ARGUMENT_TYPE, args[i].GetPosition(), argType, param.Type).WithSpan(args[i].GetSpan()))
 		}
-		err = v.CurrentScope.AddVariable(param.Name, argValue, param.Type, param.Position)
+		err = v.declare(param, argValue)
 		if err != nil {
-			panic(err)
+			panic(errorAt(err, param.Position, param.Span))
 		}
 	}
 
 	fd.Block.Accept(v)
 
 	if fd.Type != shared.VOID && !v.ReturnFlag {
-		panic(NewSemanticError(fmt.Sprintf(MISSING_RETURN, fd.Type), fd.Position))
+		panic(NewSemanticErrorWithCode(ERR_MISSING_RETURN, fd.Position, fd.Type).WithSpan(fd.Span))
 	}
 
 	currScope, err := v.ScopeStack.Pop()
@@ -744,16 +656,6 @@ func (v *CodeVisitor) VisitFunctionDefinition(fd *ast.FunctionDefinition) {
 		panic(err)
 	}
 	v.CurrentScope = currScope
-
-	if v.ReturnFlag {
-		returnType := v.DetermineType(v.LastResult)
-		if returnType != fd.Type {
-			panic(NewSemanticError(fmt.Sprintf(INVALID_RETURN_TYPE, returnType, fd.Type), fd.Position))
-		}
-		v.ReturnFlag = false
-	} else {
-		v.LastResult = nil
-	}
 }
 
 func (v *CodeVisitor) VisitEmbeddedFunction(ef *ast.EmbeddedFunction) {
@@ -764,11 +666,15 @@ func (v *CodeVisitor) VisitEmbeddedFunction(ef *ast.EmbeddedFunction) {
 			arg.Accept(v)
 			values = append(values, v.LastResult)
 		}
+		if frame := v.CallStack.Top(); frame != nil {
+			frame.Arguments = values
+		}
+		v.profileCall(ef.Name, 0)
 
 		if !ef.Variadic {
 			for i, val := range values {
 				if v.DetermineType(val) != ef.Parameters[i] {
-					panic(NewSemanticError(fmt.Sprintf(WRONG_ARGUMENT_TYPE, v.DetermineType(val), ef.Parameters[i]), args[i].GetPosition()))
+					panic(NewSemanticErrorWithCode(ERR_WRONG_ARGUMENT_TYPE, args[i].GetPosition(), v.DetermineType(val), ef.Parameters[i]).WithSpan(args[i].GetSpan()))
 				}
 			}
 		}
@@ -776,14 +682,13 @@ func (v *CodeVisitor) VisitEmbeddedFunction(ef *ast.EmbeddedFunction) {
 		result := ef.Func(values...)
 		v.LastResult = result
 	} else {
-		panic(fmt.Errorf(INVALID_ARGUMENTS_TYPE, reflect.TypeOf(v.LastResult)))
+		panic(NewSemanticErrorWithCode(ERR_INVALID_ARGUMENTS_TYPE, shared.Position{}, reflect.TypeOf(v.LastResult)))
 	}
 }
 
 func (v *CodeVisitor) VisitSwitchStatement(s *ast.SwitchStatement) {
 	newScope := NewScope(v.CurrentScope, nil)
-	v.ScopeStack.Push(v.CurrentScope)
-	v.CurrentScope = newScope
+	v.pushScope(newScope, s.Position, s.Span)
 
 	for _, variable := range s.Variables {
 		variable.Accept(v)
@@ -794,17 +699,19 @@ func (v *CodeVisitor) VisitSwitchStatement(s *ast.SwitchStatement) {
 	for _, c := range s.Cases {
 		switch caseStmt := c.(type) {
 		case *ast.SwitchCase:
+			v.Profile.hit(caseStmt.Position)
+			v.pause(caseStmt.Position)
 			caseStmt.Accept(v)
 			if v.ReturnFlag || v.SwitchEndFlag {
 				break
 			}
 		case *ast.DefaultSwitchCase:
 			if defaultCase != nil {
-				panic(NewSemanticError(MULTIPLE_DEFAULT_CASES, defaultCase.GetPosition()))
+				panic(NewSemanticErrorWithCode(ERR_MULTIPLE_DEFAULT_CASES, defaultCase.GetPosition()).WithSpan(defaultCase.GetSpan()))
 			}
 			defaultCase = caseStmt
 		default:
-			panic(NewSemanticError(INVALID_CASE_TYPE, caseStmt.GetPosition()))
+			panic(NewSemanticErrorWithCode(ERR_INVALID_CASE_TYPE, caseStmt.GetPosition()).WithSpan(caseStmt.GetSpan()))
 		}
 		if v.ReturnFlag {
 			break
@@ -813,6 +720,8 @@ func (v *CodeVisitor) VisitSwitchStatement(s *ast.SwitchStatement) {
 
 	// run default only after cases did not get executed
 	if !v.ReturnFlag && defaultCase != nil && !v.SwitchEndFlag {
+		v.Profile.hit(defaultCase.Position)
+		v.pause(defaultCase.Position)
 		defaultCase.Accept(v)
 	}
 
@@ -835,10 +744,11 @@ func (v *CodeVisitor) VisitSwitchCase(sc *ast.SwitchCase) {
 	sc.Condition.Accept(v)
 	condition := v.LastResult
 	if _, ok := condition.(bool); !ok {
-		panic(NewSemanticError(fmt.Sprintf(EXPECTED_BOOLEAN_EXPRESSION, reflect.TypeOf(condition)), sc.Condition.GetPosition()))
+		panic(NewSemanticErrorWithCode(ERR_EXPECTED_BOOLEAN_EXPRESSION, sc.Condition.GetPosition(), reflect.TypeOf(condition)).WithSpan(sc.Condition.GetSpan()))
 	}
 
 	if condition.(bool) {
+		v.Coverage.branch(sc, 0)
 		sc.OutputExpression.Accept(v)
 
 		if v.LastResult != nil {
@@ -854,6 +764,7 @@ func (v *CodeVisitor) VisitSwitchCase(sc *ast.SwitchCase) {
 }
 
 func (v *CodeVisitor) VisitDefaultSwitchCase(dsc *ast.DefaultSwitchCase) {
+	v.Coverage.branch(dsc, 0)
 	dsc.OutputExpression.Accept(v)
 	if v.LastResult != nil {
 		v.ReturnFlag = true
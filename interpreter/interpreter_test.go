@@ -2,12 +2,15 @@ package interpreter
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"tkom/ast"
+	"tkom/diagnostics"
 	"tkom/shared"
 )
 
@@ -492,7 +495,7 @@ func TestSearchVariableInScope(t *testing.T) {
 	visitor.ScopeStack.Push(scope)
 	visitor.CurrentScope = scope
 
-	expectedError := NewSemanticError(fmt.Sprintf(UNDEFINED_VARIABLE, "e"), shared.NewPosition(0, 0))
+	expectedError := NewSemanticError(fmt.Sprintf(ERR_UNDEFINED_VARIABLE.Template(), "e"), shared.NewPosition(0, 0))
 	defer func() {
 		if r := recover(); r != nil {
 			err, ok := r.(error)
@@ -872,7 +875,7 @@ func TestVisitAsignmentWithFunctionCall(t *testing.T) {
 		},
 	)
 
-    if variables := visitor.CurrentScope.InScope("c"); variables["c"] == nil {
+	if variables := visitor.CurrentScope.InScope("c"); variables["c"] == nil {
 		t.Errorf("variable not in scope but should be, got: %v", variables["c"])
 	}
 	if variables := visitor.CurrentScope.InScope("c"); variables["c"] != 3 {
@@ -1060,7 +1063,7 @@ func TestParametersAndArguments(t *testing.T) {
 				&ast.StringExpression{Value: "one"},
 				&ast.IntExpression{Value: 2},
 			},
-			expectedError: NewSemanticError(fmt.Sprintf(WRONG_ARGUMENT_TYPE, shared.STRING, shared.INT), shared.NewPosition(0, 0)).Error(),
+			expectedError: NewSemanticError(fmt.Sprintf(ERR_WRONG_ARGUMENT_TYPE.Template(), shared.STRING, shared.INT), shared.NewPosition(0, 0)).Error(),
 		},
 		{
 			name:         "float type mismatch",
@@ -1069,7 +1072,7 @@ func TestParametersAndArguments(t *testing.T) {
 				&ast.FloatExpression{Value: 4.20},
 				&ast.IntExpression{Value: 2},
 			},
-			expectedError: NewSemanticError(fmt.Sprintf(WRONG_ARGUMENT_TYPE, shared.FLOAT, shared.INT), shared.NewPosition(0, 0)).Error(),
+			expectedError: NewSemanticError(fmt.Sprintf(ERR_WRONG_ARGUMENT_TYPE.Template(), shared.FLOAT, shared.INT), shared.NewPosition(0, 0)).Error(),
 		},
 		{
 			name:         "bool type mismatch",
@@ -1078,7 +1081,7 @@ func TestParametersAndArguments(t *testing.T) {
 				&ast.BoolExpression{Value: true},
 				&ast.IntExpression{Value: 2},
 			},
-			expectedError: NewSemanticError(fmt.Sprintf(WRONG_ARGUMENT_TYPE, shared.BOOL, shared.INT), shared.NewPosition(0, 0)).Error(),
+			expectedError: NewSemanticError(fmt.Sprintf(ERR_WRONG_ARGUMENT_TYPE.Template(), shared.BOOL, shared.INT), shared.NewPosition(0, 0)).Error(),
 		},
 		{
 			name:         "Wrong number of arguments",
@@ -1086,7 +1089,7 @@ func TestParametersAndArguments(t *testing.T) {
 			arguments: []ast.Expression{
 				&ast.IntExpression{Value: 1},
 			},
-			expectedError: NewSemanticError(fmt.Sprintf(WRONG_NUMBER_OF_ARGUMENTS, "sum_a_b", 2, 1), shared.NewPosition(0, 0)).Error(),
+			expectedError: NewSemanticError(fmt.Sprintf(ERR_WRONG_NUMBER_OF_ARGUMENTS.Template(), "sum_a_b", 2, 1), shared.NewPosition(0, 0)).Error(),
 		},
 	}
 
@@ -1394,8 +1397,8 @@ func TestVisitSwitchStatement(t *testing.T) {
 				if r := recover(); r != nil {
 					if test.expectPanic {
 						err, ok := r.(error)
-						if !ok || err.Error() != NewSemanticError(MULTIPLE_DEFAULT_CASES, shared.NewPosition(0, 0)).Error() {
-							t.Errorf("Expected panic with error '%v', but got: %v", MULTIPLE_DEFAULT_CASES, r)
+						if !ok || err.Error() != NewSemanticError(ERR_MULTIPLE_DEFAULT_CASES.Template(), shared.NewPosition(0, 0)).Error() {
+							t.Errorf("Expected panic with error '%v', but got: %v", ERR_MULTIPLE_DEFAULT_CASES.Template(), r)
 						}
 					} else {
 						t.Errorf("Unexpected panic: %v", r)
@@ -1565,7 +1568,7 @@ func TestSwitchWithBlock(t *testing.T) {
 	if visitor.ReturnFlag {
 		t.Errorf("Expected returnFlag to be false, got: %v", visitor.ReturnFlag)
 	}
-    if variables := visitor.CurrentScope.InScope("i"); variables["i"] != 20 {
+	if variables := visitor.CurrentScope.InScope("i"); variables["i"] != 20 {
 		t.Errorf("Expected lastResult to be 20, got: %v", visitor.LastResult)
 	}
 }
@@ -1631,7 +1634,7 @@ func TestFunctionWithSwitch(t *testing.T) {
 	visitor.ScopeStack.Push(baseScope)
 	visitor.CurrentScope = baseScope
 
-	errorMsg := NewSemanticError(fmt.Sprintf(MISSING_RETURN, shared.STRING), shared.NewPosition(0, 0)).Error()
+	errorMsg := NewSemanticError(fmt.Sprintf(ERR_MISSING_RETURN.Template(), shared.STRING), shared.NewPosition(0, 0)).Error()
 	defer func() {
 		if r := recover(); r != nil {
 			err, ok := r.(error)
@@ -1811,7 +1814,7 @@ func TestVariableDeclarationMissmatch(t *testing.T) {
 	newScope := NewScope(nil, &scopeReturnType)
 	visitor.ScopeStack.Push(newScope)
 	visitor.CurrentScope = newScope
-	expectedError := NewSemanticError(fmt.Sprintf(TYPE_MISMATCH, shared.INT, reflect.TypeOf("missmatch???")), shared.NewPosition(0, 0))
+	expectedError := NewSemanticError(fmt.Sprintf(ERR_TYPE_MISMATCH.Template(), shared.INT, reflect.TypeOf("missmatch???")), shared.NewPosition(0, 0))
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -1854,7 +1857,7 @@ func TestRecursion(t *testing.T) {
 	visitor.ScopeStack.Push(newScope)
 	visitor.CurrentScope = newScope
 	visitor.MaxRecursionDepth = 2
-	expectedError := NewSemanticError(fmt.Sprintf(MAX_RECURSION_DEPTH_EXCEEDED, recursiveFunc.Name), shared.NewPosition(0, 0))
+	expectedError := NewSemanticError(fmt.Sprintf(ERR_MAX_RECURSION_DEPTH_EXCEEDED.Template(), recursiveFunc.Name), shared.NewPosition(0, 0))
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -1869,3 +1872,505 @@ func TestRecursion(t *testing.T) {
 
 	visitor.VisitFunctionCall(functioncall)
 }
+
+func TestRuntimeErrorTraceback(t *testing.T) {
+	divide := &ast.FunctionDefinition{
+		Name: "divide",
+		Block: &ast.Block{
+			Statements: []ast.Statement{
+				&ast.ReturnStatement{
+					Value: &ast.DivideExpression{
+						LeftExpression:  &ast.Identifier{Name: "a", Position: shared.NewPosition(2, 12)},
+						RightExpression: &ast.Identifier{Name: "b", Position: shared.NewPosition(2, 16)},
+						Position:        shared.NewPosition(2, 14),
+					},
+				},
+			},
+		},
+		Parameters: []*ast.Variable{
+			{Name: "a", Type: shared.INT},
+			{Name: "b", Type: shared.INT},
+		},
+		Type: shared.INT,
+	}
+	divideCall := &ast.FunctionCall{
+		Name:      "divide",
+		Arguments: []ast.Expression{&ast.IntExpression{Value: 10}, &ast.IntExpression{Value: 0}},
+		Position:  shared.NewPosition(6, 5),
+	}
+
+	tests := []struct {
+		name              string
+		statement         ast.Statement
+		expectedTraceback []Frame
+		expectedFormat    string
+	}{
+		{
+			"call",
+			divideCall,
+			[]Frame{
+				{Function: "main", Arguments: []any{}},
+				{Function: "divide", CallSite: shared.NewPosition(6, 5), Arguments: []any{10, 0}},
+			},
+			"traceback (most recent call last):\n  main()\n  divide(10, 0) called at [6, 5]",
+		},
+		{
+			// print has not been called yet when its argument fails
+			"call in arguments",
+			&ast.FunctionCall{
+				Name:      "print",
+				Arguments: []ast.Expression{divideCall},
+				Position:  shared.NewPosition(6, 1),
+			},
+			[]Frame{
+				{Function: "main", Arguments: []any{}},
+				{Function: "divide", CallSite: shared.NewPosition(6, 5), Arguments: []any{10, 0}},
+			},
+			"traceback (most recent call last):\n  main()\n  divide(10, 0) called at [6, 5]",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mainFunc := &ast.FunctionDefinition{
+				Name:  "main",
+				Block: &ast.Block{Statements: []ast.Statement{test.statement}},
+				Type:  shared.VOID,
+			}
+
+			visitor := NewCodeVisitor(MAX_RECURSION_DEPTH)
+			visitor.FunctionsMap = map[string]ast.Function{
+				divide.Name:        divide,
+				mainFunc.Name:      mainFunc,
+				PrintFunction.Name: PrintFunction,
+			}
+
+			defer func() {
+				r := recover()
+				err, ok := r.(*SemantciError)
+				if !ok {
+					t.Fatalf("expected semantic error, got: %v", r)
+				}
+				if !reflect.DeepEqual(err.Traceback, test.expectedTraceback) {
+					t.Errorf("expected traceback: %v, got: %v", test.expectedTraceback, err.Traceback)
+				}
+				if err.FormatTraceback() != test.expectedFormat {
+					t.Errorf("expected formatted traceback:\n%s\ngot:\n%s", test.expectedFormat, err.FormatTraceback())
+				}
+				if visitor.CallStack.Depth() != 0 {
+					t.Errorf("expected call stack to be unwound, got depth: %d", visitor.CallStack.Depth())
+				}
+			}()
+
+			visitor.VisitFunctionCall(&ast.FunctionCall{Name: "main", Arguments: []ast.Expression{}})
+		})
+	}
+}
+
+func TestEveryErrorCodeIsExplained(t *testing.T) {
+	for code := ERR_UNDEFINED_VARIABLE; code <= ERR_ASSERTION_NOT_EQUAL; code++ {
+		if _, ok := errorMessage[code]; !ok {
+			t.Errorf("no message for error code %s", code)
+		}
+		if _, ok := explanations[code]; !ok {
+			t.Errorf("no explanation for error code %s", code)
+		}
+	}
+}
+
+// codes are shown to users and looked up with flux explain, they must not change
+func TestErrorCodesAreStable(t *testing.T) {
+	tests := []struct {
+		code     ErrorCode
+		expected string
+	}{
+		{ERR_UNDEFINED_VARIABLE, "E0301"},
+		{ERR_UNDEFINED_FUNCTION, "E0302"},
+		{ERR_REDECLARED_VARIABLE, "E0303"},
+		{ERR_TYPE_MISMATCH, "E0304"},
+		{ERR_WRONG_NUMBER_OF_ARGUMENTS, "E0305"},
+		{ERR_INVALID_NEGATE_EXPRESSION, "E0306"},
+		{ERR_INVALID_MULTIPLY_EXPRESSION, "E0307"},
+		{ERR_INVALID_DIVISION_EXPRESSION, "E0308"},
+		{ERR_INVALID_SUM_EXPRESSION, "E0309"},
+		{ERR_INVALID_SUBSTRACT_EXPRESSION, "E0310"},
+		{ERR_INVALID_EQUALS_MISSMATCH, "E0311"},
+		{ERR_INVALID_NOT_EQUALS_MISSMATCH, "E0312"},
+		{ERR_INVALID_GREATER_THAN_MISSMATCH, "E0313"},
+		{ERR_INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH, "E0314"},
+		{ERR_INVALID_LESS_THAN_MISSMATCH, "E0315"},
+		{ERR_INVALID_LESS_OR_EQUALS_THAN_MISSMATCH, "E0316"},
+		{ERR_INVALID_ASSIGNMENT_TYPES, "E0317"},
+		{ERR_INVALID_TYPE_ANNOTATION, "E0318"},
+		{ERR_INVALID_RETURN_TYPE, "E0319"},
+		{ERR_MISSING_RETURN, "E0320"},
+		{ERR_FUNCTION_REDEFINITION, "E0321"},
+		{ERR_MULTIPLE_DEFAULT_CASES, "E0322"},
+		{ERR_INVALID_CASE_TYPE, "E0323"},
+		{ERR_INVALID_WHILE_CONDITION, "E0324"},
+		{ERR_ERROR_ARGUMENTS_NOT_FOUND, "E0325"},
+		{ERR_INVALID_ARGUMENTS_TYPE, "E0326"},
+		{ERR_WRONG_ARGUMENT_TYPE, "E0327"},
+		{ERR_MAX_RECURSION_DEPTH_EXCEEDED, "E0328"},
+		{ERR_EXPECTED_BOOLEAN_EXPRESSION, "E0329"},
+		{ERR_DIVISION_BY_ZERO, "E0330"},
+		{ERR_INVALID_CAST_EXPRESSION, "E0331"},
+		{ERR_SHADOWED_VARIABLE, "E0332"},
+		{ERR_STEP_LIMIT_EXCEEDED, "E0333"},
+		{ERR_EXECUTION_INTERRUPTED, "E0334"},
+		{ERR_MEMORY_LIMIT_EXCEEDED, "E0335"},
+		{ERR_ASSERTION_FAILED, "E0336"},
+		{ERR_ASSERTION_NOT_EQUAL, "E0337"},
+	}
+	for _, test := range tests {
+		if test.code.String() != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, test.code)
+		}
+	}
+}
+
+func TestSemanticErrorCode(t *testing.T) {
+	visitor := NewCodeVisitor(MAX_RECURSION_DEPTH)
+	visitor.CurrentScope = NewScope(nil, nil)
+
+	defer func() {
+		err, ok := recover().(*SemantciError)
+		if !ok {
+			t.Fatalf("expected *SemantciError")
+		}
+		expected := NewSemanticError(fmt.Sprintf(ERR_UNDEFINED_VARIABLE.Template(), "a"), shared.NewPosition(2, 5))
+		if err.Error() != expected.Error() {
+			t.Errorf("expected: %s, got: %s", expected.Error(), err.Error())
+		}
+		if err.Code != ERR_UNDEFINED_VARIABLE || err.Diagnostic().Code != "E0301" {
+			t.Errorf("expected code E0301, got: %s", err.Code)
+		}
+	}()
+
+	visitor.VisitIdentifier(&ast.Identifier{Name: "a", Position: shared.NewPosition(2, 5)})
+}
+
+// the json diagnostic of a runtime error is around the span of the failing expression
+func TestSemanticErrorJSON(t *testing.T) {
+	span := shared.NewSpan("main.fl", shared.NewPosition(2, 14), shared.NewPosition(2, 19), 22, 27)
+	err := NewSemanticErrorWithCode(ERR_DIVISION_BY_ZERO, shared.NewPosition(2, 16)).WithSpan(span)
+
+	var out bytes.Buffer
+	diagnostics.NewJSONEmitter(&out).Emit(err.Diagnostic(), nil)
+	var object struct {
+		Code    string
+		Message string
+		Start   shared.Position
+		End     shared.Position
+	}
+	if err := json.Unmarshal(out.Bytes(), &object); err != nil {
+		t.Fatalf("invalid json %s: %v", out.String(), err)
+	}
+	if object.Code != ERR_DIVISION_BY_ZERO.String() || object.Message != ERR_DIVISION_BY_ZERO.Template() {
+		t.Errorf("expected %s %q, got %s %q", ERR_DIVISION_BY_ZERO, ERR_DIVISION_BY_ZERO.Template(), object.Code, object.Message)
+	}
+	if object.Start != shared.NewPosition(2, 14) || object.End != shared.NewPosition(2, 19) {
+		t.Errorf("expected the span 2:14-2:19, got %v-%v", object.Start, object.End)
+	}
+}
+
+// every comparison operator reports mismatched operands with its own code
+func TestComparisonMismatchCodes(t *testing.T) {
+	tests := []struct {
+		operator string
+		code     ErrorCode
+	}{
+		{">", ERR_INVALID_GREATER_THAN_MISSMATCH},
+		{">=", ERR_INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH},
+		{"<", ERR_INVALID_LESS_THAN_MISSMATCH},
+		{"<=", ERR_INVALID_LESS_OR_EQUALS_THAN_MISSMATCH},
+	}
+	for _, test := range tests {
+		source := fmt.Sprintf("main() {\n    bool b := 1 %s 1.0\n}\n", test.operator)
+		for _, name := range []string{ENGINE_INTERPRETER, ENGINE_VM} {
+			program := parseProgram(t, source)
+			ResolveProgram(program)
+			err := RunProgram(mustEngine(t, name), program, &ast.FunctionCall{Name: "main"})
+			semanticError, ok := err.(*SemantciError)
+			if !ok || semanticError.Code != test.code {
+				t.Errorf("%s: expected %s for %s, got %v", name, test.code, test.operator, err)
+				continue
+			}
+			expected := fmt.Sprintf("cannot evaluate '%s' operation", test.operator)
+			if !strings.Contains(semanticError.Message, expected) {
+				t.Errorf("%s: expected message about %q, got %q", name, test.operator, semanticError.Message)
+			}
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"count", "count", 0},
+		{"cont", "count", 1},
+		{"ture", "true", 1},
+		{"prnt", "print", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.expected {
+			t.Errorf("editDistance(%q, %q) = %d, expected %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"count", "counter", "amount", "print", "a", "b"}
+
+	if got := suggest("cont", candidates); !reflect.DeepEqual(got, []string{"count"}) {
+		t.Errorf("expected [count], got: %v", got)
+	}
+	if got := suggest("countr", candidates); !reflect.DeepEqual(got, []string{"count", "counter"}) {
+		t.Errorf("expected [count counter], got: %v", got)
+	}
+	if got := suggest("c", candidates); len(got) != 0 {
+		t.Errorf("expected no suggestions for single letter name, got: %v", got)
+	}
+	if got := suggest("xyz", candidates); len(got) != 0 {
+		t.Errorf("expected no suggestions, got: %v", got)
+	}
+}
+
+func TestUndefinedNameSuggestions(t *testing.T) {
+	tests := []struct {
+		name     string
+		node     ast.Node
+		expected []string
+	}{
+		{
+			name:     "variable in parent scope",
+			node:     &ast.Identifier{Name: "cont"},
+			expected: []string{"did you mean 'count'?"},
+		},
+		{
+			name:     "keyword",
+			node:     &ast.Identifier{Name: "ture"},
+			expected: []string{"did you mean 'true'?"},
+		},
+		{
+			name:     "assignment",
+			node:     &ast.Assignment{Identifier: &ast.Identifier{Name: "cuont"}, Value: &ast.IntExpression{Value: 1}},
+			expected: []string{"did you mean 'count'?"},
+		},
+		{
+			name:     "builtin function",
+			node:     &ast.FunctionCall{Name: "prnt"},
+			expected: []string{"did you mean 'print'?"},
+		},
+		{
+			name:     "user function",
+			node:     &ast.FunctionCall{Name: "fibonaci"},
+			expected: []string{"did you mean 'fibonacci'?"},
+		},
+		{
+			name:     "nothing similar",
+			node:     &ast.Identifier{Name: "zzzzzz"},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			visitor := NewCodeVisitor(MAX_RECURSION_DEPTH)
+			visitor.FunctionsMap = map[string]ast.Function{
+				"fibonacci": &ast.FunctionDefinition{Name: "fibonacci"},
+			}
+			parent := NewScope(nil, nil)
+			_ = parent.AddVariable("count", 1, shared.INT, shared.NewPosition(1, 1))
+			visitor.CurrentScope = NewScope(parent, nil)
+
+			defer func() {
+				err, ok := recover().(*SemantciError)
+				if !ok {
+					t.Fatalf("expected *SemantciError")
+				}
+				if !reflect.DeepEqual(err.Help, tt.expected) {
+					t.Errorf("expected help: %v, got: %v", tt.expected, err.Help)
+				}
+				if !reflect.DeepEqual(err.Diagnostic().Help, tt.expected) && tt.expected != nil {
+					t.Errorf("expected diagnostic help: %v, got: %v", tt.expected, err.Diagnostic().Help)
+				}
+			}()
+
+			tt.node.Accept(visitor)
+		})
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	source := `
+count(n int, acc int) int {
+    if n == 0 {
+        return acc
+    }
+    return count(n - 1, acc + 1)
+}
+
+fib(n int, a int, b int) int {
+    switch {
+        n == 0 => a,
+        default => fib(n - 1, b, a + b)
+    }
+}
+
+down(n int) int {
+    switch {
+        n == 0 => 10 / n,
+        default => down(n - 1)
+    }
+}
+
+sum(n int) int {
+    if n == 0 {
+        return 0
+    }
+    return n + sum(n - 1)
+}
+`
+	forEachEngine(t, func(t *testing.T, run evaluate) {
+		// the runs go much deeper than the recursion limit
+		tests := []struct {
+			call     *ast.FunctionCall
+			expected any
+		}{
+			{
+				call:     &ast.FunctionCall{Name: "count", Arguments: []ast.Expression{&ast.IntExpression{Value: 10000}, &ast.IntExpression{Value: 0}}},
+				expected: 10000,
+			},
+			{
+				call:     &ast.FunctionCall{Name: "fib", Arguments: []ast.Expression{&ast.IntExpression{Value: 50}, &ast.IntExpression{Value: 0}, &ast.IntExpression{Value: 1}}},
+				expected: 12586269025,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.call.Name, func(t *testing.T) {
+				program := parseProgram(t, source)
+				ResolveProgram(program)
+				visitor := NewCodeVisitor(MAX_RECURSION_DEPTH)
+				visitor.FunctionsMap = map[string]ast.Function{}
+				for name, fd := range program.Functions {
+					visitor.FunctionsMap[name] = fd
+				}
+
+				run(visitor, tt.call)
+				if visitor.LastResult != tt.expected {
+					t.Errorf("expected %v, got %v", tt.expected, visitor.LastResult)
+				}
+				if visitor.CallStack.Depth() != 0 {
+					t.Errorf("expected empty call stack, got depth: %d", visitor.CallStack.Depth())
+				}
+			})
+		}
+
+		t.Run("traceback", func(t *testing.T) {
+			program := parseProgram(t, source)
+			ResolveProgram(program)
+			visitor := NewCodeVisitor(MAX_RECURSION_DEPTH)
+			visitor.FunctionsMap = map[string]ast.Function{"down": program.Functions["down"]}
+
+			// the frame of the last tail call replaces the ones before it
+			expectedTraceback := []Frame{
+				{Function: "down", CallSite: shared.NewPosition(19, 20), Arguments: []any{0}},
+			}
+			defer func() {
+				err, ok := recover().(*SemantciError)
+				if !ok {
+					t.Fatalf("expected *SemantciError")
+				}
+				if err.Code != ERR_DIVISION_BY_ZERO {
+					t.Errorf("expected %v, got %v", ERR_DIVISION_BY_ZERO, err.Code)
+				}
+				for i := range err.Traceback {
+					err.Traceback[i].CallSiteSpan = shared.Span{}
+				}
+				if !reflect.DeepEqual(err.Traceback, expectedTraceback) {
+					t.Errorf("expected traceback: %v, got: %v", expectedTraceback, err.Traceback)
+				}
+			}()
+			run(visitor, &ast.FunctionCall{Name: "down", Arguments: []ast.Expression{&ast.IntExpression{Value: 100}}})
+		})
+
+		t.Run("not in tail position", func(t *testing.T) {
+			program := parseProgram(t, source)
+			ResolveProgram(program)
+			visitor := NewCodeVisitor(MAX_RECURSION_DEPTH)
+			visitor.FunctionsMap = map[string]ast.Function{"sum": program.Functions["sum"]}
+
+			defer func() {
+				err, ok := recover().(*SemantciError)
+				if !ok || err.Code != ERR_MAX_RECURSION_DEPTH_EXCEEDED {
+					t.Errorf("expected %v, got: %v", ERR_MAX_RECURSION_DEPTH_EXCEEDED, err)
+				}
+			}()
+			run(visitor, &ast.FunctionCall{Name: "sum", Arguments: []ast.Expression{&ast.IntExpression{Value: 100}}})
+		})
+	})
+}
+
+// variables declared after the first one of a switch are bound by their own names
+func TestSwitchWithManyVariables(t *testing.T) {
+	source := "main() {\n    switch int a := 1, int b := 2 {\n        a + b == 3 => print(b)\n    }\n}\n"
+	for _, name := range []string{ENGINE_INTERPRETER, ENGINE_VM} {
+		program := parseProgram(t, source)
+		ResolveProgram(program)
+		var output bytes.Buffer
+		Output = &output
+		err := RunProgram(mustEngine(t, name), program, &ast.FunctionCall{Name: "main"})
+		Output = nil
+		if err != nil || output.String() != "2\n" {
+			t.Errorf("%s: expected 2, got %q, %v", name, output.String(), err)
+		}
+	}
+}
+
+// a deep recursion prints the frames of both ends of its traceback and counts the rest
+func TestFormatLongTraceback(t *testing.T) {
+	err := NewSemanticErrorWithCode(ERR_MAX_RECURSION_DEPTH_EXCEEDED, shared.NewPosition(2, 12), "f")
+	err.Traceback = []Frame{{Function: "main", Arguments: []any{}}}
+	for i := 0; i < 100; i++ {
+		err.Traceback = append(err.Traceback, Frame{Function: "f", CallSite: shared.NewPosition(2, 12), Arguments: []any{i}})
+	}
+
+	lines := strings.Split(err.FormatTraceback(), "\n")
+	if len(lines) != 2*TRACEBACK_FRAMES+2 {
+		t.Fatalf("expected %d lines, got %d:\n%s", 2*TRACEBACK_FRAMES+2, len(lines), err.FormatTraceback())
+	}
+	expected := map[int]string{
+		1:                    "  main()",
+		TRACEBACK_FRAMES:     "  f(8) called at [2, 12]",
+		TRACEBACK_FRAMES + 1: "  ... 81 more frames",
+		TRACEBACK_FRAMES + 2: "  f(90) called at [2, 12]",
+		len(lines) - 1:       "  f(99) called at [2, 12]",
+	}
+	for i, line := range expected {
+		if lines[i] != line {
+			t.Errorf("expected line %d to be %q, got %q", i, line, lines[i])
+		}
+	}
+
+	// related locations are the call sites of the printed frames, the innermost first
+	related := err.Diagnostic().Related
+	if len(related) != 2*TRACEBACK_FRAMES-1 {
+		t.Fatalf("expected %d related locations, got %d", 2*TRACEBACK_FRAMES-1, len(related))
+	}
+	if related[0].Message != "in call to f(99)" || related[TRACEBACK_FRAMES-1].Message != "in call to f(90)" || related[TRACEBACK_FRAMES].Message != "in call to f(8)" {
+		t.Errorf("expected calls of the printed frames, got %v", related)
+	}
+
+	// a traceback with a single frame to hide is printed whole
+	err.Traceback = err.Traceback[:2*TRACEBACK_FRAMES+1]
+	if lines := strings.Split(err.FormatTraceback(), "\n"); len(lines) != 2*TRACEBACK_FRAMES+2 || strings.Contains(err.FormatTraceback(), "more frames") {
+		t.Errorf("expected every frame, got:\n%s", err.FormatTraceback())
+	}
+}
@@ -0,0 +1,80 @@
+package interpreter
+
+import "tkom/shared"
+
+// names of the limits reported in LimitError
+const (
+	LIMIT_STRING_SIZE  = "string size"
+	LIMIT_STRING_BYTES = "total size of strings"
+	LIMIT_SCOPES       = "number of open scopes"
+	LIMIT_CALL_DEPTH   = "call depth"
+)
+
+// limits the memory a program can use, zero values mean no limit
+//
+// strings are counted when the program creates them, by concatenation or
+// a cast, literals of the program are already limited by the lexer;
+// the bytes are counted in every run using the limits
+type Limits struct {
+	// bytes of a single string
+	MaxStringSize int
+	// bytes of all strings created by the program
+	MaxStringBytes int
+	// scopes open at once, every running function and if, while and switch statement opens one
+	MaxScopes int
+	// calls running at once, of every function together
+	MaxCallDepth int
+	stringBytes  int
+}
+
+// bytes of strings created so far
+func (l *Limits) StringBytes() int {
+	return l.stringBytes
+}
+
+// counts the value when it is a string created by the program
+func (l *Limits) allocate(value any, position shared.Position) *LimitError {
+	s, ok := value.(string)
+	if l == nil || !ok {
+		return nil
+	}
+	if l.MaxStringSize > 0 && len(s) > l.MaxStringSize {
+		return newLimitError(LIMIT_STRING_SIZE, l.MaxStringSize, position)
+	}
+	l.stringBytes += len(s)
+	if l.MaxStringBytes > 0 && l.stringBytes > l.MaxStringBytes {
+		return newLimitError(LIMIT_STRING_BYTES, l.MaxStringBytes, position)
+	}
+	return nil
+}
+
+func (l *Limits) checkScopes(open int, position shared.Position) *LimitError {
+	if l != nil && l.MaxScopes > 0 && open > l.MaxScopes {
+		return newLimitError(LIMIT_SCOPES, l.MaxScopes, position)
+	}
+	return nil
+}
+
+// checked before the call is pushed on the call stack of the given depth
+func (l *Limits) checkCallDepth(depth int, position shared.Position) *LimitError {
+	if l != nil && l.MaxCallDepth > 0 && depth >= l.MaxCallDepth {
+		return newLimitError(LIMIT_CALL_DEPTH, l.MaxCallDepth, position)
+	}
+	return nil
+}
+
+// error of a program that exceeded one of its limits
+type LimitError struct {
+	*SemantciError
+	// name of the exceeded limit, e.g. LIMIT_STRING_SIZE
+	Limit string
+	Max   int
+}
+
+func newLimitError(limit string, max int, position shared.Position) *LimitError {
+	return &LimitError{
+		SemantciError: NewSemanticErrorWithCode(ERR_MEMORY_LIMIT_EXCEEDED, position, limit, max),
+		Limit:         limit,
+		Max:           max,
+	}
+}
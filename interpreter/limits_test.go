@@ -0,0 +1,203 @@
+package interpreter
+
+import (
+	"errors"
+	"testing"
+	"tkom/ast"
+	"tkom/shared"
+)
+
+const limitsSource = `
+repeat(s string, n int) string {
+    string result := ""
+    int i := 0
+    while i < n {
+        result = result + s
+        i = i + 1
+    }
+    return result
+}
+
+even(n int) int {
+    if n == 0 {
+        return 0
+    }
+    return 1 + odd(n - 1)
+}
+
+odd(n int) int {
+    return 1 + even(n - 1)
+}
+
+nested(n int) int {
+    if n > 0 {
+        if n > 1 {
+            if n > 2 {
+                return 3
+            }
+        }
+    }
+    return 0
+}
+
+count(n int) int {
+    switch {
+        n == 0 => 0,
+        default => count(n - 1)
+    }
+}
+`
+
+func limitsVisitor(t *testing.T, limits *Limits) *CodeVisitor {
+	program := parseProgram(t, limitsSource)
+	ResolveProgram(program)
+	visitor := NewCodeVisitor(MAX_RECURSION_DEPTH * 10)
+	visitor.FunctionsMap = map[string]ast.Function{}
+	for name, fd := range program.Functions {
+		visitor.FunctionsMap[name] = fd
+	}
+	visitor.Limits = limits
+	return visitor
+}
+
+func limitsCall(name string, args ...ast.Expression) *ast.FunctionCall {
+	return &ast.FunctionCall{Name: name, Arguments: args, Position: shared.NewPosition(1, 1)}
+}
+
+// runs the node expecting it to exceed the limit
+func exceeded(t *testing.T, run evaluate, visitor *CodeVisitor, node ast.Node, limit string) (err *LimitError) {
+	t.Helper()
+	defer func() {
+		r := recover()
+		var ok bool
+		if err, ok = r.(*LimitError); !ok {
+			t.Fatalf("expected *LimitError, got: %v", r)
+		}
+		if err.Code != ERR_MEMORY_LIMIT_EXCEEDED || err.Limit != limit {
+			t.Errorf("expected %v of %s, got %v of %s", ERR_MEMORY_LIMIT_EXCEEDED, limit, err.Code, err.Limit)
+		}
+	}()
+	run(visitor, node)
+	return nil
+}
+
+func TestStringSizeLimit(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, run evaluate) {
+		visitor := limitsVisitor(t, &Limits{MaxStringSize: 10})
+		run(visitor, limitsCall("repeat", &ast.StringExpression{Value: "ab"}, &ast.IntExpression{Value: 5}))
+		if visitor.LastResult != "ababababab" {
+			t.Errorf("expected a string of the largest size, got %v", visitor.LastResult)
+		}
+
+		call := limitsCall("repeat", &ast.StringExpression{Value: "ab"}, &ast.IntExpression{Value: 6})
+		err := exceeded(t, run, visitor, call, LIMIT_STRING_SIZE)
+		if err.Max != 10 || err.Position != shared.NewPosition(6, 25) {
+			t.Errorf("expected the limit of 10 exceeded at [6, 25], got %d at %v", err.Max, err.Position)
+		}
+		if len(err.Traceback) != 1 || err.Traceback[0].Function != "repeat" {
+			t.Errorf("expected traceback of repeat, got: %v", err.Traceback)
+		}
+	})
+}
+
+func TestStringBytesLimit(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, run evaluate) {
+		limits := &Limits{MaxStringBytes: 100}
+		visitor := limitsVisitor(t, limits)
+		// strings of 1, 2, ..., 10 bytes
+		run(visitor, limitsCall("repeat", &ast.StringExpression{Value: "a"}, &ast.IntExpression{Value: 10}))
+		if limits.StringBytes() != 55 {
+			t.Errorf("expected 55 bytes, got %d", limits.StringBytes())
+		}
+
+		// the bytes are counted in every run using the limits
+		exceeded(t, run, visitor, limitsCall("repeat", &ast.StringExpression{Value: "a"}, &ast.IntExpression{Value: 10}), LIMIT_STRING_BYTES)
+	})
+}
+
+func TestCastCreatesString(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, run evaluate) {
+		limits := &Limits{MaxStringSize: 3}
+		visitor := limitsVisitor(t, limits)
+		run(visitor, &ast.CastExpression{LeftExpression: &ast.IntExpression{Value: 123}, TypeAnnotation: shared.STRING})
+		if limits.StringBytes() != 3 {
+			t.Errorf("expected 3 bytes, got %d", limits.StringBytes())
+		}
+		cast := &ast.CastExpression{LeftExpression: &ast.IntExpression{Value: 1234}, TypeAnnotation: shared.STRING, Position: shared.NewPosition(1, 6)}
+		exceeded(t, run, visitor, cast, LIMIT_STRING_SIZE)
+	})
+}
+
+// calls of every function count, not only the calls of one function like the recursion limit
+func TestCallDepthLimit(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, run evaluate) {
+		visitor := limitsVisitor(t, &Limits{MaxCallDepth: 10})
+		run(visitor, limitsCall("even", &ast.IntExpression{Value: 8}))
+		if visitor.LastResult != 8 {
+			t.Errorf("expected 8, got %v", visitor.LastResult)
+		}
+
+		err := exceeded(t, run, visitor, limitsCall("even", &ast.IntExpression{Value: 10}), LIMIT_CALL_DEPTH)
+		if err.Position != shared.NewPosition(20, 16) {
+			t.Errorf("expected the 11th call at [20, 16], got %v", err.Position)
+		}
+		if len(err.Traceback) != 10 {
+			t.Errorf("expected traceback of 10 calls, got %d", len(err.Traceback))
+		}
+	})
+}
+
+// a tail call replaces the running call, so it does not count
+func TestCallDepthLimitTailCalls(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, run evaluate) {
+		visitor := limitsVisitor(t, &Limits{MaxCallDepth: 2, MaxScopes: 2})
+		run(visitor, limitsCall("count", &ast.IntExpression{Value: 1000}))
+		if visitor.LastResult != 0 {
+			t.Errorf("expected 0, got %v", visitor.LastResult)
+		}
+	})
+}
+
+func TestScopesLimit(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, run evaluate) {
+		visitor := limitsVisitor(t, &Limits{MaxScopes: 4})
+		run(visitor, limitsCall("nested", &ast.IntExpression{Value: 3}))
+		if visitor.LastResult != 3 {
+			t.Errorf("expected 3, got %v", visitor.LastResult)
+		}
+
+		visitor = limitsVisitor(t, &Limits{MaxScopes: 3})
+		err := exceeded(t, run, visitor, limitsCall("nested", &ast.IntExpression{Value: 3}), LIMIT_SCOPES)
+		if err.Position != shared.NewPosition(26, 18) {
+			t.Errorf("expected the third if at [26, 18], got %v", err.Position)
+		}
+	})
+}
+
+// scopes of the callers stay open, 9 function bodies and the if of the last call
+func TestScopesLimitCountsCallers(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, run evaluate) {
+		run(limitsVisitor(t, &Limits{MaxScopes: 10}), limitsCall("even", &ast.IntExpression{Value: 8}))
+		exceeded(t, run, limitsVisitor(t, &Limits{MaxScopes: 9}), limitsCall("even", &ast.IntExpression{Value: 8}), LIMIT_SCOPES)
+	})
+}
+
+func TestRunProgramReturnsLimitError(t *testing.T) {
+	program := parseProgram(t, limitsSource+"\nmain() {\n    print(repeat(\"abc\", 1000))\n}\n")
+	ResolveProgram(program)
+	for _, name := range []string{ENGINE_INTERPRETER, ENGINE_VM} {
+		t.Run(name, func(t *testing.T) {
+			engine, _ := NewEngine(name, MAX_RECURSION_DEPTH)
+			engine.SetLimits(&Limits{MaxStringBytes: 1000})
+
+			err := RunProgram(engine, program, &ast.FunctionCall{Name: "main"})
+			var limitError *LimitError
+			if !errors.As(err, &limitError) {
+				t.Fatalf("expected *LimitError, got: %v", err)
+			}
+			if limitError.Limit != LIMIT_STRING_BYTES || limitError.Max != 1000 {
+				t.Errorf("expected the limit of %s of 1000, got %s of %d", LIMIT_STRING_BYTES, limitError.Limit, limitError.Max)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package interpreter
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"tkom/shared"
+)
+
+// operators shared by the CodeVisitor and the VirtualMachine,
+// both engines compute values and report errors through them
+
+func tryCastToInt(value any) (int, error) {
+	switch val := value.(type) {
+	case int:
+		return val, nil
+	case float64:
+		return int(val), nil
+	case bool:
+		if val {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.Atoi(val)
+	default:
+		return 0, fmt.Errorf("invalid cast expression: %v to int", value)
+	}
+}
+
+func tryCastToFloat(value any) (float64, error) {
+	switch val := value.(type) {
+	case int:
+		return float64(val), nil
+	case float64:
+		return val, nil
+	case bool:
+		if val {
+			return 1.0, nil
+		}
+		return 0.0, nil
+	case string:
+		return strconv.ParseFloat(val, 64)
+	default:
+		return 0, fmt.Errorf("invalid cast expression: %v to float", value)
+	}
+}
+
+func tryCastToBool(value any) (bool, error) {
+	switch val := value.(type) {
+	case int:
+		return val != 0, nil
+	case float64:
+		return val != 0.0, nil
+	case bool:
+		return val, nil
+	case string:
+		if value == "" {
+			return false, nil
+		} else {
+			return true, nil
+		}
+	default:
+		return false, fmt.Errorf("invalid cast expression: %v to bool", value)
+	}
+}
+
+func tryCastToString(value any) (string, error) {
+	return fmt.Sprintf("%v", value), nil
+}
+
+// converts the value with the 'as' operator
+func castValue(value any, typeAnnotation shared.TypeAnnotation, position shared.Position) (any, *SemantciError) {
+	var result any
+	var err error
+
+	switch typeAnnotation {
+	case shared.INT:
+		result, err = tryCastToInt(value)
+	case shared.FLOAT:
+		result, err = tryCastToFloat(value)
+	case shared.BOOL:
+		result, err = tryCastToBool(value)
+	case shared.STRING:
+		result, err = tryCastToString(value)
+	default:
+		return nil, NewSemanticErrorWithCode(ERR_INVALID_TYPE_ANNOTATION, position, typeAnnotation)
+	}
+
+	if err != nil {
+		return nil, NewSemanticErrorWithCode(ERR_INVALID_CAST_EXPRESSION, position, value, typeAnnotation)
+	}
+	return result, nil
+}
+
+func checkType(value any, expectedType shared.TypeAnnotation, pos shared.Position) error {
+	switch expectedType {
+	case shared.INT:
+		if _, ok := value.(int); !ok {
+			return NewSemanticErrorWithCode(ERR_TYPE_MISMATCH, pos, expectedType, reflect.TypeOf(value))
+		}
+	case shared.FLOAT:
+		if _, ok := value.(float64); !ok {
+			return NewSemanticErrorWithCode(ERR_TYPE_MISMATCH, pos, expectedType, reflect.TypeOf(value))
+		}
+	case shared.BOOL:
+		if _, ok := value.(bool); !ok {
+			return NewSemanticErrorWithCode(ERR_TYPE_MISMATCH, pos, expectedType, reflect.TypeOf(value))
+		}
+	case shared.STRING:
+		if _, ok := value.(string); !ok {
+			return NewSemanticErrorWithCode(ERR_TYPE_MISMATCH, pos, expectedType, reflect.TypeOf(value))
+		}
+	default:
+		return NewSemanticErrorWithCode(ERR_INVALID_TYPE_ANNOTATION, pos, expectedType)
+	}
+	return nil
+}
+
+func determineType(value any) shared.TypeAnnotation {
+	switch value.(type) {
+	case int:
+		return shared.INT
+	case float64:
+		return shared.FLOAT
+	case bool:
+		return shared.BOOL
+	case string:
+		return shared.STRING
+	default:
+		return shared.VOID
+	}
+}
+
+// '-' and '!' share the negate expression, bool values are negated logically
+func negate(value any) (any, bool) {
+	switch value := value.(type) {
+	case int:
+		return -value, true
+	case float64:
+		return -value, true
+	case bool:
+		return !value, true
+	}
+	return nil, false
+}
+
+func multiply(left, right any) (any, bool) {
+	switch l := left.(type) {
+	case int:
+		switch r := right.(type) {
+		case int:
+			return l * r, true
+		case float64:
+			return float64(l) * r, true
+		}
+	case float64:
+		switch r := right.(type) {
+		case float64:
+			return l * r, true
+		case int:
+			return l * float64(r), true
+		}
+	}
+	return nil, false
+}
+
+func isZero(value any) bool {
+	if v, ok := value.(int); ok && v == 0 {
+		return true
+	} else if v, ok := value.(float64); ok && v == 0.0 {
+		return true
+	}
+	return false
+}
+
+// the right operand has to be checked with isZero first
+func divide(left, right any) (any, bool) {
+	switch l := left.(type) {
+	case int:
+		if r, ok := right.(int); ok {
+			return l / r, true
+		}
+		if r, ok := right.(float64); ok {
+			return float64(l) / r, true
+		}
+	case float64:
+		switch r := right.(type) {
+		case float64:
+			return l / r, true
+		case int:
+			return l / float64(r), true
+		}
+	}
+	return nil, false
+}
+
+func sum(left, right any) (any, bool) {
+	var result any
+	var err error
+
+	switch l := left.(type) {
+	case int:
+		result, err = sumInt(l, right)
+	case float64:
+		result, err = sumFloat64(l, right)
+	case string:
+		result, err = sumString(l, right)
+	default:
+		err = fmt.Errorf("invalid left operand type: %T", left)
+	}
+	return result, err == nil
+}
+
+// helper function for the sum expression function
+func sumInt(left int, right any) (any, error) {
+	switch right := right.(type) {
+	case int:
+		return left + right, nil
+	case float64:
+		return float64(left) + right, nil
+	case string:
+		return fmt.Sprintf("%d%s", left, right), nil
+	default:
+		return nil, fmt.Errorf("invalid right operand type: %T", right)
+	}
+}
+
+// helper function for the sum expression function
+func sumFloat64(left float64, right any) (any, error) {
+	switch right := right.(type) {
+	case int:
+		return left + float64(right), nil
+	case float64:
+		return left + right, nil
+	case string:
+		return fmt.Sprintf("%f%s", left, right), nil
+	default:
+		return nil, fmt.Errorf("invalid right operand type: %T", right)
+	}
+}
+
+// helper function for the sum expression function
+func sumString(left string, right any) (any, error) {
+	switch right := right.(type) {
+	case int:
+		return left + fmt.Sprintf("%d", right), nil
+	case float64:
+		return left + fmt.Sprintf("%f", right), nil
+	case string:
+		return left + right, nil
+	default:
+		return nil, fmt.Errorf("invalid right operand type: %T", right)
+	}
+}
+
+// an int or float left operand with a right operand of other type
+// leaves the right operand as the result
+func subtract(left, right any) (any, bool) {
+	switch l := left.(type) {
+	case int:
+		switch r := right.(type) {
+		case int:
+			return l - r, true
+		}
+	case float64:
+		switch r := right.(type) {
+		case float64:
+			return l - r, true
+		case int:
+			return l - float64(r), true
+		}
+	default:
+		return nil, false
+	}
+	return right, true
+}
+
+// only two ints or two floats can be compared
+func compareNumbers(left, right any, ints func(a, b int) bool, floats func(a, b float64) bool) (bool, bool) {
+	switch l := left.(type) {
+	case int:
+		if r, ok := right.(int); ok {
+			return ints(l, r), true
+		}
+	case float64:
+		if r, ok := right.(float64); ok {
+			return floats(l, r), true
+		}
+	}
+	return false, false
+}
+
+func greaterThan(left, right any) (bool, bool) {
+	return compareNumbers(left, right,
+		func(a, b int) bool { return a > b },
+		func(a, b float64) bool { return a > b })
+}
+
+func greaterOrEqual(left, right any) (bool, bool) {
+	return compareNumbers(left, right,
+		func(a, b int) bool { return a >= b },
+		func(a, b float64) bool { return a >= b })
+}
+
+func lessThan(left, right any) (bool, bool) {
+	return compareNumbers(left, right,
+		func(a, b int) bool { return a < b },
+		func(a, b float64) bool { return a < b })
+}
+
+func lessOrEqual(left, right any) (bool, bool) {
+	return compareNumbers(left, right,
+		func(a, b int) bool { return a <= b },
+		func(a, b float64) bool { return a <= b })
+}
+
+// builds the error of a binary operator that got operands of wrong types
+func operandsError(code ErrorCode, position shared.Position, left, right any) *SemantciError {
+	return NewSemanticErrorWithCode(code, position, reflect.TypeOf(left), reflect.TypeOf(right))
+}
+
+// a variable keeps the type of the value it was declared with
+func checkVariableType(variable, value any) error {
+	var ok bool
+	switch variable.(type) {
+	case int:
+		_, ok = value.(int)
+	case bool:
+		_, ok = value.(bool)
+	case float64:
+		_, ok = value.(float64)
+	case string:
+		_, ok = value.(string)
+	default:
+		return nil
+	}
+	if !ok {
+		return NewSemanticErrorWithCode(ERR_TYPE_MISMATCH, shared.Position{}, reflect.TypeOf(variable), reflect.TypeOf(value))
+	}
+	return nil
+}
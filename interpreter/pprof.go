@@ -0,0 +1,190 @@
+package interpreter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sort"
+)
+
+// fields of the messages of profile.proto used by go tool pprof
+const (
+	PPROF_SAMPLE_TYPE         = 1
+	PPROF_SAMPLE              = 2
+	PPROF_LOCATION            = 4
+	PPROF_FUNCTION            = 5
+	PPROF_STRING_TABLE        = 6
+	PPROF_DURATION_NANOS      = 10
+	PPROF_PERIOD_TYPE         = 11
+	PPROF_PERIOD              = 12
+	PPROF_DEFAULT_SAMPLE_TYPE = 14
+
+	PPROF_VALUE_TYPE_TYPE = 1
+	PPROF_VALUE_TYPE_UNIT = 2
+
+	PPROF_SAMPLE_LOCATION_ID = 1
+	PPROF_SAMPLE_VALUE       = 2
+
+	PPROF_LOCATION_ID   = 1
+	PPROF_LOCATION_LINE = 4
+
+	PPROF_LINE_FUNCTION_ID = 1
+	PPROF_LINE_LINE        = 2
+
+	PPROF_FUNCTION_ID          = 1
+	PPROF_FUNCTION_NAME        = 2
+	PPROF_FUNCTION_SYSTEM_NAME = 3
+	PPROF_FUNCTION_FILENAME    = 4
+	PPROF_FUNCTION_START_LINE  = 5
+)
+
+// encoder of protocol buffer messages, fields are written in the order of the calls
+type protobuf struct {
+	bytes.Buffer
+}
+
+func (b *protobuf) varint(value uint64) {
+	for value >= 0x80 {
+		b.WriteByte(byte(value) | 0x80)
+		value >>= 7
+	}
+	b.WriteByte(byte(value))
+}
+
+func (b *protobuf) uint(field int, value uint64) {
+	b.varint(uint64(field) << 3)
+	b.varint(value)
+}
+
+func (b *protobuf) bytes(field int, value []byte) {
+	b.varint(uint64(field)<<3 | 2)
+	b.varint(uint64(len(value)))
+	b.Write(value)
+}
+
+func (b *protobuf) message(field int, encode func(m *protobuf)) {
+	m := &protobuf{}
+	encode(m)
+	b.bytes(field, m.Bytes())
+}
+
+func (b *protobuf) packed(field int, values []uint64) {
+	m := &protobuf{}
+	for _, value := range values {
+		m.varint(value)
+	}
+	b.bytes(field, m.Bytes())
+}
+
+// writes the profile in the gzipped protocol buffer format read by go tool pprof,
+// samples are the stacks of calls with their calls and exclusive time,
+// and the lines run by every function with their hits
+func (p *Profile) WritePprof(w io.Writer, fileName string) error {
+	strings := map[string]uint64{}
+	table := []string{}
+	index := func(s string) uint64 {
+		if i, ok := strings[s]; ok {
+			return i
+		}
+		strings[s] = uint64(len(table))
+		table = append(table, s)
+		return strings[s]
+	}
+	// the first string has to be empty
+	index("")
+
+	b := &protobuf{}
+	valueType := func(field int, kind, unit string) {
+		b.message(field, func(m *protobuf) {
+			m.uint(PPROF_VALUE_TYPE_TYPE, index(kind))
+			m.uint(PPROF_VALUE_TYPE_UNIT, index(unit))
+		})
+	}
+	valueType(PPROF_SAMPLE_TYPE, "calls", "count")
+	valueType(PPROF_SAMPLE_TYPE, "time", "nanoseconds")
+	valueType(PPROF_SAMPLE_TYPE, "hits", "count")
+
+	names := make([]string, 0, len(p.Functions))
+	for name := range p.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	functionIDs := map[*FunctionProfile]uint64{}
+	for i, name := range names {
+		function := p.Functions[name]
+		functionIDs[function] = uint64(i + 1)
+		b.message(PPROF_FUNCTION, func(m *protobuf) {
+			m.uint(PPROF_FUNCTION_ID, uint64(i+1))
+			m.uint(PPROF_FUNCTION_NAME, index(name))
+			m.uint(PPROF_FUNCTION_SYSTEM_NAME, index(name))
+			m.uint(PPROF_FUNCTION_FILENAME, index(fileName))
+			m.uint(PPROF_FUNCTION_START_LINE, uint64(function.Line))
+		})
+	}
+
+	locationIDs := map[lineOfFunction]uint64{}
+	location := func(function *FunctionProfile, line int) uint64 {
+		key := lineOfFunction{function: function, line: line}
+		if id, ok := locationIDs[key]; ok {
+			return id
+		}
+		id := uint64(len(locationIDs) + 1)
+		locationIDs[key] = id
+		b.message(PPROF_LOCATION, func(m *protobuf) {
+			m.uint(PPROF_LOCATION_ID, id)
+			m.message(PPROF_LOCATION_LINE, func(l *protobuf) {
+				l.uint(PPROF_LINE_FUNCTION_ID, functionIDs[function])
+				l.uint(PPROF_LINE_LINE, uint64(line))
+			})
+		})
+		return id
+	}
+	sample := func(locations []uint64, calls, nanoseconds, hits int64) {
+		b.message(PPROF_SAMPLE, func(m *protobuf) {
+			m.packed(PPROF_SAMPLE_LOCATION_ID, locations)
+			m.packed(PPROF_SAMPLE_VALUE, []uint64{uint64(calls), uint64(nanoseconds), uint64(hits)})
+		})
+	}
+
+	keys := make([]string, 0, len(p.stacks))
+	for key := range p.stacks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		stack := p.stacks[key]
+		// the innermost frame first
+		locations := make([]uint64, len(stack.frames))
+		for i, frame := range stack.frames {
+			locations[len(locations)-1-i] = location(frame.function, frame.line)
+		}
+		sample(locations, int64(stack.calls), int64(stack.exclusive), 0)
+	}
+
+	hits := make([]lineOfFunction, 0, len(p.hits))
+	for key := range p.hits {
+		hits = append(hits, key)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].function.Name < hits[j].function.Name ||
+			hits[i].function.Name == hits[j].function.Name && hits[i].line < hits[j].line
+	})
+	for _, key := range hits {
+		sample([]uint64{location(key.function, key.line)}, 0, 0, int64(p.hits[key]))
+	}
+
+	b.uint(PPROF_DURATION_NANOS, uint64(p.Total))
+	valueType(PPROF_PERIOD_TYPE, "time", "nanoseconds")
+	b.uint(PPROF_PERIOD, 1)
+	b.uint(PPROF_DEFAULT_SAMPLE_TYPE, index("time"))
+	// the table is complete once every message is encoded
+	for _, s := range table {
+		b.bytes(PPROF_STRING_TABLE, []byte(s))
+	}
+
+	compressed := gzip.NewWriter(w)
+	if _, err := compressed.Write(b.Bytes()); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
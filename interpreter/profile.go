@@ -0,0 +1,209 @@
+package interpreter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"tkom/shared"
+)
+
+// measures where the program spends its time, set as CodeVisitor.Profile
+//
+// a call is timed from the moment its arguments are evaluated until it
+// returns, so a call in the arguments of another one is not part of it
+type Profile struct {
+	// statistics of every called function by its name
+	Functions map[string]*FunctionProfile
+	// times the statements and switch cases of every line ran
+	Lines map[int]int
+	// time of the calls made from outside of any profiled call
+	Total time.Duration
+
+	// calls and time of every distinct stack of calls, for the pprof format
+	stacks map[string]*stackProfile
+	// hits of the lines by the function running them, for the pprof format
+	hits map[lineOfFunction]int
+	open []*openCall
+	// counts the running calls of every function, inclusive time of
+	// recursive calls is counted once
+	active map[string]int
+	now    func() time.Time
+}
+
+type FunctionProfile struct {
+	Name string
+	// line the function is defined at, 0 for builtins
+	Line  int
+	Calls int
+	// time of the calls including the functions they called
+	Inclusive time.Duration
+	// time of the calls without the functions they called
+	Exclusive time.Duration
+}
+
+type openCall struct {
+	function *FunctionProfile
+	// position of the call in the calling function
+	callSite shared.Position
+	depth    int
+	start    time.Time
+	children time.Duration
+}
+
+// frame of a stack, the line is where the function calls the next
+// frame, or where it is defined for the innermost one
+type stackFrame struct {
+	function *FunctionProfile
+	line     int
+}
+
+type stackProfile struct {
+	frames    []stackFrame
+	calls     int
+	exclusive time.Duration
+}
+
+type lineOfFunction struct {
+	function *FunctionProfile
+	line     int
+}
+
+func NewProfile() *Profile {
+	return &Profile{
+		Functions: map[string]*FunctionProfile{},
+		Lines:     map[int]int{},
+		stacks:    map[string]*stackProfile{},
+		hits:      map[lineOfFunction]int{},
+		active:    map[string]int{},
+		now:       time.Now,
+	}
+}
+
+// starts the call on the top of the call stack of the given depth
+func (p *Profile) enter(name string, line int, callSite shared.Position, depth int) {
+	if p == nil {
+		return
+	}
+	function, ok := p.Functions[name]
+	if !ok {
+		function = &FunctionProfile{Name: name, Line: line}
+		p.Functions[name] = function
+	}
+	function.Calls++
+	p.active[name]++
+	p.open = append(p.open, &openCall{function: function, callSite: callSite, depth: depth, start: p.now()})
+}
+
+// ends the call started at the depth, calls whose arguments failed were not started
+func (p *Profile) exit(depth int) {
+	if p == nil || len(p.open) == 0 || p.open[len(p.open)-1].depth != depth {
+		return
+	}
+	call := p.open[len(p.open)-1]
+	elapsed := p.now().Sub(call.start)
+	exclusive := elapsed - call.children
+
+	stack := p.stack()
+	stack.calls++
+	stack.exclusive += exclusive
+	p.open = p.open[:len(p.open)-1]
+
+	call.function.Exclusive += exclusive
+	p.active[call.function.Name]--
+	if p.active[call.function.Name] == 0 {
+		call.function.Inclusive += elapsed
+	}
+	if len(p.open) > 0 {
+		p.open[len(p.open)-1].children += elapsed
+	} else {
+		p.Total += elapsed
+	}
+}
+
+// a tail call ends the running call and starts the next one in its place
+func (p *Profile) restart(depth int) {
+	if p == nil || len(p.open) == 0 || p.open[len(p.open)-1].depth != depth {
+		return
+	}
+	call := p.open[len(p.open)-1]
+	p.exit(depth)
+	p.enter(call.function.Name, call.function.Line, call.callSite, depth)
+}
+
+// profile of the calls open now
+func (p *Profile) stack() *stackProfile {
+	frames := make([]stackFrame, len(p.open))
+	var key strings.Builder
+	for i, call := range p.open {
+		frames[i] = stackFrame{function: call.function, line: call.function.Line}
+		if i < len(p.open)-1 {
+			frames[i].line = p.open[i+1].callSite.Line
+		}
+		key.WriteString(call.function.Name + ":" + strconv.Itoa(frames[i].line) + ";")
+	}
+	stack, ok := p.stacks[key.String()]
+	if !ok {
+		stack = &stackProfile{frames: frames}
+		p.stacks[key.String()] = stack
+	}
+	return stack
+}
+
+// counts the statement at the position, run by the innermost call
+func (p *Profile) hit(position shared.Position) {
+	if p == nil || position.Line == 0 {
+		return
+	}
+	p.Lines[position.Line]++
+	if len(p.open) > 0 {
+		p.hits[lineOfFunction{function: p.open[len(p.open)-1].function, line: position.Line}]++
+	}
+}
+
+// functions sorted by their exclusive time, the slowest first
+func (p *Profile) SortedFunctions() []*FunctionProfile {
+	functions := make([]*FunctionProfile, 0, len(p.Functions))
+	for _, function := range p.Functions {
+		functions = append(functions, function)
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		a, b := functions[i], functions[j]
+		return a.Exclusive > b.Exclusive || a.Exclusive == b.Exclusive && a.Name < b.Name
+	})
+	return functions
+}
+
+// prints the functions and the lines run, lines are followed by their
+// code when the lines of the source are given
+func (p *Profile) WriteTable(w io.Writer, source []string) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(table, "function\tcalls\tinclusive\texclusive\texclusive %%\t\n")
+	for _, function := range p.SortedFunctions() {
+		share := 0.0
+		if p.Total > 0 {
+			share = 100 * float64(function.Exclusive) / float64(p.Total)
+		}
+		fmt.Fprintf(table, "%s\t%d\t%v\t%v\t%.1f\t\n", function.Name, function.Calls,
+			function.Inclusive.Round(time.Microsecond), function.Exclusive.Round(time.Microsecond), share)
+	}
+	fmt.Fprintln(table)
+
+	lines := make([]int, 0, len(p.Lines))
+	for line := range p.Lines {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	fmt.Fprintf(table, "line\thits\t\n")
+	for _, line := range lines {
+		code := ""
+		if line > 0 && line <= len(source) {
+			code = strings.TrimSpace(source[line-1])
+		}
+		fmt.Fprintf(table, "%d\t%d\t  %s\n", line, p.Lines[line], code)
+	}
+	return table.Flush()
+}
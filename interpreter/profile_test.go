@@ -0,0 +1,207 @@
+package interpreter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"tkom/ast"
+	"tkom/shared"
+)
+
+const profileSource = `main() {
+    int i := 0
+    while i < 3 {
+        i = i + 1
+    }
+    print(fact(3), countdown(2))
+}
+
+fact(n int) int {
+    if n <= 1 {
+        return 1
+    }
+    return n * fact(n - 1)
+}
+
+countdown(n int) int {
+    if n == 0 {
+        return 0
+    }
+    return countdown(n - 1)
+}
+`
+
+// runs the program with a clock advancing a millisecond every time it is read
+func profileRun(t *testing.T) *Profile {
+	t.Helper()
+	program := parseProgram(t, profileSource)
+	ResolveProgram(program)
+	visitor := NewCodeVisitor(MAX_RECURSION_DEPTH)
+	profile := NewProfile()
+	clock := time.Time{}
+	profile.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+	visitor.Profile = profile
+	Output = io.Discard
+	defer func() { Output = nil }()
+	visitor.Run(program, &ast.FunctionCall{Name: "main", Position: shared.NewPosition(1, 1)})
+	return profile
+}
+
+func TestProfileFunctions(t *testing.T) {
+	profile := profileRun(t)
+
+	// the clock is read at the start and the end of every call: main 1-16,
+	// fact(3) 2-7 running fact(2) 3-6 and fact(1) 4-5, countdown(2) 8-9
+	// replaced by the tail calls countdown(1) 10-11 and countdown(0) 12-13,
+	// print 14-15
+	expected := map[string]FunctionProfile{
+		"main":      {Name: "main", Line: 1, Calls: 1, Inclusive: 15 * time.Millisecond, Exclusive: 6 * time.Millisecond},
+		"fact":      {Name: "fact", Line: 9, Calls: 3, Inclusive: 5 * time.Millisecond, Exclusive: 5 * time.Millisecond},
+		"countdown": {Name: "countdown", Line: 16, Calls: 3, Inclusive: 3 * time.Millisecond, Exclusive: 3 * time.Millisecond},
+		"print":     {Name: "print", Line: 0, Calls: 1, Inclusive: time.Millisecond, Exclusive: time.Millisecond},
+	}
+	if len(profile.Functions) != len(expected) {
+		t.Errorf("expected %d functions, got %d", len(expected), len(profile.Functions))
+	}
+	for name, function := range expected {
+		if got := profile.Functions[name]; got == nil || *got != function {
+			t.Errorf("expected %+v, got %+v", function, got)
+		}
+	}
+	if profile.Total != 15*time.Millisecond {
+		t.Errorf("expected total 15ms, got %v", profile.Total)
+	}
+
+	sorted := profile.SortedFunctions()
+	if sorted[0].Name != "main" || sorted[len(sorted)-1].Name != "print" {
+		t.Errorf("expected functions sorted by exclusive time, got %v, ..., %v", sorted[0].Name, sorted[len(sorted)-1].Name)
+	}
+}
+
+func TestProfileLines(t *testing.T) {
+	profile := profileRun(t)
+
+	expected := map[int]int{2: 1, 3: 1, 4: 3, 6: 1, 10: 3, 11: 1, 13: 2, 17: 3, 18: 1, 20: 2}
+	if len(profile.Lines) != len(expected) {
+		t.Errorf("expected lines %v, got %v", expected, profile.Lines)
+	}
+	for line, hits := range expected {
+		if profile.Lines[line] != hits {
+			t.Errorf("expected %d hits of line %d, got %d", hits, line, profile.Lines[line])
+		}
+	}
+}
+
+func TestProfileTable(t *testing.T) {
+	profile := profileRun(t)
+	var out strings.Builder
+	if err := profile.WriteTable(&out, strings.Split(profileSource, "\n")); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"function  calls  inclusive  exclusive  exclusive %",
+		"     fact      3        5ms        5ms         33.3",
+		"line  hits",
+		"   4     3  i = i + 1",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in the table:\n%s", expected, out.String())
+		}
+	}
+}
+
+// field of a protocol buffer message, value is the number or the bytes
+type protobufField struct {
+	number int
+	value  uint64
+	bytes  []byte
+}
+
+func decodeProtobuf(t *testing.T, data []byte) []protobufField {
+	t.Helper()
+	varint := func() uint64 {
+		var value uint64
+		for shift := 0; ; shift += 7 {
+			if len(data) == 0 {
+				t.Fatal("truncated varint")
+			}
+			b := data[0]
+			data = data[1:]
+			value |= uint64(b&0x7f) << shift
+			if b < 0x80 {
+				return value
+			}
+		}
+	}
+	fields := []protobufField{}
+	for len(data) > 0 {
+		key := varint()
+		field := protobufField{number: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			field.value = varint()
+		case 2:
+			length := varint()
+			field.bytes, data = data[:length], data[length:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func TestProfilePprof(t *testing.T) {
+	profile := profileRun(t)
+	var out bytes.Buffer
+	if err := profile.WritePprof(&out, "main.fl"); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[int]int{}
+	var table []string
+	var duration uint64
+	for _, field := range decodeProtobuf(t, data) {
+		counts[field.number]++
+		switch field.number {
+		case PPROF_STRING_TABLE:
+			table = append(table, string(field.bytes))
+		case PPROF_DURATION_NANOS:
+			duration = field.value
+		}
+	}
+	if len(table) == 0 || table[0] != "" {
+		t.Fatalf("expected the string table to start with the empty string, got %q", table)
+	}
+	for _, s := range []string{"main", "fact", "countdown", "print", "main.fl", "time", "nanoseconds"} {
+		found := false
+		for _, got := range table {
+			found = found || got == s
+		}
+		if !found {
+			t.Errorf("expected %q in the string table %q", s, table)
+		}
+	}
+	// stacks: main, main;fact, main;fact;fact, main;fact;fact;fact, main;countdown, main;print,
+	// lines of main, fact and countdown
+	if counts[PPROF_SAMPLE_TYPE] != 3 || counts[PPROF_FUNCTION] != 4 || counts[PPROF_SAMPLE] != 6+10 {
+		t.Errorf("expected 3 sample types, 4 functions and 16 samples, got %v", counts)
+	}
+	if duration != uint64(15*time.Millisecond) {
+		t.Errorf("expected duration 15ms, got %v", time.Duration(duration))
+	}
+}
@@ -0,0 +1,386 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"tkom/ast"
+	"tkom/diagnostics"
+	"tkom/shared"
+)
+
+// binds identifiers and declarations of the program to slots of the
+// scopes the CodeVisitor creates at runtime, so the variables can be
+// read by index instead of by name
+//
+// scopes of the resolver mirror the runtime ones: a function body,
+// an if, a while and a switch statement open a scope, a bare block does not
+//
+// names that cannot be bound to a single declaration, e.g. a variable used
+// before it is declared in the same scope, keep a nil slot and are looked up by name
+type Resolver struct {
+	functions map[string]ast.Function
+	scope     *resolverScope
+	// function being resolved, its calls in tail position are marked
+	function *ast.FunctionDefinition
+	// reported problems that do not stop the program
+	Warnings []*SemantciError
+}
+
+type resolverScope struct {
+	parent *resolverScope
+	// variables declared so far on the path being resolved
+	visible map[string]binding
+	// every variable declared anywhere in the scope
+	declared map[string]int
+	count    int
+}
+
+type binding struct {
+	index    int
+	position shared.Position
+}
+
+func NewResolver(functions map[string]*ast.FunctionDefinition) *Resolver {
+	r := &Resolver{functions: map[string]ast.Function{}}
+	for name, fd := range functions {
+		r.functions[name] = fd
+	}
+	return r
+}
+
+// resolves every function of the program, the first error panics
+// like the runtime errors do, warnings are returned
+func ResolveProgram(program *ast.Program) []*SemantciError {
+	r := NewResolver(program.Functions)
+
+	names := make([]string, 0, len(program.Functions))
+	for name := range program.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		program.Functions[name].Accept(r)
+	}
+	return r.Warnings
+}
+
+func (r *Resolver) openScope(names []string) {
+	scope := &resolverScope{
+		parent:   r.scope,
+		visible:  map[string]binding{},
+		declared: map[string]int{},
+	}
+	for _, name := range names {
+		scope.declared[name] = -1
+	}
+	r.scope = scope
+}
+
+func (r *Resolver) closeScope() {
+	r.scope = r.scope.parent
+}
+
+// copy of the visible variables, restored after a branch that may not run
+func (r *Resolver) snapshot() map[string]binding {
+	visible := make(map[string]binding, len(r.scope.visible))
+	for name, b := range r.scope.visible {
+		visible[name] = b
+	}
+	return visible
+}
+
+func (r *Resolver) declare(variable *ast.Variable) {
+	scope := r.scope
+	if _, ok := scope.visible[variable.Name]; ok {
+		panic(NewSemanticErrorWithCode(ERR_REDECLARED_VARIABLE, variable.Position, variable.Name).WithSpan(variable.Span))
+	}
+
+	for outer := scope.parent; outer != nil; outer = outer.parent {
+		if b, ok := outer.visible[variable.Name]; ok {
+			r.warn(ERR_SHADOWED_VARIABLE, variable.Position, variable.Span, variable.Name, b.position.Line, b.position.Column)
+			break
+		}
+	}
+
+	// declarations in different branches of the scope share the slot,
+	// so two switch arms declaring the same name still collide at runtime
+	index, ok := scope.declared[variable.Name]
+	if !ok || index < 0 {
+		index = scope.count
+		scope.count++
+		scope.declared[variable.Name] = index
+	}
+	scope.visible[variable.Name] = binding{index: index, position: variable.Position}
+	variable.Slot = &ast.Slot{Depth: 0, Index: index}
+}
+
+func (r *Resolver) use(identifier *ast.Identifier) {
+	depth := 0
+	for scope := r.scope; scope != nil; scope = scope.parent {
+		if b, ok := scope.visible[identifier.Name]; ok {
+			identifier.Slot = &ast.Slot{Depth: depth, Index: b.index}
+			return
+		}
+		if _, ok := scope.declared[identifier.Name]; ok {
+			identifier.Slot = nil
+			return
+		}
+		depth++
+	}
+
+	err := NewSemanticErrorWithCode(ERR_UNDEFINED_VARIABLE, identifier.Position, identifier.Name).WithSpan(identifier.Span)
+	panic(addSuggestions(err, identifier.Name, candidateNames(r.visibleNames(), r.functions)))
+}
+
+func (r *Resolver) visibleNames() []string {
+	names := []string{}
+	for scope := r.scope; scope != nil; scope = scope.parent {
+		for name := range scope.visible {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (r *Resolver) warn(code ErrorCode, position shared.Position, span shared.Span, args ...any) {
+	warning := NewSemanticErrorWithCode(code, position, args...).WithSpan(span)
+	warning.Message = fmt.Sprintf("warning [%v, %v]: %s", position.Line, position.Column, warning.Reason)
+	warning.Severity = diagnostics.WARNING
+	r.Warnings = append(r.Warnings, warning)
+}
+
+func (r *Resolver) VisitIntExpression(e *ast.IntExpression)       {}
+func (r *Resolver) VisitFloatExpression(e *ast.FloatExpression)   {}
+func (r *Resolver) VisitStringExpression(e *ast.StringExpression) {}
+func (r *Resolver) VisitBoolExpression(e *ast.BoolExpression)     {}
+
+func (r *Resolver) VisitIdentifier(e *ast.Identifier) {
+	r.use(e)
+}
+
+func (r *Resolver) VisitFunctionCall(fc *ast.FunctionCall) {
+	for _, argument := range fc.Arguments {
+		argument.Accept(r)
+	}
+}
+
+func (r *Resolver) VisitVariable(variable *ast.Variable) {
+	variable.Value.Accept(r)
+	r.declare(variable)
+}
+
+func (r *Resolver) VisitAssignement(assignment *ast.Assignment) {
+	assignment.Value.Accept(r)
+	r.use(assignment.Identifier)
+}
+
+func (r *Resolver) VisitNegateExpression(e *ast.NegateExpression) {
+	e.Expression.Accept(r)
+}
+
+func (r *Resolver) VisitCastExpression(e *ast.CastExpression) {
+	e.LeftExpression.Accept(r)
+}
+
+func (r *Resolver) VisitMultiplyExpression(e *ast.MultiplyExpression) {
+	e.LeftExpression.Accept(r)
+	e.RightExpression.Accept(r)
+}
+
+func (r *Resolver) VisitDivideExpression(e *ast.DivideExpression) {
+	e.LeftExpression.Accept(r)
+	e.RightExpression.Accept(r)
+}
+
+func (r *Resolver) VisitSumExpression(e *ast.SumExpression) {
+	e.LeftExpression.Accept(r)
+	e.RightExpression.Accept(r)
+}
+
+func (r *Resolver) VisitSubstractExpression(e *ast.SubstractExpression) {
+	e.LeftExpression.Accept(r)
+	e.RightExpression.Accept(r)
+}
+
+func (r *Resolver) VisitEqualsExpression(e *ast.EqualsExpression) {
+	e.LeftExpression.Accept(r)
+	e.RightExpression.Accept(r)
+}
+
+func (r *Resolver) VisitNotEqualsExpression(e *ast.NotEqualsExpression) {
+	e.LeftExpression.Accept(r)
+	e.RightExpression.Accept(r)
+}
+
+func (r *Resolver) VisitGreaterThanExpression(e *ast.GreaterThanExpression) {
+	e.LeftExpression.Accept(r)
+	e.RightExpression.Accept(r)
+}
+
+func (r *Resolver) VisitLessThanExpression(e *ast.LessThanExpression) {
+	e.LeftExpression.Accept(r)
+	e.RightExpression.Accept(r)
+}
+
+func (r *Resolver) VisitGreaterOrEqualExpression(e *ast.GreaterOrEqualExpression) {
+	e.LeftExpression.Accept(r)
+	e.RightExpression.Accept(r)
+}
+
+func (r *Resolver) VisitLessOrEqualExpression(e *ast.LessOrEqualExpression) {
+	e.LeftExpression.Accept(r)
+	e.RightExpression.Accept(r)
+}
+
+func (r *Resolver) VisitAndExpression(e *ast.AndExpression) {
+	e.LeftExpression.Accept(r)
+	e.RightExpression.Accept(r)
+}
+
+func (r *Resolver) VisitOrExpression(e *ast.OrExpression) {
+	e.LeftExpression.Accept(r)
+	e.RightExpression.Accept(r)
+}
+
+func (r *Resolver) VisitBlock(block *ast.Block) {
+	for _, statement := range block.Statements {
+		statement.Accept(r)
+	}
+}
+
+func (r *Resolver) VisitIfStatement(ifStmt *ast.IfStatement) {
+	r.openScope(DeclaredNames(ifStmt.InstructionsBlock, ifStmt.ElseInstructionsBlock))
+	ifStmt.Condition.Accept(r)
+
+	visible := r.snapshot()
+	ifStmt.InstructionsBlock.Accept(r)
+	if ifStmt.ElseInstructionsBlock != nil {
+		r.scope.visible = visible
+		ifStmt.ElseInstructionsBlock.Accept(r)
+	}
+	r.closeScope()
+}
+
+func (r *Resolver) VisitReturnStatement(returnStmt *ast.ReturnStatement) {
+	if returnStmt.Value != nil {
+		returnStmt.Value.Accept(r)
+		r.markTail(returnStmt.Value)
+	}
+}
+
+// a call of the function being resolved that gives its result
+// to the caller unchanged is run in place of the caller
+func (r *Resolver) markTail(node ast.Node) {
+	if fc, ok := node.(*ast.FunctionCall); ok && r.function != nil && fc.Name == r.function.Name {
+		fc.Tail = true
+	}
+}
+
+// an arm leaving a value returns it, void functions never
+// get a value from their own call so the switch goes on
+func (r *Resolver) markTailArm(output ast.Expression) {
+	if r.function != nil && r.function.Type != shared.VOID {
+		r.markTail(ArmValue(output))
+	}
+}
+
+func (r *Resolver) VisitWhileStatement(whileStmt *ast.WhileStatement) {
+	r.openScope(DeclaredNames(whileStmt.InstructionsBlock))
+	whileStmt.Condition.Accept(r)
+	whileStmt.InstructionsBlock.Accept(r)
+	r.closeScope()
+}
+
+func (r *Resolver) VisitSwitchStatement(s *ast.SwitchStatement) {
+	names := []string{}
+	for _, variable := range s.Variables {
+		names = append(names, variable.Name)
+	}
+	for _, c := range s.Cases {
+		names = append(names, CaseDeclaredNames(c)...)
+	}
+	r.openScope(names)
+
+	for _, variable := range s.Variables {
+		variable.Accept(r)
+	}
+
+	// arms run one after another as long as none of them ends the switch,
+	// so an arm cannot rely on variables declared by the previous ones
+	variables := r.snapshot()
+	for _, c := range s.Cases {
+		c.Accept(r)
+		r.scope.visible = variables
+		variables = r.snapshot()
+	}
+	r.closeScope()
+}
+
+// names declared by a case whose output is a block
+func CaseDeclaredNames(c ast.Case) []string {
+	var output ast.Expression
+	switch c := c.(type) {
+	case *ast.SwitchCase:
+		output = c.OutputExpression
+	case *ast.DefaultSwitchCase:
+		output = c.OutputExpression
+	}
+	if block, ok := output.(*ast.Block); ok {
+		return DeclaredNames(block)
+	}
+	return nil
+}
+
+// the last statement of an arm, when it leaves a value
+func ArmValue(node ast.Node) ast.Node {
+	block, ok := node.(*ast.Block)
+	if !ok {
+		return node
+	}
+	if len(block.Statements) == 0 {
+		return nil
+	}
+	switch last := block.Statements[len(block.Statements)-1].(type) {
+	case *ast.Variable, *ast.Assignment, *ast.IfStatement,
+		*ast.WhileStatement, *ast.SwitchStatement, *ast.ReturnStatement:
+		return nil
+	default:
+		return ArmValue(last)
+	}
+}
+
+func (r *Resolver) VisitSwitchCase(sc *ast.SwitchCase) {
+	sc.Condition.Accept(r)
+	sc.OutputExpression.Accept(r)
+	r.markTailArm(sc.OutputExpression)
+}
+
+func (r *Resolver) VisitDefaultSwitchCase(dsc *ast.DefaultSwitchCase) {
+	dsc.OutputExpression.Accept(r)
+	r.markTailArm(dsc.OutputExpression)
+}
+
+func (r *Resolver) VisitFunctionDefinition(fd *ast.FunctionDefinition) {
+	names := []string{}
+	for _, param := range fd.Parameters {
+		names = append(names, param.Name)
+	}
+
+	scope, function := r.scope, r.function
+	r.scope, r.function = nil, fd
+	r.openScope(append(names, DeclaredNames(fd.Block)...))
+	for _, param := range fd.Parameters {
+		r.declare(param)
+	}
+	fd.Block.Accept(r)
+	r.scope, r.function = scope, function
+}
+
+func (r *Resolver) VisitProgram(program *ast.Program) {
+	for _, fd := range program.Functions {
+		fd.Accept(r)
+	}
+}
+
+func (r *Resolver) VisitEmbeddedFunction(ef *ast.EmbeddedFunction) {}
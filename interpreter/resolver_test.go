@@ -0,0 +1,442 @@
+package interpreter
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"tkom/ast"
+	"tkom/diagnostics"
+	"tkom/lexer"
+	"tkom/parser"
+)
+
+func parseProgram(t *testing.T, source string) *ast.Program {
+	t.Helper()
+	scanner, _ := lexer.NewScanner(strings.NewReader(source))
+	lex := lexer.NewLexer(scanner, 500, 1000, math.MaxInt)
+	errorHandler := func(err error) {
+		t.Fatalf("unexpected syntax error: %v", err)
+	}
+	lex.ErrorHandler = errorHandler
+	return parser.NewParser(lex, errorHandler).ParseProgram()
+}
+
+// runs the resolver and returns the error it panicked with
+func resolveError(program *ast.Program) (err *SemantciError) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(*SemantciError)
+		}
+	}()
+	ResolveProgram(program)
+	return nil
+}
+
+// collects the nodes of the function by the variable name, in the order of the source
+type slotCollector struct {
+	identifiers map[string][]*ast.Identifier
+	variables   map[string][]*ast.Variable
+}
+
+func collectSlots(fd *ast.FunctionDefinition) *slotCollector {
+	c := &slotCollector{
+		identifiers: map[string][]*ast.Identifier{},
+		variables:   map[string][]*ast.Variable{},
+	}
+	for _, param := range fd.Parameters {
+		c.variables[param.Name] = append(c.variables[param.Name], param)
+	}
+	c.walk(fd.Block)
+	return c
+}
+
+func (c *slotCollector) walk(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.Block:
+		for _, statement := range node.Statements {
+			c.walk(statement)
+		}
+	case *ast.Variable:
+		c.walk(node.Value)
+		c.variables[node.Name] = append(c.variables[node.Name], node)
+	case *ast.Assignment:
+		c.walk(node.Value)
+		c.identifiers[node.Identifier.Name] = append(c.identifiers[node.Identifier.Name], node.Identifier)
+	case *ast.Identifier:
+		c.identifiers[node.Name] = append(c.identifiers[node.Name], node)
+	case *ast.IfStatement:
+		c.walk(node.Condition)
+		c.walk(node.InstructionsBlock)
+		if node.ElseInstructionsBlock != nil {
+			c.walk(node.ElseInstructionsBlock)
+		}
+	case *ast.WhileStatement:
+		c.walk(node.Condition)
+		c.walk(node.InstructionsBlock)
+	case *ast.SwitchStatement:
+		for _, variable := range node.Variables {
+			c.walk(variable)
+		}
+		for _, switchCase := range node.Cases {
+			c.walk(switchCase)
+		}
+	case *ast.SwitchCase:
+		c.walk(node.Condition)
+		c.walk(node.OutputExpression)
+	case *ast.DefaultSwitchCase:
+		c.walk(node.OutputExpression)
+	case *ast.ReturnStatement:
+		if node.Value != nil {
+			c.walk(node.Value)
+		}
+	case *ast.FunctionCall:
+		for _, argument := range node.Arguments {
+			c.walk(argument)
+		}
+	case *ast.SumExpression:
+		c.walk(node.LeftExpression)
+		c.walk(node.RightExpression)
+	case *ast.LessThanExpression:
+		c.walk(node.LeftExpression)
+		c.walk(node.RightExpression)
+	case *ast.GreaterThanExpression:
+		c.walk(node.LeftExpression)
+		c.walk(node.RightExpression)
+	case *ast.EqualsExpression:
+		c.walk(node.LeftExpression)
+		c.walk(node.RightExpression)
+	}
+}
+
+func TestResolverAssignsSlots(t *testing.T) {
+	program := parseProgram(t, `
+add(a, b int) int {
+    int c := a + b
+    if c > 0 {
+        int d := c
+        while d < 10 {
+            d = d + a
+        }
+        return d
+    }
+    return c
+}
+`)
+	if err := resolveError(program); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := collectSlots(program.Functions["add"])
+
+	variables := []struct {
+		name     string
+		expected ast.Slot
+	}{
+		{"a", ast.Slot{Depth: 0, Index: 0}},
+		{"b", ast.Slot{Depth: 0, Index: 1}},
+		{"c", ast.Slot{Depth: 0, Index: 2}},
+		{"d", ast.Slot{Depth: 0, Index: 0}},
+	}
+	for _, test := range variables {
+		variable := c.variables[test.name][0]
+		if variable.Slot == nil || *variable.Slot != test.expected {
+			t.Errorf("expected declaration of %s at %v, got %v", test.name, test.expected, variable.Slot)
+		}
+	}
+
+	identifiers := []struct {
+		name     string
+		expected []ast.Slot
+	}{
+		// a + b, d = d + a inside the while
+		{"a", []ast.Slot{{Depth: 0, Index: 0}, {Depth: 2, Index: 0}}},
+		{"b", []ast.Slot{{Depth: 0, Index: 1}}},
+		// c > 0, int d := c, return c
+		{"c", []ast.Slot{{Depth: 1, Index: 2}, {Depth: 1, Index: 2}, {Depth: 0, Index: 2}}},
+		// d < 10, d = ..., d + a, return d
+		{"d", []ast.Slot{{Depth: 1, Index: 0}, {Depth: 1, Index: 0}, {Depth: 1, Index: 0}, {Depth: 0, Index: 0}}},
+	}
+	for _, test := range identifiers {
+		uses := c.identifiers[test.name]
+		if len(uses) != len(test.expected) {
+			t.Fatalf("expected %d uses of %s, got %d", len(test.expected), test.name, len(uses))
+		}
+		for i, use := range uses {
+			if use.Slot == nil || *use.Slot != test.expected[i] {
+				t.Errorf("expected use %d of %s at %v, got %v", i, test.name, test.expected[i], use.Slot)
+			}
+		}
+	}
+}
+
+func TestResolverLeavesAmbiguousNamesUnresolved(t *testing.T) {
+	program := parseProgram(t, `
+main() {
+    int a := 1
+    if a > 0 {
+        print(a)
+        int a := 2
+    }
+    switch int b := 1 {
+        b == 1 => {
+            int c := 1
+        },
+        b == 1 => {
+            print(c)
+            int c := 2
+        }
+    }
+}
+`)
+	if err := resolveError(program); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := collectSlots(program.Functions["main"])
+
+	// the outer a is printed, the inner one is declared later in the same scope
+	if slot := c.identifiers["a"][1].Slot; slot != nil {
+		t.Errorf("expected a used before its declaration to be looked up by name, got %v", slot)
+	}
+	// c exists only when the first arm ran
+	if slot := c.identifiers["c"][0].Slot; slot != nil {
+		t.Errorf("expected c declared by another arm to be looked up by name, got %v", slot)
+	}
+	// both arms can run, so their declarations collide in the same slot
+	first, second := c.variables["c"][0].Slot, c.variables["c"][1].Slot
+	if first == nil || second == nil || *first != *second {
+		t.Errorf("expected declarations of c in both arms to share the slot, got %v and %v", first, second)
+	}
+}
+
+func TestResolverErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		code     ErrorCode
+		line     int
+		column   int
+		helpText string
+	}{
+		{
+			"undefined variable",
+			"main() {\n    int count := 1\n    print(cout)\n}\n",
+			ERR_UNDEFINED_VARIABLE, 3, 11, "'count'",
+		},
+		{
+			"undefined variable in function that is never called",
+			"unused() {\n    x = 1\n}\n\nmain() {}\n",
+			ERR_UNDEFINED_VARIABLE, 2, 5, "",
+		},
+		{
+			"variable of another branch",
+			"main() {\n    if true {\n        int a := 1\n    }\n    print(a)\n}\n",
+			ERR_UNDEFINED_VARIABLE, 5, 11, "",
+		},
+		{
+			"redeclared variable",
+			"main() {\n    int a := 1\n    int a := 2\n}\n",
+			ERR_REDECLARED_VARIABLE, 3, 9, "",
+		},
+		{
+			"redeclared parameter",
+			"f(a int) {\n    string a := \"a\"\n}\n\nmain() {}\n",
+			ERR_REDECLARED_VARIABLE, 2, 12, "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := resolveError(parseProgram(t, test.source))
+			if err == nil {
+				t.Fatalf("expected error %v, got none", test.code)
+			}
+			if err.Code != test.code {
+				t.Errorf("expected error %v, got %v: %v", test.code, err.Code, err)
+			}
+			if err.Position.Line != test.line || err.Position.Column != test.column {
+				t.Errorf("expected error at %d:%d, got %v", test.line, test.column, err.Position)
+			}
+			if test.helpText != "" && (len(err.Help) == 0 || !strings.Contains(err.Help[0], test.helpText)) {
+				t.Errorf("expected help mentioning %s, got %v", test.helpText, err.Help)
+			}
+		})
+	}
+}
+
+func TestResolverWarnsAboutShadowing(t *testing.T) {
+	program := parseProgram(t, `
+main() {
+    int a := 1
+    while a < 3 {
+        a = a + 1
+        int a := 5
+    }
+    if a > 0 {
+        int b := 1
+    } else {
+        int b := 2
+    }
+}
+`)
+	warnings := ResolveProgram(program)
+	if len(warnings) != 1 {
+		t.Fatalf("expected 1 warning, got %d: %v", len(warnings), warnings)
+	}
+	warning := warnings[0]
+	if warning.Code != ERR_SHADOWED_VARIABLE {
+		t.Errorf("expected %v, got %v", ERR_SHADOWED_VARIABLE, warning.Code)
+	}
+	if warning.Position.Line != 6 || warning.Position.Column != 13 {
+		t.Errorf("expected warning at 6:13, got %v", warning.Position)
+	}
+	if warning.Diagnostic().Severity != diagnostics.WARNING {
+		t.Errorf("expected diagnostic with severity %v, got %v", diagnostics.WARNING, warning.Diagnostic().Severity)
+	}
+}
+
+func TestResolvedProgramGivesSameResults(t *testing.T) {
+	source := `
+fib(n int) int {
+    if n < 2 {
+        return n
+    }
+    return fib(n - 1) + fib(n - 2)
+}
+
+sum(n int) int {
+    int total := 0
+    int i := 0
+    while i < n {
+        i = i + 1
+        if i > 5 {
+            int step := 0
+            total = total + step
+        } else {
+            int step := i
+            total = total + step
+        }
+    }
+    return total
+}
+
+grade(points int) string {
+    switch int p := points {
+        p > 90 => "A",
+        p > 50 => {
+            string g := "B"
+            return g
+        },
+        default => "C"
+    }
+}
+
+main(n int) string {
+    return fib(n) + " " + sum(n) + " " + grade(n * 10)
+}
+`
+	results := []any{}
+	for _, resolve := range []bool{false, true} {
+		program := parseProgram(t, source)
+		if resolve {
+			ResolveProgram(program)
+		}
+		visitor := NewCodeVisitor(MAX_RECURSION_DEPTH * 10)
+		visitor.FunctionsMap = map[string]ast.Function{}
+		visitor.Run(program, &ast.FunctionCall{Name: "main", Arguments: []ast.Expression{&ast.IntExpression{Value: 8}}})
+		results = append(results, visitor.LastResult)
+	}
+
+	if results[0] != "21 15 B" {
+		t.Errorf("expected \"21 15 B\", got %v", results[0])
+	}
+	if results[1] != results[0] {
+		t.Errorf("expected resolved program to return %v, got %v", results[0], results[1])
+	}
+}
+
+func TestResolverMarksTailCalls(t *testing.T) {
+	program := parseProgram(t, `
+count(n int, acc int) int {
+    if n == 0 {
+        return acc
+    }
+    return count(n - 1, count(0, acc + 1))
+}
+
+fib(n int, a int, b int) int {
+    switch {
+        n == 0 => a,
+        n == 1 => { fib(n - 1, b, a + b) },
+        default => fib(n - 1, b, a + b)
+    }
+}
+
+countdown(n int) {
+    switch {
+        n > 0 => countdown(n - 1)
+    }
+    return countdown(0)
+}
+
+sum(n int) int {
+    return n + sum(n - 1)
+}
+`)
+	ResolveProgram(program)
+
+	tails := map[string][]bool{}
+	var walk func(node ast.Node)
+	walk = func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.Block:
+			for _, statement := range node.Statements {
+				walk(statement)
+			}
+		case *ast.IfStatement:
+			walk(node.InstructionsBlock)
+		case *ast.SwitchStatement:
+			for _, switchCase := range node.Cases {
+				walk(switchCase)
+			}
+		case *ast.SwitchCase:
+			walk(node.OutputExpression)
+		case *ast.DefaultSwitchCase:
+			walk(node.OutputExpression)
+		case *ast.ReturnStatement:
+			if node.Value != nil {
+				walk(node.Value)
+			}
+		case *ast.SumExpression:
+			walk(node.LeftExpression)
+			walk(node.RightExpression)
+		case *ast.FunctionCall:
+			tails[node.Name] = append(tails[node.Name], node.Tail)
+			for _, argument := range node.Arguments {
+				walk(argument)
+			}
+		}
+	}
+	for _, fd := range program.Functions {
+		walk(fd.Block)
+	}
+
+	expected := map[string][]bool{
+		// the call in the arguments is not in tail position
+		"count": {true, false},
+		"fib":   {true, true},
+		// void functions go on after an arm
+		"countdown": {false, true},
+		"sum":       {false},
+	}
+	if !reflect.DeepEqual(tails, expected) {
+		t.Errorf("expected tail calls: %v, got: %v", expected, tails)
+	}
+}
+
+func TestResolverErrorSpans(t *testing.T) {
+	source := "main() {\n    int a := count + 1\n}\n"
+	err := resolveError(parseProgram(t, source))
+	if err == nil || source[err.Span.StartOffset:err.Span.EndOffset] != "count" {
+		t.Errorf("expected an error spanning the undefined variable, got %+v", err)
+	}
+}
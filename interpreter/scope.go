@@ -2,7 +2,7 @@ package interpreter
 
 import (
 	"fmt"
-	"reflect"
+	"tkom/ast"
 	"tkom/shared"
 )
 
@@ -39,82 +39,168 @@ func (s *Stack) Size() int {
 	return len(s.elem)
 }
 
+// variables of a block kept in flat slices, resolved code reads them
+// by the index from its ast.Slot, other code looks them up by name
 type Scope struct {
-	Parent    *Scope
-	variables map[string]any
+	Parent *Scope
+	names  []string
+	values []any
+	// called when a variable changes, by the index of the variable
+	watchers map[int]func(old, value any)
 }
 
 func NewScope(parent *Scope, returnType *shared.TypeAnnotation) *Scope {
 	return &Scope{
-		Parent:    parent,
-		variables: map[string]any{},
+		Parent: parent,
 	}
 }
 
-func (s *Scope) InScope(name string) map[string]any {
-	if _, ok := s.variables[name]; ok {
-		return s.variables
+// index of the declared variable in this scope or -1
+func (s *Scope) indexOf(name string) int {
+	for i, n := range s.names {
+		if n == name && s.values[i] != nil {
+			return i
+		}
 	}
+	return -1
+}
 
-	if s.Parent != nil {
-		return s.Parent.InScope(name)
+// finds the scope declaring the variable, the innermost one first
+func (s *Scope) lookup(name string) (*Scope, int) {
+	for scope := s; scope != nil; scope = scope.Parent {
+		if i := scope.indexOf(name); i >= 0 {
+			return scope, i
+		}
 	}
+	return nil, -1
+}
 
-	return s.variables
+// returns copy of the variables of the scope declaring the name
+func (s *Scope) InScope(name string) map[string]any {
+	variables := map[string]any{}
+	if scope, _ := s.lookup(name); scope != nil {
+		for i, n := range scope.names {
+			if scope.values[i] != nil {
+				variables[n] = scope.values[i]
+			}
+		}
+	}
+	return variables
 }
 
 func (s *Scope) AddVariable(name string, value any, variableType shared.TypeAnnotation, position shared.Position) error {
-	if _, ok := s.variables[name]; ok {
-		return NewSemanticError(fmt.Sprintf(REDECLARED_VARIABLE, name), position)
+	if s.indexOf(name) >= 0 {
+		return NewSemanticErrorWithCode(ERR_REDECLARED_VARIABLE, position, name)
+	}
+
+	s.names = append(s.names, name)
+	s.values = append(s.values, value)
+	return nil
+}
+
+// declares the variable at the index given by the resolver
+func (s *Scope) DeclareAt(index int, name string, value any, position shared.Position) error {
+	for len(s.values) <= index {
+		s.names = append(s.names, "")
+		s.values = append(s.values, nil)
+	}
+	if s.values[index] != nil {
+		return NewSemanticErrorWithCode(ERR_REDECLARED_VARIABLE, position, name)
 	}
 
-	s.variables[name] = value
+	s.names[index] = name
+	s.values[index] = value
 	return nil
 }
 
+func (s *Scope) at(slot ast.Slot) (*Scope, bool) {
+	scope := s
+	for i := 0; i < slot.Depth && scope != nil; i++ {
+		scope = scope.Parent
+	}
+	if scope == nil || slot.Index >= len(scope.values) || scope.values[slot.Index] == nil {
+		return nil, false
+	}
+	return scope, true
+}
+
 // Sets a value to variable in the scope
 //
 // If no such value is foud returns a UNDEFINED_VARIABLE error
 //
 // If variable type doesn't match with value type, returns a TYPE_MISMATCH error
 func (s *Scope) SetValue(name string, value any) error {
-	variables := s.InScope(name)
-	v := variables[name]
-	if v == nil {
-		return fmt.Errorf(UNDEFINED_VARIABLE, name)
+	scope, i := s.lookup(name)
+	if scope == nil {
+		return NewSemanticErrorWithCode(ERR_UNDEFINED_VARIABLE, shared.Position{}, name)
 	}
 
-	err := s.CheckVariableType(v, value)
+	err := s.CheckVariableType(scope.values[i], value)
 	if err != nil {
 		return err
 	}
-	variables[name] = value
+	scope.set(i, value)
 
 	return nil
 }
 
-func (s *Scope) CheckVariableType(variable, value any) error {
-	typeCheckers := map[reflect.Type]func(any) bool{
-		reflect.TypeOf(1):     func(v any) bool { _, ok := v.(int); return ok },
-		reflect.TypeOf(true):  func(v any) bool { _, ok := v.(bool); return ok },
-		reflect.TypeOf(0.0):   func(v any) bool { _, ok := v.(float64); return ok },
-		reflect.TypeOf("str"): func(v any) bool { _, ok := v.(string); return ok },
+// SetValue for a variable bound to a slot by the resolver
+func (s *Scope) SetValueAt(slot ast.Slot, name string, value any) error {
+	scope, ok := s.at(slot)
+	if !ok {
+		return NewSemanticErrorWithCode(ERR_UNDEFINED_VARIABLE, shared.Position{}, name)
 	}
 
-	if checkFunc, found := typeCheckers[reflect.TypeOf(variable)]; found {
-		if !checkFunc(value) {
-			return fmt.Errorf(TYPE_MISMATCH, reflect.TypeOf(variable), reflect.TypeOf(value))
-		}
+	err := s.CheckVariableType(scope.values[slot.Index], value)
+	if err != nil {
+		return err
 	}
+	scope.set(slot.Index, value)
 
 	return nil
 }
 
+// the watcher is called after the value is set, so it sees the new one
+func (s *Scope) set(index int, value any) {
+	old := s.values[index]
+	s.values[index] = value
+	if changed, ok := s.watchers[index]; ok && old != value {
+		changed(old, value)
+	}
+}
+
+// calls changed when SetValue or SetValueAt changes the variable visible
+// in the scope, returns the function that stops watching it or false
+// when no such variable is declared
+func (s *Scope) Watch(name string, changed func(old, value any)) (func(), bool) {
+	scope, i := s.lookup(name)
+	if scope == nil {
+		return nil, false
+	}
+	if scope.watchers == nil {
+		scope.watchers = map[int]func(old, value any){}
+	}
+	scope.watchers[i] = changed
+	return func() { delete(scope.watchers, i) }, true
+}
+
+func (s *Scope) CheckVariableType(variable, value any) error {
+	return checkVariableType(variable, value)
+}
+
 func (s *Scope) GetVariable(name string) (any, error) {
-	variables := s.InScope(name)
-	v := variables[name]
-	if v == nil {
-		return nil, fmt.Errorf(fmt.Sprintf(UNDEFINED_VARIABLE, name))
+	scope, i := s.lookup(name)
+	if scope == nil {
+		return nil, NewSemanticErrorWithCode(ERR_UNDEFINED_VARIABLE, shared.Position{}, name)
+	}
+	return scope.values[i], nil
+}
+
+// GetVariable for a variable bound to a slot by the resolver
+func (s *Scope) GetVariableAt(slot ast.Slot, name string) (any, error) {
+	scope, ok := s.at(slot)
+	if !ok {
+		return nil, NewSemanticErrorWithCode(ERR_UNDEFINED_VARIABLE, shared.Position{}, name)
 	}
-	return v, nil
+	return scope.values[slot.Index], nil
 }
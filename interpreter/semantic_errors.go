@@ -2,54 +2,206 @@ package interpreter
 
 import (
 	"fmt"
+	"strings"
+	"tkom/diagnostics"
 	"tkom/shared"
 )
 
 type SemantciError struct {
+	Code     ErrorCode
 	Message  string
+	Reason   string
 	Position shared.Position
+	// source of the node the error is about, set where the error is raised
+	Span shared.Span
+	// call frames active when the error was raised, the outermost first
+	Traceback []Frame
+	Help      []string
+	// zero value reports the error, warnings do not stop the program
+	Severity diagnostics.Severity
 }
 
 func NewSemanticError(message string, position shared.Position) *SemantciError {
 	msg := fmt.Sprintf("error [%v, %v]: %s", position.Line, position.Column, message)
 	return &SemantciError{
 		Message:  msg,
+		Reason:   message,
 		Position: position,
 	}
 }
 
+// creates error with message built from the template registered for the code
+func NewSemanticErrorWithCode(code ErrorCode, position shared.Position, args ...any) *SemantciError {
+	err := NewSemanticError(fmt.Sprintf(errorMessage[code], args...), position)
+	err.Code = code
+	return err
+}
+
+// sets the source of the node the error is about, nodes built without
+// a source leave the error without it
+func (err *SemantciError) WithSpan(span shared.Span) *SemantciError {
+	err.Span = span
+	return err
+}
+
+// returns copy of the error reported at the given position and span
+func (err *SemantciError) At(position shared.Position, span shared.Span) *SemantciError {
+	moved := NewSemanticError(err.Reason, position)
+	moved.Span = span
+	moved.Code = err.Code
+	moved.Traceback = err.Traceback
+	moved.Help = err.Help
+	moved.Severity = err.Severity
+	return moved
+}
+
 func (err *SemantciError) Error() string {
 	return err.Message
 }
 
+// frames printed from each end of a long traceback, e.g. of a deep
+// recursion, the frames between them are only counted
+const TRACEBACK_FRAMES = 10
+
+// whether the frame is left out of the middle of a traceback of the length,
+// a single frame is printed rather than counted
+func hiddenFrame(length, i int) bool {
+	hidden := length - 2*TRACEBACK_FRAMES
+	return hidden > 1 && i >= TRACEBACK_FRAMES && i < TRACEBACK_FRAMES+hidden
+}
+
+func (err *SemantciError) FormatTraceback() string {
+	if len(err.Traceback) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("traceback (most recent call last):")
+	for i, frame := range err.Traceback {
+		if hiddenFrame(len(err.Traceback), i) {
+			if i == TRACEBACK_FRAMES {
+				fmt.Fprintf(&b, "\n  ... %d more frames", len(err.Traceback)-2*TRACEBACK_FRAMES)
+			}
+			continue
+		}
+		b.WriteString("\n  ")
+		b.WriteString(frame.String())
+	}
+	return b.String()
+}
+
+func (err *SemantciError) Diagnostic() *diagnostics.Diagnostic {
+	d := diagnostics.NewDiagnostic(err.Severity, err.Code.String(), err.Reason, err.Position)
+	d.Span = err.Span
+	if traceback := err.FormatTraceback(); traceback != "" {
+		d.Notes = append(d.Notes, traceback)
+	}
+	d.Help = append(d.Help, err.Help...)
+	for i := len(err.Traceback) - 1; i >= 0; i-- {
+		frame := err.Traceback[i]
+		if frame.CallSite == (shared.Position{}) || hiddenFrame(len(err.Traceback), i) {
+			continue
+		}
+		d.Related = append(d.Related, diagnostics.Related{
+			Message:  "in call to " + frame.Call(),
+			Position: frame.CallSite,
+			Span:     frame.CallSiteSpan,
+		})
+	}
+	return d
+}
+
+type ErrorCode int
+
+// every semantic error has its own code, numbered E0301, E0302, ...,
+// the numbers are shown to users, so they are written out and never reused
 const (
-	UNDEFINED_VARIABLE                       = "undefined: %s"
-	UNDEFINED_FUNCTION                       = "undefined function: %s"
-	REDECLARED_VARIABLE                      = "redeclared variable: %s, variable with that name already exists"
-	TYPE_MISMATCH                            = "type mismatch: expected %v, got %v"
-	WRONG_NUMBER_OF_ARGUMENTS                = "function %s expects %d arguments but got: %d"
-	INVALID_NEGATE_EXPRESSION                = "cannot negate %v of type %v"
-	INVALID_MULTIPLY_EXPRESSION              = "cannot evaluate '*' operation with instances of %v and %v"
-	INVALID_DIVISION_EXPRESSION              = "cannot evaluate '/' operation with instances of %v and %v"
-	INVALID_SUM_EXPRESSION                   = "cannot evaluate '+' operation with instances of %v and %v"
-	INVALID_SUBSTRACT_EXPRESSION             = "cannot evaluate '-' operation with instances of %v and %v"
-	INVALID_EQUALS_MISSMATCH                 = "cannot evaluate '==' operation with instances, mismatched types of %v and %v"
-	INVALID_NOT_EQUALS_MISSMATCH             = "cannot evaluate '!=' operation with instances, mismatched types of %v and %v"
-	INVALID_GREATER_THAN_MISSMATCH           = "cannot evaluate '>' operation with instances, mismatched types of %v and %v"
-	INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH = "cannot evaluate '>=' operation with instances, mismatched types of %v and %v"
-	INVALID_LESS_THAN_MISSMATCH              = "cannot evaluate '<' operation with instances, mismatched types of %v and %v"
-	INVALID_LESS_OR_EQUALS_THAN_MISSMATCH    = "cannot evaluate '<=' operation with instances, mismatched types of %v and %v"
-	INVALID_ASSIGNMENT_TYPES                 = "cannot assign value of type %v to variable of type: %v"
-	INVALID_TYPE_ANNOTATION                  = "invalid type annotation: %s"
-	INVALID_RETURN_TYPE                      = "invalid return type: %s, expected: %s"
-	MISSING_RETURN                           = "missing return, function should return type: %v"
-	FUNCTION_REDEFINITION                    = "function redefinition, function with name: '%s' already defined here: %v"
-	MULTIPLE_DEFAULT_CASES                   = "multiple default cases in switch statement"
-	INVALID_CASE_TYPE                        = "unknown case type in switch statement"
-	INVALID_WHILE_CONDITION                  = "expected boolean expression as condition but got: %v"
-	ERROR_ARGUMENTS_NOT_FOUND                = "Last result is not an array of arguments: %v"
-	INVALID_ARGUMENTS_TYPE                   = "invalid arguments type: %v"
-    WRONG_ARGUMENT_TYPE                      = "cannot use: %v as arguments of type: %v"
-	MAX_RECURSION_DEPTH_EXCEEDED             = "maximum recursion depth exceeded for function: %s"
-	EXPECTED_BOOLEAN_EXPRESSION              = "expected boolean expression but got: %v"
+	ERR_UNDEFINED_VARIABLE                       ErrorCode = 301
+	ERR_UNDEFINED_FUNCTION                       ErrorCode = 302
+	ERR_REDECLARED_VARIABLE                      ErrorCode = 303
+	ERR_TYPE_MISMATCH                            ErrorCode = 304
+	ERR_WRONG_NUMBER_OF_ARGUMENTS                ErrorCode = 305
+	ERR_INVALID_NEGATE_EXPRESSION                ErrorCode = 306
+	ERR_INVALID_MULTIPLY_EXPRESSION              ErrorCode = 307
+	ERR_INVALID_DIVISION_EXPRESSION              ErrorCode = 308
+	ERR_INVALID_SUM_EXPRESSION                   ErrorCode = 309
+	ERR_INVALID_SUBSTRACT_EXPRESSION             ErrorCode = 310
+	ERR_INVALID_EQUALS_MISSMATCH                 ErrorCode = 311
+	ERR_INVALID_NOT_EQUALS_MISSMATCH             ErrorCode = 312
+	ERR_INVALID_GREATER_THAN_MISSMATCH           ErrorCode = 313
+	ERR_INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH ErrorCode = 314
+	ERR_INVALID_LESS_THAN_MISSMATCH              ErrorCode = 315
+	ERR_INVALID_LESS_OR_EQUALS_THAN_MISSMATCH    ErrorCode = 316
+	ERR_INVALID_ASSIGNMENT_TYPES                 ErrorCode = 317
+	ERR_INVALID_TYPE_ANNOTATION                  ErrorCode = 318
+	ERR_INVALID_RETURN_TYPE                      ErrorCode = 319
+	ERR_MISSING_RETURN                           ErrorCode = 320
+	ERR_FUNCTION_REDEFINITION                    ErrorCode = 321
+	ERR_MULTIPLE_DEFAULT_CASES                   ErrorCode = 322
+	ERR_INVALID_CASE_TYPE                        ErrorCode = 323
+	ERR_INVALID_WHILE_CONDITION                  ErrorCode = 324
+	ERR_ERROR_ARGUMENTS_NOT_FOUND                ErrorCode = 325
+	ERR_INVALID_ARGUMENTS_TYPE                   ErrorCode = 326
+	ERR_WRONG_ARGUMENT_TYPE                      ErrorCode = 327
+	ERR_MAX_RECURSION_DEPTH_EXCEEDED             ErrorCode = 328
+	ERR_EXPECTED_BOOLEAN_EXPRESSION              ErrorCode = 329
+	ERR_DIVISION_BY_ZERO                         ErrorCode = 330
+	ERR_INVALID_CAST_EXPRESSION                  ErrorCode = 331
+	ERR_SHADOWED_VARIABLE                        ErrorCode = 332
+	ERR_STEP_LIMIT_EXCEEDED                      ErrorCode = 333
+	ERR_EXECUTION_INTERRUPTED                    ErrorCode = 334
+	ERR_MEMORY_LIMIT_EXCEEDED                    ErrorCode = 335
+	ERR_ASSERTION_FAILED                         ErrorCode = 336
+	ERR_ASSERTION_NOT_EQUAL                      ErrorCode = 337
 )
+
+var errorMessage = map[ErrorCode]string{
+	ERR_UNDEFINED_VARIABLE:                       "undefined: %s",
+	ERR_UNDEFINED_FUNCTION:                       "undefined function: %s",
+	ERR_REDECLARED_VARIABLE:                      "redeclared variable: %s, variable with that name already exists",
+	ERR_TYPE_MISMATCH:                            "type mismatch: expected %v, got %v",
+	ERR_WRONG_NUMBER_OF_ARGUMENTS:                "function %s expects %d arguments but got: %d",
+	ERR_INVALID_NEGATE_EXPRESSION:                "cannot negate %v of type %v",
+	ERR_INVALID_MULTIPLY_EXPRESSION:              "cannot evaluate '*' operation with instances of %v and %v",
+	ERR_INVALID_DIVISION_EXPRESSION:              "cannot evaluate '/' operation with instances of %v and %v",
+	ERR_INVALID_SUM_EXPRESSION:                   "cannot evaluate '+' operation with instances of %v and %v",
+	ERR_INVALID_SUBSTRACT_EXPRESSION:             "cannot evaluate '-' operation with instances of %v and %v",
+	ERR_INVALID_EQUALS_MISSMATCH:                 "cannot evaluate '==' operation with instances, mismatched types of %v and %v",
+	ERR_INVALID_NOT_EQUALS_MISSMATCH:             "cannot evaluate '!=' operation with instances, mismatched types of %v and %v",
+	ERR_INVALID_GREATER_THAN_MISSMATCH:           "cannot evaluate '>' operation with instances, mismatched types of %v and %v",
+	ERR_INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH: "cannot evaluate '>=' operation with instances, mismatched types of %v and %v",
+	ERR_INVALID_LESS_THAN_MISSMATCH:              "cannot evaluate '<' operation with instances, mismatched types of %v and %v",
+	ERR_INVALID_LESS_OR_EQUALS_THAN_MISSMATCH:    "cannot evaluate '<=' operation with instances, mismatched types of %v and %v",
+	ERR_INVALID_ASSIGNMENT_TYPES:                 "cannot assign value of type %v to variable of type: %v",
+	ERR_INVALID_TYPE_ANNOTATION:                  "invalid type annotation: %s",
+	ERR_INVALID_RETURN_TYPE:                      "invalid return type: %s, expected: %s",
+	ERR_MISSING_RETURN:                           "missing return, function should return type: %v",
+	ERR_FUNCTION_REDEFINITION:                    "function redefinition, function with name: '%s' already defined here: %v",
+	ERR_MULTIPLE_DEFAULT_CASES:                   "multiple default cases in switch statement",
+	ERR_INVALID_CASE_TYPE:                        "unknown case type in switch statement",
+	ERR_INVALID_WHILE_CONDITION:                  "expected boolean expression as condition but got: %v",
+	ERR_ERROR_ARGUMENTS_NOT_FOUND:                "Last result is not an array of arguments: %v",
+	ERR_INVALID_ARGUMENTS_TYPE:                   "invalid arguments type: %v",
+	ERR_WRONG_ARGUMENT_TYPE:                      "cannot use: %v as arguments of type: %v",
+	ERR_MAX_RECURSION_DEPTH_EXCEEDED:             "maximum recursion depth exceeded for function: %s",
+	ERR_EXPECTED_BOOLEAN_EXPRESSION:              "expected boolean expression but got: %v",
+	ERR_DIVISION_BY_ZERO:                         "Division by zero",
+	ERR_INVALID_CAST_EXPRESSION:                  "invalid cast expression: %v to %v",
+	ERR_SHADOWED_VARIABLE:                        "declaration of %s shadows the variable declared at: %v, %v",
+	ERR_STEP_LIMIT_EXCEEDED:                      "step limit exceeded, the program was stopped after %d steps",
+	ERR_EXECUTION_INTERRUPTED:                    "execution interrupted: %v",
+	ERR_MEMORY_LIMIT_EXCEEDED:                    "memory limit exceeded: %s cannot be larger than %d",
+	ERR_ASSERTION_FAILED:                         "assertion failed",
+	ERR_ASSERTION_NOT_EQUAL:                      "assertion failed: %s is not equal to %s",
+}
+
+// message of the code with verbs for the arguments of the error
+func (c ErrorCode) Template() string {
+	return errorMessage[c]
+}
+
+func (c ErrorCode) String() string {
+	if c == 0 {
+		return ""
+	}
+	return fmt.Sprintf("E%04d", int(c))
+}
@@ -0,0 +1,131 @@
+package interpreter
+
+import (
+	"sort"
+	"strings"
+	"tkom/ast"
+	"tkom/lexer"
+)
+
+const MAX_SUGGESTIONS = 3
+
+// edit distance between two names counted in runes, swapping two
+// neighbouring characters counts as a single edit, like in "ture" and "true"
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+// returns candidates close enough to the name to be a likely typo,
+// the closest ones first
+func suggest(name string, candidates []string) []string {
+	maxDistance := max(1, len([]rune(name))/3)
+
+	seen := map[string]bool{}
+	distances := map[string]int{}
+	suggestions := []string{}
+	for _, candidate := range candidates {
+		if candidate == name || seen[candidate] {
+			continue
+		}
+		seen[candidate] = true
+		// a single letter name is one edit away from every other one
+		if distance := editDistance(name, candidate); distance <= maxDistance && distance < len([]rune(name)) {
+			distances[candidate] = distance
+			suggestions = append(suggestions, candidate)
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if distances[a] != distances[b] {
+			return distances[a] < distances[b]
+		}
+		return a < b
+	})
+	if len(suggestions) > MAX_SUGGESTIONS {
+		suggestions = suggestions[:MAX_SUGGESTIONS]
+	}
+	return suggestions
+}
+
+// names of the variables visible from the scope, including the parent scopes
+func (s *Scope) VisibleNames() []string {
+	names := []string{}
+	for scope := s; scope != nil; scope = scope.Parent {
+		for i, name := range scope.names {
+			if scope.values[i] != nil {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// every name the undefined one could have been meant as:
+// visible variables, user functions, builtins and keywords
+func candidateNames(variables []string, functions map[string]ast.Function) []string {
+	names := append([]string{}, variables...)
+	for name := range functions {
+		names = append(names, name)
+	}
+	for name := range embeddedFunctions {
+		names = append(names, name)
+	}
+	for keyword := range lexer.KeyWords {
+		names = append(names, keyword)
+	}
+	return names
+}
+
+func (v *CodeVisitor) knownNames() []string {
+	variables := []string{}
+	if v.CurrentScope != nil {
+		variables = v.CurrentScope.VisibleNames()
+	}
+	return candidateNames(variables, v.FunctionsMap)
+}
+
+// attaches "did you mean" help to the error about an undefined name
+func (v *CodeVisitor) withSuggestions(err *SemantciError, name string) *SemantciError {
+	return addSuggestions(err, name, v.knownNames())
+}
+
+func addSuggestions(err *SemantciError, name string, candidates []string) *SemantciError {
+	suggestions := suggest(name, candidates)
+	if len(suggestions) == 0 {
+		return err
+	}
+
+	quoted := make([]string, len(suggestions))
+	for i, suggestion := range suggestions {
+		quoted[i] = "'" + suggestion + "'"
+	}
+	if len(quoted) == 1 {
+		err.Help = append(err.Help, "did you mean "+quoted[0]+"?")
+	} else {
+		err.Help = append(err.Help, "did you mean one of: "+strings.Join(quoted, ", ")+"?")
+	}
+	return err
+}
@@ -0,0 +1,641 @@
+package interpreter
+
+import (
+	"fmt"
+	"reflect"
+	"tkom/ast"
+	"tkom/shared"
+)
+
+// stack based virtual machine running the bytecode of the Compiler,
+// it follows the CodeVisitor step by step, so programs print
+// the same output and fail with the same errors on both engines
+type VirtualMachine struct {
+	Functions         map[string]ast.Function
+	CallStack         CallStack
+	MaxRecursionDepth int
+	Budget            *Budget
+	Limits            *Limits
+	compiled          map[ast.Function]*Function
+	stack             []any
+	locals            []any
+	frames            []*vmFrame
+	// mirrors LastResult of the CodeVisitor
+	result      any
+	switchEnded bool
+	// scopes open in every frame, mirrors the ScopeStack of the CodeVisitor
+	scopes int
+	// scope the evaluated fragment declares its variables in
+	outer *Scope
+}
+
+type vmFrame struct {
+	function *Function
+	ip       int
+	base     int
+	// scopes opened by the frame, the function body counts as one
+	scopes int
+}
+
+func NewVirtualMachine(maxRecursionDepth int) *VirtualMachine {
+	return &VirtualMachine{
+		Functions:         EmbeddedFunctions(),
+		CallStack:         CallStack{elem: map[string]int{}, frames: []*Frame{}},
+		MaxRecursionDepth: maxRecursionDepth,
+		compiled:          map[ast.Function]*Function{},
+	}
+}
+
+func (m *VirtualMachine) Run(program *ast.Program, call *ast.FunctionCall) {
+	for name, fd := range program.Functions {
+		m.Functions[name] = fd
+	}
+	m.Evaluate(call, nil)
+}
+
+// runs the node in the scope, returned is set when the
+// node executed a return statement
+func (m *VirtualMachine) Evaluate(node ast.Node, scope *Scope) (result any, returned bool) {
+	if scope == nil {
+		scope = NewScope(nil, nil)
+	}
+	m.outer = scope
+	defer m.reset()
+
+	m.enter(CompileFragment(node))
+	return m.run()
+}
+
+// state of a failed run is dropped, the error is already on its way up
+func (m *VirtualMachine) reset() {
+	m.stack = m.stack[:0]
+	m.locals = m.locals[:0]
+	m.frames = m.frames[:0]
+	m.CallStack = CallStack{elem: map[string]int{}, frames: []*Frame{}}
+	m.switchEnded = false
+	m.scopes = 0
+	m.outer = nil
+}
+
+// compiles user functions on their first call
+func (m *VirtualMachine) function(declaration ast.Function) *Function {
+	if function, ok := m.compiled[declaration]; ok {
+		return function
+	}
+	var function *Function
+	switch declaration := declaration.(type) {
+	case *ast.FunctionDefinition:
+		function = Compile(declaration)
+	case *ast.EmbeddedFunction:
+		function = &Function{Name: declaration.Name, Declaration: declaration}
+	default:
+		panic(NewSemanticErrorWithCode(ERR_INVALID_ARGUMENTS_TYPE, shared.Position{}, reflect.TypeOf(declaration)))
+	}
+	m.compiled[declaration] = function
+	return function
+}
+
+func (m *VirtualMachine) enter(function *Function) {
+	frame := &vmFrame{function: function, base: len(m.locals)}
+	for i := 0; i < function.Locals; i++ {
+		m.locals = append(m.locals, nil)
+	}
+	m.frames = append(m.frames, frame)
+}
+
+func (m *VirtualMachine) push(value any) {
+	m.stack = append(m.stack, value)
+}
+
+func (m *VirtualMachine) pop() any {
+	value := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	return value
+}
+
+// errors raised inside a call carry the traceback, errors without
+// a position are reported at the innermost call site
+func (m *VirtualMachine) fail(err error) {
+	top := m.CallStack.Top()
+	if top == nil {
+		panic(err)
+	}
+	panic(attachTraceback(err, top, &m.CallStack))
+}
+
+// variables of the running function visible from the scope, for suggestions
+func (m *VirtualMachine) visibleNames(frame *vmFrame, scope int) []string {
+	names := []string{}
+	scopes := frame.function.Chunk.Scopes
+	for ; scope >= 0; scope = scopes[scope].Parent {
+		for name, slot := range scopes[scope].Names {
+			if m.locals[frame.base+slot] != nil {
+				names = append(names, name)
+			}
+		}
+	}
+	if frame.function.IsFragment() {
+		names = append(names, m.outer.VisibleNames()...)
+	}
+	return names
+}
+
+func (m *VirtualMachine) undefinedVariable(frame *vmFrame, ref *nameRef, position shared.Position, span shared.Span) {
+	err := NewSemanticErrorWithCode(ERR_UNDEFINED_VARIABLE, position, ref.Name).WithSpan(span)
+	m.fail(addSuggestions(err, ref.Name, candidateNames(m.visibleNames(frame, ref.Scope), m.Functions)))
+}
+
+func (m *VirtualMachine) load(frame *vmFrame, ref *nameRef, position shared.Position, span shared.Span) any {
+	for _, slot := range ref.Slots {
+		if value := m.locals[frame.base+slot]; value != nil {
+			return value
+		}
+	}
+	if frame.function.IsFragment() {
+		value, err := m.outer.GetVariable(ref.Name)
+		if err == nil {
+			return value
+		}
+	}
+	m.undefinedVariable(frame, ref, position, span)
+	return nil
+}
+
+func (m *VirtualMachine) store(frame *vmFrame, ref *nameRef, value any, position shared.Position, span shared.Span) {
+	for _, slot := range ref.Slots {
+		if current := m.locals[frame.base+slot]; current != nil {
+			if err := checkVariableType(current, value); err != nil {
+				m.fail(errorAt(err, position, span))
+			}
+			m.locals[frame.base+slot] = value
+			return
+		}
+	}
+	if frame.function.IsFragment() {
+		err := m.outer.SetValue(ref.Name, value)
+		if err == nil {
+			return
+		}
+		if semanticError := errorAt(err, position, span); semanticError.Code != ERR_UNDEFINED_VARIABLE {
+			m.fail(semanticError)
+		}
+	}
+	m.undefinedVariable(frame, ref, position, span)
+}
+
+func (m *VirtualMachine) declare(frame *vmFrame, decl *declaration, value any) {
+	if err := checkType(value, decl.Type, decl.Position); err != nil {
+		m.fail(err.(*SemantciError).WithSpan(decl.Span))
+	}
+	if decl.Slot < 0 {
+		if err := m.outer.AddVariable(decl.Name, value, decl.Type, decl.Position); err != nil {
+			m.fail(errorAt(err, decl.Position, decl.Span))
+		}
+		return
+	}
+	if m.locals[frame.base+decl.Slot] != nil {
+		m.fail(NewSemanticErrorWithCode(ERR_REDECLARED_VARIABLE, decl.Position, decl.Name).WithSpan(decl.Span))
+	}
+	m.locals[frame.base+decl.Slot] = value
+}
+
+// checks done before the arguments of a call are evaluated
+func (m *VirtualMachine) enterCall(site *callSite, frame *vmFrame) {
+	fc := site.Call
+	m.step(fc.Position, fc.Span)
+
+	declaration := m.Functions[fc.Name]
+	if declaration == nil {
+		err := NewSemanticErrorWithCode(ERR_UNDEFINED_FUNCTION, fc.Position, fc.Name).WithSpan(fc.Span)
+		m.fail(addSuggestions(err, fc.Name, candidateNames(m.visibleNames(frame, site.Scope), m.Functions)))
+	}
+	if site.Target == nil || site.Target.Declaration != declaration {
+		site.Target = m.function(declaration)
+	}
+
+	// a tail call replaces the running call, so it does not go deeper
+	if !site.Tail && m.CallStack.RecursionDepth(fc.Name) >= m.MaxRecursionDepth {
+		m.fail(NewSemanticErrorWithCode(ERR_MAX_RECURSION_DEPTH_EXCEEDED, fc.Position, fc.Name).WithSpan(fc.Span))
+	}
+	if !site.Tail {
+		if err := m.Limits.checkCallDepth(m.CallStack.Depth(), fc.Position); err != nil {
+			err.Span = fc.Span
+			m.fail(err)
+		}
+	}
+
+	m.CallStack.Push(NewFrame(fc.Name, fc.Position, fc.Span))
+
+	if len(fc.Arguments) != declaration.GetParametersLen() && !declaration.IsVariadic() {
+		m.fail(NewSemanticErrorWithCode(ERR_WRONG_NUMBER_OF_ARGUMENTS, fc.Position, fc.Name, declaration.GetParametersLen(), len(fc.Arguments)).WithSpan(fc.Span))
+	}
+}
+
+func (m *VirtualMachine) step(position shared.Position, span shared.Span) {
+	if err := m.Budget.step(position); err != nil {
+		err.Span = span
+		m.fail(err)
+	}
+}
+
+func (m *VirtualMachine) allocate(value any, position shared.Position, span shared.Span) {
+	if err := m.Limits.allocate(value, position); err != nil {
+		err.Span = span
+		m.fail(err)
+	}
+}
+
+func (m *VirtualMachine) openScope(frame *vmFrame, position shared.Position, span shared.Span) {
+	frame.scopes++
+	m.scopes++
+	if err := m.Limits.checkScopes(m.scopes, position); err != nil {
+		err.Span = span
+		m.fail(err)
+	}
+}
+
+// pops the evaluated arguments, builtins are run right away,
+// user functions get a new frame with parameters bound to their slots
+func (m *VirtualMachine) call(site *callSite) {
+	fc := site.Call
+	values := m.arguments(site)
+
+	switch declaration := site.Target.Declaration.(type) {
+	case *ast.EmbeddedFunction:
+		if !declaration.Variadic {
+			for i, val := range values {
+				if determineType(val) != declaration.Parameters[i] {
+					m.fail(NewSemanticErrorWithCode(ERR_WRONG_ARGUMENT_TYPE, fc.Arguments[i].GetPosition(), determineType(val), declaration.Parameters[i]).WithSpan(fc.Arguments[i].GetSpan()))
+				}
+			}
+		}
+		result := m.callEmbedded(declaration, values)
+		m.CallStack.Pop()
+		m.push(result)
+	case *ast.FunctionDefinition:
+		m.enter(site.Target)
+		frame := m.frames[len(m.frames)-1]
+		m.openScope(frame, declaration.Position, declaration.Span)
+		m.bind(frame, declaration, fc.Arguments, values)
+	}
+}
+
+// the running call returns and its frame is reused for the call of itself,
+// the frame of the call on the call stack takes the place of the caller's
+func (m *VirtualMachine) tailCall(site *callSite, frame *vmFrame) {
+	fc := site.Call
+	values := m.arguments(site)
+	m.CallStack.Pop()
+	caller := m.CallStack.Top()
+	caller.CallSite = fc.Position
+	caller.CallSiteSpan = fc.Span
+	caller.Arguments = values
+
+	clear(m.locals[frame.base : frame.base+frame.function.Locals])
+	// scopes the call was made from are closed, only the body stays open
+	m.scopes -= frame.scopes - 1
+	frame.scopes = 1
+	m.bind(frame, site.Target.Declaration.(*ast.FunctionDefinition), fc.Arguments, values)
+	frame.ip = 0
+}
+
+func (m *VirtualMachine) arguments(site *callSite) []any {
+	args := site.Call.Arguments
+	values := make([]any, len(args))
+	copy(values, m.stack[len(m.stack)-len(args):])
+	m.stack = m.stack[:len(m.stack)-len(args)]
+	m.CallStack.Top().Arguments = values
+	return values
+}
+
+func (m *VirtualMachine) bind(frame *vmFrame, declaration *ast.FunctionDefinition, args []ast.Expression, values []any) {
+	for i, param := range declaration.Parameters {
+		if err := checkType(values[i], param.Type, args[i].GetPosition()); err != nil {
+			m.fail(NewSemanticErrorWithCode(ERR_WRONG_ARGUMENT_TYPE, args[i].GetPosition(), determineType(values[i]), param.Type).WithSpan(args[i].GetSpan()))
+		}
+		slot := frame.base + frame.function.Parameters[i]
+		if m.locals[slot] != nil {
+			m.fail(NewSemanticErrorWithCode(ERR_REDECLARED_VARIABLE, param.Position, param.Name).WithSpan(param.Span))
+		}
+		m.locals[slot] = values[i]
+	}
+	if len(values) > 0 {
+		m.result = values[len(values)-1]
+	} else {
+		m.result = args
+	}
+}
+
+// the frame of the call is on the top of the call stack while the builtin runs
+func (m *VirtualMachine) callEmbedded(ef *ast.EmbeddedFunction, values []any) any {
+	defer func() {
+		if r := recover(); r != nil {
+			panic(attachTraceback(r, m.CallStack.Top(), &m.CallStack))
+		}
+	}()
+	return ef.Func(values...)
+}
+
+// leaves the function with the value, a fragment stops the machine
+func (m *VirtualMachine) leave(value any) (halted bool) {
+	frame := m.frames[len(m.frames)-1]
+	if frame.function.IsFragment() {
+		return true
+	}
+
+	fd := frame.function.Declaration.(*ast.FunctionDefinition)
+	if returnType := determineType(value); returnType != fd.Type {
+		m.fail(NewSemanticErrorWithCode(ERR_INVALID_RETURN_TYPE, fd.Position, returnType, fd.Type).WithSpan(fd.Span))
+	}
+
+	m.locals = m.locals[:frame.base]
+	m.frames = m.frames[:len(m.frames)-1]
+	m.scopes -= frame.scopes
+	m.CallStack.Pop()
+	m.push(value)
+	return false
+}
+
+func (m *VirtualMachine) run() (any, bool) {
+	frame := m.frames[len(m.frames)-1]
+	chunk := frame.function.Chunk
+	code := chunk.Code
+	ip := frame.ip
+
+	for {
+		op := Opcode(code[ip])
+		switch op {
+		case OP_CONSTANT:
+			m.push(chunk.Constants[readOperand(code, ip+1)])
+			ip += 3
+
+		case OP_NIL:
+			m.push(nil)
+			ip++
+
+		case OP_LOAD_LOCAL:
+			value := m.locals[frame.base+readOperand(code, ip+1)]
+			if value == nil {
+				value = m.load(frame, chunk.Names[readOperand(code, ip+3)], chunk.Positions[ip], chunk.Spans[ip])
+			}
+			m.push(value)
+			ip += 5
+
+		case OP_LOAD:
+			m.push(m.load(frame, chunk.Names[readOperand(code, ip+1)], chunk.Positions[ip], chunk.Spans[ip]))
+			ip += 3
+
+		case OP_STORE:
+			m.store(frame, chunk.Names[readOperand(code, ip+1)], m.pop(), chunk.Positions[ip], chunk.Spans[ip])
+			m.result = nil
+			ip += 3
+
+		case OP_DECLARE:
+			m.declare(frame, chunk.Declarations[readOperand(code, ip+1)], m.pop())
+			m.result = nil
+			ip += 3
+
+		case OP_ENTER_SCOPE:
+			first := frame.base + readOperand(code, ip+1)
+			clear(m.locals[first : first+readOperand(code, ip+3)])
+			m.openScope(frame, chunk.Positions[ip], chunk.Spans[ip])
+			ip += 5
+
+		case OP_LEAVE_SCOPE:
+			frame.scopes--
+			m.scopes--
+			ip++
+
+		case OP_NEGATE:
+			value := m.stack[len(m.stack)-1]
+			result, valid := negate(value)
+			if !valid {
+				m.fail(NewSemanticErrorWithCode(ERR_INVALID_NEGATE_EXPRESSION, chunk.Positions[ip], value, "string").WithSpan(chunk.Spans[ip]))
+			}
+			m.stack[len(m.stack)-1] = result
+			ip++
+
+		case OP_CAST:
+			value := m.stack[len(m.stack)-1]
+			typeAnnotation := chunk.Constants[readOperand(code, ip+1)].(shared.TypeAnnotation)
+			result, err := castValue(value, typeAnnotation, chunk.Positions[ip])
+			if err != nil {
+				m.fail(err.WithSpan(chunk.Spans[ip]))
+			}
+			m.allocate(result, chunk.Positions[ip], chunk.Spans[ip])
+			m.stack[len(m.stack)-1] = result
+			ip += 3
+
+		case OP_MULTIPLY, OP_DIVIDE, OP_SUM, OP_SUBSTRACT, OP_EQUALS, OP_NOT_EQUALS,
+			OP_GREATER_THAN, OP_GREATER_OR_EQUAL, OP_LESS_THAN, OP_LESS_OR_EQUAL:
+			n := len(m.stack)
+			m.stack[n-2] = m.binary(op, m.stack[n-2], m.stack[n-1], chunk.Positions[ip], chunk.Spans[ip])
+			m.stack = m.stack[:n-1]
+			ip++
+
+		case OP_AND, OP_OR:
+			value := m.stack[len(m.stack)-1]
+			b, ok := value.(bool)
+			if !ok {
+				m.fail(NewSemanticErrorWithCode(ERR_EXPECTED_BOOLEAN_EXPRESSION, chunk.Positions[ip], reflect.TypeOf(value)).WithSpan(chunk.Spans[ip]))
+			}
+			// false ends 'and', true ends 'or'
+			if b == (op == OP_OR) {
+				ip = readOperand(code, ip+1)
+			} else {
+				m.stack = m.stack[:len(m.stack)-1]
+				ip += 3
+			}
+
+		case OP_EXPECT_BOOL:
+			value := m.stack[len(m.stack)-1]
+			if _, ok := value.(bool); !ok {
+				m.fail(NewSemanticErrorWithCode(ERR_EXPECTED_BOOLEAN_EXPRESSION, chunk.Positions[ip], reflect.TypeOf(value)).WithSpan(chunk.Spans[ip]))
+			}
+			ip++
+
+		case OP_JUMP:
+			ip = readOperand(code, ip+1)
+
+		case OP_JUMP_IF_FALSE:
+			value := m.pop()
+			condition, ok := value.(bool)
+			if !ok {
+				m.fail(NewSemanticErrorWithCode(ErrorCode(readOperand(code, ip+3)), chunk.Positions[ip], reflect.TypeOf(value)).WithSpan(chunk.Spans[ip]))
+			}
+			m.result = value
+			if condition {
+				ip += 5
+			} else {
+				ip = readOperand(code, ip+1)
+			}
+
+		case OP_SET_RESULT:
+			m.result = m.pop()
+			ip++
+
+		case OP_CLEAR_RESULT:
+			m.result = nil
+			ip++
+
+		case OP_ENTER:
+			m.enterCall(chunk.Calls[readOperand(code, ip+1)], frame)
+			ip += 3
+
+		case OP_CALL:
+			site := chunk.Calls[readOperand(code, ip+1)]
+			ip += 3
+			frame.ip = ip
+			m.call(site)
+			frame = m.frames[len(m.frames)-1]
+			chunk = frame.function.Chunk
+			code = chunk.Code
+			ip = frame.ip
+
+		case OP_TAIL_CALL:
+			if readOperand(code, ip+3) == 1 {
+				m.switchEnded = false
+			}
+			m.tailCall(chunk.Calls[readOperand(code, ip+1)], frame)
+			ip = frame.ip
+
+		case OP_RETURN, OP_END, OP_ARM_END:
+			var value any
+			switch op {
+			case OP_RETURN:
+				value = m.pop()
+				if readOperand(code, ip+1) == 1 {
+					m.switchEnded = false
+				}
+			case OP_END:
+				if frame.function.IsFragment() {
+					return m.result, false
+				}
+				fd := frame.function.Declaration.(*ast.FunctionDefinition)
+				if fd.Type != shared.VOID {
+					m.fail(NewSemanticErrorWithCode(ERR_MISSING_RETURN, fd.Position, fd.Type).WithSpan(fd.Span))
+				}
+				m.result = nil
+			case OP_ARM_END:
+				if m.result == nil {
+					m.switchEnded = true
+					ip++
+					continue
+				}
+				value = m.result
+				m.switchEnded = false
+			}
+
+			if m.leave(value) {
+				return value, true
+			}
+			m.result = value
+			frame = m.frames[len(m.frames)-1]
+			chunk = frame.function.Chunk
+			code = chunk.Code
+			ip = frame.ip
+
+		case OP_JUMP_IF_SWITCH_ENDED:
+			if m.switchEnded {
+				ip = readOperand(code, ip+1)
+			} else {
+				ip += 3
+			}
+
+		case OP_SWITCH_END:
+			m.switchEnded = false
+			ip++
+
+		case OP_STEP:
+			m.step(chunk.Positions[ip], chunk.Spans[ip])
+			ip++
+
+		case OP_FAIL:
+			m.fail(NewSemanticErrorWithCode(ErrorCode(readOperand(code, ip+1)), chunk.Positions[ip]).WithSpan(chunk.Spans[ip]))
+
+		default:
+			panic(fmt.Errorf("unknown opcode %v at %d", op, ip))
+		}
+	}
+}
+
+// int operands are computed in place, everything else
+// goes through the operators shared with the CodeVisitor
+func (m *VirtualMachine) binary(op Opcode, left, right any, position shared.Position, span shared.Span) any {
+	if l, ok := left.(int); ok {
+		if r, ok := right.(int); ok {
+			switch op {
+			case OP_SUM:
+				return l + r
+			case OP_SUBSTRACT:
+				return l - r
+			case OP_MULTIPLY:
+				return l * r
+			case OP_LESS_THAN:
+				return l < r
+			case OP_LESS_OR_EQUAL:
+				return l <= r
+			case OP_GREATER_THAN:
+				return l > r
+			case OP_GREATER_OR_EQUAL:
+				return l >= r
+			case OP_EQUALS:
+				return l == r
+			case OP_NOT_EQUALS:
+				return l != r
+			}
+		}
+	}
+
+	var result any
+	var valid bool
+	switch op {
+	case OP_MULTIPLY:
+		if result, valid = multiply(left, right); !valid {
+			m.fail(operandsError(ERR_INVALID_MULTIPLY_EXPRESSION, position, left, right).WithSpan(span))
+		}
+	case OP_DIVIDE:
+		if isZero(right) {
+			m.fail(NewSemanticErrorWithCode(ERR_DIVISION_BY_ZERO, position).WithSpan(span))
+		}
+		if result, valid = divide(left, right); !valid {
+			m.fail(operandsError(ERR_INVALID_DIVISION_EXPRESSION, position, left, right).WithSpan(span))
+		}
+	case OP_SUM:
+		if result, valid = sum(left, right); !valid {
+			m.fail(NewSemanticErrorWithCode(ERR_INVALID_SUM_EXPRESSION, position, left, right).WithSpan(span))
+		}
+		m.allocate(result, position, span)
+	case OP_SUBSTRACT:
+		if result, valid = subtract(left, right); !valid {
+			m.fail(NewSemanticErrorWithCode(ERR_INVALID_SUBSTRACT_EXPRESSION, position, left, right).WithSpan(span))
+		}
+	case OP_EQUALS:
+		if reflect.TypeOf(left) != reflect.TypeOf(right) {
+			m.fail(operandsError(ERR_INVALID_EQUALS_MISSMATCH, position, left, right).WithSpan(span))
+		}
+		result = left == right
+	case OP_NOT_EQUALS:
+		if reflect.TypeOf(left) != reflect.TypeOf(right) {
+			m.fail(operandsError(ERR_INVALID_NOT_EQUALS_MISSMATCH, position, left, right).WithSpan(span))
+		}
+		result = left != right
+	case OP_GREATER_THAN:
+		if result, valid = greaterThan(left, right); !valid {
+			m.fail(operandsError(ERR_INVALID_GREATER_THAN_MISSMATCH, position, left, right).WithSpan(span))
+		}
+	case OP_GREATER_OR_EQUAL:
+		if result, valid = greaterOrEqual(left, right); !valid {
+			m.fail(operandsError(ERR_INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH, position, left, right).WithSpan(span))
+		}
+	case OP_LESS_THAN:
+		if result, valid = lessThan(left, right); !valid {
+			m.fail(operandsError(ERR_INVALID_LESS_THAN_MISSMATCH, position, left, right).WithSpan(span))
+		}
+	case OP_LESS_OR_EQUAL:
+		if result, valid = lessOrEqual(left, right); !valid {
+			m.fail(operandsError(ERR_INVALID_LESS_OR_EQUALS_THAN_MISSMATCH, position, left, right).WithSpan(span))
+		}
+	}
+	return result
+}
@@ -0,0 +1,258 @@
+package interpreter
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"tkom/ast"
+	"tkom/shared"
+)
+
+// evaluates the node with the state of the visitor,
+// the results are left in the visitor whichever engine runs it
+type evaluate func(visitor *CodeVisitor, node ast.Node)
+
+func evaluateWithVisitor(visitor *CodeVisitor, node ast.Node) {
+	node.Accept(visitor)
+}
+
+func evaluateWithVirtualMachine(visitor *CodeVisitor, node ast.Node) {
+	vm := NewVirtualMachine(visitor.MaxRecursionDepth)
+	vm.Functions = visitor.FunctionsMap
+	vm.Budget = visitor.Budget
+	vm.Limits = visitor.Limits
+	visitor.LastResult, visitor.ReturnFlag = vm.Evaluate(node, visitor.CurrentScope)
+}
+
+// runs the test against both engines
+func forEachEngine(t *testing.T, test func(t *testing.T, run evaluate)) {
+	t.Run(ENGINE_INTERPRETER, func(t *testing.T) { test(t, evaluateWithVisitor) })
+	t.Run(ENGINE_VM, func(t *testing.T) { test(t, evaluateWithVirtualMachine) })
+}
+
+func TestDisassemble(t *testing.T) {
+	add := &ast.FunctionDefinition{
+		Name: "add",
+		Parameters: []*ast.Variable{
+			{Name: "a", Type: shared.INT},
+			{Name: "b", Type: shared.INT},
+		},
+		Block: &ast.Block{
+			Statements: []ast.Statement{
+				&ast.ReturnStatement{
+					Value: &ast.SumExpression{
+						LeftExpression:  &ast.Identifier{Name: "a", Position: shared.NewPosition(2, 12)},
+						RightExpression: &ast.Identifier{Name: "b", Position: shared.NewPosition(2, 16)},
+						Position:        shared.NewPosition(2, 14),
+					},
+				},
+			},
+		},
+		Type:     shared.INT,
+		Position: shared.NewPosition(1, 1),
+	}
+
+	expected := "== add ==\n" +
+		"0000    2:12  LOAD_LOCAL           0 0 ; a\n" +
+		"0005    2:16  LOAD_LOCAL           1 1 ; b\n" +
+		"0010    2:14  SUM                 \n" +
+		"0011    0:0   RETURN               0\n" +
+		"0014    1:1   END                 \n"
+	if got := Compile(add).Disassemble(); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+// TESTING CASE:
+//
+//	{
+//		int i := 0
+//		while i < 2 {
+//			int a := i
+//			i = i + 1
+//		}
+//	}
+//
+// the scope of the loop is created once, so the second
+// declaration of 'a' is a redeclaration on both engines
+func TestRedeclarationInLoop(t *testing.T) {
+	forEachEngine(t, func(t *testing.T, run evaluate) {
+		block := &ast.Block{
+			Statements: []ast.Statement{
+				&ast.Variable{Name: "i", Type: shared.INT, Value: &ast.IntExpression{Value: 0}},
+				&ast.WhileStatement{
+					Condition: &ast.LessThanExpression{
+						LeftExpression:  &ast.Identifier{Name: "i"},
+						RightExpression: &ast.IntExpression{Value: 2},
+					},
+					InstructionsBlock: &ast.Block{
+						Statements: []ast.Statement{
+							&ast.Variable{Name: "a", Type: shared.INT, Value: &ast.Identifier{Name: "i"}, Position: shared.NewPosition(3, 3)},
+							&ast.Assignment{
+								Identifier: &ast.Identifier{Name: "i"},
+								Value: &ast.SumExpression{
+									LeftExpression:  &ast.Identifier{Name: "i"},
+									RightExpression: &ast.IntExpression{Value: 1},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		visitor := NewCodeVisitor(MAX_RECURSION_DEPTH)
+		visitor.CurrentScope = NewScope(nil, nil)
+
+		expectedError := NewSemanticError(fmt.Sprintf(ERR_REDECLARED_VARIABLE.Template(), "a"), shared.NewPosition(3, 3))
+		defer func() {
+			err, ok := recover().(error)
+			if !ok || err.Error() != expectedError.Error() {
+				t.Errorf("expected panic with error: %v, got: %v", expectedError, err)
+			}
+			if i, _ := visitor.CurrentScope.GetVariable("i"); i != 1 {
+				t.Errorf("expected i to be 1, got: %v", i)
+			}
+		}()
+		run(visitor, block)
+	})
+}
+
+func TestVirtualMachineReuse(t *testing.T) {
+	vm := NewVirtualMachine(MAX_RECURSION_DEPTH)
+	scope := NewScope(nil, nil)
+
+	vm.Evaluate(&ast.Variable{Name: "a", Type: shared.INT, Value: &ast.IntExpression{Value: 20}}, scope)
+	result, returned := vm.Evaluate(&ast.SumExpression{
+		LeftExpression:  &ast.Identifier{Name: "a"},
+		RightExpression: &ast.IntExpression{Value: 22},
+	}, scope)
+
+	if result != 42 || returned {
+		t.Errorf("expected result 42 without return, got: %v, %v", result, returned)
+	}
+}
+
+func TestNewEngine(t *testing.T) {
+	if _, ok := mustEngine(t, ENGINE_INTERPRETER).(*CodeVisitor); !ok {
+		t.Errorf("expected %s engine to be a CodeVisitor", ENGINE_INTERPRETER)
+	}
+	if _, ok := mustEngine(t, ENGINE_VM).(*VirtualMachine); !ok {
+		t.Errorf("expected %s engine to be a VirtualMachine", ENGINE_VM)
+	}
+	if _, err := NewEngine("jit", MAX_RECURSION_DEPTH); err == nil {
+		t.Errorf("expected error for unknown engine")
+	}
+}
+
+func mustEngine(t *testing.T, name string) Engine {
+	engine, err := NewEngine(name, MAX_RECURSION_DEPTH)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return engine
+}
+
+// programs print the same and fail with the same error in both engines
+func TestEngineParity(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		output string
+		code   ErrorCode
+	}{
+		{"arithmetic", "main() {\n    print(1 + 2 * 3 - 4 / 2)\n    print(1.5 * 2.0)\n    print(\"a\" + \"b\")\n    print(-3)\n}\n", "5\n3\nab\n-3\n", 0},
+		{"comparisons", "main() {\n    print(1 < 2 and 2 <= 2)\n    print(1 > 2 or 2 >= 3)\n    print(\"a\" == \"a\")\n    print(1 != 1)\n}\n", "true\nfalse\ntrue\nfalse\n", 0},
+		{"casts", "main() {\n    print(5 as string)\n    print(0 as bool)\n    print(\"7\" as int + 1)\n}\n", "5\nfalse\n8\n", 0},
+		{"if and while", "main() {\n    int i := 3\n    while i > 0 {\n        if i == 2 {\n            print(\"two\")\n        } else {\n            print(i)\n        }\n        i = i - 1\n    }\n}\n", "3\ntwo\n1\n", 0},
+		{"switch", "pick(n int) string {\n    switch int c := n {\n        c < 0 => \"negative\",\n        c == 0 => \"zero\",\n        default => \"positive\"\n    }\n}\n\nmain() {\n    print(pick(-1))\n    print(pick(0))\n    print(pick(4))\n}\n", "negative\nzero\npositive\n", 0},
+		{"recursion", "fib(n int) int {\n    if n <= 1 {\n        return n\n    }\n    return fib(n - 1) + fib(n - 2)\n}\n\nmain() {\n    print(fib(4))\n}\n", "3\n", 0},
+		{"division by zero", "main() {\n    print(\"before\")\n    int a := 0\n    print(1 / a)\n}\n", "before\n", ERR_DIVISION_BY_ZERO},
+		{"type mismatch", "main() {\n    int a := \"x\"\n}\n", "", ERR_TYPE_MISMATCH},
+		{"undefined function", "main() {\n    missing()\n}\n", "", ERR_UNDEFINED_FUNCTION},
+		{"wrong argument type", "f(n int) int {\n    return n\n}\n\nmain() {\n    f(\"x\")\n}\n", "", ERR_WRONG_ARGUMENT_TYPE},
+		{"missing return", "f() int {\n}\n\nmain() {\n    f()\n}\n", "", ERR_MISSING_RETURN},
+		{"recursion depth", "f(n int) int {\n    return 1 + f(n + 1)\n}\n\nmain() {\n    f(0)\n}\n", "", ERR_MAX_RECURSION_DEPTH_EXCEEDED},
+		{"error in arguments", "f(n int) int {\n    return 1 / n\n}\n\nmain() {\n    print(\"a\", f(0))\n}\n", "", ERR_DIVISION_BY_ZERO},
+		{"non boolean condition", "main() {\n    while 1 {\n    }\n}\n", "", ERR_INVALID_WHILE_CONDITION},
+	}
+	defer func() { Output = nil }()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var errors [2]*SemantciError
+			for i, name := range []string{ENGINE_INTERPRETER, ENGINE_VM} {
+				program := parseProgram(t, test.source)
+				ResolveProgram(program)
+				var output bytes.Buffer
+				Output = &output
+				err := RunProgram(mustEngine(t, name), program, &ast.FunctionCall{Name: "main"})
+				if output.String() != test.output {
+					t.Errorf("%s: expected output %q, got %q", name, test.output, output.String())
+				}
+				if test.code == 0 {
+					if err != nil {
+						t.Errorf("%s: unexpected error: %v", name, err)
+					}
+					continue
+				}
+				semanticError, ok := err.(*SemantciError)
+				if !ok || semanticError.Code != test.code {
+					t.Fatalf("%s: expected %v, got %v", name, test.code, err)
+				}
+				errors[i] = semanticError
+			}
+			if test.code == 0 {
+				return
+			}
+			if errors[0].Error() != errors[1].Error() {
+				t.Errorf("engines report different errors: %q and %q", errors[0], errors[1])
+			}
+			if errors[0].FormatTraceback() != errors[1].FormatTraceback() {
+				t.Errorf("engines report different tracebacks:\n%s\nand:\n%s", errors[0].FormatTraceback(), errors[1].FormatTraceback())
+			}
+		})
+	}
+}
+
+// runtime errors underline the node they are raised at, in both engines,
+// errors of builtins underline their call
+func TestErrorSpans(t *testing.T) {
+	tests := []struct {
+		source string
+		code   ErrorCode
+		text   string
+	}{
+		{"main() {\n    int a := 0\n    print(1 + 4 / a)\n}\n", ERR_DIVISION_BY_ZERO, "4 / a"},
+		{"main() {\n    bool a := modulo(4, 0)\n}\n", ERR_DIVISION_BY_ZERO, "modulo(4, 0)"},
+		{"main() {\n    int a := 1\n    if a {\n    }\n}\n", ERR_EXPECTED_BOOLEAN_EXPRESSION, "a"},
+	}
+	for _, test := range tests {
+		for _, name := range []string{ENGINE_INTERPRETER, ENGINE_VM} {
+			program := parseProgram(t, test.source)
+			ResolveProgram(program)
+			err, ok := RunProgram(mustEngine(t, name), program, &ast.FunctionCall{Name: "main"}).(*SemantciError)
+			if !ok || err.Code != test.code {
+				t.Fatalf("%s: expected %v, got %v", name, test.code, err)
+			}
+			if text := test.source[err.Span.StartOffset:err.Span.EndOffset]; text != test.text {
+				t.Errorf("%s: expected the error to span %q, got %q", name, test.text, text)
+			}
+		}
+	}
+}
+
+// functions of a program are not left behind for the programs run after it
+func TestEnginesDoNotShareFunctions(t *testing.T) {
+	first := parseProgram(t, "helper() {\n}\n\nmain() {\n    helper()\n}\n")
+	second := parseProgram(t, "main() {\n    helper()\n}\n")
+	for _, name := range []string{ENGINE_INTERPRETER, ENGINE_VM} {
+		if err := RunProgram(mustEngine(t, name), first, &ast.FunctionCall{Name: "main"}); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+	}
+	for _, name := range []string{ENGINE_INTERPRETER, ENGINE_VM} {
+		err, ok := RunProgram(mustEngine(t, name), second, &ast.FunctionCall{Name: "main"}).(*SemantciError)
+		if !ok || err.Code != ERR_UNDEFINED_FUNCTION {
+			t.Errorf("%s: expected %v, got %v", name, ERR_UNDEFINED_FUNCTION, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package lexer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// widths of the columns of the text, wider spans move the rest of their line
+const (
+	SPAN_WIDTH = 10
+	// the longest type name is RIGHT_PARENTHESIS
+	TYPE_WIDTH = 17
+)
+
+// JSON of a token, every token is written as one object on its own line:
+//
+//	{"type": "IDENTIFIER", "value": "main", "start": {"line": 1, "column": 1}, "end": {"line": 1, "column": 5}}
+//
+// the end is the position after the last character of the token
+type jsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type jsonToken struct {
+	Type  string       `json:"type"`
+	Value any          `json:"value"`
+	Start jsonPosition `json:"start"`
+	End   jsonPosition `json:"end"`
+}
+
+// prints every token of the lexer up to ETX with its type, value, start and end,
+// comments included, as columns of text or as JSON
+//
+// the lexer cannot go past an UNDEFINED token, it is printed last and reported
+// to the error handler of the lexer
+func WriteTokens(w io.Writer, l *Lexer, asJSON bool) error {
+	encoder := json.NewEncoder(w)
+
+	for {
+		token := l.GetNextToken()
+		start, end := token.Span.Start, token.Span.End
+
+		var err error
+		if asJSON {
+			err = encoder.Encode(jsonToken{
+				Type:  token.Type.TypeName(),
+				Value: tokenValue(token),
+				Start: jsonPosition(start),
+				End:   jsonPosition(end),
+			})
+		} else {
+			span := fmt.Sprintf("%d:%d-%d:%d", start.Line, start.Column, end.Line, end.Column)
+			line := fmt.Sprintf("%-*s %-*s %s", SPAN_WIDTH, span, TYPE_WIDTH, token.Type.TypeName(), formatValue(token))
+			_, err = fmt.Fprintln(w, strings.TrimRight(line, " "))
+		}
+		if err != nil {
+			return err
+		}
+
+		switch token.Type {
+		case ETX:
+			return nil
+		case UNDEFINED:
+			l.ErrorHandler(NewLexerError(NONE_TOKEN_MATCH, token.Position))
+			return nil
+		}
+	}
+}
+
+// value of the token, the character of an UNDEFINED token as a string
+func tokenValue(token *Token) any {
+	if r, ok := token.Value.(rune); ok {
+		return string(r)
+	}
+	return token.Value
+}
+
+func formatValue(token *Token) string {
+	switch value := tokenValue(token).(type) {
+	case nil:
+		return ""
+	case string:
+		return fmt.Sprintf("%q", value)
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
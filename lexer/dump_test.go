@@ -0,0 +1,71 @@
+package lexer
+
+import (
+	"strings"
+	"testing"
+)
+
+func writeTokens(t *testing.T, input string, asJSON bool) (string, []error) {
+	t.Helper()
+	source, _ := NewScanner(strings.NewReader(input))
+	lexer := NewLexer(source, identifierLimit, stringLimit, intLimit)
+	var errors []error
+	lexer.ErrorHandler = func(err error) {
+		errors = append(errors, err)
+	}
+	var out strings.Builder
+	if err := WriteTokens(&out, lexer, asJSON); err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), errors
+}
+
+func TestWriteTokens(t *testing.T) {
+	out, errors := writeTokens(t, "# add\nx := 12 >= -1.5\nprint(\"a\\tb\", true)\n", false)
+	expected := `1:1-1:6    COMMENT           "# add"
+2:1-2:2    IDENTIFIER        "x"
+2:3-2:5    DECLARE
+2:6-2:8    CONST_INT         12
+2:9-2:11   GREATER_OR_EQUAL
+2:12-2:13  MINUS
+2:13-2:16  CONST_FLOAT       1.5
+3:1-3:6    IDENTIFIER        "print"
+3:6-3:7    LEFT_PARENTHESIS
+3:7-3:13   CONST_STRING      "a\tb"
+3:13-3:14  COMMA
+3:15-3:19  CONST_TRUE
+3:19-3:20  RIGHT_PARENTHESIS
+4:1-4:1    ETX
+`
+	if out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+	if len(errors) != 0 {
+		t.Errorf("unexpected errors: %v", errors)
+	}
+}
+
+func TestWriteTokensJSON(t *testing.T) {
+	out, _ := writeTokens(t, "a 1", true)
+	expected := `{"type":"IDENTIFIER","value":"a","start":{"line":1,"column":1},"end":{"line":1,"column":2}}
+{"type":"CONST_INT","value":1,"start":{"line":1,"column":3},"end":{"line":1,"column":4}}
+{"type":"ETX","value":null,"start":{"line":1,"column":4},"end":{"line":1,"column":4}}
+`
+	if out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+// the lexer stops at a character it does not know
+func TestWriteTokensUndefined(t *testing.T) {
+	out, errors := writeTokens(t, "a $ b", true)
+	expected := `{"type":"IDENTIFIER","value":"a","start":{"line":1,"column":1},"end":{"line":1,"column":2}}
+{"type":"UNDEFINED","value":"$","start":{"line":1,"column":3},"end":{"line":1,"column":4}}
+`
+	if out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+	if len(errors) != 1 || errors[0].(*LexerError).Code != NONE_TOKEN_MATCH {
+		t.Errorf("expected %v, got %v", NONE_TOKEN_MATCH, errors)
+	}
+}
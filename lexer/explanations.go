@@ -0,0 +1,47 @@
+package lexer
+
+import "tkom/diagnostics"
+
+type explanation struct {
+	text    string
+	example string
+}
+
+var explanations = map[ErrorCode]explanation{
+	INT_CAPACITY_EXCEEDED: {
+		text:    "An integer literal is larger than the biggest int the interpreter can hold.\nUse a smaller number or a float literal.",
+		example: "main() {\n    int a := 99999999999999999999\n}",
+	},
+	FLOAT_CAPACITY_EXCEEDED: {
+		text:    "The fractional part of a float literal has more digits than the lexer accepts.\nRound the literal to fewer decimal places.",
+		example: "main() {\n    float a := 0.99999999999999999999\n}",
+	},
+	IDENTIFIER_CAPACITY_EXCEEDED: {
+		text: "A variable or function name is longer than the identifier limit,\nthe flux command accepts names of up to 500 characters.",
+	},
+	STRING_CAPACITY_EXCEEDED: {
+		text: "A string literal is longer than the string limit, the flux command accepts literals\nof up to 1000 characters. Split the text into several literals joined with '+'.",
+	},
+	STRING_NOT_CLOSED: {
+		text:    "A string literal reached the end of the line or file without the closing quote.\nEvery string has to be closed on the line it was opened.",
+		example: "main() {\n    print(\"hello)\n}",
+	},
+	INVALID_ESCAPING: {
+		text:    "A backslash inside a string is followed by a character that cannot be escaped.\nOnly \\n, \\t, \\\" and \\\\ are recognised.",
+		example: "main() {\n    print(\"tab\\q\")\n}",
+	},
+	NONE_TOKEN_MATCH: {
+		text: "The source contains a character that does not start any token of the language.\nSuch characters are currently reported by the parser as E0234.",
+	},
+}
+
+func init() {
+	for code, e := range explanations {
+		diagnostics.RegisterExplanation(&diagnostics.Explanation{
+			Code:    code.String(),
+			Message: errorMessage[code],
+			Text:    e.text,
+			Example: e.example,
+		})
+	}
+}
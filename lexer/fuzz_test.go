@@ -0,0 +1,49 @@
+package lexer
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// seeds the fuzz target with the example programs and inputs the lexer rejects
+func addSeeds(f *testing.F) {
+	files, err := filepath.Glob("../example_codes/*.fl")
+	if err != nil || len(files) == 0 {
+		f.Fatalf("no example codes found: %v", err)
+	}
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(source))
+	}
+	for _, source := range []string{"", "\"not closed", "\"\\q\"", "99999999999999999999", "1.99999999999999999999", "a$b", "x\r\ny\r", "# comment"} {
+		f.Add(source)
+	}
+}
+
+// the lexer reports malformed input with lexer errors and always reaches the end
+func FuzzGetNextToken(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, source string) {
+		scanner, _ := NewScanner(strings.NewReader(source))
+		lex := NewLexer(scanner, 500, 1000, math.MaxInt)
+		lex.ErrorHandler = func(err error) {
+			if _, ok := err.(*LexerError); !ok {
+				t.Fatalf("unexpected error %T: %v", err, err)
+			}
+		}
+		// every token consumes at least one character, an undefined one stops the parser
+		for i := 0; i <= len(source)+1; i++ {
+			token := lex.GetNextToken()
+			if token.Type == ETX || token.Type == UNDEFINED {
+				return
+			}
+		}
+		t.Fatalf("the lexer did not reach the end of %q", source)
+	})
+}
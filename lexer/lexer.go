@@ -8,8 +8,10 @@ import (
 )
 
 type Lexer struct {
-	scanner         *Scanner
-	ErrorHandler    func(err error)
+	scanner      *Scanner
+	ErrorHandler func(err error)
+	// name of the source put in the spans of the tokens, empty when it has none
+	File            string
 	pos             shared.Position
 	identifierLimit int
 	stringLimit     int
@@ -27,15 +29,26 @@ func NewLexer(scanner *Scanner, identifierLimit, stringLimit, intLimit int) *Lex
 }
 
 func (l *Lexer) GetNextToken() (t *Token) {
+	var start shared.Span
 	defer func() {
 		if err := recover(); err != nil {
+			if e, ok := err.(*LexerError); ok {
+				e.Span = l.spanFrom(start)
+			}
 			t = NewToken(ETX, l.pos, nil)
+			t.Span = l.span()
 			l.ErrorHandler(err.(error))
 		}
 	}()
 
 	l.skipWhiteChar()
 	pos := l.pos
+	start = l.span()
+	defer func() {
+		if t != nil {
+			t.Span = l.spanFrom(start)
+		}
+	}()
 
 	if l.scanner.Character() == EOF {
 		return NewToken(ETX, pos, nil)
@@ -69,6 +82,22 @@ func (l *Lexer) GetNextToken() (t *Token) {
 	return NewToken(UNDEFINED, l.scanner.Position(), l.scanner.Character())
 }
 
+// empty span at the current character
+func (l *Lexer) span() shared.Span {
+	return shared.NewSpan(l.File, l.pos, l.pos, l.scanner.Offset, l.scanner.Offset)
+}
+
+// span from the start up to the current character, a character that is not
+// consumed, like the one of an UNDEFINED token, is the whole span
+func (l *Lexer) spanFrom(start shared.Span) shared.Span {
+	end := l.span()
+	if end.EndOffset == start.StartOffset && l.scanner.Character() != EOF {
+		end.End.Column++
+		end.EndOffset += l.scanner.size
+	}
+	return start.To(end)
+}
+
 func (l *Lexer) consume() rune {
 	l.scanner.NextRune()
 	l.pos = l.scanner.Position()
@@ -209,7 +238,7 @@ func (l *Lexer) createString(position shared.Position) *Token {
 	}
 	var strBuilder strings.Builder
 	l.consume()
-	for l.scanner.Character() != '"' && l.scanner.Character() != EOF && l.scanner.Character() != '\n'{
+	for l.scanner.Character() != '"' && l.scanner.Character() != EOF && l.scanner.Character() != '\n' {
 		if strBuilder.Len() == l.stringLimit {
 			panic(NewLexerError(STRING_CAPACITY_EXCEEDED, l.pos))
 		}
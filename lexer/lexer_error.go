@@ -2,34 +2,45 @@ package lexer
 
 import (
 	"fmt"
+	"tkom/diagnostics"
 	"tkom/shared"
 )
 
 type ErrorCode int
 
 const (
-	INT_CAPACITY_EXCEEDED ErrorCode = iota
-	FLOAT_CAPACITY_EXCEEDED
-	IDENTIFIER_CAPACITY_EXCEEDED
-	STRING_CAPACITY_EXCEEDED
-	STRING_NOT_CLOSED
-	INVALID_ESCAPING
-	NONE_TOKEN_MATCH
+	INT_CAPACITY_EXCEEDED        ErrorCode = 101
+	FLOAT_CAPACITY_EXCEEDED      ErrorCode = 102
+	IDENTIFIER_CAPACITY_EXCEEDED ErrorCode = 103
+	STRING_CAPACITY_EXCEEDED     ErrorCode = 104
+	STRING_NOT_CLOSED            ErrorCode = 105
+	INVALID_ESCAPING             ErrorCode = 106
+	NONE_TOKEN_MATCH             ErrorCode = 107
 )
 
 var errorMessage = map[ErrorCode]string{
-	INT_CAPACITY_EXCEEDED:        "error [%d, %d] Int value limit Exceeded",
-	FLOAT_CAPACITY_EXCEEDED:      "error [%d, %d] Float decimal value limit Exceeded",
-	IDENTIFIER_CAPACITY_EXCEEDED: "error [%d, %d] Identifier capacity exceeded",
-	STRING_CAPACITY_EXCEEDED:     "error [%d, %d] String capacity exceeded",
-	STRING_NOT_CLOSED:            "error [%d, %d] String not closed, perhaps you forgot \"",
-	INVALID_ESCAPING:             "error [%d, %d] Invalid syntax escaping",
-	NONE_TOKEN_MATCH:             "error [%d, %d] None token match found for the source",
+	INT_CAPACITY_EXCEEDED:        "Int value limit Exceeded",
+	FLOAT_CAPACITY_EXCEEDED:      "Float decimal value limit Exceeded",
+	IDENTIFIER_CAPACITY_EXCEEDED: "Identifier capacity exceeded",
+	STRING_CAPACITY_EXCEEDED:     "String capacity exceeded",
+	STRING_NOT_CLOSED:            "String not closed, perhaps you forgot \"",
+	INVALID_ESCAPING:             "Invalid syntax escaping",
+	NONE_TOKEN_MATCH:             "None token match found for the source",
+}
+
+// lexer errors are numbered E0101, E0102, ...
+func (c ErrorCode) String() string {
+	if c == 0 {
+		return ""
+	}
+	return fmt.Sprintf("E%04d", int(c))
 }
 
 type LexerError struct {
 	Code     ErrorCode
 	Position shared.Position
+	// source the lexer read for the token before the error, set by the lexer
+	Span shared.Span
 }
 
 func (e *LexerError) Error() string {
@@ -37,7 +48,17 @@ func (e *LexerError) Error() string {
 	if !ok {
 		return "unknown error"
 	}
-	return fmt.Sprintf(msg, e.Position.Line, e.Position.Column)
+	return fmt.Sprintf("error [%d, %d] %s", e.Position.Line, e.Position.Column, msg)
+}
+
+func (e *LexerError) Diagnostic() *diagnostics.Diagnostic {
+	msg, ok := errorMessage[e.Code]
+	if !ok {
+		msg = "unknown error"
+	}
+	d := diagnostics.NewDiagnostic(diagnostics.ERROR, e.Code.String(), msg, e.Position)
+	d.Span = e.Span
+	return d
 }
 
 func NewLexerError(code ErrorCode, position shared.Position) *LexerError {
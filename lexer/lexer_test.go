@@ -15,6 +15,12 @@ const (
 	intLimit        = math.MaxInt
 )
 
+// spans are checked by TestTokenSpans, other tests compare tokens without them
+func withoutSpan(token *Token) *Token {
+	token.Span = shared.Span{}
+	return token
+}
+
 func TestSingleTokens(t *testing.T) {
 	testCases := []struct {
 		expect *Token
@@ -89,7 +95,7 @@ func TestSingleTokens(t *testing.T) {
 			source, _ := NewScanner(reader)
 			lexer := NewLexer(source, identifierLimit, stringLimit, intLimit)
 
-			token := lexer.GetNextToken()
+			token := withoutSpan(lexer.GetNextToken())
 
 			if !reflect.DeepEqual(token, tc.expect) {
 				t.Errorf("Expected token: %v, Got: %v", tc.expect, token)
@@ -145,7 +151,7 @@ func TestLexerCodeExample(t *testing.T) {
 		var tokens []*Token
 		for {
 			token := lexer.GetNextToken()
-			tokens = append(tokens, token)
+			tokens = append(tokens, withoutSpan(token))
 			if token.GetType() == ETX {
 				break
 			}
@@ -283,7 +289,7 @@ func TestLexerErrorHandling(t *testing.T) {
 
 	for {
 		token := lexer.GetNextToken()
-		tokens = append(tokens, token)
+		tokens = append(tokens, withoutSpan(token))
 		if token.Type == ETX {
 			break
 		}
@@ -301,3 +307,75 @@ func TestLexerErrorHandling(t *testing.T) {
 		t.Errorf("Expected error: %s, but got: %s", expectedError, externalErrors[0].Error())
 	}
 }
+
+func TestEveryErrorCodeIsExplained(t *testing.T) {
+	for code := INT_CAPACITY_EXCEEDED; code <= NONE_TOKEN_MATCH; code++ {
+		if _, ok := errorMessage[code]; !ok {
+			t.Errorf("no message for error code %s", code)
+		}
+		if _, ok := explanations[code]; !ok {
+			t.Errorf("no explanation for error code %s", code)
+		}
+	}
+}
+
+// codes are shown to users and looked up with flux explain, they must not change
+func TestErrorCodesAreStable(t *testing.T) {
+	tests := []struct {
+		code     ErrorCode
+		expected string
+	}{
+		{INT_CAPACITY_EXCEEDED, "E0101"},
+		{FLOAT_CAPACITY_EXCEEDED, "E0102"},
+		{IDENTIFIER_CAPACITY_EXCEEDED, "E0103"},
+		{STRING_CAPACITY_EXCEEDED, "E0104"},
+		{STRING_NOT_CLOSED, "E0105"},
+		{INVALID_ESCAPING, "E0106"},
+		{NONE_TOKEN_MATCH, "E0107"},
+	}
+	for _, test := range tests {
+		if test.code.String() != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, test.code)
+		}
+	}
+}
+
+func TestTokenSpans(t *testing.T) {
+	source, _ := NewScanner(strings.NewReader("x := \"é\"\r\n  12 $"))
+	lexer := NewLexer(source, identifierLimit, stringLimit, intLimit)
+	lexer.File = "main.fl"
+
+	span := func(startLine, startColumn, endLine, endColumn, startOffset, endOffset int) shared.Span {
+		return shared.NewSpan("main.fl", shared.NewPosition(startLine, startColumn), shared.NewPosition(endLine, endColumn), startOffset, endOffset)
+	}
+	expected := []shared.Span{
+		span(1, 1, 1, 2, 0, 1),
+		span(1, 3, 1, 5, 2, 4),
+		// é takes two bytes, but a single column
+		span(1, 6, 1, 9, 5, 9),
+		span(2, 3, 2, 5, 13, 15),
+		// an undefined character is not consumed, its span is still the character
+		span(2, 6, 2, 7, 16, 17),
+	}
+	for i, e := range expected {
+		if token := lexer.GetNextToken(); token.Span != e {
+			t.Errorf("token %d: expected span %+v, got %+v", i, e, token.Span)
+		}
+	}
+}
+
+func TestLexerErrorSpan(t *testing.T) {
+	source, _ := NewScanner(strings.NewReader("a = \"abc\n"))
+	lexer := NewLexer(source, identifierLimit, stringLimit, intLimit)
+	var errors []error
+	lexer.ErrorHandler = func(err error) {
+		errors = append(errors, err)
+	}
+	for token := lexer.GetNextToken(); token.Type != ETX; token = lexer.GetNextToken() {
+	}
+
+	expected := shared.NewSpan("", shared.NewPosition(1, 5), shared.NewPosition(1, 9), 4, 8)
+	if len(errors) != 1 || errors[0].(*LexerError).Span != expected {
+		t.Errorf("expected an error with span %+v, got %v", expected, errors)
+	}
+}
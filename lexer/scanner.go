@@ -14,6 +14,10 @@ type Scanner struct {
 	Current   rune
 	LineCount int
 	CharCount int
+	// byte offset of Current from the start of the source
+	Offset int
+	// bytes Current was read from, "\r\n" is read as a single '\n'
+	size int
 }
 
 func NewScanner(reader io.Reader) (*Scanner, error) {
@@ -28,17 +32,17 @@ func NewScanner(reader io.Reader) (*Scanner, error) {
 	return scanner, nil
 }
 
-func (s *Scanner) readRune() rune {
-	char, _, err := s.Reader.ReadRune()
+func (s *Scanner) readRune() (rune, int) {
+	char, size, err := s.Reader.ReadRune()
 	if err != nil {
 		if err == io.EOF {
 			char = EOF
 		} else {
 			log.Println("Unexpected error while reading source")
-			return EOF
+			return EOF, 0
 		}
 	}
-	return char
+	return char, size
 }
 
 func (s *Scanner) NextRune() {
@@ -51,12 +55,14 @@ func (s *Scanner) NextRune() {
 		return
 	}
 
-	char := s.readRune()
+	s.Offset += s.size
+	char, size := s.readRune()
 
 	if char == '\r' {
-		nextChar := s.readRune()
+		nextChar, nextSize := s.readRune()
 		if nextChar == '\n' {
 			char = nextChar
+			size += nextSize
 		} else {
 			err := s.Reader.UnreadRune()
 			if err != nil {
@@ -68,6 +74,7 @@ func (s *Scanner) NextRune() {
 
 	s.CharCount++
 	s.Current = char
+	s.size = size
 }
 
 func (s *Scanner) Position() shared.Position {
@@ -12,6 +12,8 @@ type Token struct {
 	Value    any
 	Type     TokenType
 	Position shared.Position
+	// set by the lexer, from the first character of the token to the one after the last
+	Span shared.Span
 }
 
 func convertValue(value any, expectedType reflect.Kind) (any, error) {
@@ -0,0 +1,445 @@
+package lsp
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"tkom/ast"
+	"tkom/diagnostics"
+	"tkom/interpreter"
+	"tkom/lexer"
+	"tkom/parser"
+	"tkom/shared"
+	"unicode/utf16"
+)
+
+const (
+	IDENTIFIER_LIMIT = 500
+	STRING_LIMIT     = 1000
+	INT_LIMIT        = math.MaxInt
+)
+
+// opened file with the result of its analysis
+type Document struct {
+	URI  string
+	Text string
+	// lines of runes, columns of positions count runes
+	lines       [][]rune
+	Program     *ast.Program
+	Diagnostics []*diagnostics.Diagnostic
+	index       *index
+}
+
+// name in the source bound to the node declaring it, the declaration
+// itself is a reference to the node as well
+type reference struct {
+	position   shared.Position
+	length     int
+	definition ast.Node
+}
+
+// variables visible between the braces of a block,
+// each one from its declaration on
+type scope struct {
+	open      shared.Position
+	close     shared.Position
+	variables []*ast.Variable
+}
+
+type index struct {
+	references []reference
+	scopes     []scope
+	// closing braces of the function bodies
+	ends      map[*ast.FunctionDefinition]shared.Position
+	functions map[string]ast.Function
+}
+
+// parses and resolves the text, a document that does not parse keeps
+// the index of its previous version so names can still be looked up
+func NewDocument(uri, text string, previous *Document) *Document {
+	d := &Document{URI: uri, Text: text}
+	for _, line := range strings.Split(text, "\n") {
+		d.lines = append(d.lines, []rune(strings.TrimSuffix(line, "\r")))
+	}
+	d.Program = d.parse()
+	if d.Program == nil {
+		if previous != nil {
+			d.index = previous.index
+		}
+		return d
+	}
+	d.resolve()
+	i := newIndexer(d.Program, text)
+	d.index = i.index
+	d.Diagnostics = append(d.Diagnostics, i.problems...)
+	return d
+}
+
+func newLexer(text string) *lexer.Lexer {
+	scanner, _ := lexer.NewScanner(strings.NewReader(text))
+	lex := lexer.NewLexer(scanner, IDENTIFIER_LIMIT, STRING_LIMIT, INT_LIMIT)
+	lex.ErrorHandler = func(err error) {
+		panic(err)
+	}
+	return lex
+}
+
+func (d *Document) report(r any) {
+	d.Diagnostics = append(d.Diagnostics, diagnostics.FromPanic(r))
+}
+
+func (d *Document) parse() (program *ast.Program) {
+	defer func() {
+		if r := recover(); r != nil {
+			d.report(r)
+			program = nil
+		}
+	}()
+	errorHandler := func(err error) {
+		panic(err)
+	}
+	return parser.NewParser(newLexer(d.Text), errorHandler).ParseProgram()
+}
+
+// the first error of the resolver stops it, its warnings are kept until then
+func (d *Document) resolve() {
+	defer func() {
+		if r := recover(); r != nil {
+			d.report(r)
+		}
+	}()
+	for _, warning := range interpreter.ResolveProgram(d.Program) {
+		d.Diagnostics = append(d.Diagnostics, warning.Diagnostic())
+	}
+}
+
+// position of the source from the position of the protocol
+func (d *Document) position(p Position) shared.Position {
+	if p.Line < 0 || p.Line >= len(d.lines) {
+		return shared.NewPosition(p.Line+1, 1)
+	}
+	line, units := d.lines[p.Line], 0
+	for i, r := range line {
+		if units >= p.Character {
+			return shared.NewPosition(p.Line+1, i+1)
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return shared.NewPosition(p.Line+1, len(line)+1)
+}
+
+// position of the protocol from the position of the source, unknown positions are at the start
+func (d *Document) protocolPosition(p shared.Position) Position {
+	if p.Line < 1 {
+		return Position{}
+	}
+	if p.Line > len(d.lines) {
+		return Position{Line: p.Line - 1}
+	}
+	line := d.lines[p.Line-1]
+	column := min(max(p.Column-1, 0), len(line))
+	return Position{Line: p.Line - 1, Character: len(utf16.Encode(line[:column]))}
+}
+
+func (d *Document) protocolRange(start shared.Position, length int) Range {
+	return Range{Start: d.protocolPosition(start), End: d.protocolPosition(shared.NewPosition(start.Line, start.Column+length))}
+}
+
+// reference under the cursor, the cursor right after a name is still on it
+func (d *Document) referenceAt(p Position) *reference {
+	if d.index == nil {
+		return nil
+	}
+	position := d.position(p)
+	for i := range d.index.references {
+		r := &d.index.references[i]
+		if r.position.Line == position.Line && r.position.Column <= position.Column && position.Column <= r.position.Column+r.length {
+			return r
+		}
+	}
+	return nil
+}
+
+// variables visible at the position, an inner variable hides the outer one of the same name
+func (d *Document) visibleVariables(position shared.Position) map[string]*ast.Variable {
+	variables := map[string]*ast.Variable{}
+	if d.index == nil {
+		return variables
+	}
+	// scopes are in the order of their opening braces, so inner scopes come later
+	for _, s := range d.index.scopes {
+		if !before(s.open, position) || before(s.close, position) {
+			continue
+		}
+		for _, v := range s.variables {
+			if before(v.Position, position) {
+				variables[v.Name] = v
+			}
+		}
+	}
+	return variables
+}
+
+func before(a, b shared.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// positions of the opening and the closing brace of a block
+type braces struct {
+	open  shared.Position
+	close shared.Position
+}
+
+// walks the program in the order of the source, blocks take the braces
+// in the same order, so the extent of every scope is known
+type indexer struct {
+	*index
+	braces []braces
+	// visible variables of the open scopes and their indexes in scopes, the innermost last
+	open    []map[string]*ast.Variable
+	current []int
+	// parameters declared in the scope of the next block
+	parameters []*ast.Variable
+	problems   []*diagnostics.Diagnostic
+}
+
+func newIndexer(program *ast.Program, text string) *indexer {
+	i := &indexer{index: &index{
+		ends:      map[*ast.FunctionDefinition]shared.Position{},
+		functions: interpreter.EmbeddedFunctions(),
+	}}
+	for name, f := range program.Functions {
+		i.functions[name] = f
+	}
+
+	lex := newLexer(text)
+	open := []int{}
+	for token := lex.GetNextToken(); token.Type != lexer.ETX; token = lex.GetNextToken() {
+		switch token.Type {
+		case lexer.LEFT_BRACE:
+			open = append(open, len(i.braces))
+			i.braces = append(i.braces, braces{open: token.Position})
+		case lexer.RIGHT_BRACE:
+			i.braces[open[len(open)-1]].close = token.Position
+			open = open[:len(open)-1]
+		}
+	}
+
+	functions := []*ast.FunctionDefinition{}
+	for _, f := range program.Functions {
+		functions = append(functions, f)
+	}
+	sort.Slice(functions, func(a, b int) bool {
+		return before(functions[a].Position, functions[b].Position)
+	})
+	for _, f := range functions {
+		f.Accept(i)
+	}
+	sort.SliceStable(i.references, func(a, b int) bool {
+		return before(i.references[a].position, i.references[b].position)
+	})
+	return i
+}
+
+func (i *indexer) refer(position shared.Position, name string, definition ast.Node) {
+	i.references = append(i.references, reference{position: position, length: len([]rune(name)), definition: definition})
+}
+
+func (i *indexer) nextBraces() braces {
+	b := i.braces[0]
+	i.braces = i.braces[1:]
+	return b
+}
+
+func (i *indexer) openScope(b braces) {
+	i.current = append(i.current, len(i.scopes))
+	i.scopes = append(i.scopes, scope{open: b.open, close: b.close})
+	i.open = append(i.open, map[string]*ast.Variable{})
+}
+
+func (i *indexer) declare(variable *ast.Variable) {
+	s := &i.scopes[i.current[len(i.current)-1]]
+	s.variables = append(s.variables, variable)
+	i.open[len(i.open)-1][variable.Name] = variable
+	i.refer(variable.Position, variable.Name, variable)
+}
+
+func (i *indexer) closeScope() {
+	i.open = i.open[:len(i.open)-1]
+	i.current = i.current[:len(i.current)-1]
+}
+
+// names the resolver rejects are left without a reference
+func (i *indexer) use(identifier *ast.Identifier) {
+	for s := len(i.open) - 1; s >= 0; s-- {
+		if variable, ok := i.open[s][identifier.Name]; ok {
+			i.refer(identifier.Position, identifier.Name, variable)
+			return
+		}
+	}
+}
+
+func (i *indexer) VisitIntExpression(e *ast.IntExpression)       {}
+func (i *indexer) VisitFloatExpression(e *ast.FloatExpression)   {}
+func (i *indexer) VisitStringExpression(e *ast.StringExpression) {}
+func (i *indexer) VisitBoolExpression(e *ast.BoolExpression)     {}
+
+func (i *indexer) VisitIdentifier(e *ast.Identifier) {
+	i.use(e)
+}
+
+// calls of unknown functions fail only when they run, the editor shows them before
+func (i *indexer) VisitFunctionCall(fc *ast.FunctionCall) {
+	if function, ok := i.functions[fc.Name]; ok {
+		i.refer(fc.Position, fc.Name, function)
+	} else {
+		err := interpreter.NewSemanticErrorWithCode(interpreter.ERR_UNDEFINED_FUNCTION, fc.Position, fc.Name)
+		d := err.Diagnostic()
+		d.Length = len([]rune(fc.Name))
+		i.problems = append(i.problems, d)
+	}
+	for _, argument := range fc.Arguments {
+		argument.Accept(i)
+	}
+}
+
+func (i *indexer) VisitVariable(variable *ast.Variable) {
+	variable.Value.Accept(i)
+	i.declare(variable)
+}
+
+func (i *indexer) VisitAssignement(assignment *ast.Assignment) {
+	assignment.Value.Accept(i)
+	i.use(assignment.Identifier)
+}
+
+func (i *indexer) VisitNegateExpression(e *ast.NegateExpression) {
+	e.Expression.Accept(i)
+}
+
+func (i *indexer) VisitCastExpression(e *ast.CastExpression) {
+	e.LeftExpression.Accept(i)
+}
+
+func (i *indexer) binary(left, right ast.Expression) {
+	left.Accept(i)
+	right.Accept(i)
+}
+
+func (i *indexer) VisitMultiplyExpression(e *ast.MultiplyExpression) {
+	i.binary(e.LeftExpression, e.RightExpression)
+}
+
+func (i *indexer) VisitDivideExpression(e *ast.DivideExpression) {
+	i.binary(e.LeftExpression, e.RightExpression)
+}
+
+func (i *indexer) VisitSumExpression(e *ast.SumExpression) {
+	i.binary(e.LeftExpression, e.RightExpression)
+}
+
+func (i *indexer) VisitSubstractExpression(e *ast.SubstractExpression) {
+	i.binary(e.LeftExpression, e.RightExpression)
+}
+
+func (i *indexer) VisitEqualsExpression(e *ast.EqualsExpression) {
+	i.binary(e.LeftExpression, e.RightExpression)
+}
+
+func (i *indexer) VisitNotEqualsExpression(e *ast.NotEqualsExpression) {
+	i.binary(e.LeftExpression, e.RightExpression)
+}
+
+func (i *indexer) VisitGreaterThanExpression(e *ast.GreaterThanExpression) {
+	i.binary(e.LeftExpression, e.RightExpression)
+}
+
+func (i *indexer) VisitLessThanExpression(e *ast.LessThanExpression) {
+	i.binary(e.LeftExpression, e.RightExpression)
+}
+
+func (i *indexer) VisitGreaterOrEqualExpression(e *ast.GreaterOrEqualExpression) {
+	i.binary(e.LeftExpression, e.RightExpression)
+}
+
+func (i *indexer) VisitLessOrEqualExpression(e *ast.LessOrEqualExpression) {
+	i.binary(e.LeftExpression, e.RightExpression)
+}
+
+func (i *indexer) VisitAndExpression(e *ast.AndExpression) {
+	i.binary(e.LeftExpression, e.RightExpression)
+}
+
+func (i *indexer) VisitOrExpression(e *ast.OrExpression) {
+	i.binary(e.LeftExpression, e.RightExpression)
+}
+
+// every block is a scope of its own, a function body also holds the parameters
+func (i *indexer) VisitBlock(block *ast.Block) {
+	i.openScope(i.nextBraces())
+	parameters := i.parameters
+	i.parameters = nil
+	for _, parameter := range parameters {
+		i.declare(parameter)
+	}
+	for _, statement := range block.Statements {
+		statement.Accept(i)
+	}
+	i.closeScope()
+}
+
+func (i *indexer) VisitIfStatement(ifStmt *ast.IfStatement) {
+	ifStmt.Condition.Accept(i)
+	ifStmt.InstructionsBlock.Accept(i)
+	if ifStmt.ElseInstructionsBlock != nil {
+		ifStmt.ElseInstructionsBlock.Accept(i)
+	}
+}
+
+func (i *indexer) VisitReturnStatement(returnStmt *ast.ReturnStatement) {
+	if returnStmt.Value != nil {
+		returnStmt.Value.Accept(i)
+	}
+}
+
+func (i *indexer) VisitWhileStatement(whileStmt *ast.WhileStatement) {
+	whileStmt.Condition.Accept(i)
+	whileStmt.InstructionsBlock.Accept(i)
+}
+
+// variables of the switch are visible in all of its cases
+func (i *indexer) VisitSwitchStatement(s *ast.SwitchStatement) {
+	b := i.nextBraces()
+	i.openScope(braces{open: s.Position, close: b.close})
+	for _, variable := range s.Variables {
+		variable.Accept(i)
+	}
+	for _, c := range s.Cases {
+		c.Accept(i)
+	}
+	i.closeScope()
+}
+
+func (i *indexer) VisitSwitchCase(sc *ast.SwitchCase) {
+	sc.Condition.Accept(i)
+	sc.OutputExpression.Accept(i)
+}
+
+func (i *indexer) VisitDefaultSwitchCase(dsc *ast.DefaultSwitchCase) {
+	dsc.OutputExpression.Accept(i)
+}
+
+func (i *indexer) VisitFunctionDefinition(fd *ast.FunctionDefinition) {
+	i.refer(fd.Position, fd.Name, fd)
+	i.ends[fd] = i.braces[0].close
+	i.parameters = fd.Parameters
+	fd.Block.Accept(i)
+}
+
+func (i *indexer) VisitProgram(program *ast.Program) {
+	for _, fd := range program.Functions {
+		fd.Accept(i)
+	}
+}
+
+func (i *indexer) VisitEmbeddedFunction(ef *ast.EmbeddedFunction) {}
@@ -0,0 +1,293 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"tkom/ast"
+	"tkom/diagnostics"
+)
+
+const SOURCE_NAME = "flux"
+
+// the client asked to exit without shutting the server down first
+var ErrExitWithoutShutdown = errors.New("exit without shutdown")
+
+// language server of one client talking over a pair of streams, requests are handled one by one
+type Server struct {
+	in        *bufio.Reader
+	out       io.Writer
+	documents map[string]*Document
+	shutdown  bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: map[string]*Document{},
+	}
+}
+
+type handler func(s *Server, params json.RawMessage) (any, error)
+
+// requests are answered, notifications of unknown methods are ignored
+var requests = map[string]handler{
+	"initialize":                  (*Server).initialize,
+	"shutdown":                    (*Server).shutdownRequest,
+	"textDocument/definition":     (*Server).definition,
+	"textDocument/hover":          (*Server).hover,
+	"textDocument/completion":     (*Server).completion,
+	"textDocument/documentSymbol": (*Server).documentSymbol,
+}
+
+var notifications = map[string]handler{
+	"textDocument/didOpen":   (*Server).didOpen,
+	"textDocument/didChange": (*Server).didChange,
+	"textDocument/didClose":  (*Server).didClose,
+}
+
+// serves the client until it sends exit or closes the stream
+func (s *Server) Serve() error {
+	for {
+		message, err := ReadMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		var responseError *ResponseError
+		if errors.As(err, &responseError) {
+			s.respond(nil, nil, responseError)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if message.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+		if message.ID == nil {
+			if handle, ok := notifications[message.Method]; ok && !s.shutdown {
+				handle(s, message.Params)
+			}
+			continue
+		}
+
+		handle, ok := requests[message.Method]
+		switch {
+		case s.shutdown:
+			s.respond(message.ID, nil, &ResponseError{Code: INVALID_REQUEST, Message: "server is shut down"})
+		case !ok:
+			s.respond(message.ID, nil, &ResponseError{Code: METHOD_NOT_FOUND, Message: "method not found: " + message.Method})
+		default:
+			result, err := handle(s, message.Params)
+			if err != nil {
+				s.respond(message.ID, nil, &ResponseError{Code: INVALID_PARAMS, Message: err.Error()})
+			} else {
+				s.respond(message.ID, result, nil)
+			}
+		}
+	}
+}
+
+func (s *Server) respond(id *json.RawMessage, result any, responseError *ResponseError) {
+	message := &Message{ID: id, Error: responseError}
+	if id == nil {
+		null := json.RawMessage("null")
+		message.ID = &null
+	}
+	if responseError == nil {
+		body, _ := json.Marshal(result)
+		raw := json.RawMessage(body)
+		message.Result = &raw
+	}
+	WriteMessage(s.out, message)
+}
+
+func (s *Server) notify(method string, params any) {
+	body, _ := json.Marshal(params)
+	WriteMessage(s.out, &Message{Method: method, Params: body})
+}
+
+func (s *Server) initialize(params json.RawMessage) (any, error) {
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:       TEXT_DOCUMENT_SYNC_FULL,
+			DefinitionProvider:     true,
+			HoverProvider:          true,
+			CompletionProvider:     CompletionOptions{},
+			DocumentSymbolProvider: true,
+		},
+		ServerInfo: ServerInfo{Name: SOURCE_NAME},
+	}, nil
+}
+
+func (s *Server) shutdownRequest(params json.RawMessage) (any, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) open(uri, text string) {
+	document := NewDocument(uri, text, s.documents[uri])
+	s.documents[uri] = document
+	s.publish(document)
+}
+
+func (s *Server) didOpen(params json.RawMessage) (any, error) {
+	var p DidOpenTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	s.open(p.TextDocument.URI, p.TextDocument.Text)
+	return nil, nil
+}
+
+func (s *Server) didChange(params json.RawMessage) (any, error) {
+	var p DidChangeTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if len(p.ContentChanges) > 0 {
+		s.open(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+	}
+	return nil, nil
+}
+
+// diagnostics of a closed document are cleared
+func (s *Server) didClose(params json.RawMessage) (any, error) {
+	var p DidCloseTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.documents, p.TextDocument.URI)
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	return nil, nil
+}
+
+var severities = map[diagnostics.Severity]int{
+	diagnostics.ERROR:   SEVERITY_ERROR,
+	diagnostics.WARNING: SEVERITY_WARNING,
+	diagnostics.NOTE:    SEVERITY_INFORMATION,
+}
+
+func (s *Server) publish(document *Document) {
+	published := []Diagnostic{}
+	for _, d := range document.Diagnostics {
+		diagnostic := Diagnostic{
+			Range:    Range{Start: document.protocolPosition(d.Start()), End: document.protocolPosition(d.End())},
+			Severity: severities[d.Severity],
+			Code:     d.Code,
+			Source:   SOURCE_NAME,
+			Message:  d.Message,
+		}
+		for _, related := range d.Related {
+			diagnostic.RelatedInformation = append(diagnostic.RelatedInformation, DiagnosticRelatedInformation{
+				Location: Location{URI: document.URI, Range: document.protocolRange(related.Position, 1)},
+				Message:  related.Message,
+			})
+		}
+		published = append(published, diagnostic)
+	}
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: document.URI, Diagnostics: published})
+}
+
+func (s *Server) reference(params json.RawMessage) (*Document, *reference, error) {
+	var p TextDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, nil, err
+	}
+	document, ok := s.documents[p.TextDocument.URI]
+	if !ok {
+		return nil, nil, nil
+	}
+	return document, document.referenceAt(p.Position), nil
+}
+
+// builtins have no definition in the source
+func (s *Server) definition(params json.RawMessage) (any, error) {
+	document, r, err := s.reference(params)
+	if r == nil {
+		return nil, err
+	}
+	switch definition := r.definition.(type) {
+	case *ast.Variable:
+		return Location{URI: document.URI, Range: document.protocolRange(definition.Position, len([]rune(definition.Name)))}, nil
+	case *ast.FunctionDefinition:
+		return Location{URI: document.URI, Range: document.protocolRange(definition.Position, len([]rune(definition.Name)))}, nil
+	}
+	return nil, nil
+}
+
+func (s *Server) hover(params json.RawMessage) (any, error) {
+	document, r, err := s.reference(params)
+	if r == nil {
+		return nil, err
+	}
+	var text string
+	switch definition := r.definition.(type) {
+	case *ast.Variable:
+		text = definition.Type.String() + " " + definition.Name
+	case ast.Function:
+		text = ast.Signature(definition)
+	}
+	return Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```flux\n" + text + "\n```"},
+		Range:    document.protocolRange(r.position, r.length),
+	}, nil
+}
+
+// variables visible at the cursor, functions of the program and builtins
+func (s *Server) completion(params json.RawMessage) (any, error) {
+	var p TextDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	items := []CompletionItem{}
+	document, ok := s.documents[p.TextDocument.URI]
+	if !ok || document.index == nil {
+		return items, nil
+	}
+	for name, v := range document.visibleVariables(document.position(p.Position)) {
+		items = append(items, CompletionItem{Label: name, Kind: COMPLETION_VARIABLE, Detail: v.Type.String()})
+	}
+	for name, function := range document.index.functions {
+		items = append(items, CompletionItem{Label: name, Kind: COMPLETION_FUNCTION, Detail: ast.Signature(function)})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Kind > items[j].Kind || items[i].Kind == items[j].Kind && items[i].Label < items[j].Label
+	})
+	return items, nil
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (any, error) {
+	var p DocumentSymbolParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	symbols := []DocumentSymbol{}
+	document, ok := s.documents[p.TextDocument.URI]
+	if !ok || document.Program == nil {
+		return symbols, nil
+	}
+	for _, f := range document.Program.Functions {
+		// the body ends with its closing brace
+		end := document.index.ends[f]
+		symbols = append(symbols, DocumentSymbol{
+			Name:           f.Name,
+			Detail:         ast.Signature(f),
+			Kind:           SYMBOL_FUNCTION,
+			Range:          Range{Start: document.protocolPosition(f.Position), End: document.protocolRange(end, 1).End},
+			SelectionRange: document.protocolRange(f.Position, len([]rune(f.Name))),
+		})
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		a, b := symbols[i].Range.Start, symbols[j].Range.Start
+		return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+	})
+	return symbols, nil
+}
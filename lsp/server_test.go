@@ -0,0 +1,405 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+const URI = "file:///test.fl"
+
+const source = `add(a, b int) int {
+    return a + b
+}
+
+main() {
+    int total := add(1, 2)
+    if total > 2 {
+        string text := total as string
+        print(text)
+    }
+    total = total + 1
+}
+`
+
+// client talking to a server running in the same process
+type client struct {
+	t        *testing.T
+	in       io.WriteCloser
+	messages chan *Message
+	done     chan error
+	id       int
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{t: t, in: clientOut, messages: make(chan *Message, 100), done: make(chan error, 1)}
+
+	go func() {
+		c.done <- NewServer(serverIn, serverOut).Serve()
+		serverOut.Close()
+	}()
+	go func() {
+		reader := bufio.NewReader(clientIn)
+		for {
+			message, err := ReadMessage(reader)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			c.messages <- message
+		}
+	}()
+	t.Cleanup(func() { clientOut.Close() })
+
+	c.call("initialize", map[string]any{"capabilities": map[string]any{}}, nil)
+	c.notify("initialized", map[string]any{})
+	return c
+}
+
+func (c *client) send(message *Message, params any) {
+	c.t.Helper()
+	body, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	message.Params = body
+	if err := WriteMessage(c.in, message); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	c.send(&Message{Method: method}, params)
+}
+
+func (c *client) next() *Message {
+	c.t.Helper()
+	select {
+	case message, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("server closed the connection")
+		}
+		return message
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("no message from the server")
+	}
+	return nil
+}
+
+// sends the request and decodes the result of its response into result
+func (c *client) call(method string, params any, result any) *ResponseError {
+	c.t.Helper()
+	c.id++
+	id := json.RawMessage(strings.TrimSpace(string(mustMarshal(c.t, c.id))))
+	c.send(&Message{ID: &id, Method: method}, params)
+
+	for {
+		message := c.next()
+		if message.ID == nil || string(*message.ID) != string(id) {
+			continue
+		}
+		if message.Error != nil {
+			return message.Error
+		}
+		// a null result is decoded as a missing one
+		if result != nil && message.Result != nil {
+			if err := json.Unmarshal(*message.Result, result); err != nil {
+				c.t.Fatalf("cannot decode the result of %s: %v", method, err)
+			}
+		}
+		return nil
+	}
+}
+
+func mustMarshal(t *testing.T, value any) []byte {
+	body, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// opens or changes the document and waits for its diagnostics
+func (c *client) open(text string) []Diagnostic {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: URI, LanguageID: "flux", Text: text}})
+	return c.diagnostics()
+}
+
+func (c *client) change(text string) []Diagnostic {
+	c.t.Helper()
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: URI},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: text}},
+	})
+	return c.diagnostics()
+}
+
+func (c *client) diagnostics() []Diagnostic {
+	c.t.Helper()
+	message := c.next()
+	if message.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected diagnostics, got %s", message.Method)
+	}
+	var params PublishDiagnosticsParams
+	if err := json.Unmarshal(message.Params, &params); err != nil {
+		c.t.Fatal(err)
+	}
+	return params.Diagnostics
+}
+
+func at(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: URI}, Position: Position{Line: line, Character: character}}
+}
+
+func span(line, start, end int) Range {
+	return Range{Start: Position{Line: line, Character: start}, End: Position{Line: line, Character: end}}
+}
+
+func TestInitialize(t *testing.T) {
+	c := newClient(t)
+	var result InitializeResult
+	if err := c.call("initialize", map[string]any{}, &result); err != nil {
+		t.Fatal(err)
+	}
+	capabilities := result.Capabilities
+	if capabilities.TextDocumentSync != TEXT_DOCUMENT_SYNC_FULL || !capabilities.DefinitionProvider ||
+		!capabilities.HoverProvider || !capabilities.DocumentSymbolProvider {
+		t.Errorf("unexpected capabilities: %+v", capabilities)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+	if diagnostics := c.open(source); len(diagnostics) != 0 {
+		t.Errorf("expected no diagnostics, got %+v", diagnostics)
+	}
+
+	tests := []struct {
+		name     string
+		text     string
+		code     string
+		severity int
+		position Position
+	}{
+		// the range starts with the span, the string from its quote and the declaration from its type
+		{"lexer", "main() {\n    string s := \"abc\n}\n", "E0105", SEVERITY_ERROR, Position{Line: 1, Character: 16}},
+		{"parser", "main() {\n    int a := \n}\n", "E0217", SEVERITY_ERROR, Position{Line: 2, Character: 0}},
+		{"resolver", "main() {\n    print(count)\n}\n", "E0301", SEVERITY_ERROR, Position{Line: 1, Character: 10}},
+		{"warning", "main() {\n    int a := 1\n    if a > 0 {\n        int a := 2\n    }\n}\n", "E0332", SEVERITY_WARNING, Position{Line: 3, Character: 8}},
+		{"undefined function", "main() {\n    prnt(1)\n}\n", "E0302", SEVERITY_ERROR, Position{Line: 1, Character: 4}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diagnostics := c.change(test.text)
+			if len(diagnostics) != 1 {
+				t.Fatalf("expected one diagnostic, got %+v", diagnostics)
+			}
+			d := diagnostics[0]
+			if d.Code != test.code || d.Severity != test.severity || d.Range.Start != test.position || d.Source != SOURCE_NAME {
+				t.Errorf("expected %s of severity %d at %+v, got %+v", test.code, test.severity, test.position, d)
+			}
+		})
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: URI}})
+	if diagnostics := c.diagnostics(); len(diagnostics) != 0 {
+		t.Errorf("expected the diagnostics to be cleared, got %+v", diagnostics)
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	c := newClient(t)
+	c.open(source)
+
+	tests := []struct {
+		name     string
+		position TextDocumentPositionParams
+		expected Range
+	}{
+		{"call of a function", at(5, 18), span(0, 0, 3)},
+		{"parameter", at(1, 11), span(0, 4, 5)},
+		{"variable of an outer scope", at(7, 24), span(5, 8, 13)},
+		{"end of a name", at(8, 18), span(7, 15, 19)},
+		{"assigned variable", at(10, 4), span(5, 8, 13)},
+		{"declaration", at(5, 10), span(5, 8, 13)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var location *Location
+			if err := c.call("textDocument/definition", test.position, &location); err != nil {
+				t.Fatal(err)
+			}
+			if location == nil || location.URI != URI || location.Range != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, location)
+			}
+		})
+	}
+
+	for _, position := range []TextDocumentPositionParams{at(8, 8), at(3, 0), at(6, 5)} {
+		var location *Location
+		if err := c.call("textDocument/definition", position, &location); err != nil {
+			t.Fatal(err)
+		}
+		if location != nil {
+			t.Errorf("expected no definition at %+v, got %+v", position.Position, location)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t)
+	c.open(source)
+
+	tests := []struct {
+		position TextDocumentPositionParams
+		expected string
+	}{
+		{at(5, 18), "add(a int, b int) int"},
+		{at(8, 9), "print(...)"},
+		{at(7, 24), "int total"},
+		{at(7, 16), "string text"},
+		{at(1, 15), "int b"},
+	}
+	for _, test := range tests {
+		var hover *Hover
+		if err := c.call("textDocument/hover", test.position, &hover); err != nil {
+			t.Fatal(err)
+		}
+		expected := "```flux\n" + test.expected + "\n```"
+		if hover == nil || hover.Contents.Value != expected || hover.Contents.Kind != "markdown" {
+			t.Errorf("expected hover %q at %+v, got %+v", expected, test.position.Position, hover)
+		}
+	}
+}
+
+func labels(items []CompletionItem, kind int) []string {
+	names := []string{}
+	for _, item := range items {
+		if item.Kind == kind {
+			names = append(names, item.Label)
+		}
+	}
+	return names
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t)
+	c.open(source)
+
+	tests := []struct {
+		name      string
+		position  TextDocumentPositionParams
+		variables []string
+	}{
+		{"parameters", at(1, 4), []string{"a", "b"}},
+		{"before a declaration", at(5, 4), []string{}},
+		{"inner scope", at(8, 8), []string{"text", "total"}},
+		{"after the inner scope", at(10, 4), []string{"total"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var items []CompletionItem
+			if err := c.call("textDocument/completion", test.position, &items); err != nil {
+				t.Fatal(err)
+			}
+			if got := labels(items, COMPLETION_VARIABLE); strings.Join(got, " ") != strings.Join(test.variables, " ") {
+				t.Errorf("expected variables %v, got %v", test.variables, got)
+			}
+			functions := strings.Join(labels(items, COMPLETION_FUNCTION), " ")
+			if functions != "add assert assert_eq main modulo power print println sqrt" {
+				t.Errorf("expected functions of the program and builtins, got %v", functions)
+			}
+		})
+	}
+}
+
+// a document that does not parse keeps the names of its last version
+func TestCompletionWhileTyping(t *testing.T) {
+	c := newClient(t)
+	c.open(source)
+	diagnostics := c.change(strings.Replace(source, "    total = total + 1\n", "    total = \n", 1))
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected a syntax error, got %+v", diagnostics)
+	}
+
+	var items []CompletionItem
+	if err := c.call("textDocument/completion", at(10, 12), &items); err != nil {
+		t.Fatal(err)
+	}
+	if got := labels(items, COMPLETION_VARIABLE); len(got) != 1 || got[0] != "total" {
+		t.Errorf("expected total, got %v", got)
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	c := newClient(t)
+	c.open(source)
+
+	var symbols []DocumentSymbol
+	if err := c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: URI}}, &symbols); err != nil {
+		t.Fatal(err)
+	}
+	expected := []DocumentSymbol{
+		{Name: "add", Detail: "add(a int, b int) int", Kind: SYMBOL_FUNCTION, Range: Range{End: Position{Line: 2, Character: 1}}, SelectionRange: span(0, 0, 3)},
+		{Name: "main", Detail: "main()", Kind: SYMBOL_FUNCTION, Range: Range{Start: Position{Line: 4}, End: Position{Line: 11, Character: 1}}, SelectionRange: span(4, 0, 4)},
+	}
+	if len(symbols) != len(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, symbols)
+	}
+	for i := range expected {
+		if symbols[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], symbols[i])
+		}
+	}
+}
+
+// positions count UTF-16 code units, the emoji takes two of them
+func TestPositionsOfWideCharacters(t *testing.T) {
+	c := newClient(t)
+	c.open("main() {\n    string s := \"😀\" int n := 1\n    print(n)\n}\n")
+
+	var location *Location
+	if err := c.call("textDocument/definition", at(2, 10), &location); err != nil {
+		t.Fatal(err)
+	}
+	if location == nil || location.Range != span(1, 25, 26) {
+		t.Errorf("expected the declaration at 1:25, got %+v", location)
+	}
+}
+
+func TestShutdownAndExit(t *testing.T) {
+	c := newClient(t)
+	if err := c.call("unknown/method", map[string]any{}, nil); err == nil || err.Code != METHOD_NOT_FOUND {
+		t.Errorf("expected method not found, got %v", err)
+	}
+	if err := c.call("shutdown", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.call("textDocument/hover", at(0, 0), nil); err == nil || err.Code != INVALID_REQUEST {
+		t.Errorf("expected requests after shutdown to fail, got %v", err)
+	}
+	c.notify("exit", nil)
+	select {
+	case err := <-c.done:
+		if err != nil {
+			t.Errorf("expected a clean exit, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not exit")
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	c := newClient(t)
+	c.notify("exit", nil)
+	if err := <-c.done; err != ErrExitWithoutShutdown {
+		t.Errorf("expected %v, got %v", ErrExitWithoutShutdown, err)
+	}
+}
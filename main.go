@@ -1,17 +1,30 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io"
 	"math"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
 	"tkom/ast"
+	"tkom/astdump"
+	"tkom/dap"
+	"tkom/debugger"
+	"tkom/diagnostics"
+	"tkom/formatter"
 	"tkom/interpreter"
 	"tkom/lexer"
+	"tkom/lsp"
 	"tkom/parser"
+	"tkom/repl"
+	"tkom/testrunner"
+	"tkom/transpiler"
 )
 
 const (
@@ -21,46 +34,224 @@ const (
 	MAX_RECURSION_DEPTH = 200
 )
 
+var diagnosticsFormat = flag.String("diagnostics", "text", "format of reported errors: text or json")
+var engineName = flag.String("engine", interpreter.ENGINE_INTERPRETER, "engine running the program: interpreter or vm")
+var maxSteps = flag.Int("max-steps", 0, "stop the program after this many loop iterations and function calls, 0 for no limit")
+var timeout = flag.Duration("timeout", 0, "stop the program when it runs longer, e.g. 500ms or 2s, 0 for no limit")
+var maxStringSize = flag.Int("max-string-size", 0, "largest string in bytes the program can create, 0 for no limit")
+var maxStringBytes = flag.Int("max-string-bytes", 0, "bytes of all strings the program can create, 0 for no limit")
+var maxScopes = flag.Int("max-scopes", 0, "scopes of functions and statements open at once, 0 for no limit")
+var maxCallDepth = flag.Int("max-call-depth", 0, "calls of all functions running at once, 0 for no limit")
+var profile = flag.Bool("profile", false, "print the calls and time of every function and the hits of every line to standard error after the run")
+var profileOutput = flag.String("profile-output", "", "write the profile in the pprof format to the file, implies --profile")
+var coverage = flag.Bool("coverage", false, "print the statements run and the branches taken to standard error after the run")
+var coverageLcov = flag.String("coverage-lcov", "", "write the coverage in the LCOV format to the file, implies --coverage")
+var coverageHTML = flag.String("coverage-html", "", "write the source annotated with its coverage to an HTML file in the directory, implies --coverage")
+
+// every error is reported through the emitter chosen with the --diagnostics flag
+var emitter diagnostics.Emitter
+
+// subcommands are recognised by the first argument, everything else runs a program
+var commands = map[string]func(args []string) int{
+	"explain": explainCommand,
+	"build":   buildCommand,
+	"repl":    replCommand,
+	"fmt":     fmtCommand,
+	"lsp":     lspCommand,
+	"dap":     dapCommand,
+	"debug":   debugCommand,
+	"test":    testCommand,
+	"ast":     astCommand,
+	"tokens":  tokensCommand,
+}
+
 func main() {
+	// flux run is the same as flux
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: flux [run] [--diagnostics=text|json] [--engine=interpreter|vm] [--max-steps=n] [--timeout=duration] [--max-string-size=n] [--max-string-bytes=n] [--max-scopes=n] [--max-call-depth=n] [--profile] [--profile-output=file] [--coverage] [--coverage-lcov=file] [--coverage-html=dir] <file.fl | file.json | -> [arguments...]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       flux explain [code]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       flux build --emit=go|c [-o output] <file.fl>\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       flux repl [--history=file]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       flux fmt [--check | --write] [files...]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       flux lsp\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       flux dap\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       flux debug <file.fl> [arguments...]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       flux test [--run=regexp] [-v] [--timeout=duration] [files or directories...]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       flux ast [--format=tree|json] <file.fl | ->\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       flux tokens [--format=text|json] <file.fl | ->\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var err error
+	emitter, err = diagnostics.NewEmitter(*diagnosticsFormat, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	engine, err := interpreter.NewEngine(*engineName, MAX_RECURSION_DEPTH)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	if *maxSteps > 0 || *timeout > 0 {
+		ctx := context.Background()
+		if *timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, *timeout)
+			defer cancel()
+		}
+		engine.SetBudget(interpreter.NewBudget(ctx, *maxSteps))
+	}
+	engine.SetLimits(&interpreter.Limits{
+		MaxStringSize:  *maxStringSize,
+		MaxStringBytes: *maxStringBytes,
+		MaxScopes:      *maxScopes,
+		MaxCallDepth:   *maxCallDepth,
+	})
+
+	measureCoverage := *coverage || *coverageLcov != "" || *coverageHTML != ""
+	var visitor *interpreter.CodeVisitor
+	if *profile || *profileOutput != "" || measureCoverage {
+		var ok bool
+		visitor, ok = engine.(*interpreter.CodeVisitor)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: profiling and coverage need the %s engine\n", interpreter.ENGINE_INTERPRETER)
+			os.Exit(2)
+		}
+	}
+	var profiler *interpreter.Profile
+	if *profile || *profileOutput != "" {
+		profiler = interpreter.NewProfile()
+		visitor.Profile = profiler
+	}
+	var covered *interpreter.Coverage
+
+	var source *diagnostics.Source
 	defer func() {
 		if r := recover(); r != nil {
-			fmt.Fprintf(os.Stderr, "%v\n", r)
+			reportError(r, source)
+			os.Exit(1)
 		}
 	}()
+	// deferred after the recover, so a program stopped by an error is profiled too
+	defer func() {
+		writeProfile(profiler, source)
+		writeCoverage(covered, source)
+	}()
 
-	if len(os.Args) < 2 {
+	args := flag.Args()
+	if len(args) < 1 {
 		fmt.Println("Missing parameter, provide file name and arguments or use '-' to run from stream")
 		return
 	}
 
+	// programs written by flux ast --format=json run without their source
 	var program *ast.Program
-	var err error
-
-	if len(os.Args) >= 2 && os.Args[1] == "-" {
-		program, err = parseProgramFromStdin()
+	if args[0] == "-" {
+		source, err = readSource(os.Stdin, "<stdin>")
 	} else {
-		fileName := os.Args[1]
+		fileName := args[0]
 		ext := filepath.Ext(fileName)
-		if ext != ".fl" {
-			log.Fatal("File must have '.fl' extension")
+		if ext != ".fl" && ext != ".json" {
+			reportError(errors.New("file must have '.fl' or '.json' extension"), nil)
 			os.Exit(1)
 		}
 
-		program, err = parseProgramFromFile(fileName)
+		if ext == ".json" {
+			program, err = loadProgramFromFile(fileName)
+			source = &diagnostics.Source{Path: fileName}
+		} else {
+			source, err = readSourceFromFile(fileName)
+		}
 	}
 
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		reportError(err, nil)
 		os.Exit(1)
 	}
 
-	visitor := interpreter.NewCodeVisitor(MAX_RECURSION_DEPTH)
-	visitor.MaxRecursionDepth = MAX_RECURSION_DEPTH
-	for _, fd := range program.Functions {
-		visitor.FunctionsMap[fd.Name] = fd
+	if program == nil {
+		program = parseProgram(source)
+	}
+	resolveProgram(program, source)
+	if measureCoverage {
+		covered = interpreter.NewCoverage(program, source.Path)
+		visitor.Coverage = covered
+	}
+
+	engine.Run(program, mainCall(args[1:]))
+}
+
+// prints the profile of the run and writes it to the --profile-output file
+func writeProfile(profiler *interpreter.Profile, source *diagnostics.Source) {
+	if profiler == nil || source == nil {
+		return
+	}
+	profiler.WriteTable(os.Stderr, source.Lines)
+	if *profileOutput == "" {
+		return
+	}
+	err := writeFile(*profileOutput, func(w io.Writer) error {
+		return profiler.WritePprof(w, source.Path)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+}
+
+// prints the coverage of the run and writes it to the --coverage-lcov file
+// and the --coverage-html directory
+func writeCoverage(covered *interpreter.Coverage, source *diagnostics.Source) {
+	if covered == nil {
+		return
+	}
+	covered.WriteSummary(os.Stderr)
+	if *coverageLcov != "" {
+		if err := writeFile(*coverageLcov, covered.WriteLcov); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
 	}
+	if *coverageHTML != "" {
+		err := os.MkdirAll(*coverageHTML, 0755)
+		if err == nil {
+			// the standard input is named <stdin>
+			name := strings.Trim(filepath.Base(source.Path), "<>") + ".html"
+			err = writeFile(filepath.Join(*coverageHTML, name), func(w io.Writer) error {
+				return covered.WriteHTML(w, source.Lines)
+			})
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+}
 
-	arguments := os.Args[2:]
+// creates the file and writes it with write
+func writeFile(fileName string, write func(w io.Writer) error) error {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	err = write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// call of main with the arguments of the command line, numbers are passed as ints
+func mainCall(arguments []string) *ast.FunctionCall {
 	functionCallArgs := make([]ast.Expression, len(arguments))
 	for i, arg := range arguments {
 		if intValue, err := strconv.Atoi(arg); err == nil {
@@ -70,44 +261,501 @@ func main() {
 		}
 	}
 
-	functionCall := &ast.FunctionCall{
+	return &ast.FunctionCall{
 		Name:      "main",
 		Arguments: functionCallArgs,
 	}
-	visitor.VisitFunctionCall(functionCall)
 }
 
-func parseProgramFromFile(fileName string) (*ast.Program, error) {
+func loadProgramFromFile(fileName string) (*ast.Program, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	program, err := astdump.Load(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return program, nil
+}
+
+func readSourceFromFile(fileName string) (*diagnostics.Source, error) {
 	file, err := os.Open(fileName)
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
+
+	return readSource(file, fileName)
+}
+
+func readSource(reader io.Reader, path string) (*diagnostics.Source, error) {
+	text, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return diagnostics.NewSource(path, string(text)), nil
+}
+
+// syntax errors stop the program before it runs, so they end with exit code 1
+func parseProgram(source *diagnostics.Source) *ast.Program {
 	defer func() {
 		if r := recover(); r != nil {
-			file.Close()
-			fmt.Fprintf(os.Stderr, "%v\n", r)
+			reportError(r, source)
 			os.Exit(1)
 		}
 	}()
 
-	reader := bufio.NewReader(file)
-	return parseProgram(reader)
-}
-
-func parseProgramFromStdin() (*ast.Program, error) {
-	reader := bufio.NewReader(os.Stdin)
-	return parseProgram(reader)
-}
-
-func parseProgram(reader *bufio.Reader) (*ast.Program, error) {
-	source, _ := lexer.NewScanner(reader)
-	lex := lexer.NewLexer(source, IDENTIFIERLIMIT, STRING_LIMIT, INT_LIMIT)
+	scanner, _ := lexer.NewScanner(strings.NewReader(source.Text))
+	lex := lexer.NewLexer(scanner, IDENTIFIERLIMIT, STRING_LIMIT, INT_LIMIT)
+	lex.File = source.Path
 	errorHandler := func(err error) {
 		panic(err)
 	}
 	lex.ErrorHandler = errorHandler
 	parser := parser.NewParser(lex, errorHandler)
 
-	program := parser.ParseProgram()
-	return program, nil
+	return parser.ParseProgram()
+}
+
+// binds variables to their scopes before the program runs, undefined and
+// redeclared variables are reported without running it, warnings are only printed
+func resolveProgram(program *ast.Program, source *diagnostics.Source) {
+	defer func() {
+		if r := recover(); r != nil {
+			reportError(r, source)
+			os.Exit(1)
+		}
+	}()
+
+	for _, warning := range interpreter.ResolveProgram(program) {
+		emitter.Emit(warning.Diagnostic(), source)
+	}
+}
+
+func reportError(r any, source *diagnostics.Source) {
+	emitter.Emit(diagnostics.FromPanic(r), source)
+}
+
+// prints the explanation of an error code, without arguments lists all codes
+func explainCommand(args []string) int {
+	if len(args) == 0 {
+		for _, code := range diagnostics.ExplainedCodes() {
+			e, _ := diagnostics.Explain(code)
+			fmt.Printf("%s  %s\n", code, e.Message)
+		}
+		return 0
+	}
+
+	status := 0
+	for i, code := range args {
+		e, ok := diagnostics.Explain(code)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: no explanation for error code: %s\n", code)
+			status = 1
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		e.Write(os.Stdout)
+	}
+	return status
+}
+
+// translates a program to the source of another language instead of running it
+func buildCommand(args []string) int {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	emit := flags.String("emit", transpiler.LANGUAGE_GO, "language of the generated code: go or c")
+	output := flags.String("o", "", "file the generated code is written to, standard output by default")
+	format := flags.String("diagnostics", "text", "format of reported errors: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: flux build --emit=go|c [-o output] <file.fl>\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var err error
+	emitter, err = diagnostics.NewEmitter(*format, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	fileName := flags.Arg(0)
+	generator, err := transpiler.NewGenerator(*emit, fileName, MAX_RECURSION_DEPTH)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	source, err := readSourceFromFile(fileName)
+	if err != nil {
+		reportError(err, nil)
+		return 1
+	}
+	program := parseProgram(source)
+	resolveProgram(program, source)
+
+	code, err := generator.Generate(program)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if *output == "" {
+		os.Stdout.Write(code)
+		return 0
+	}
+	if err := os.WriteFile(*output, code, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runs statements and expressions typed line by line, keeping their variables and functions
+func replCommand(args []string) int {
+	flags := flag.NewFlagSet("repl", flag.ExitOnError)
+	history := flags.String("history", defaultHistoryFile(), "file the typed inputs are kept in, empty to not keep them")
+	format := flags.String("diagnostics", "text", "format of reported errors: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: flux repl [--history=file]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var err error
+	emitter, err = diagnostics.NewEmitter(*format, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	r := repl.NewRepl(os.Stdin, os.Stdout, emitter, MAX_RECURSION_DEPTH)
+	r.HistoryFile = *history
+	if err := r.LoadHistory(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	r.Run()
+	return 0
+}
+
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".flux_history")
+}
+
+// prints programs in the canonical style, without files formats the standard input
+func fmtCommand(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	check := flags.Bool("check", false, "list the files that are not formatted and fail if there are any")
+	write := flags.Bool("write", false, "write the formatted code back to the files")
+	format := flags.String("diagnostics", "text", "format of reported errors: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: flux fmt [--check | --write] [files...]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var err error
+	emitter, err = diagnostics.NewEmitter(*format, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	if *check && *write {
+		flags.Usage()
+		return 2
+	}
+
+	// the standard input has no file to write to, it is printed instead
+	fileNames := flags.Args()
+	if len(fileNames) == 0 {
+		fileNames = []string{"-"}
+	}
+
+	status := 0
+	for _, fileName := range fileNames {
+		var source *diagnostics.Source
+		if fileName == "-" {
+			source, err = readSource(os.Stdin, "<stdin>")
+		} else {
+			source, err = readSourceFromFile(fileName)
+		}
+		if err != nil {
+			reportError(err, nil)
+			status = 1
+			continue
+		}
+
+		formatted, err := formatter.Format([]byte(source.Text))
+		if err != nil {
+			reportError(err, source)
+			status = 1
+			continue
+		}
+		switch {
+		case *check:
+			if string(formatted) != source.Text {
+				fmt.Println(source.Path)
+				status = 1
+			}
+		case *write && fileName != "-":
+			if string(formatted) == source.Text {
+				continue
+			}
+			if err := os.WriteFile(fileName, formatted, 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				status = 1
+			}
+		default:
+			os.Stdout.Write(formatted)
+		}
+	}
+	return status
+}
+
+// serves an editor speaking the language server protocol over the standard streams
+func lspCommand(args []string) int {
+	flags := flag.NewFlagSet("lsp", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: flux lsp\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// serves an editor speaking the debug adapter protocol over the standard streams
+func dapCommand(args []string) int {
+	flags := flag.NewFlagSet("dap", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: flux dap\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if err := dap.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runs the program stopped before its first statement, commands are read from the standard input
+func debugCommand(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	format := flags.String("diagnostics", "text", "format of reported errors: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: flux debug <file.fl> [arguments...]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+
+	var err error
+	emitter, err = diagnostics.NewEmitter(*format, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	source, err := readSourceFromFile(flags.Arg(0))
+	if err != nil {
+		reportError(err, nil)
+		return 1
+	}
+	program := parseProgram(source)
+	resolveProgram(program, source)
+
+	fmt.Println("type help for the list of commands")
+	d := debugger.NewDebugger(program, source, os.Stdin, os.Stdout, emitter, MAX_RECURSION_DEPTH)
+	return d.Run(mainCall(flags.Args()[1:]))
+}
+
+// runs the test_ functions of the files, directories are searched for .fl files,
+// without arguments the current directory is searched
+func testCommand(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	run := flags.String("run", "", "run only the tests whose names match the regular expression")
+	verbose := flags.Bool("v", false, "print every test run and the output of the passing ones")
+	testTimeout := flags.Duration("timeout", 0, "stop every test running longer, e.g. 500ms or 2s, 0 for no limit")
+	format := flags.String("diagnostics", "text", "format of reported errors: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: flux test [--run=regexp] [-v] [--timeout=duration] [files or directories...]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var err error
+	emitter, err = diagnostics.NewEmitter(*format, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	runner := testrunner.NewRunner(os.Stdout, emitter, MAX_RECURSION_DEPTH)
+	runner.Verbose = *verbose
+	runner.Timeout = *testTimeout
+	if *run != "" {
+		runner.Filter, err = regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	fileNames, err := testFiles(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	for _, fileName := range fileNames {
+		source, err := readSourceFromFile(fileName)
+		if err != nil {
+			reportError(err, nil)
+			runner.Broken++
+			continue
+		}
+		runner.RunSource(source)
+	}
+	runner.WriteSummary()
+	if runner.Failed > 0 || runner.Broken > 0 {
+		return 1
+	}
+	return 0
+}
+
+// the files and the .fl files of the directories, in the order of the arguments
+func testFiles(paths []string) ([]string, error) {
+	fileNames := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			fileNames = append(fileNames, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.fl"))
+		if err != nil {
+			return nil, err
+		}
+		fileNames = append(fileNames, matches...)
+	}
+	return fileNames, nil
+}
+
+// prints the syntax tree of the program as an indented tree or as JSON,
+// which flux runs like the source
+func astCommand(args []string) int {
+	flags := flag.NewFlagSet("ast", flag.ExitOnError)
+	format := flags.String("format", "tree", "format of the tree: tree or json")
+	diagnosticsFormat := flags.String("diagnostics", "text", "format of reported errors: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: flux ast [--format=tree|json] <file.fl | ->\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 || (*format != "tree" && *format != "json") {
+		flags.Usage()
+		return 2
+	}
+
+	var err error
+	emitter, err = diagnostics.NewEmitter(*diagnosticsFormat, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	var source *diagnostics.Source
+	if flags.Arg(0) == "-" {
+		source, err = readSource(os.Stdin, "<stdin>")
+	} else {
+		source, err = readSourceFromFile(flags.Arg(0))
+	}
+	if err != nil {
+		reportError(err, nil)
+		return 1
+	}
+
+	program := parseProgram(source)
+	if *format == "json" {
+		err = astdump.WriteJSON(os.Stdout, program)
+	} else {
+		err = astdump.WriteTree(os.Stdout, program)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// prints the tokens of the source with their positions, to find what the lexer makes of it
+func tokensCommand(args []string) int {
+	flags := flag.NewFlagSet("tokens", flag.ExitOnError)
+	format := flags.String("format", "text", "format of the tokens: text or json")
+	diagnosticsFormat := flags.String("diagnostics", "text", "format of reported errors: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: flux tokens [--format=text|json] <file.fl | ->\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 || (*format != "text" && *format != "json") {
+		flags.Usage()
+		return 2
+	}
+
+	var err error
+	emitter, err = diagnostics.NewEmitter(*diagnosticsFormat, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	var source *diagnostics.Source
+	if flags.Arg(0) == "-" {
+		source, err = readSource(os.Stdin, "<stdin>")
+	} else {
+		source, err = readSourceFromFile(flags.Arg(0))
+	}
+	if err != nil {
+		reportError(err, nil)
+		return 1
+	}
+
+	status := 0
+	scanner, _ := lexer.NewScanner(strings.NewReader(source.Text))
+	lex := lexer.NewLexer(scanner, IDENTIFIERLIMIT, STRING_LIMIT, INT_LIMIT)
+	// the tokens read before an error are printed, the lexer ends with ETX after it
+	lex.ErrorHandler = func(err error) {
+		reportError(err, source)
+		status = 1
+	}
+	if err := lexer.WriteTokens(os.Stdout, lex, *format == "json"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return status
 }
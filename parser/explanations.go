@@ -0,0 +1,154 @@
+package parser
+
+import "tkom/diagnostics"
+
+type explanation struct {
+	text    string
+	example string
+}
+
+// codes without an example are kept for compatibility, the parser
+// currently reports these situations with a more specific code
+var explanations = map[ErrorCode]explanation{
+	SYNTAX_ERROR_FUNC_DEF_NO_PARENTHASIS: {
+		text:    "A function definition needs a parameter list in parentheses right after its name,\neven when the function takes no parameters.",
+		example: "main {\n    print(\"hello\")\n}",
+	},
+	SYNTAX_ERROR_FUNCTION_REDEFINITION: {
+		text:    "Two functions with the same name were defined in one program.\nFunctions cannot be overloaded, rename one of them.",
+		example: "add(a, b int) int {\n    return a + b\n}\n\nadd(a, b float) float {\n    return a + b\n}",
+	},
+	SYNTAX_ERROR_NO_BLOCK: {
+		text: "A statement that requires a block in curly braces was not followed by one.",
+	},
+	SYNTAX_ERROR_NO_IDENTIFIER: {
+		text:    "A parameter name was expected in the parameter list of a function definition.",
+		example: "add(a, int) int {\n    return a\n}",
+	},
+	SYNTAX_ERROR_NO_VARIABLE_IDETIFIER: {
+		text:    "A variable declaration starts with a type, which must be followed by the name of the variable.",
+		example: "main() {\n    int := 5\n}",
+	},
+	SYNTAX_ERROR_NO_TYPE: {
+		text:    "Every group of parameters must end with its type, e.g. 'a, b int'.",
+		example: "add(a, b) int {\n    return a + b\n}",
+	},
+	SYNTAX_ERROR_NO_PARAMETERS_AFTER_COMMA: {
+		text:    "A comma in a parameter list must be followed by another parameter.",
+		example: "add(a int,) int {\n    return a\n}",
+	},
+	SYNTAX_ERROR_NO_TYPE_IN_CAST: {
+		text:    "The 'as' operator must be followed by one of the types: int, float, bool or string.",
+		example: "main() {\n    string s := 5 as\n}",
+	},
+	ERROR_NO_ETX_TOKEN: {
+		text:    "The parser finished reading function definitions but the source did not end.\nOnly function definitions are allowed at the top level of a program.",
+		example: "main() {\n}\n}",
+	},
+	SYNTAX_ERROR_EXPECTED_RIGHT_BRACE: {
+		text:    "A block was opened with '{' but never closed with '}'.",
+		example: "main() {\n    print(\"hello\")",
+	},
+	SYNTAX_ERROR_UNKNOWN_STATEMENT: {
+		text: "The tokens at this place do not form any statement of the language.",
+	},
+	SYNTAX_ERROR_MISSING_COLON_ASSIGN: {
+		text:    "Variables are declared with ':=', plain '=' is only used to assign to existing variables.",
+		example: "main() {\n    int a = 5\n}",
+	},
+	SYNTAX_ERROR_FUNC_CALL_NOT_CLOSED: {
+		text:    "The list of arguments of a function call was not closed with ')'.",
+		example: "main() {\n    print(\"hello\"\n}",
+	},
+	ERROR_ASIGNMENT_TO_FUNCTION_CALL: {
+		text: "The result of a function call is not a variable and cannot be assigned to.",
+	},
+	ERROR_MISSING_EXPRESSION: {
+		text:    "An operator or keyword requires an expression after it, but none was found.",
+		example: "main() {\n    int a := 5\n    a = \n}",
+	},
+	SYNTAX_ERROR_NO_TERM: {
+		text:    "An expression was expected here, e.g. a literal, a variable or a function call.",
+		example: "main() {\n    int a := -\n}",
+	},
+	SYNTAX_ERROR_NO_EXPRESSION_IN_VARIABLE_DECLARATION: {
+		text:    "Every variable has to be initialized with a value when it is declared.",
+		example: "main() {\n    int a :=\n}",
+	},
+	SYNTAX_ERROR_NO_TYPE_IN_DECLARATION: {
+		text: "A variable declaration has to start with the type of the variable.",
+	},
+	SYNTAX_ERROR_NO_RIGHT_PARENTHESIS_IN_NESTED_EXPRESSION: {
+		text:    "A parenthesised expression was opened with '(' but not closed with ')'.",
+		example: "main() {\n    int a := (1 + 2 * 3\n}",
+	},
+	SYNTAX_ERROR_NO_RETURN: {
+		text: "A return statement was expected here.",
+	},
+	SYNTAX_ERROR_NO_LEFT_CURLY_BRACKET_IN_SWITCH: {
+		text:    "The cases of a switch statement have to be placed in curly braces.",
+		example: "main() {\n    int a := 1\n    switch a\n}",
+	},
+	SYNTAX_ERROR_NO_ARROW: {
+		text:    "Every switch case needs '=>' between its condition and its result.\nThe error is also reported when the last case is followed by a comma.",
+		example: "main() {\n    int a := 1\n    switch {\n        a == 1 print(\"one\")\n    }\n}",
+	},
+	SYNTAX_ERROR_NOT_CLOSED_SWITCH: {
+		text:    "A switch statement was not closed with '}', or two of its cases are not separated with a comma.",
+		example: "main() {\n    int a := 1\n    switch {\n        a == 1 => print(\"one\")\n        a == 2 => print(\"two\")\n    }\n}",
+	},
+	SYNTAX_ERROR_NO_SWITCH_CASES: {
+		text: "A switch statement must contain at least one case.",
+	},
+	ERROR_MISSING_SWITCH_CASE: {
+		text:    "A switch case must start with a condition or the 'default' keyword.",
+		example: "main() {\n    int a := 1\n    switch {\n        => print(\"one\")\n    }\n}",
+	},
+	SYNTAX_ERROR_UNDEFIND_RELATION_FOR_SWITCH_CASE: {
+		text: "The relation used in a switch case is not a valid relational operator.",
+	},
+	SYNTA_ERROR_NO_RELATION_FOR_SWITCH_CASE: {
+		text: "A switch case condition must compare values with a relational operator.",
+	},
+	SYNTAX_ERROR_NOT_VALID_TYPE_IN_FUNC: {
+		text: "The type used in the function definition is not one of int, float, bool or string.",
+	},
+	SYNTA_ERROR_NO_BLOCK_DEFINED: {
+		text:    "A function definition must be followed by its body in curly braces.",
+		example: "main()",
+	},
+	SYNTAX_ERROR_NO_VARIABLE_AFTER_COMMA: {
+		text:    "Variables declared in the head of a switch statement are separated with commas,\neach comma must be followed by another declaration.",
+		example: "main() {\n    switch int a := 1, {\n        a == 1 => print(\"one\")\n    }\n}",
+	},
+	SYNTAX_ERROR_BAD_VARIABLE_DECLARATION: {
+		text: "A declaration in the head of a switch statement is malformed.",
+	},
+	SYNTAX_ERROR_EMPTY_BLOCK_IN_IF_STATEMENT: {
+		text:    "The condition of an if statement, and the 'else' keyword, must be followed by a block in curly braces.",
+		example: "main() {\n    if true print(\"yes\")\n}",
+	},
+	SYNTAX_ERROR_EMPTY_BLOCK_IN_WHILE_STATEMENT: {
+		text:    "The condition of a while loop must be followed by a block in curly braces.",
+		example: "main() {\n    while true print(\"again\")\n}",
+	},
+	INVALID_TOKEN: {
+		text:    "The lexer found a character that is not part of the language.",
+		example: "main() {\n    int a := 1 $ 2\n}",
+	},
+	SYNTAX_ERROR_NO_SWITCH_CASE_OUTPUT: {
+		text:    "The arrow of a switch case must be followed by the outcome of the case,\nan expression or a block in curly braces.",
+		example: "main() {\n    switch {\n        true =>\n    }\n}",
+	},
+}
+
+func init() {
+	for code, e := range explanations {
+		diagnostics.RegisterExplanation(&diagnostics.Explanation{
+			Code:    code.String(),
+			Message: errorMessage[code],
+			Text:    e.text,
+			Example: e.example,
+		})
+	}
+}
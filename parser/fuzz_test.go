@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tkom/diagnostics"
+	"tkom/lexer"
+)
+
+// the parser reports malformed programs with errors of the language,
+// never with runtime errors of Go
+func FuzzParseProgram(f *testing.F) {
+	files, err := filepath.Glob("../example_codes/*.fl")
+	if err != nil || len(files) == 0 {
+		f.Fatalf("no example codes found: %v", err)
+	}
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(source))
+	}
+	for _, source := range []string{"", "main(", "main() { int }", "main() { switch { default => 1, default => 2 } }", "a() {} a() {}", "main() { x = $ }"} {
+		f.Add(source)
+	}
+
+	f.Fuzz(func(t *testing.T, source string) {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(diagnostics.Diagnosable); !ok {
+					t.Fatalf("unexpected panic %T: %v", r, r)
+				}
+			}
+		}()
+		scanner, _ := lexer.NewScanner(strings.NewReader(source))
+		lex := lexer.NewLexer(scanner, 500, 1000, math.MaxInt)
+		errorHandler := func(err error) {
+			panic(err)
+		}
+		lex.ErrorHandler = errorHandler
+		NewParser(lex, errorHandler).ParseProgram()
+	})
+}
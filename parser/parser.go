@@ -1,8 +1,8 @@
 package parser
 
 import (
-	"fmt"
 	. "tkom/ast"
+	"tkom/diagnostics"
 	lex "tkom/lexer"
 	"tkom/shared"
 )
@@ -17,6 +17,10 @@ type Parser struct {
 	lexer        *lex.Lexer
 	ErrorHandler func(error)
 	token        lex.Token
+	// last consumed token, nodes end where it ends
+	previous lex.Token
+	// comments are skipped by the grammar, but kept for the program
+	comments []*Comment
 }
 
 func NewParser(lexer *lex.Lexer, errHandler func(error)) *Parser {
@@ -29,47 +33,94 @@ func NewParser(lexer *lex.Lexer, errHandler func(error)) *Parser {
 }
 
 func (p *Parser) consumeToken() {
+	p.previous = p.token
 	for {
         token := p.lexer.GetNextToken()
         if token.Type == lex.UNDEFINED {
-            panic(NewParserError(fmt.Sprintf(INVALID_TOKEN, token.Position.Line, token.Position.Column, string(token.Value.(rune)))))
+            panic(NewParserErrorAt(INVALID_TOKEN, token.Span, string(token.Value.(rune))))
         }
 		p.token = *token
 		if p.token.Type != lex.COMMENT {
 			break
-		} 
+		}
+		comment := NewComment(p.token.Value.(string), p.token.Position)
+		comment.Span = p.token.Span
+		p.comments = append(p.comments, comment)
 	}
 }
 
-func (p *Parser) requierAndConsume(tokenType lex.TokenType, syntaxErrMessage string) lex.Token {
+func (p *Parser) requierAndConsume(tokenType lex.TokenType, errorCode ErrorCode) lex.Token {
 	token := p.token
 	if token.Type != tokenType {
-		panic(NewParserError(fmt.Sprintf(syntaxErrMessage, token.Position.Line, token.Position.Column)))
+		panic(NewParserErrorAt(errorCode, token.Span))
 	}
 	p.consumeToken()
 	return token
 }
 
+// span from the start of the first token of a node to the end of the last consumed one
+func (p *Parser) spanFrom(start shared.Span) shared.Span {
+	return start.To(p.previous.Span)
+}
+
 // program = { function_definition } ;
 func (p *Parser) ParseProgram() *Program {
 	defer p.recoverFromPanic()
 
+	// comments before the first function are part of the program
+	start := p.token.Span
+	if len(p.comments) > 0 {
+		start = p.comments[0].Span
+	}
 	functions := map[string]*FunctionDefinition{}
 
 	for funDef := p.parseFunDef(); funDef != nil; funDef = p.parseFunDef() {
 		if f, ok := functions[funDef.Name]; ok {
-			tokenCol := p.token.Position.Column
-			tokenLine := p.token.Position.Line
-			panic(NewParserError(fmt.Sprintf(SYNTAX_ERROR_FUNCTION_REDEFINITION, tokenCol, tokenLine, f.Position.Line, f.Position.Column)))
+			err := NewParserError(SYNTAX_ERROR_FUNCTION_REDEFINITION, funDef.Position, f.Position.Line, f.Position.Column)
+			err.Related = []diagnostics.Related{{Message: "previous definition of " + f.Name, Position: f.Position}}
+			panic(err)
 		} else {
 			functions[funDef.Name] = funDef
 		}
 	}
 
 	if p.token.Type != lex.ETX {
-		panic(NewParserError(fmt.Sprintf(ERROR_NO_ETX_TOKEN, p.token.Position.Line, p.token.Position.Line)))
+		panic(NewParserErrorAt(ERROR_NO_ETX_TOKEN, p.token.Span))
 	}
-	return NewProgram(functions)
+	program := NewProgram(functions)
+	program.Comments = p.comments
+	program.Span = start.To(p.token.Span)
+	return program
+}
+
+// input of the interactive interpreter that is a single expression,
+// nil when the input does not start with one or does not end after it
+//
+// expression_input = expression ;
+func (p *Parser) ParseExpressionInput() Expression {
+	defer p.recoverFromPanic()
+
+	expression := p.parseExpression()
+	if p.token.Type != lex.ETX {
+		return nil
+	}
+	return expression
+}
+
+// input of the interactive interpreter made of statements
+//
+// statements_input = { statement } ;
+func (p *Parser) ParseStatementsInput() []Statement {
+	defer p.recoverFromPanic()
+
+	statements := []Statement{}
+	for statement := p.parseStatement(); statement != nil; statement = p.parseStatement() {
+		statements = append(statements, statement)
+	}
+	if p.token.Type != lex.ETX {
+		panic(NewParserErrorAt(ERROR_NO_ETX_TOKEN, p.token.Span))
+	}
+	return statements
 }
 
 // function_definition = identifier , "(", [ parameters ], ")", [ type_annotation ] , block ;
@@ -79,6 +130,7 @@ func (p *Parser) parseFunDef() *FunctionDefinition {
 	}
 	name := p.token.Value.(string)
 	possition := p.token.Position
+	start := p.token.Span
 
 	p.consumeToken()
 	p.requierAndConsume(lex.LEFT_PARENTHESIS, SYNTAX_ERROR_FUNC_DEF_NO_PARENTHASIS)
@@ -94,10 +146,12 @@ func (p *Parser) parseFunDef() *FunctionDefinition {
 	}
 	block := p.parseBlock()
 	if block == nil {
-		panic(NewParserError(fmt.Sprintf(SYNTA_ERROR_NO_BLOCK_DEFINED, p.token.Position.Line, p.token.Position.Column)))
+		panic(NewParserErrorAt(SYNTA_ERROR_NO_BLOCK_DEFINED, p.token.Span))
 	}
 
-	return NewFunctionDefinition(name, params, funcType, block, possition)
+	funDef := NewFunctionDefinition(name, params, funcType, block, possition)
+	funDef.Span = p.spanFrom(start)
+	return funDef
 }
 
 // parameters = parameter_group , { "," , parameter_group } ;
@@ -113,7 +167,7 @@ func (p *Parser) parseParameters() []*Variable {
 		p.consumeToken()
 		paramGroup := p.parseParameterGroup()
 		if paramGroup == nil {
-			panic(NewParserError(fmt.Sprintf(SYNTAX_ERROR_NO_PARAMETERS_AFTER_COMMA, p.token.Position.Line, p.token.Position.Column)))
+			panic(NewParserErrorAt(SYNTAX_ERROR_NO_PARAMETERS_AFTER_COMMA, p.token.Span))
 		}
 		parameters = append(parameters, paramGroup...)
 	}
@@ -129,34 +183,38 @@ func (p *Parser) parseParameterGroup() []*Variable {
 	type Parameter struct {
 		Name     string
 		Position shared.Position
+		Span     shared.Span
 	}
 
 	name := p.token.Value.(string)
 	possition := p.token.Position
 
 	namesAndPositions := []Parameter{}
-	namesAndPositions = append(namesAndPositions, Parameter{Name: name, Position: possition})
+	namesAndPositions = append(namesAndPositions, Parameter{Name: name, Position: possition, Span: p.token.Span})
 
 	p.consumeToken()
 	for p.token.Type == lex.COMMA {
 		p.consumeToken()
 		if p.token.Type != lex.IDENTIFIER {
-			panic(NewParserError(fmt.Sprintf(SYNTAX_ERROR_NO_IDENTIFIER, p.token.Position.Line, p.token.Position.Column)))
+			panic(NewParserErrorAt(SYNTAX_ERROR_NO_IDENTIFIER, p.token.Span))
 		}
 		name := p.token.Value.(string)
 		possition := p.token.Position
-		namesAndPositions = append(namesAndPositions, Parameter{Name: name, Position: possition})
+		namesAndPositions = append(namesAndPositions, Parameter{Name: name, Position: possition, Span: p.token.Span})
 		p.consumeToken()
 	}
 	paramsType := p.parseTypeAnnotation()
 
 	if paramsType == nil {
-		panic(NewParserError(fmt.Sprintf(SYNTAX_ERROR_NO_TYPE, p.token.Position.Line, p.token.Position.Column)))
+		panic(NewParserErrorAt(SYNTAX_ERROR_NO_TYPE, p.token.Span))
 	}
 	params := []*Variable{}
 
+	// the type is shared by the group, so a parameter spans only its name
 	for _, t := range namesAndPositions {
-		params = append(params, NewVariable(*paramsType, t.Name, nil, t.Position))
+		param := NewVariable(*paramsType, t.Name, nil, t.Position)
+		param.Span = t.Span
+		params = append(params, param)
 	}
 	return params
 }
@@ -178,6 +236,7 @@ func (p *Parser) parseBlock() *Block {
 	if p.token.Type != lex.LEFT_BRACE {
 		return nil
 	}
+	start := p.token.Span
 	p.consumeToken()
 
 	statements := []Statement{}
@@ -187,7 +246,9 @@ func (p *Parser) parseBlock() *Block {
 	}
 	p.requierAndConsume(lex.RIGHT_BRACE, SYNTAX_ERROR_EXPECTED_RIGHT_BRACE)
 
-	return NewBlock(statements)
+	block := NewBlock(statements)
+	block.Span = p.spanFrom(start)
+	return block
 }
 
 // statement = variable_declaration | assigment | conditional_statement | loop_statement | switch_statement | return_statement ;
@@ -218,6 +279,7 @@ func (p *Parser) parseStatement() Statement {
 
 // variable_declaration  = type_annotation, identifier, ":=", expression ;
 func (p *Parser) parseVariableDeclaration() *Variable {
+	start := p.token.Span
 	typeAnnotation := p.parseTypeAnnotation()
 	if typeAnnotation == nil {
 		return nil
@@ -227,12 +289,13 @@ func (p *Parser) parseVariableDeclaration() *Variable {
 
 	expression := p.parseExpression()
 	if expression == nil {
-		panic(NewParserError(fmt.Sprintf(SYNTAX_ERROR_NO_EXPRESSION_IN_VARIABLE_DECLARATION, p.token.Position.Line, p.token.Position.Column)))
+		panic(NewParserErrorAt(SYNTAX_ERROR_NO_EXPRESSION_IN_VARIABLE_DECLARATION, p.token.Span))
 	}
 
 	name := identifierToken.Value.(string)
 	position := identifierToken.Position
 	variable := NewVariable(*typeAnnotation, name, expression, position)
+	variable.Span = p.spanFrom(start)
 	return variable
 }
 
@@ -244,24 +307,29 @@ func (p *Parser) parseAssignment() Statement {
 
 	name := p.token.Value.(string)
 	position := p.token.Position
+	start := p.token.Span
 	p.consumeToken()
 
-	if functionCall := p.parseFunctionCall(name, position); functionCall != nil {
+	if functionCall := p.parseFunctionCall(name, position, start); functionCall != nil {
 		return functionCall
 	}
 
+	identifier := NewIdentifier(name, position)
+	identifier.Span = start
 	if p.token.Type != lex.ASSIGN {
-		return NewIdentifier(name, position)
+		return identifier
 	}
 
 	p.consumeToken()
 
 	expression := p.parseExpression()
 	if expression == nil {
-		panic(NewParserError(fmt.Sprintf(ERROR_MISSING_EXPRESSION, p.token.Position.Line, p.token.Position.Column, "=")))
+		panic(NewParserErrorAt(ERROR_MISSING_EXPRESSION, p.token.Span, "="))
 	}
 
-	return NewAssignment(NewIdentifier(name, position), expression)
+	assignment := NewAssignment(identifier, expression)
+	assignment.Span = p.spanFrom(start)
+	return assignment
 }
 
 // identifier_or_call = identifier, [ "(", [ argumets ], ")" ] ;
@@ -272,16 +340,19 @@ func (p *Parser) parseIdentifierOrCall() Statement {
 
 	name := p.token.Value.(string)
 	position := p.token.Position
+	start := p.token.Span
 	p.consumeToken()
 
-	if functionCall := p.parseFunctionCall(name, position); functionCall != nil {
+	if functionCall := p.parseFunctionCall(name, position, start); functionCall != nil {
 		return functionCall
 	}
 
-	return NewIdentifier(name, position)
+	identifier := NewIdentifier(name, position)
+	identifier.Span = start
+	return identifier
 }
 
-func (p *Parser) parseFunctionCall(name string, position shared.Position) *FunctionCall {
+func (p *Parser) parseFunctionCall(name string, position shared.Position, start shared.Span) *FunctionCall {
 	if p.token.Type != lex.LEFT_PARENTHESIS {
 		return nil
 	}
@@ -291,7 +362,9 @@ func (p *Parser) parseFunctionCall(name string, position shared.Position) *Funct
 
 	p.requierAndConsume(lex.RIGHT_PARENTHESIS, SYNTAX_ERROR_FUNC_CALL_NOT_CLOSED)
 
-	return NewFunctionCall(name, position, arguments)
+	functionCall := NewFunctionCall(name, position, arguments)
+	functionCall.Span = p.spanFrom(start)
+	return functionCall
 }
 
 // functionCall = identifier, "(", [ argumets ], ")" ;
@@ -302,6 +375,7 @@ func (p *Parser) parseFunctionCallStatement() *FunctionCall {
 
 	name := p.token.Value.(string)
 	position := p.token.Position
+	start := p.token.Span
 	p.consumeToken()
 
 	if p.token.Type != lex.LEFT_PARENTHESIS {
@@ -313,7 +387,9 @@ func (p *Parser) parseFunctionCallStatement() *FunctionCall {
 
 	p.requierAndConsume(lex.RIGHT_PARENTHESIS, SYNTAX_ERROR_FUNC_CALL_NOT_CLOSED)
 
-	return NewFunctionCall(name, position, arguments)
+	functionCall := NewFunctionCall(name, position, arguments)
+	functionCall.Span = p.spanFrom(start)
+	return functionCall
 }
 
 // arguments = expression , { "," , expression } ;
@@ -331,7 +407,7 @@ func (p *Parser) parseArguments() []Expression {
 		p.consumeToken()
 		expression := p.parseExpression()
 		if expression == nil {
-			panic(NewParserError(fmt.Sprintf(ERROR_MISSING_EXPRESSION, p.token.Position.Line, p.token.Position.Column, p.token.Type.TypeName())))
+			panic(NewParserErrorAt(ERROR_MISSING_EXPRESSION, p.token.Span, p.token.Type.TypeName()))
 		}
 		expressions = append(expressions, expression)
 	}
@@ -350,10 +426,12 @@ func (p *Parser) parseExpression() Expression {
 		p.consumeToken()
 		rightExpression := p.parseAndCondition()
 		if rightExpression == nil {
-			panic(NewParserError(fmt.Sprintf(ERROR_MISSING_EXPRESSION, p.token.Position.Line, p.token.Position.Column, "OR")))
+			panic(NewParserErrorAt(ERROR_MISSING_EXPRESSION, p.token.Span, "OR"))
 		}
 
+		span := p.spanFrom(leftExpression.GetSpan())
 		leftExpression = NewOrExpression(leftExpression, rightExpression, position)
+		leftExpression.SetSpan(span)
 	}
 	return leftExpression
 }
@@ -370,10 +448,12 @@ func (p *Parser) parseAndCondition() Expression {
 		p.consumeToken()
 		rightExpression := p.parseRelationCondition()
 		if rightExpression == nil {
-			panic(NewParserError(fmt.Sprintf(ERROR_MISSING_EXPRESSION, p.token.Position.Line, p.token.Position.Column, "AND")))
+			panic(NewParserErrorAt(ERROR_MISSING_EXPRESSION, p.token.Span, "AND"))
 		}
 
+		span := p.spanFrom(leftExpression.GetSpan())
 		leftExpression = NewAndExpression(leftExpression, rightExpression, position)
+		leftExpression.SetSpan(span)
 	}
 
 	return leftExpression
@@ -402,10 +482,12 @@ func (p *Parser) parseRelationCondition() Expression {
 
 		rightExpression := p.parseAdditiveTerm()
 		if rightExpression == nil {
-			panic(NewParserError(fmt.Sprintf(ERROR_MISSING_EXPRESSION, p.token.Position.Line, p.token.Position.Column, operationType)))
+			panic(NewParserErrorAt(ERROR_MISSING_EXPRESSION, p.token.Span, operationType))
 		}
 
+		span := p.spanFrom(leftExpression.GetSpan())
 		leftExpression = factory(leftExpression, rightExpression, position)
+		leftExpression.SetSpan(span)
 	}
 
 	return leftExpression
@@ -429,9 +511,11 @@ func (p *Parser) parseAdditiveTerm() Expression {
 			p.consumeToken()
 			rightExpression := p.parseMultiplicativeTerm()
 			if rightExpression == nil {
-				panic(NewParserError(fmt.Sprintf(ERROR_MISSING_EXPRESSION, p.token.Position.Line, p.token.Position.Column, "additive operator")))
+				panic(NewParserErrorAt(ERROR_MISSING_EXPRESSION, p.token.Span, "additive operator"))
 			}
+			span := p.spanFrom(leftExpression.GetSpan())
 			leftExpression = factory(leftExpression, rightExpression, position)
+			leftExpression.SetSpan(span)
 		} else {
 			return leftExpression
 		}
@@ -456,9 +540,11 @@ func (p *Parser) parseMultiplicativeTerm() Expression {
 			p.consumeToken()
 			rightExpression := p.parseCastedTerm()
 			if rightExpression == nil {
-				panic(NewParserError(fmt.Sprintf(ERROR_MISSING_EXPRESSION, p.token.Position.Line, p.token.Position.Column, "* or /")))
+				panic(NewParserErrorAt(ERROR_MISSING_EXPRESSION, p.token.Span, "* or /"))
 			}
+			span := p.spanFrom(leftExpression.GetSpan())
 			leftExpression = factory(leftExpression, rightExpression, position)
+			leftExpression.SetSpan(span)
 		} else {
 			return leftExpression
 		}
@@ -481,9 +567,11 @@ func (p *Parser) parseCastedTerm() Expression {
 	p.consumeToken()
 	typeAnnotation := p.parseTypeAnnotation()
 	if typeAnnotation == nil {
-		panic(NewParserError(fmt.Sprintf(SYNTAX_ERROR_NO_TYPE_IN_CAST, p.token.Position.Line, p.token.Position.Column)))
+		panic(NewParserErrorAt(SYNTAX_ERROR_NO_TYPE_IN_CAST, p.token.Span))
 	} else {
-		return NewCastExpression(unaryTerm, *typeAnnotation, position)
+		castExpression := NewCastExpression(unaryTerm, *typeAnnotation, position)
+		castExpression.SetSpan(p.spanFrom(unaryTerm.GetSpan()))
+		return castExpression
 	}
 }
 
@@ -494,13 +582,16 @@ func (p *Parser) parseUnaryOperator() Expression {
 	}
 
 	position := p.token.Position
+	start := p.token.Span
 	p.consumeToken()
 	term := p.parseTerm()
 	if term == nil {
-		panic(fmt.Sprintf(SYNTAX_ERROR_NO_TERM, p.token.Position.Line, p.token.Position.Column))
+		panic(NewParserErrorAt(SYNTAX_ERROR_NO_TERM, p.token.Span))
 	}
 
-	return NewNegateExpression(term, position)
+	negateExpression := NewNegateExpression(term, position)
+	negateExpression.SetSpan(p.spanFrom(start))
+	return negateExpression
 }
 
 // term = integer | float | bool | string | identifier_or_call | "(" , expression , ")" ;
@@ -527,16 +618,20 @@ func (p *Parser) parseTerm() Expression {
 }
 
 // nestedExpression = "(", expression, ")"
+//
+// the expression spans its parentheses
 func (p *Parser) parseNestedExpression() Expression {
 	if p.token.Type != lex.LEFT_PARENTHESIS {
 		return nil
 	}
+	start := p.token.Span
 	p.consumeToken()
 	expression := p.parseExpression()
 	if expression == nil {
-		panic(NewParserError(fmt.Sprintf(ERROR_MISSING_EXPRESSION, p.token.Position.Line, p.token.Position.Column, p.token.Type.TypeName())))
+		panic(NewParserErrorAt(ERROR_MISSING_EXPRESSION, p.token.Span, p.token.Type.TypeName()))
 	}
 	p.requierAndConsume(lex.RIGHT_PARENTHESIS, SYNTAX_ERROR_NO_RIGHT_PARENTHESIS_IN_NESTED_EXPRESSION)
+	expression.SetSpan(p.spanFrom(start))
 	return expression
 }
 
@@ -547,7 +642,9 @@ func (p *Parser) parseIntExpression() Expression {
 	value := p.token.Value.(int)
 	position := p.token.Position
 	p.consumeToken()
-	return NewIntExpression(value, position)
+	intExpression := NewIntExpression(value, position)
+	intExpression.SetSpan(p.previous.Span)
+	return intExpression
 }
 
 func (p *Parser) parseFloatExpression() Expression {
@@ -557,7 +654,9 @@ func (p *Parser) parseFloatExpression() Expression {
 	value := p.token.Value.(float64)
 	position := p.token.Position
 	p.consumeToken()
-	return NewFloatExpression(value, position)
+	floatExpression := NewFloatExpression(value, position)
+	floatExpression.SetSpan(p.previous.Span)
+	return floatExpression
 }
 
 func (p *Parser) parseBoolExpression() Expression {
@@ -567,7 +666,9 @@ func (p *Parser) parseBoolExpression() Expression {
 	value := p.token.Type == lex.CONST_TRUE
 	position := p.token.Position
 	p.consumeToken()
-	return NewBoolExpression(value, position)
+	boolExpression := NewBoolExpression(value, position)
+	boolExpression.SetSpan(p.previous.Span)
+	return boolExpression
 }
 
 func (p *Parser) parseStringExpression() Expression {
@@ -577,7 +678,9 @@ func (p *Parser) parseStringExpression() Expression {
 	value := p.token.Value.(string)
 	position := p.token.Position
 	p.consumeToken()
-	return NewStringExpression(value, position)
+	stringExpression := NewStringExpression(value, position)
+	stringExpression.SetSpan(p.previous.Span)
+	return stringExpression
 }
 
 // conditional_statement = "if" , expression , block , [ "else" , block ] ;
@@ -585,29 +688,32 @@ func (p *Parser) parseConditionalStatement() *IfStatement {
 	if p.token.Type != lex.IF {
 		return nil
 	}
+	start := p.token.Span
 	p.consumeToken()
 
 	condition := p.parseExpression()
 	if condition == nil {
-		panic(NewParserError(fmt.Sprintf(ERROR_MISSING_EXPRESSION, p.token.Position.Line, p.token.Position.Column, "if")))
+		panic(NewParserErrorAt(ERROR_MISSING_EXPRESSION, p.token.Span, "if"))
 	}
 
 	instructions := p.parseBlock()
 	if instructions == nil {
-		panic(NewParserError(fmt.Sprintf(SYNTAX_ERROR_EMPTY_BLOCK_IN_IF_STATEMENT, p.token.Position.Line, p.token.Position.Column)))
+		panic(NewParserErrorAt(SYNTAX_ERROR_EMPTY_BLOCK_IN_IF_STATEMENT, p.token.Span))
 	}
 
-	if p.token.Type != lex.ELSE {
-		return NewIfStatement(condition, instructions, nil)
-	}
-	p.consumeToken()
+	var elseInstructions *Block
+	if p.token.Type == lex.ELSE {
+		p.consumeToken()
 
-	elseInstructions := p.parseBlock()
-	if elseInstructions == nil {
-		panic(NewParserError(fmt.Sprintf(SYNTAX_ERROR_EMPTY_BLOCK_IN_IF_STATEMENT, p.token.Position.Line, p.token.Position.Column)))
+		elseInstructions = p.parseBlock()
+		if elseInstructions == nil {
+			panic(NewParserErrorAt(SYNTAX_ERROR_EMPTY_BLOCK_IN_IF_STATEMENT, p.token.Span))
+		}
 	}
 
-	return NewIfStatement(condition, instructions, elseInstructions)
+	ifStatement := NewIfStatement(condition, instructions, elseInstructions)
+	ifStatement.Span = p.spanFrom(start)
+	return ifStatement
 }
 
 // loop_statement = "while" , expression, block ;
@@ -615,19 +721,22 @@ func (p *Parser) parseWhileStatement() *WhileStatement {
 	if p.token.Type != lex.WHILE {
 		return nil
 	}
+	start := p.token.Span
 	p.consumeToken()
 
 	condition := p.parseExpression()
 	if condition == nil {
-		panic(NewParserError(fmt.Sprintf(ERROR_MISSING_EXPRESSION, p.token.Position.Line, p.token.Position.Column, lex.WHILE.TypeName())))
+		panic(NewParserErrorAt(ERROR_MISSING_EXPRESSION, p.token.Span, lex.WHILE.TypeName()))
 	}
 
 	instructions := p.parseBlock()
 	if instructions == nil {
-		panic(NewParserError(fmt.Sprintf(SYNTAX_ERROR_EMPTY_BLOCK_IN_WHILE_STATEMENT, p.token.Position.Line, p.token.Position.Column)))
+		panic(NewParserErrorAt(SYNTAX_ERROR_EMPTY_BLOCK_IN_WHILE_STATEMENT, p.token.Span))
 	}
 
-	return NewWhileStatement(condition, instructions)
+	whileStatement := NewWhileStatement(condition, instructions)
+	whileStatement.Span = p.spanFrom(start)
+	return whileStatement
 }
 
 func (p *Parser) parseSwitchVariables() (variables []*Variable) {
@@ -641,9 +750,9 @@ func (p *Parser) parseSwitchVariables() (variables []*Variable) {
 		p.consumeToken()
 		variableDeclaration := p.parseVariableDeclaration()
 		if variableDeclaration == nil {
-			panic(NewParserError(fmt.Sprintf(SYNTAX_ERROR_NO_VARIABLE_AFTER_COMMA, p.token.Position.Line, p.token.Position.Column)))
+			panic(NewParserErrorAt(SYNTAX_ERROR_NO_VARIABLE_AFTER_COMMA, p.token.Span))
 		}
-		variables = append(variables, variable)
+		variables = append(variables, variableDeclaration)
 	}
 	return variables
 }
@@ -655,6 +764,7 @@ func (p *Parser) parseSwitchStatement() *SwitchStatement {
 	}
 
 	position := p.token.Position
+	start := p.token.Span
 	p.consumeToken()
 
 	variables := p.parseSwitchVariables()
@@ -666,7 +776,7 @@ func (p *Parser) parseSwitchStatement() *SwitchStatement {
 
 	caseStatement := p.parseSwitchCase()
 	if caseStatement == nil {
-		panic(NewParserError(fmt.Sprintf(ERROR_MISSING_SWITCH_CASE, p.token.Position.Line, p.token.Position.Column)))
+		panic(NewParserErrorAt(ERROR_MISSING_SWITCH_CASE, p.token.Span))
 	}
 	cases = append(cases, caseStatement)
 
@@ -674,7 +784,7 @@ func (p *Parser) parseSwitchStatement() *SwitchStatement {
 		p.consumeToken()
 		caseStatement := p.parseSwitchCase()
 		if caseStatement == nil {
-			panic(NewParserError(fmt.Sprintf(ERROR_MISSING_SWITCH_CASE, p.token.Position.Line, p.token.Position.Column)))
+			panic(NewParserErrorAt(ERROR_MISSING_SWITCH_CASE, p.token.Span))
 		}
 		cases = append(cases, caseStatement)
 	}
@@ -682,39 +792,47 @@ func (p *Parser) parseSwitchStatement() *SwitchStatement {
 	p.requierAndConsume(lex.RIGHT_BRACE, SYNTAX_ERROR_NOT_CLOSED_SWITCH)
 
 	// return NewSwitchStatement(variables, expression, cases)
-	return NewSwitchStatement(variables, cases, position)
+	switchStatement := NewSwitchStatement(variables, cases, position)
+	switchStatement.Span = p.spanFrom(start)
+	return switchStatement
 }
 
 // switch_case = ( expression | "default" ), "=>", ( expression | block ) ;
 func (p *Parser) parseSwitchCase() Case {
+	start := p.token.Span
 	if p.token.Type == lex.DEFAULT {
 		p.consumeToken()
 		token := p.requierAndConsume(lex.CASE_ARROW, SYNTAX_ERROR_NO_ARROW)
 		postition := token.Position
 
-		outputExpression := p.parseExpression()
-		if outputExpression == nil {
-			outputExpression = p.parseBlock()
-		}
-
-		return NewDefaultCase(outputExpression, postition)
+		defaultCase := NewDefaultCase(p.parseSwitchCaseOutput(), postition)
+		defaultCase.Span = p.spanFrom(start)
+		return defaultCase
 	}
 
 	condition := p.parseExpression()
 
 	if condition == nil {
-		panic(NewParserError(fmt.Sprintf(ERROR_MISSING_SWITCH_CASE, p.token.Position.Line, p.token.Position.Column)))
+		panic(NewParserErrorAt(ERROR_MISSING_SWITCH_CASE, p.token.Span))
 	}
 
 	token := p.requierAndConsume(lex.CASE_ARROW, SYNTAX_ERROR_NO_ARROW)
 	position := token.Position
 
-	outputExpression := p.parseExpression()
-	if outputExpression == nil {
-		outputExpression = p.parseBlock()
-	}
+	switchCase := NewSwitchCase(condition, p.parseSwitchCaseOutput(), position)
+	switchCase.Span = p.spanFrom(start)
+	return switchCase
+}
 
-	return NewSwitchCase(condition, outputExpression, position)
+// expression | block
+func (p *Parser) parseSwitchCaseOutput() Expression {
+	if outputExpression := p.parseExpression(); outputExpression != nil {
+		return outputExpression
+	}
+	if block := p.parseBlock(); block != nil {
+		return block
+	}
+	panic(NewParserErrorAt(SYNTAX_ERROR_NO_SWITCH_CASE_OUTPUT, p.token.Span))
 }
 
 // return_statement = "return" , [ expression ] ;
@@ -722,10 +840,13 @@ func (p *Parser) parseReturnStatement() *ReturnStatement {
 	if p.token.Type != lex.RETURN {
 		return nil
 	}
+	start := p.token.Span
 	p.consumeToken()
 
 	expression := p.parseExpression()
     // nil expression is allowed for void functins
     
-	return NewReturnStatement(expression)
+	returnStatement := NewReturnStatement(expression)
+	returnStatement.Span = p.spanFrom(start)
+	return returnStatement
 }
@@ -15,6 +15,37 @@ func createLexer(input string) *lexer.Lexer {
 	return lexer.NewLexer(source, 1000, 1000, 1000)
 }
 
+// spans are checked by TestNodeSpans, other tests compare nodes without them
+func withoutSpans[T any](node T) T {
+	clearSpans(reflect.ValueOf(node))
+	return node
+}
+
+func clearSpans(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			clearSpans(v.Elem())
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			clearSpans(v.MapIndex(key))
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			clearSpans(v.Index(i))
+		}
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(shared.Span{}) {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			clearSpans(v.Field(i))
+		}
+	}
+}
+
 // Helper function to create parser
 func createParser(t *testing.T, input string) *Parser {
 	lex := createLexer(input)
@@ -28,7 +59,7 @@ func TestParseParameterGroup(t *testing.T) {
 	input := "param1, param2, param3 string"
 	parser := createParser(t, input)
 
-	params := parser.parseParameterGroup()
+	params := withoutSpans(parser.parseParameterGroup())
 
 	if len(params) != 3 {
 		t.Errorf("Expected 3 parameters, got %d", len(params))
@@ -60,7 +91,7 @@ func TestParseParameters(t *testing.T) {
 	}
 	parser := createParser(t, input)
 
-	params := parser.parseParameters()
+	params := withoutSpans(parser.parseParameters())
 
 	if len(params) != len(expected) {
 		t.Errorf("Expected %d parameters, got %d", len(expected), len(params))
@@ -85,7 +116,7 @@ func TestParseIdentifier(t *testing.T) {
 		t.Errorf("Parse Identifier error: %v", err)
 	}
 	parser := NewParser(lex, errorHandler)
-	identifier := parser.parseIdentifierOrCall()
+	identifier := withoutSpans(parser.parseIdentifierOrCall())
 
 	if !reflect.DeepEqual(identifier.(*Identifier), expected) {
 		t.Errorf("expected: %v, got: %v ", identifier, expected)
@@ -127,7 +158,7 @@ func TestParseFunctionDefinitions(t *testing.T) {
 
 	for _, tt := range tests {
 		parser := createParser(t, tt.input)
-		functionDefinition := parser.parseFunDef()
+		functionDefinition := withoutSpans(parser.parseFunDef())
 
 		if !reflect.DeepEqual(functionDefinition, tt.expected) {
 			t.Errorf("function definitions are not equal, expected: %v, got: %v", tt.expected, functionDefinition)
@@ -145,7 +176,7 @@ func TestParseExpressionIdentifierAsTerm(t *testing.T) {
 	}
 	parser := NewParser(lex, errorHandler)
 
-	expression := parser.parseExpression()
+	expression := withoutSpans(parser.parseExpression())
 
 	if expr, ok := expression.(*Identifier); ok {
 		if !expr.Equals(expected) {
@@ -226,7 +257,7 @@ func TestParseExpressions(t *testing.T) {
 			}
 			parser := NewParser(lex, errorHandler)
 
-			expression := parser.parseExpression()
+			expression := withoutSpans(parser.parseExpression())
 
 			// type assertion
 			if reflect.TypeOf(expression) != reflect.TypeOf(tt.expected) {
@@ -282,7 +313,7 @@ func TestParseVariableDeclaration(t *testing.T) {
 
 	for _, test := range tests {
 		parser := createParser(t, test.input)
-		statement := parser.parseVariableDeclaration()
+		statement := withoutSpans(parser.parseVariableDeclaration())
 
 		if !reflect.DeepEqual(test.expected, statement) {
 			t.Errorf("Input: %s\nExpressions are not equal, expected: %v, got: %v", test.input, test.expected, statement)
@@ -307,7 +338,7 @@ func TestParseNegateExpression(t *testing.T) {
 
 	for _, test := range tests {
 		parser := createParser(t, test.input)
-		statement := parser.parseExpression()
+		statement := withoutSpans(parser.parseExpression())
 
 		if statement, ok := statement.(*NegateExpression); !ok {
 			t.Errorf("Parsed statement is not of type Variable")
@@ -343,7 +374,7 @@ func TestParseVariableDeclarationsWithTerm(t *testing.T) {
 
 	for _, tc := range testCases {
 		parser := createParser(t, tc.input)
-		statement := parser.parseVariableDeclaration()
+		statement := withoutSpans(parser.parseVariableDeclaration())
 
 		if !reflect.DeepEqual(tc.expected, statement) {
 			t.Errorf("Expressions are not equal, expected: %v, got: %v", tc.expected, statement)
@@ -357,7 +388,7 @@ func TestParseFloatExpression(t *testing.T) {
 	expected := NewFloatExpression(3.14, shared.NewPosition(1, 1))
 	parser := createParser(t, input)
 
-	statement := parser.parseExpression()
+	statement := withoutSpans(parser.parseExpression())
 
 	if statement, ok := statement.(*FloatExpression); !ok {
 		t.Errorf("Parsed statement is not of type Float")
@@ -377,7 +408,7 @@ func TestParseStringExpression(t *testing.T) {
 	expected := NewStringExpression("This is test a string", shared.NewPosition(1, 1))
 	parser := createParser(t, input)
 
-	statement := parser.parseExpression()
+	statement := withoutSpans(parser.parseExpression())
 
 	if statement, ok := statement.(*StringExpression); !ok {
 		t.Errorf("Parsed statement is not of type Float")
@@ -406,7 +437,7 @@ func TestParseParenthesisExpression(t *testing.T) {
 		shared.NewPosition(1, 3))
 	parser := createParser(t, input)
 
-	statement := parser.parseExpression()
+	statement := withoutSpans(parser.parseExpression())
 
 	if statement, ok := statement.(*MultiplyExpression); !ok {
 		t.Errorf("Parsed statement is not of type %v", reflect.TypeOf(expected))
@@ -429,7 +460,7 @@ func TestParseOrAndExpression(t *testing.T) {
 	expected := NewOrExpression(idA, NewAndExpression(idB, idC, shared.NewPosition(1, 8)), shared.NewPosition(1, 3))
 	parser := createParser(t, input)
 
-	expression := parser.parseExpression()
+	expression := withoutSpans(parser.parseExpression())
 
 	if statement, ok := expression.(*OrExpression); !ok {
 		t.Errorf("Parsed statement is not of type OrExpression")
@@ -454,7 +485,7 @@ func TestParseIfStatement(t *testing.T) {
 	)
 	parser := createParser(t, input)
 
-	statement := parser.parseConditionalStatement()
+	statement := withoutSpans(parser.parseConditionalStatement())
 
 	if !reflect.DeepEqual(expected, statement) {
 		t.Errorf("If statement not parsed correctly, expected: %v, got: %v", expected, statement)
@@ -470,7 +501,7 @@ func TestParseIfStatementWithElse(t *testing.T) {
 		NewBlock([]Statement{NewAssignment(NewIdentifier("y", shared.NewPosition(1, 30)), NewIntExpression(15, shared.NewPosition(1, 34)))}),
 	)
 	parser := createParser(t, input)
-	statement := parser.parseConditionalStatement()
+	statement := withoutSpans(parser.parseConditionalStatement())
 
 	if !reflect.DeepEqual(expected, statement) {
 		t.Errorf("If statement not parsed correctly, expected: %v, got: %v", expected, statement)
@@ -489,7 +520,7 @@ func TestParseWhileStatement(t *testing.T) {
 	)
 	parser := createParser(t, input)
 
-	statement := parser.parseWhileStatement()
+	statement := withoutSpans(parser.parseWhileStatement())
 
 	if !reflect.DeepEqual(statement, expected) {
 		t.Errorf("While statement not parsed correctly, expected: %v, got: %v", expected, statement)
@@ -533,7 +564,7 @@ func TestSwitchStatement(t *testing.T) {
 	expected := &SwitchStatement{Variables: variables, Cases: cases, Position: shared.Position{Line: 1, Column: 1}}
 	parser := createParser(t, input)
 
-	statement := parser.parseSwitchStatement()
+	statement := withoutSpans(parser.parseSwitchStatement())
 
 	if !reflect.DeepEqual(statement, expected) {
 		t.Errorf("Switch statement not parsed correctly, expected: %v, got: %v", expected, statement)
@@ -581,7 +612,7 @@ func TestSwitchStatementWithDefault(t *testing.T) {
 		}
 	}()
 
-	statement = parser.parseSwitchStatement()
+	statement = withoutSpans(parser.parseSwitchStatement())
 
 	if len(errors) > 0 {
 		t.Errorf("unexpected error: %v", errors[0])
@@ -620,7 +651,7 @@ func TestSwitchStatementWithBlock(t *testing.T) {
 		}
 	}()
 
-	statement = parser.parseSwitchStatement()
+	statement = withoutSpans(parser.parseSwitchStatement())
 
 	if len(errors) > 0 {
 		t.Errorf("unexpected error: %v", errors[0])
@@ -650,7 +681,7 @@ func TestParseSwitchError(t *testing.T) {
 		}
 	}()
 
-	statement := parser.parseSwitchStatement()
+	statement := withoutSpans(parser.parseSwitchStatement())
 
 	if len(errors) == 0 {
 		t.Errorf("expected error but got none")
@@ -714,7 +745,7 @@ func TestParseProgram(t *testing.T) {
 
 	expected := NewProgram(funDefs)
 	parser := createParser(t, input)
-	program := parser.ParseProgram()
+	program := withoutSpans(parser.ParseProgram())
 
 	if !reflect.DeepEqual(expected, program) {
 		t.Errorf("Program not parsed correctly, expected: %v, got: %v", expected, program)
@@ -743,9 +774,250 @@ func TestParseProgramFunctionWithType(t *testing.T) {
 
 	expected := NewProgram(funDefs)
 	parser := createParser(t, input)
-	program := parser.ParseProgram()
+	program := withoutSpans(parser.ParseProgram())
 
 	if !reflect.DeepEqual(program, expected) {
 		t.Errorf("Program not parsed correctly, expected: %v, got: %v", expected, program)
 	}
 }
+
+func TestParseFunctionRedefinitionRelated(t *testing.T) {
+	input := `f() {}
+f() {}`
+
+	parser := createParser(t, input)
+	var parserError *ParserError
+	parser.ErrorHandler = func(err error) { parserError = err.(*ParserError) }
+
+	parser.ParseProgram()
+
+	if parserError == nil {
+		t.Fatalf("expected redefinition error but got none")
+	}
+	if parserError.Position != shared.NewPosition(2, 1) {
+		t.Errorf("expected error at [2, 1], got %v", parserError.Position)
+	}
+
+	related := parserError.Diagnostic().Related
+	if len(related) != 1 || related[0].Position != shared.NewPosition(1, 1) {
+		t.Errorf("expected related location of previous definition at [1, 1], got %v", related)
+	}
+}
+
+func TestEveryErrorCodeIsExplained(t *testing.T) {
+	for code := SYNTAX_ERROR_FUNC_DEF_NO_PARENTHASIS; code <= SYNTAX_ERROR_NO_SWITCH_CASE_OUTPUT; code++ {
+		if _, ok := errorMessage[code]; !ok {
+			t.Errorf("no message for error code %s", code)
+		}
+		if _, ok := explanations[code]; !ok {
+			t.Errorf("no explanation for error code %s", code)
+		}
+	}
+}
+
+// codes are shown to users and looked up with flux explain, they must not change
+func TestErrorCodesAreStable(t *testing.T) {
+	tests := []struct {
+		code     ErrorCode
+		expected string
+	}{
+		{SYNTAX_ERROR_FUNC_DEF_NO_PARENTHASIS, "E0201"},
+		{SYNTAX_ERROR_FUNCTION_REDEFINITION, "E0202"},
+		{SYNTAX_ERROR_NO_BLOCK, "E0203"},
+		{SYNTAX_ERROR_NO_IDENTIFIER, "E0204"},
+		{SYNTAX_ERROR_NO_VARIABLE_IDETIFIER, "E0205"},
+		{SYNTAX_ERROR_NO_TYPE, "E0206"},
+		{SYNTAX_ERROR_NO_PARAMETERS_AFTER_COMMA, "E0207"},
+		{SYNTAX_ERROR_NO_TYPE_IN_CAST, "E0208"},
+		{ERROR_NO_ETX_TOKEN, "E0209"},
+		{SYNTAX_ERROR_EXPECTED_RIGHT_BRACE, "E0210"},
+		{SYNTAX_ERROR_UNKNOWN_STATEMENT, "E0211"},
+		{SYNTAX_ERROR_MISSING_COLON_ASSIGN, "E0212"},
+		{SYNTAX_ERROR_FUNC_CALL_NOT_CLOSED, "E0213"},
+		{ERROR_ASIGNMENT_TO_FUNCTION_CALL, "E0214"},
+		{ERROR_MISSING_EXPRESSION, "E0215"},
+		{SYNTAX_ERROR_NO_TERM, "E0216"},
+		{SYNTAX_ERROR_NO_EXPRESSION_IN_VARIABLE_DECLARATION, "E0217"},
+		{SYNTAX_ERROR_NO_TYPE_IN_DECLARATION, "E0218"},
+		{SYNTAX_ERROR_NO_RIGHT_PARENTHESIS_IN_NESTED_EXPRESSION, "E0219"},
+		{SYNTAX_ERROR_NO_RETURN, "E0220"},
+		{SYNTAX_ERROR_NO_LEFT_CURLY_BRACKET_IN_SWITCH, "E0221"},
+		{SYNTAX_ERROR_NO_ARROW, "E0222"},
+		{SYNTAX_ERROR_NOT_CLOSED_SWITCH, "E0223"},
+		{SYNTAX_ERROR_NO_SWITCH_CASES, "E0224"},
+		{ERROR_MISSING_SWITCH_CASE, "E0225"},
+		{SYNTAX_ERROR_UNDEFIND_RELATION_FOR_SWITCH_CASE, "E0226"},
+		{SYNTA_ERROR_NO_RELATION_FOR_SWITCH_CASE, "E0227"},
+		{SYNTAX_ERROR_NOT_VALID_TYPE_IN_FUNC, "E0228"},
+		{SYNTA_ERROR_NO_BLOCK_DEFINED, "E0229"},
+		{SYNTAX_ERROR_NO_VARIABLE_AFTER_COMMA, "E0230"},
+		{SYNTAX_ERROR_BAD_VARIABLE_DECLARATION, "E0231"},
+		{SYNTAX_ERROR_EMPTY_BLOCK_IN_IF_STATEMENT, "E0232"},
+		{SYNTAX_ERROR_EMPTY_BLOCK_IN_WHILE_STATEMENT, "E0233"},
+		{INVALID_TOKEN, "E0234"},
+		{SYNTAX_ERROR_NO_SWITCH_CASE_OUTPUT, "E0235"},
+	}
+	for _, test := range tests {
+		if test.code.String() != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, test.code)
+		}
+	}
+}
+
+func TestParserErrorCode(t *testing.T) {
+	input := `main() {
+    int a = 5
+}`
+	defer func() {
+		r := recover()
+		err, ok := r.(*ParserError)
+		if !ok {
+			t.Fatalf("expected *ParserError, got: %v", r)
+		}
+		if err.Code.String() != "E0212" {
+			t.Errorf("expected code E0212, got: %s", err.Code)
+		}
+		if err.Diagnostic().Code != "E0212" {
+			t.Errorf("expected diagnostic code E0212, got: %s", err.Diagnostic().Code)
+		}
+	}()
+
+	parser := NewParser(createLexer(input), func(err error) { panic(err) })
+	parser.ParseProgram()
+}
+
+func TestParseExpressionInput(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Expression
+	}{
+		{"1 + a", NewSumExpression(NewIntExpression(1, shared.NewPosition(1, 1)), NewIdentifier("a", shared.NewPosition(1, 5)), shared.NewPosition(1, 3))},
+		{"f(2)", NewFunctionCall("f", shared.NewPosition(1, 1), []Expression{NewIntExpression(2, shared.NewPosition(1, 3))})},
+		// statements are not expressions, even when they start like one
+		{"a = 1", nil},
+		{"int a := 1", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		expression := withoutSpans(createParser(t, test.input).ParseExpressionInput())
+		if !reflect.DeepEqual(expression, test.expected) {
+			t.Errorf("%q: expected %v, got %v", test.input, test.expected, expression)
+		}
+	}
+}
+
+func TestParseStatementsInput(t *testing.T) {
+	statements := withoutSpans(createParser(t, "int a := 1\na = 2").ParseStatementsInput())
+	expected := []Statement{
+		NewVariable(shared.INT, "a", NewIntExpression(1, shared.NewPosition(1, 10)), shared.NewPosition(1, 5)),
+		NewAssignment(NewIdentifier("a", shared.NewPosition(2, 1)), NewIntExpression(2, shared.NewPosition(2, 5))),
+	}
+	if !reflect.DeepEqual(statements, expected) {
+		t.Errorf("expected %v, got %v", expected, statements)
+	}
+
+	defer func() {
+		err, ok := recover().(*ParserError)
+		if !ok || err.Code != ERROR_NO_ETX_TOKEN || err.Position != shared.NewPosition(1, 3) {
+			t.Errorf("expected %v at [1, 3], got: %v", ERROR_NO_ETX_TOKEN, err)
+		}
+	}()
+	NewParser(createLexer("a := 1"), func(err error) { panic(err) }).ParseStatementsInput()
+}
+
+// every variable after a comma is kept, not the first one again
+func TestParseSwitchVariables(t *testing.T) {
+	variables := withoutSpans(createParser(t, "int a := 1, string b := \"x\"").parseSwitchVariables())
+	expected := []*Variable{
+		NewVariable(shared.INT, "a", NewIntExpression(1, shared.NewPosition(1, 10)), shared.NewPosition(1, 5)),
+		NewVariable(shared.STRING, "b", NewStringExpression("x", shared.NewPosition(1, 25)), shared.NewPosition(1, 20)),
+	}
+	if !reflect.DeepEqual(variables, expected) {
+		t.Errorf("expected %v, got %v", expected, variables)
+	}
+}
+
+func TestSwitchCaseWithoutOutput(t *testing.T) {
+	for _, input := range []string{
+		"main() {\n    switch {\n        1 => \n    }\n}",
+		"main() {\n    switch {\n        default => \n    }\n}",
+	} {
+		func() {
+			defer func() {
+				err, ok := recover().(*ParserError)
+				if !ok || err.Code != SYNTAX_ERROR_NO_SWITCH_CASE_OUTPUT || err.Position != shared.NewPosition(4, 5) {
+					t.Errorf("%q: expected %v at [4, 5], got: %v", input, SYNTAX_ERROR_NO_SWITCH_CASE_OUTPUT, err)
+				}
+			}()
+			NewParser(createLexer(input), func(err error) { panic(err) }).ParseProgram()
+		}()
+	}
+}
+
+func TestParseProgramComments(t *testing.T) {
+	input := `# first
+main() { # second
+    int a := 1
+    # third
+}`
+	program := withoutSpans(createParser(t, input).ParseProgram())
+	expected := []*Comment{
+		NewComment("# first", shared.NewPosition(1, 1)),
+		NewComment("# second", shared.NewPosition(2, 10)),
+		NewComment("# third", shared.NewPosition(4, 5)),
+	}
+	if !reflect.DeepEqual(program.Comments, expected) {
+		t.Errorf("expected %v, got %v", expected, program.Comments)
+	}
+}
+
+func TestNodeSpans(t *testing.T) {
+	source := "main() {\n    int a := (1 + 2) * f(x, \"s\")\n    if a > 3 {\n        return\n    }\n}\n"
+	program := createParser(t, source).ParseProgram()
+
+	main := program.Functions["main"]
+	variable := main.Block.Statements[0].(*Variable)
+	multiply := variable.Value.(*MultiplyExpression)
+	call := multiply.RightExpression.(*FunctionCall)
+	ifStatement := main.Block.Statements[1].(*IfStatement)
+
+	tests := []struct {
+		node       Spanned
+		text       string
+		start, end shared.Position
+	}{
+		{main, source[:len(source)-1], shared.NewPosition(1, 1), shared.NewPosition(6, 2)},
+		{variable, `int a := (1 + 2) * f(x, "s")`, shared.NewPosition(2, 5), shared.NewPosition(2, 33)},
+		{multiply, `(1 + 2) * f(x, "s")`, shared.NewPosition(2, 14), shared.NewPosition(2, 33)},
+		{multiply.LeftExpression, "(1 + 2)", shared.NewPosition(2, 14), shared.NewPosition(2, 21)},
+		{call, `f(x, "s")`, shared.NewPosition(2, 24), shared.NewPosition(2, 33)},
+		{call.Arguments[1], `"s"`, shared.NewPosition(2, 29), shared.NewPosition(2, 32)},
+		{ifStatement, "if a > 3 {\n        return\n    }", shared.NewPosition(3, 5), shared.NewPosition(5, 6)},
+		{ifStatement.InstructionsBlock, "{\n        return\n    }", shared.NewPosition(3, 14), shared.NewPosition(5, 6)},
+		{ifStatement.InstructionsBlock.Statements[0], "return", shared.NewPosition(4, 9), shared.NewPosition(4, 15)},
+	}
+	for _, test := range tests {
+		span := test.node.GetSpan()
+		if text := source[span.StartOffset:span.EndOffset]; text != test.text {
+			t.Errorf("%T: expected to span %q, got %q", test.node, test.text, text)
+		}
+		if span.Start != test.start || span.End != test.end {
+			t.Errorf("%T: expected %v-%v, got %v-%v", test.node, test.start, test.end, span.Start, span.End)
+		}
+	}
+	if program.Span.StartOffset != 0 || program.Span.EndOffset != len(source) {
+		t.Errorf("expected the program to span the source, got %+v", program.Span)
+	}
+}
+
+// the unexpected token is underlined
+func TestParserErrorSpan(t *testing.T) {
+	defer func() {
+		err, ok := recover().(*ParserError)
+		expected := shared.NewSpan("", shared.NewPosition(1, 12), shared.NewPosition(1, 14), 11, 13)
+		if !ok || err.Span != expected {
+			t.Errorf("expected an error with span %+v, got %+v", expected, err)
+		}
+	}()
+	NewParser(createLexer("main() { a := 1 }"), func(err error) { panic(err) }).ParseProgram()
+}
@@ -1,52 +1,131 @@
 package parser
 
+import (
+	"fmt"
+	"tkom/diagnostics"
+	"tkom/shared"
+)
+
+type ErrorCode int
+
 const (
-	SYNTAX_ERROR_FUNC_DEF_NO_PARENTHASIS                   = "error [%v, %v]: no parenthasis after identifier in function definition, perhaps you forgot '(' or to close the function definition with ')'"
-	SYNTAX_ERROR_FUNCTION_REDEFINITION                     = "error [%v, %v]: redefinition of function that already exsists at: %v, %v"
-	SYNTAX_ERROR_NO_BLOCK                                  = "error [%v, %v]: no block defined"
-	SYNTAX_ERROR_NO_IDENTIFIER                             = "error [%v, %v]: identifier requierd here but was ommited"
-	SYNTAX_ERROR_NO_VARIABLE_IDETIFIER                     = "error [%v, %v]: no identifier in variable declaration"
-	SYNTAX_ERROR_NO_TYPE                                   = "error [%v, %v]: no type for parameter group"
-	SYNTAX_ERROR_NO_PARAMETERS_AFTER_COMMA                 = "error [%v, %v]: no parameters defined after comma"
-	SYNTAX_ERROR_NO_TYPE_IN_CAST                           = "error [%v, %v]: no type in casted expression"
-	ERROR_NO_ETX_TOKEN                                     = "error [%v, %v]: program parsed but no ETX was found"
-	SYNTAX_ERROR_EXPECTED_RIGHT_BRACE                      = "error [%v, %v]: expected right brace, to close the block"
-	SYNTAX_ERROR_UNKNOWN_STATEMENT                         = "error [%v, %v]: unknown statement"
-	SYNTAX_ERROR_MISSING_COLON_ASSIGN                      = "error [%v, %v]: missing ':' after identifier in variable declaration"
-	SYNTAX_ERROR_FUNC_CALL_NOT_CLOSED                      = "error [%v, %v]: function call not closed, perhaps you forgot '('"
-	ERROR_ASIGNMENT_TO_FUNCTION_CALL                       = "error [%v, %v]: cannot assign value to function call"
-	ERROR_MISSING_EXPRESSION                               = "error [%v, %v]: missing expression after: %v"
-	SYNTAX_ERROR_NO_TERM                                   = "error [%v, %v]: no term defined for expression"
-	SYNTAX_ERROR_NO_EXPRESSION_IN_VARIABLE_DECLARATION     = "error [%v, %v]: no expression defined for variable declaration"
-	SYNTAX_ERROR_NO_TYPE_IN_DECLARATION                    = "error [%v, %v]: no type defined for variable declaration"
-	SYNTAX_ERROR_NO_RIGHT_PARENTHESIS_IN_NESTED_EXPRESSION = "error [%v, %v]: no right parenthesis in nested expression"
-	SYNTAX_ERROR_NO_RETURN                                 = "error [%v, %v]: no return statement defined"
-	SYNTAX_ERROR_NO_LEFT_CURLY_BRACKET_IN_SWITCH           = "error [%v, %v]: no left curly bracket in switch statement"
-	SYNTAX_ERROR_NO_ARROW                                  = "error [%v, %v]: no arrow in case condition, perhaps you have ',' after the last case"
-	SYNTAX_ERROR_NOT_CLOSED_SWITCH                         = "error [%v, %v]: switch statement not closed, or no comma after switch case"
-	SYNTAX_ERROR_NO_SWITCH_CASES                           = "error [%v, %v]: no switch cases defined"
-	ERROR_MISSING_SWITCH_CASE                              = "error [%v, %v]: missing or bad switch case condition"
-	SYNTAX_ERROR_UNDEFIND_RELATION_FOR_SWITCH_CASE         = "error [%v, %v]: undefined relation for switch case"
-	SYNTA_ERROR_NO_RELATION_FOR_SWITCH_CASE                = "error [%v, %v]: no relation oporator for switch case"
-	SYNTAX_ERROR_NOT_VALID_TYPE_IN_FUNC                    = "error [%v, %v]: not valid type in function declaration"
-	SYNTA_ERROR_NO_BLOCK_DEFINED                           = "error [%v, %v]: no block defined for the function declaration"
-	SYNTAX_ERROR_NO_VARIABLE_AFTER_COMMA                   = "error [%v, %v]: no variable after comma in switch case"
-	SYNTAX_ERROR_BAD_VARIABLE_DECLARATION                  = "error [%v, %v]: bad variable declaration in switch statement"
-	SYNTAX_ERROR_EMPTY_BLOCK_IN_IF_STATEMENT               = "error [%v, %v]: empty block in if statement"
-	SYNTAX_ERROR_EMPTY_BLOCK_IN_WHILE_STATEMENT            = "error [%v, %v]: empty block in while statement"
-    INVALID_TOKEN                                          = "error [%v, %v]: received invalid Token: '%s'"
+	SYNTAX_ERROR_FUNC_DEF_NO_PARENTHASIS                   ErrorCode = 201
+	SYNTAX_ERROR_FUNCTION_REDEFINITION                     ErrorCode = 202
+	SYNTAX_ERROR_NO_BLOCK                                  ErrorCode = 203
+	SYNTAX_ERROR_NO_IDENTIFIER                             ErrorCode = 204
+	SYNTAX_ERROR_NO_VARIABLE_IDETIFIER                     ErrorCode = 205
+	SYNTAX_ERROR_NO_TYPE                                   ErrorCode = 206
+	SYNTAX_ERROR_NO_PARAMETERS_AFTER_COMMA                 ErrorCode = 207
+	SYNTAX_ERROR_NO_TYPE_IN_CAST                           ErrorCode = 208
+	ERROR_NO_ETX_TOKEN                                     ErrorCode = 209
+	SYNTAX_ERROR_EXPECTED_RIGHT_BRACE                      ErrorCode = 210
+	SYNTAX_ERROR_UNKNOWN_STATEMENT                         ErrorCode = 211
+	SYNTAX_ERROR_MISSING_COLON_ASSIGN                      ErrorCode = 212
+	SYNTAX_ERROR_FUNC_CALL_NOT_CLOSED                      ErrorCode = 213
+	ERROR_ASIGNMENT_TO_FUNCTION_CALL                       ErrorCode = 214
+	ERROR_MISSING_EXPRESSION                               ErrorCode = 215
+	SYNTAX_ERROR_NO_TERM                                   ErrorCode = 216
+	SYNTAX_ERROR_NO_EXPRESSION_IN_VARIABLE_DECLARATION     ErrorCode = 217
+	SYNTAX_ERROR_NO_TYPE_IN_DECLARATION                    ErrorCode = 218
+	SYNTAX_ERROR_NO_RIGHT_PARENTHESIS_IN_NESTED_EXPRESSION ErrorCode = 219
+	SYNTAX_ERROR_NO_RETURN                                 ErrorCode = 220
+	SYNTAX_ERROR_NO_LEFT_CURLY_BRACKET_IN_SWITCH           ErrorCode = 221
+	SYNTAX_ERROR_NO_ARROW                                  ErrorCode = 222
+	SYNTAX_ERROR_NOT_CLOSED_SWITCH                         ErrorCode = 223
+	SYNTAX_ERROR_NO_SWITCH_CASES                           ErrorCode = 224
+	ERROR_MISSING_SWITCH_CASE                              ErrorCode = 225
+	SYNTAX_ERROR_UNDEFIND_RELATION_FOR_SWITCH_CASE         ErrorCode = 226
+	SYNTA_ERROR_NO_RELATION_FOR_SWITCH_CASE                ErrorCode = 227
+	SYNTAX_ERROR_NOT_VALID_TYPE_IN_FUNC                    ErrorCode = 228
+	SYNTA_ERROR_NO_BLOCK_DEFINED                           ErrorCode = 229
+	SYNTAX_ERROR_NO_VARIABLE_AFTER_COMMA                   ErrorCode = 230
+	SYNTAX_ERROR_BAD_VARIABLE_DECLARATION                  ErrorCode = 231
+	SYNTAX_ERROR_EMPTY_BLOCK_IN_IF_STATEMENT               ErrorCode = 232
+	SYNTAX_ERROR_EMPTY_BLOCK_IN_WHILE_STATEMENT            ErrorCode = 233
+	INVALID_TOKEN                                          ErrorCode = 234
+	SYNTAX_ERROR_NO_SWITCH_CASE_OUTPUT                     ErrorCode = 235
 )
 
+var errorMessage = map[ErrorCode]string{
+	SYNTAX_ERROR_FUNC_DEF_NO_PARENTHASIS:                   "no parenthasis after identifier in function definition, perhaps you forgot '(' or to close the function definition with ')'",
+	SYNTAX_ERROR_FUNCTION_REDEFINITION:                     "redefinition of function that already exsists at: %v, %v",
+	SYNTAX_ERROR_NO_BLOCK:                                  "no block defined",
+	SYNTAX_ERROR_NO_IDENTIFIER:                             "identifier requierd here but was ommited",
+	SYNTAX_ERROR_NO_VARIABLE_IDETIFIER:                     "no identifier in variable declaration",
+	SYNTAX_ERROR_NO_TYPE:                                   "no type for parameter group",
+	SYNTAX_ERROR_NO_PARAMETERS_AFTER_COMMA:                 "no parameters defined after comma",
+	SYNTAX_ERROR_NO_TYPE_IN_CAST:                           "no type in casted expression",
+	ERROR_NO_ETX_TOKEN:                                     "program parsed but no ETX was found",
+	SYNTAX_ERROR_EXPECTED_RIGHT_BRACE:                      "expected right brace, to close the block",
+	SYNTAX_ERROR_UNKNOWN_STATEMENT:                         "unknown statement",
+	SYNTAX_ERROR_MISSING_COLON_ASSIGN:                      "missing ':' after identifier in variable declaration",
+	SYNTAX_ERROR_FUNC_CALL_NOT_CLOSED:                      "function call not closed, perhaps you forgot '('",
+	ERROR_ASIGNMENT_TO_FUNCTION_CALL:                       "cannot assign value to function call",
+	ERROR_MISSING_EXPRESSION:                               "missing expression after: %v",
+	SYNTAX_ERROR_NO_TERM:                                   "no term defined for expression",
+	SYNTAX_ERROR_NO_EXPRESSION_IN_VARIABLE_DECLARATION:     "no expression defined for variable declaration",
+	SYNTAX_ERROR_NO_TYPE_IN_DECLARATION:                    "no type defined for variable declaration",
+	SYNTAX_ERROR_NO_RIGHT_PARENTHESIS_IN_NESTED_EXPRESSION: "no right parenthesis in nested expression",
+	SYNTAX_ERROR_NO_RETURN:                                 "no return statement defined",
+	SYNTAX_ERROR_NO_LEFT_CURLY_BRACKET_IN_SWITCH:           "no left curly bracket in switch statement",
+	SYNTAX_ERROR_NO_ARROW:                                  "no arrow in case condition, perhaps you have ',' after the last case",
+	SYNTAX_ERROR_NOT_CLOSED_SWITCH:                         "switch statement not closed, or no comma after switch case",
+	SYNTAX_ERROR_NO_SWITCH_CASES:                           "no switch cases defined",
+	ERROR_MISSING_SWITCH_CASE:                              "missing or bad switch case condition",
+	SYNTAX_ERROR_UNDEFIND_RELATION_FOR_SWITCH_CASE:         "undefined relation for switch case",
+	SYNTA_ERROR_NO_RELATION_FOR_SWITCH_CASE:                "no relation oporator for switch case",
+	SYNTAX_ERROR_NOT_VALID_TYPE_IN_FUNC:                    "not valid type in function declaration",
+	SYNTA_ERROR_NO_BLOCK_DEFINED:                           "no block defined for the function declaration",
+	SYNTAX_ERROR_NO_VARIABLE_AFTER_COMMA:                   "no variable after comma in switch case",
+	SYNTAX_ERROR_BAD_VARIABLE_DECLARATION:                  "bad variable declaration in switch statement",
+	SYNTAX_ERROR_EMPTY_BLOCK_IN_IF_STATEMENT:               "empty block in if statement",
+	SYNTAX_ERROR_EMPTY_BLOCK_IN_WHILE_STATEMENT:            "empty block in while statement",
+	INVALID_TOKEN:                                          "received invalid Token: '%s'",
+	SYNTAX_ERROR_NO_SWITCH_CASE_OUTPUT:                     "no expression or block after the arrow of switch case",
+}
+
+// parser errors are numbered E0201, E0202, ...
+func (c ErrorCode) String() string {
+	if c == 0 {
+		return ""
+	}
+	return fmt.Sprintf("E%04d", int(c))
+}
+
 type ParserError struct {
-	Message string
+	Code     ErrorCode
+	Message  string
+	Reason   string
+	Position shared.Position
+	// source the error is about, zero when only the position is known
+	Span    shared.Span
+	Related []diagnostics.Related
 }
 
-func NewParserError(message string) *ParserError {
+func NewParserError(code ErrorCode, position shared.Position, args ...any) *ParserError {
+	reason := fmt.Sprintf(errorMessage[code], args...)
 	return &ParserError{
-		Message: message,
+		Code:     code,
+		Message:  fmt.Sprintf("error [%v, %v]: %s", position.Line, position.Column, reason),
+		Reason:   reason,
+		Position: position,
 	}
 }
 
+// error about the source of the span, e.g. of the unexpected token
+func NewParserErrorAt(code ErrorCode, span shared.Span, args ...any) *ParserError {
+	err := NewParserError(code, span.Start, args...)
+	err.Span = span
+	return err
+}
+
 func (e *ParserError) Error() string {
 	return e.Message
 }
+
+func (e *ParserError) Diagnostic() *diagnostics.Diagnostic {
+	d := diagnostics.NewDiagnostic(diagnostics.ERROR, e.Code.String(), e.Reason, e.Position)
+	d.Span = e.Span
+	d.Related = e.Related
+	return d
+}
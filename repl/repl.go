@@ -0,0 +1,411 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"tkom/ast"
+	"tkom/diagnostics"
+	"tkom/interpreter"
+	"tkom/lexer"
+	"tkom/parser"
+	"tkom/shared"
+)
+
+const (
+	IDENTIFIER_LIMIT = 500
+	STRING_LIMIT     = 1000
+	INT_LIMIT        = math.MaxInt
+
+	PROMPT          = ">>> "
+	CONTINUE_PROMPT = "... "
+	// name of the source errors of the input are reported in
+	SOURCE_NAME = "<repl>"
+)
+
+const HELP = `Statements, expressions and function definitions are run as they are typed,
+input with unclosed braces continues on the next line.
+
+  :type expr   prints the type of the expression, or the signature of a function
+  :ast expr    prints the syntax tree of the input
+  :history     prints the inputs typed so far
+  :reset       forgets every variable and function
+  :help        prints this help
+  :quit        leaves the repl
+`
+
+// interactive interpreter, variables and functions of every
+// input stay defined for the following ones until :reset
+type Repl struct {
+	Visitor *interpreter.CodeVisitor
+	// scope the variables of the inputs are declared in
+	Scope   *interpreter.Scope
+	Emitter diagnostics.Emitter
+	// inputs typed so far, the oldest first
+	History []string
+	// file the history is loaded from and appended to, empty to keep it in memory
+	HistoryFile string
+
+	// lines of the code run so far, errors raised in functions defined by
+	// earlier inputs are reported in the lines of their definitions
+	lines             []string
+	maxRecursionDepth int
+	in                *bufio.Scanner
+	out               io.Writer
+}
+
+func NewRepl(in io.Reader, out io.Writer, emitter diagnostics.Emitter, maxRecursionDepth int) *Repl {
+	r := &Repl{
+		Emitter:           emitter,
+		maxRecursionDepth: maxRecursionDepth,
+		in:                bufio.NewScanner(in),
+		out:               out,
+	}
+	r.Reset()
+	return r
+}
+
+// forgets every variable and function defined so far
+func (r *Repl) Reset() {
+	r.Visitor = interpreter.NewCodeVisitor(r.maxRecursionDepth)
+	r.Scope = interpreter.NewScope(nil, nil)
+	r.Visitor.CurrentScope = r.Scope
+	r.lines = nil
+}
+
+// reads the history written by the previous sessions
+func (r *Repl) LoadHistory() error {
+	if r.HistoryFile == "" {
+		return nil
+	}
+	text, err := os.ReadFile(r.HistoryFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range strings.Split(string(text), "\n\n") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			r.History = append(r.History, entry)
+		}
+	}
+	return nil
+}
+
+// entries of the history file are separated by an empty line, so inputs of many lines stay together
+func (r *Repl) remember(input string) {
+	r.History = append(r.History, input)
+	if r.HistoryFile == "" {
+		return
+	}
+	file, err := os.OpenFile(r.HistoryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintf(file, "%s\n\n", input)
+}
+
+// runs the inputs until the end of the input or :quit
+func (r *Repl) Run() {
+	for {
+		input, ok := r.read()
+		if !ok {
+			fmt.Fprintln(r.out)
+			return
+		}
+		if input == "" {
+			continue
+		}
+		r.remember(input)
+		if !r.Execute(input) {
+			return
+		}
+	}
+}
+
+// reads lines until the braces of the input are balanced
+func (r *Repl) read() (string, bool) {
+	fmt.Fprint(r.out, PROMPT)
+	lines := []string{}
+	for r.in.Scan() {
+		lines = append(lines, r.in.Text())
+		input := strings.TrimSpace(strings.Join(lines, "\n"))
+		if strings.HasPrefix(input, ":") || !Unbalanced(input) {
+			return input, true
+		}
+		fmt.Fprint(r.out, CONTINUE_PROMPT)
+	}
+	if len(lines) > 0 {
+		return strings.TrimSpace(strings.Join(lines, "\n")), true
+	}
+	return "", false
+}
+
+// reports whether the input has more opening braces than closing ones,
+// braces in strings and comments do not count
+func Unbalanced(input string) bool {
+	depth := 0
+	for _, token := range tokens(input) {
+		switch token.Type {
+		case lexer.LEFT_BRACE:
+			depth++
+		case lexer.RIGHT_BRACE:
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+// tokens of the input up to the first error of the lexer
+func tokens(input string) []lexer.Token {
+	scanner, _ := lexer.NewScanner(strings.NewReader(input))
+	lex := lexer.NewLexer(scanner, IDENTIFIER_LIMIT, STRING_LIMIT, INT_LIMIT)
+	lex.ErrorHandler = func(err error) {}
+	result := []lexer.Token{}
+	for {
+		token := lex.GetNextToken()
+		if token.Type == lexer.ETX || token.Type == lexer.UNDEFINED {
+			return result
+		}
+		if token.Type != lexer.COMMENT {
+			result = append(result, *token)
+		}
+	}
+}
+
+// runs a single input, returns false when the repl should stop
+func (r *Repl) Execute(input string) (running bool) {
+	// code of meta-commands is reported on its own
+	source := diagnostics.NewSource(SOURCE_NAME, input)
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			r.Emitter.Emit(diagnostics.FromPanic(recovered), source)
+			r.recover()
+			running = true
+		}
+	}()
+
+	command, argument, _ := strings.Cut(input, " ")
+	argument = strings.TrimSpace(argument)
+	switch command {
+	case ":quit", ":q":
+		return false
+	case ":help":
+		fmt.Fprint(r.out, HELP)
+	case ":reset":
+		r.Reset()
+	case ":history":
+		for i, entry := range r.History {
+			fmt.Fprintf(r.out, "%4d  %s\n", i+1, strings.ReplaceAll(entry, "\n", "\n      "))
+		}
+	case ":type":
+		var code string
+		code, source = r.session(argument)
+		r.printType(code)
+	case ":ast":
+		for _, node := range r.parse(argument) {
+			DumpNode(r.out, node)
+		}
+	default:
+		if strings.HasPrefix(command, ":") {
+			fmt.Fprintf(r.out, "unknown command: %s, type :help for the list of commands\n", command)
+			return true
+		}
+		var code string
+		code, source = r.session(input)
+		r.run(code, source)
+	}
+	return true
+}
+
+// adds the code to the lines of the session, the returned code
+// is moved to its lines and reported in the source of the whole session
+func (r *Repl) session(code string) (string, *diagnostics.Source) {
+	offset := len(r.lines)
+	r.lines = append(r.lines, strings.Split(code, "\n")...)
+	return strings.Repeat("\n", offset) + code, diagnostics.NewSource(SOURCE_NAME, strings.Join(r.lines, "\n"))
+}
+
+// after an error the interpreter is left in the scope the error was raised in,
+// the frames of the calls are already popped, variables declared before the error stay
+func (r *Repl) recover() {
+	visitor := r.Visitor
+	visitor.CurrentScope = r.Scope
+	visitor.ScopeStack = interpreter.Stack{}
+	visitor.ReturnFlag = false
+	visitor.SwitchEndFlag = false
+	visitor.CurrentReturnType = shared.VOID
+}
+
+// parses the input as function definitions, a single expression or statements
+func (r *Repl) parse(input string) []ast.Node {
+	newParser := func() *parser.Parser {
+		scanner, _ := lexer.NewScanner(strings.NewReader(input))
+		lex := lexer.NewLexer(scanner, IDENTIFIER_LIMIT, STRING_LIMIT, INT_LIMIT)
+		errorHandler := func(err error) {
+			panic(err)
+		}
+		lex.ErrorHandler = errorHandler
+		return parser.NewParser(lex, errorHandler)
+	}
+
+	nodes := []ast.Node{}
+	if isFunctionDefinition(tokens(input)) {
+		program := newParser().ParseProgram()
+		names := make([]string, 0, len(program.Functions))
+		for name := range program.Functions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			nodes = append(nodes, program.Functions[name])
+		}
+		return nodes
+	}
+	if expression := newParser().ParseExpressionInput(); expression != nil {
+		return append(nodes, expression)
+	}
+	for _, statement := range newParser().ParseStatementsInput() {
+		nodes = append(nodes, statement)
+	}
+	return nodes
+}
+
+// a function definition starts like a call, but the parentheses are followed by a type or a block
+func isFunctionDefinition(tokens []lexer.Token) bool {
+	if len(tokens) < 3 || tokens[0].Type != lexer.IDENTIFIER || tokens[1].Type != lexer.LEFT_PARENTHESIS {
+		return false
+	}
+	depth := 0
+	for i, token := range tokens[1:] {
+		switch token.Type {
+		case lexer.LEFT_PARENTHESIS:
+			depth++
+		case lexer.RIGHT_PARENTHESIS:
+			depth--
+		}
+		if depth == 0 {
+			if i+2 >= len(tokens) {
+				return false
+			}
+			next := tokens[i+2].Type
+			_, isType := ast.ValidTypeAnnotation[next]
+			return isType || next == lexer.LEFT_BRACE
+		}
+	}
+	return false
+}
+
+func (r *Repl) run(input string, source *diagnostics.Source) {
+	for _, node := range r.parse(input) {
+		switch node := node.(type) {
+		case *ast.FunctionDefinition:
+			r.define(node, source)
+		case ast.Expression:
+			r.Visitor.LastResult = nil
+			node.Accept(r.Visitor)
+			if r.Visitor.LastResult != nil {
+				fmt.Fprintf(r.out, "%s : %s\n", FormatValue(r.Visitor.LastResult), r.Visitor.DetermineType(r.Visitor.LastResult))
+			}
+		default:
+			node.Accept(r.Visitor)
+			r.Visitor.ReturnFlag = false
+			r.Visitor.LastResult = nil
+		}
+	}
+}
+
+// functions can be defined again, the new definition replaces the old one
+func (r *Repl) define(fd *ast.FunctionDefinition, source *diagnostics.Source) {
+	program := ast.NewProgram(map[string]*ast.FunctionDefinition{fd.Name: fd})
+	for _, warning := range interpreter.ResolveProgram(program) {
+		r.Emitter.Emit(warning.Diagnostic(), source)
+	}
+	r.Visitor.FunctionsMap[fd.Name] = fd
+	fmt.Fprintf(r.out, "defined %s\n", ast.Signature(fd))
+}
+
+func (r *Repl) printType(input string) {
+	node := r.parseSingle(input)
+	if identifier, ok := node.(*ast.Identifier); ok {
+		// names of functions that are not covered by a variable print their signature
+		if _, err := r.Scope.GetVariable(identifier.Name); err != nil {
+			if function, ok := r.Visitor.FunctionsMap[identifier.Name]; ok {
+				fmt.Fprintln(r.out, ast.Signature(function))
+				return
+			}
+		}
+	}
+	r.Visitor.LastResult = nil
+	node.Accept(r.Visitor)
+	fmt.Fprintln(r.out, r.Visitor.DetermineType(r.Visitor.LastResult))
+}
+
+func (r *Repl) parseSingle(input string) ast.Node {
+	nodes := r.parse(input)
+	if len(nodes) != 1 {
+		panic(fmt.Errorf("expected a single expression, got %d statements", len(nodes)))
+	}
+	return nodes[0]
+}
+
+// values are printed like literals of the language
+func FormatValue(value any) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// prints the tree of the node, a node per line with its position
+// and the fields of the node indented below it
+func DumpNode(out io.Writer, node ast.Node) {
+	dump(out, reflect.ValueOf(node), "", "")
+}
+
+func dump(out io.Writer, value reflect.Value, indent, label string) {
+	if value.Kind() == reflect.Interface || value.Kind() == reflect.Pointer {
+		if !value.IsNil() {
+			dump(out, value.Elem(), indent, label)
+		}
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		if position, ok := value.Interface().(shared.Position); ok {
+			fmt.Fprintf(out, "%s%s[%d, %d]\n", indent, label, position.Line, position.Column)
+			return
+		}
+		header := value.Type().Name()
+		if position := value.FieldByName("Position"); position.IsValid() {
+			p := position.Interface().(shared.Position)
+			header += fmt.Sprintf(" [%d, %d]", p.Line, p.Column)
+		}
+		fmt.Fprintf(out, "%s%s%s\n", indent, label, header)
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			// the span is left out like the position, it would repeat it
+			if !field.IsExported() || field.Name == "Position" || field.Name == "Location" || value.Field(i).IsZero() {
+				continue
+			}
+			dump(out, value.Field(i), indent+"  ", field.Name+": ")
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			dump(out, value.Index(i), indent, fmt.Sprintf("%s[%d]: ", strings.TrimSuffix(label, ": "), i))
+		}
+	case reflect.String:
+		fmt.Fprintf(out, "%s%s%q\n", indent, label, value.String())
+	default:
+		fmt.Fprintf(out, "%s%s%v\n", indent, label, value.Interface())
+	}
+}
@@ -0,0 +1,210 @@
+package repl
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tkom/diagnostics"
+)
+
+const MAX_RECURSION_DEPTH = 100
+
+// runs the lines in a new repl, returns what it printed and the reported errors
+func session(t *testing.T, lines ...string) (string, string) {
+	t.Helper()
+	var out, errors bytes.Buffer
+	r := NewRepl(strings.NewReader(strings.Join(lines, "\n")), &out, diagnostics.NewRenderer(&errors, false), MAX_RECURSION_DEPTH)
+	r.Run()
+	return out.String(), errors.String()
+}
+
+// output of the inputs without the prompts
+func results(out string) []string {
+	out = strings.ReplaceAll(out, CONTINUE_PROMPT, "")
+	lines := []string{}
+	for _, line := range strings.Split(out, PROMPT) {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func checkResults(t *testing.T, out string, expected []string) {
+	t.Helper()
+	got := results(out)
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestValuesArePrintedWithTheirType(t *testing.T) {
+	out, errors := session(t,
+		"1 + 2",
+		"1.5 * 2.0",
+		`"a" + "b"`,
+		"3 > 2 and false",
+		"7 as string",
+	)
+	if errors != "" {
+		t.Fatalf("unexpected errors:\n%s", errors)
+	}
+	checkResults(t, out, []string{`3 : int`, `3 : float`, `"ab" : string`, `false : bool`, `"7" : string`})
+}
+
+func TestStatePersistsBetweenInputs(t *testing.T) {
+	out, errors := session(t,
+		"int a := 2",
+		"square(x int) int {",
+		"    return x * x",
+		"}",
+		"a = square(a)",
+		"square(a) + a",
+	)
+	if errors != "" {
+		t.Fatalf("unexpected errors:\n%s", errors)
+	}
+	checkResults(t, out, []string{"defined square(x int) int", "20 : int"})
+}
+
+func TestMultiLineInput(t *testing.T) {
+	out, errors := session(t,
+		"int i := 0",
+		"while i < 3 {",
+		"    if i == 1 {",
+		"        i = i + 10",
+		"    }",
+		"    i = i + 1",
+		"}",
+		"i",
+	)
+	if errors != "" {
+		t.Fatalf("unexpected errors:\n%s", errors)
+	}
+	checkResults(t, out, []string{"12 : int"})
+	if strings.Count(out, CONTINUE_PROMPT) != 5 {
+		t.Errorf("expected 5 continuation prompts, got:\n%s", out)
+	}
+}
+
+func TestUnbalanced(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"main() {", true},
+		{"main() {\n}", false},
+		{"if a {\n    while b {\n    }", true},
+		{`"{"`, false},
+		{"# {\n1", false},
+		{"}", false},
+	}
+	for _, test := range tests {
+		if got := Unbalanced(test.input); got != test.expected {
+			t.Errorf("%q: expected %v, got %v", test.input, test.expected, got)
+		}
+	}
+}
+
+func TestErrorsKeepTheSession(t *testing.T) {
+	out, errors := session(t,
+		"int a := 1",
+		"a + b",
+		"a = a +",
+		"divide(x int) int {",
+		"    return x / 0",
+		"}",
+		"divide(a)",
+		"a",
+	)
+	checkResults(t, out, []string{"defined divide(x int) int", "1 : int"})
+	for _, expected := range []string{
+		"error[E0301]: undefined: b\n --> <repl>:2:5",
+		"error[E0215]: missing expression after: additive operator\n --> <repl>:3:8",
+		"error[E0330]: Division by zero\n --> <repl>:5:12\n  |\n5 |     return x / 0",
+	} {
+		if !strings.Contains(errors, expected) {
+			t.Errorf("expected error containing %q, got:\n%s", expected, errors)
+		}
+	}
+}
+
+func TestTypeCommand(t *testing.T) {
+	out, errors := session(t,
+		"greet(name string) {",
+		"}",
+		"int greet2 := 1",
+		":type greet",
+		":type greet2",
+		":type 1.0 / 2.0",
+		":type sqrt",
+		":type print",
+	)
+	if errors != "" {
+		t.Fatalf("unexpected errors:\n%s", errors)
+	}
+	checkResults(t, out, []string{"defined greet(name string)", "greet(name string)", "int", "float", "sqrt(float)", "print(...)"})
+}
+
+func TestAstCommand(t *testing.T) {
+	out, errors := session(t, ":ast -a + f(1)")
+	if errors != "" {
+		t.Fatalf("unexpected errors:\n%s", errors)
+	}
+	expected := "SumExpression [1, 4]\n" +
+		"  LeftExpression: NegateExpression [1, 1]\n" +
+		"    Expression: Identifier [1, 2]\n" +
+		"      Name: \"a\"\n" +
+		"  RightExpression: FunctionCall [1, 6]\n" +
+		"    Name: \"f\"\n" +
+		"    Arguments[0]: IntExpression [1, 8]\n" +
+		"      Value: 1"
+	checkResults(t, out, []string{expected})
+}
+
+func TestResetCommand(t *testing.T) {
+	out, errors := session(t,
+		"int a := 1",
+		"f() int {",
+		"    return 1",
+		"}",
+		":reset",
+		"int a := 2",
+		"a",
+		"f()",
+	)
+	checkResults(t, out, []string{"defined f() int", "2 : int"})
+	if !strings.Contains(errors, "error[E0302]: undefined function: f") {
+		t.Errorf("expected f to be forgotten, got:\n%s", errors)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+	run := func(lines ...string) string {
+		var out bytes.Buffer
+		r := NewRepl(strings.NewReader(strings.Join(lines, "\n")), &out, diagnostics.NewRenderer(&out, false), MAX_RECURSION_DEPTH)
+		r.HistoryFile = file
+		if err := r.LoadHistory(); err != nil {
+			t.Fatal(err)
+		}
+		r.Run()
+		return out.String()
+	}
+
+	run("int a := 1", "if a > 0 {", "    a = 2", "}", ":quit", "a")
+	out := run(":history")
+	expected := "1  int a := 1\n" +
+		"   2  if a > 0 {\n" +
+		"          a = 2\n" +
+		"      }\n" +
+		"   3  :quit\n" +
+		"   4  :history"
+	checkResults(t, out, []string{expected})
+}
+
+func TestUnknownCommand(t *testing.T) {
+	out, _ := session(t, ":what")
+	checkResults(t, out, []string{"unknown command: :what, type :help for the list of commands"})
+}
@@ -0,0 +1,39 @@
+package shared
+
+// part of the source from Start up to End, End is the position right after
+// the last character, offsets are in bytes from the start of the source
+type Span struct {
+	File        string
+	Start       Position
+	End         Position
+	StartOffset int
+	EndOffset   int
+}
+
+func NewSpan(file string, start, end Position, startOffset, endOffset int) Span {
+	return Span{
+		File:        file,
+		Start:       start,
+		End:         end,
+		StartOffset: startOffset,
+		EndOffset:   endOffset,
+	}
+}
+
+// span from the start of s to the end of end, a zero span is left out
+func (s Span) To(end Span) Span {
+	if s.IsZero() {
+		return end
+	}
+	if end.IsZero() {
+		return s
+	}
+	s.End = end.End
+	s.EndOffset = end.EndOffset
+	return s
+}
+
+// reports whether the span is unknown, e.g. of a node built without a source
+func (s Span) IsZero() bool {
+	return s.Start.Line == 0
+}
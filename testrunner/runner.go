@@ -0,0 +1,175 @@
+package testrunner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"tkom/ast"
+	"tkom/diagnostics"
+	"tkom/interpreter"
+	"tkom/lexer"
+	"tkom/parser"
+)
+
+const (
+	IDENTIFIER_LIMIT = 500
+	STRING_LIMIT     = 1000
+	INT_LIMIT        = math.MaxInt
+
+	// functions named with the prefix are tests
+	TEST_PREFIX = "test_"
+)
+
+// runs the test functions of programs, every test by a new interpreter,
+// so nothing a test does is seen by the next one
+type Runner struct {
+	Out     io.Writer
+	Emitter diagnostics.Emitter
+	// runs only the tests whose names match, nil runs all of them
+	Filter *regexp.Regexp
+	// prints every test run, with the output of the passing ones
+	Verbose bool
+	// stops a test running longer, 0 for no limit
+	Timeout           time.Duration
+	MaxRecursionDepth int
+
+	Passed int
+	Failed int
+	// sources whose tests could not run, because of syntax or resolver errors
+	Broken int
+}
+
+func NewRunner(out io.Writer, emitter diagnostics.Emitter, maxRecursionDepth int) *Runner {
+	return &Runner{
+		Out:               out,
+		Emitter:           emitter,
+		MaxRecursionDepth: maxRecursionDepth,
+	}
+}
+
+// test functions of the program in the order of the source
+func Tests(program *ast.Program) []*ast.FunctionDefinition {
+	tests := []*ast.FunctionDefinition{}
+	for name, fd := range program.Functions {
+		if strings.HasPrefix(name, TEST_PREFIX) {
+			tests = append(tests, fd)
+		}
+	}
+	sort.Slice(tests, func(i, j int) bool {
+		return tests[i].Position.Line < tests[j].Position.Line
+	})
+	return tests
+}
+
+// runs the tests of the source and prints the result of the source,
+// returns false when a test failed or the source could not run
+func (r *Runner) RunSource(source *diagnostics.Source) bool {
+	program, ok := r.load(source)
+	if !ok {
+		r.Broken++
+		fmt.Fprintf(r.Out, "FAIL\t%s\t[build failed]\n", source.Path)
+		return false
+	}
+
+	passed, failed := 0, 0
+	for _, test := range Tests(program) {
+		if r.Filter != nil && !r.Filter.MatchString(test.Name) {
+			continue
+		}
+		if r.runTest(program, test, source) {
+			passed++
+		} else {
+			failed++
+		}
+	}
+	r.Passed += passed
+	r.Failed += failed
+
+	switch {
+	case passed+failed == 0:
+		fmt.Fprintf(r.Out, "?\t%s\t[no tests to run]\n", source.Path)
+	case failed > 0:
+		fmt.Fprintf(r.Out, "FAIL\t%s\t%d passed, %d failed\n", source.Path, passed, failed)
+	default:
+		fmt.Fprintf(r.Out, "ok\t%s\t%d passed\n", source.Path, passed)
+	}
+	return failed == 0
+}
+
+// prints the numbers of the tests of all sources run
+func (r *Runner) WriteSummary() {
+	result := "PASS"
+	if r.Failed > 0 || r.Broken > 0 {
+		result = "FAIL"
+	}
+	fmt.Fprintf(r.Out, "%s: %d passed, %d failed", result, r.Passed, r.Failed)
+	if r.Broken > 0 {
+		fmt.Fprintf(r.Out, ", %d files could not run", r.Broken)
+	}
+	fmt.Fprintln(r.Out)
+}
+
+// parses and resolves the source, errors are reported with the emitter
+func (r *Runner) load(source *diagnostics.Source) (program *ast.Program, ok bool) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			r.Emitter.Emit(diagnostics.FromPanic(rec), source)
+			program, ok = nil, false
+		}
+	}()
+	scanner, _ := lexer.NewScanner(strings.NewReader(source.Text))
+	lex := lexer.NewLexer(scanner, IDENTIFIER_LIMIT, STRING_LIMIT, INT_LIMIT)
+	lex.File = source.Path
+	errorHandler := func(err error) {
+		panic(err)
+	}
+	lex.ErrorHandler = errorHandler
+	program = parser.NewParser(lex, errorHandler).ParseProgram()
+	interpreter.ResolveProgram(program)
+	return program, true
+}
+
+// what the test prints is shown when it fails, or always with Verbose
+func (r *Runner) runTest(program *ast.Program, test *ast.FunctionDefinition, source *diagnostics.Source) bool {
+	if r.Verbose {
+		fmt.Fprintf(r.Out, "=== RUN   %s\n", test.Name)
+	}
+	if len(test.Parameters) > 0 {
+		fmt.Fprintf(r.Out, "--- FAIL: %s (0.00s)\n", test.Name)
+		fmt.Fprintf(r.Out, "%s:%d: test functions take no parameters\n", source.Path, test.Position.Line)
+		return false
+	}
+
+	var output bytes.Buffer
+	interpreter.Output = &output
+	defer func() { interpreter.Output = nil }()
+
+	visitor := interpreter.NewCodeVisitor(r.MaxRecursionDepth)
+	if r.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+		defer cancel()
+		visitor.Budget = interpreter.NewBudget(ctx, 0)
+	}
+
+	start := time.Now()
+	err := interpreter.RunProgram(visitor, program, &ast.FunctionCall{Name: test.Name})
+	elapsed := time.Since(start).Seconds()
+
+	if err == nil {
+		if r.Verbose {
+			fmt.Fprintf(r.Out, "--- PASS: %s (%.2fs)\n", test.Name, elapsed)
+			r.Out.Write(output.Bytes())
+		}
+		return true
+	}
+	fmt.Fprintf(r.Out, "--- FAIL: %s (%.2fs)\n", test.Name, elapsed)
+	r.Out.Write(output.Bytes())
+	r.Emitter.Emit(diagnostics.FromPanic(err), source)
+	return false
+}
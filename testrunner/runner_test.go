@@ -0,0 +1,157 @@
+package testrunner
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"tkom/diagnostics"
+)
+
+const source = `add(a, b int) int {
+    return a + b
+}
+
+test_add() {
+    assert(add(2, 2) == 4)
+}
+
+test_add_fails() {
+    print("adding")
+    assert_eq(add(2, 2), 5)
+}
+
+test_assert_fails() {
+    assert(add(1, 1) > 2)
+}
+
+test_types() {
+    assert_eq("a" + "b", "ab")
+    assert_eq(1, 1.0)
+}
+
+helper() {
+    assert(false)
+}
+`
+
+func run(t *testing.T, runner func(r *Runner), texts ...string) (string, *Runner) {
+	t.Helper()
+	var out strings.Builder
+	r := NewRunner(&out, diagnostics.NewRenderer(&out, false), 200)
+	if runner != nil {
+		runner(r)
+	}
+	for i, text := range texts {
+		r.RunSource(diagnostics.NewSource([]string{"math.fl", "other.fl"}[i], text))
+	}
+	r.WriteSummary()
+	return out.String(), r
+}
+
+func expectContains(t *testing.T, out string, expected ...string) {
+	t.Helper()
+	for _, e := range expected {
+		if !strings.Contains(out, e) {
+			t.Errorf("expected %q in the output:\n%s", e, out)
+		}
+	}
+}
+
+func TestRunTests(t *testing.T) {
+	out, r := run(t, nil, source)
+	if r.Passed != 1 || r.Failed != 3 {
+		t.Errorf("expected 1 passed and 3 failed, got %d and %d", r.Passed, r.Failed)
+	}
+	expectContains(t, out,
+		"--- FAIL: test_add_fails",
+		// output of failing tests is shown
+		"adding\n",
+		"assertion failed: 4 (int) is not equal to 5 (int)",
+		"--> math.fl:11:5",
+		"--- FAIL: test_assert_fails",
+		"error[E0336]: assertion failed\n",
+		"--> math.fl:15:5",
+		`assertion failed: 1 (int) is not equal to 1 (float)`,
+		"FAIL\tmath.fl\t1 passed, 3 failed\n",
+		"FAIL: 1 passed, 3 failed\n",
+	)
+	if strings.Contains(out, "--- PASS") || strings.Contains(out, "helper") {
+		t.Errorf("expected only the failing tests reported:\n%s", out)
+	}
+	// tests run in the order of the source
+	if strings.Index(out, "test_add_fails") > strings.Index(out, "test_assert_fails") {
+		t.Errorf("expected the tests in the order of the source:\n%s", out)
+	}
+}
+
+func TestFilterAndVerbose(t *testing.T) {
+	out, r := run(t, func(r *Runner) {
+		r.Filter = regexp.MustCompile("^test_add")
+		r.Verbose = true
+	}, source)
+	if r.Passed != 1 || r.Failed != 1 {
+		t.Errorf("expected 1 passed and 1 failed, got %d and %d", r.Passed, r.Failed)
+	}
+	expectContains(t, out, "=== RUN   test_add\n", "--- PASS: test_add (", "=== RUN   test_add_fails\n")
+	if strings.Contains(out, "test_types") {
+		t.Errorf("expected test_types filtered out:\n%s", out)
+	}
+}
+
+// a test stopped by an error or a timeout does not affect the following ones
+func TestIsolation(t *testing.T) {
+	text := `loop() int {
+    return 1 + loop()
+}
+
+forever() {
+    while true {
+    }
+}
+
+test_recursion() {
+    int x := loop()
+}
+
+test_timeout() {
+    forever()
+}
+
+test_after() {
+    assert_eq(1 + 1, 2)
+}
+`
+	out, r := run(t, func(r *Runner) { r.Timeout = 50 * time.Millisecond }, text)
+	if r.Passed != 1 || r.Failed != 2 {
+		t.Errorf("expected 1 passed and 2 failed, got %d and %d:\n%s", r.Passed, r.Failed, out)
+	}
+	expectContains(t, out, "maximum recursion depth exceeded", "execution interrupted")
+}
+
+func TestBrokenSources(t *testing.T) {
+	out, r := run(t, nil, "main() {\n    int x := \n}\n", "main() {\n}\n")
+	if r.Broken != 1 {
+		t.Errorf("expected 1 broken source, got %d", r.Broken)
+	}
+	expectContains(t, out,
+		"FAIL\tmath.fl\t[build failed]\n",
+		"?\tother.fl\t[no tests to run]\n",
+		"FAIL: 0 passed, 0 failed, 1 files could not run\n",
+	)
+}
+
+func TestParameters(t *testing.T) {
+	out, r := run(t, nil, "test_square(x int) {\n    assert(x * x >= 0)\n}\n")
+	if r.Failed != 1 {
+		t.Errorf("expected the test failed, got %d", r.Failed)
+	}
+	expectContains(t, out, "math.fl:1: test functions take no parameters")
+}
+
+func TestPass(t *testing.T) {
+	out, _ := run(t, nil, "test_one() {\n    assert(true)\n}\n")
+	if out != "ok\tmath.fl\t1 passed\nPASS: 1 passed, 0 failed\n" {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
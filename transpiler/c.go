@@ -0,0 +1,741 @@
+package transpiler
+
+import (
+	_ "embed"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"tkom/ast"
+	"tkom/interpreter"
+	"tkom/shared"
+)
+
+//go:embed c_runtime.tmpl
+var cRuntime string
+
+// functions of the runtime standing for the builtins of the interpreter
+var cBuiltins = map[string]string{
+	"print":     "builtin_print",
+	"println":   "builtin_println",
+	"modulo":    "builtin_modulo",
+	"sqrt":      "builtin_sqrt",
+	"power":     "builtin_power",
+	"assert":    "builtin_assert",
+	"assert_eq": "builtin_assert_equal",
+}
+
+// translates a program to a single C99 file, values are tagged unions of
+// the int, float, bool and string types checked by the bundled runtime
+//
+// C leaves the order of evaluating arguments to the compiler, so every
+// expression with side effects is evaluated to a temporary in a statement
+// of its own, in the order the interpreter evaluates it
+type CGenerator struct {
+	out               strings.Builder
+	indent            int
+	sourceFile        string
+	maxRecursionDepth int
+	maxArguments      int
+	functions         map[string]*ast.FunctionDefinition
+	function          *ast.FunctionDefinition
+	scope             *goScope
+	scopes            int
+	temps             int
+	switchDepth       int
+	// expression statement whose value ends the switch arm
+	armValue ast.Node
+	// tail calls of the function jump back to its start
+	tailCalls int
+	// C expression of the last visited expression
+	value string
+	// last temporary declared and where its declaration starts
+	temp      string
+	tempStart int
+}
+
+func NewCGenerator(sourceFile string, maxRecursionDepth int) *CGenerator {
+	return &CGenerator{
+		sourceFile:        sourceFile,
+		maxRecursionDepth: maxRecursionDepth,
+	}
+}
+
+// generates C source of the program, the program should
+// be resolved with interpreter.ResolveProgram first
+func (g *CGenerator) Generate(program *ast.Program) ([]byte, error) {
+	g.out.Reset()
+	g.functions = program.Functions
+	g.maxArguments = 1
+
+	names := make([]string, 0, len(program.Functions))
+	for name := range program.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		program.Functions[name].Accept(g)
+	}
+	g.entry()
+	functions := g.out.String()
+
+	g.out.Reset()
+	g.write("/* Code generated by flux build --emit=c from %s. DO NOT EDIT. */\n\n", g.sourceFile)
+	g.header(names)
+	g.write("%s\n", cRuntime)
+	for _, name := range names {
+		g.write("%s;\n", g.signature(program.Functions[name]))
+	}
+	g.write("\n%s", functions)
+	return []byte(g.out.String()), nil
+}
+
+// constants of the program the runtime refers to
+func (g *CGenerator) header(names []string) {
+	g.write("#define SOURCE_FILE %s\n", cQuote(g.sourceFile))
+	g.write("#define MAX_RECURSION_DEPTH %d\n", g.maxRecursionDepth)
+	g.write("#define MAX_ARGUMENTS %d\n\n", g.maxArguments)
+
+	g.write("enum {\n")
+	for _, e := range runtimeErrors {
+		g.write("    %s = %d,\n", e.name, int(e.code))
+	}
+	g.write("};\n\n")
+	g.write("static const struct {\n    int code;\n    const char *text;\n} messages[] = {\n")
+	for _, e := range runtimeErrors {
+		g.write("    {%s, %s},\n", e.name, cQuote(e.code.Template()))
+	}
+	g.write("};\n\n")
+
+	// user functions take precedence over builtins of the same name
+	all := append([]string{}, names...)
+	for _, name := range sortedKeys(cBuiltins) {
+		if _, ok := g.functions[name]; !ok {
+			all = append(all, name)
+		}
+	}
+	g.write("enum {\n")
+	for _, name := range all {
+		g.write("    %s,\n", functionID(name))
+	}
+	g.write("    FUNCTION_COUNT\n};\n\n")
+	g.write("static const char *function_names[] = {\n")
+	for _, name := range all {
+		g.write("    %s,\n", cQuote(name))
+	}
+	g.write("};\n\n")
+}
+
+func (g *CGenerator) signature(fd *ast.FunctionDefinition) string {
+	parameters := "int call"
+	for i := range fd.Parameters {
+		parameters += fmt.Sprintf(", value a%d", i)
+	}
+	return fmt.Sprintf("static value %s(%s)", functionName(fd.Name), parameters)
+}
+
+// calls main with the arguments of the program
+func (g *CGenerator) entry() {
+	g.open("static void run(int count, value *values) {")
+	fd, ok := g.functions["main"]
+	if !ok {
+		g.line("undefined_function(at(0, 0), \"main\");")
+		g.close("}")
+		return
+	}
+	g.line("int call = enter(%s, at(0, 0), count, NULL);", functionID("main"))
+	g.open("if (count != %d) {", len(fd.Parameters))
+	g.line("wrong_arguments(call, %d, count);", len(fd.Parameters))
+	g.close("}")
+	arguments := "call"
+	for i := range fd.Parameters {
+		arguments += fmt.Sprintf(", values[%d]", i)
+	}
+	g.line("%s(%s);", functionName("main"), arguments)
+	g.close("}")
+}
+
+func (g *CGenerator) write(format string, args ...any) {
+	fmt.Fprintf(&g.out, format, args...)
+}
+
+func (g *CGenerator) line(format string, args ...any) {
+	g.out.WriteString(strings.Repeat("    ", g.indent))
+	g.write(format, args...)
+	g.out.WriteString("\n")
+}
+
+func (g *CGenerator) open(format string, args ...any) {
+	g.line(format, args...)
+	g.indent++
+}
+
+func (g *CGenerator) close(format string, args ...any) {
+	g.indent--
+	g.line(format, args...)
+}
+
+// evaluates the expression to a new temporary
+func (g *CGenerator) evaluate(expression string) string {
+	g.temps++
+	g.temp = fmt.Sprintf("t%d", g.temps)
+	g.tempStart = g.out.Len()
+	g.line("value %s = %s;", g.temp, expression)
+	return g.temp
+}
+
+// visits the expression and returns the C expression of its value,
+// which can be read any time later as it has no side effects
+func (g *CGenerator) expression(expression ast.Expression) string {
+	expression.Accept(g)
+	return g.value
+}
+
+func cPosition(position shared.Position) string {
+	return fmt.Sprintf("at(%d, %d)", position.Line, position.Column)
+}
+
+func cType(typeAnnotation shared.TypeAnnotation) string {
+	switch typeAnnotation {
+	case shared.INT:
+		return "T_INT"
+	case shared.FLOAT:
+		return "T_FLOAT"
+	case shared.BOOL:
+		return "T_BOOL"
+	case shared.STRING:
+		return "T_STRING"
+	default:
+		return "T_VOID"
+	}
+}
+
+// string literal of C, bytes out of printable ASCII are written as octal escapes
+func cQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\' || c == '?':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString("\\n")
+		case c == '\t':
+			b.WriteString("\\t")
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// opens a scope declaring C variables for every name declared in it
+func (g *CGenerator) openScope(names []string) {
+	g.scope = &goScope{parent: g.scope, id: g.scopes, names: map[string]bool{}}
+	g.scopes++
+
+	for _, name := range names {
+		if !g.scope.names[name] {
+			g.scope.names[name] = true
+			g.line("value %s = {0};", g.variable(g.scope, name))
+		}
+	}
+}
+
+func (g *CGenerator) closeScope() {
+	g.scope = g.scope.parent
+}
+
+func (g *CGenerator) variable(scope *goScope, name string) string {
+	return fmt.Sprintf("v%d_%s", scope.id, name)
+}
+
+// C variables of every scope that declares the name, the innermost first
+func (g *CGenerator) candidates(name string) []string {
+	variables := []string{}
+	for scope := g.scope; scope != nil; scope = scope.parent {
+		if scope.names[name] {
+			variables = append(variables, g.variable(scope, name))
+		}
+	}
+	return variables
+}
+
+// C variable of the identifier bound to a slot
+func (g *CGenerator) bound(slot *ast.Slot, name string) (string, bool) {
+	if slot == nil {
+		return "", false
+	}
+	scope := g.scope
+	for i := 0; i < slot.Depth && scope != nil; i++ {
+		scope = scope.parent
+	}
+	if scope == nil || !scope.names[name] {
+		return "", false
+	}
+	return g.variable(scope, name), true
+}
+
+func (g *CGenerator) binary(function string, left, right ast.Expression, position shared.Position, span shared.Span) {
+	l := g.expression(left)
+	r := g.expression(right)
+	g.value = g.evaluate(fmt.Sprintf("%s(%s, %s, %s)", function, l, r, cPosition(errorPosition(position, span))))
+}
+
+// statements leave values the way they leave LastResult in the interpreter,
+// only the value of the last statement of a switch arm is used
+func (g *CGenerator) statement(statement ast.Node) {
+	switch statement.(type) {
+	case *ast.Variable, *ast.Assignment, *ast.Block, *ast.IfStatement,
+		*ast.WhileStatement, *ast.SwitchStatement, *ast.ReturnStatement:
+		statement.Accept(g)
+		return
+	}
+	if fc, ok := statement.(*ast.FunctionCall); ok && statement == g.armValue && g.isTailCall(fc) {
+		g.tailCall(fc)
+		return
+	}
+
+	value := g.expression(statement.(ast.Expression))
+	if value == g.temp && g.out.Len() > g.tempStart {
+		// the temporary is not needed when the value is used right away
+		code := g.out.String()
+		value = strings.TrimSuffix(strings.TrimPrefix(code[g.tempStart:], strings.Repeat("    ", g.indent)+"value "+value+" = "), ";\n")
+		g.out.Reset()
+		g.out.WriteString(code[:g.tempStart])
+		g.temp = ""
+		if statement != g.armValue {
+			g.line("%s;", value)
+		}
+	}
+	if statement == g.armValue {
+		g.line("arm = %s;", value)
+	}
+}
+
+// returning from inside of a switch clears the switch flag,
+// like leaving every switch statement on the way out does
+func (g *CGenerator) returnValue(value string) {
+	if g.switchDepth > 0 {
+		g.line("switch_ended = false;")
+	}
+	g.line("return leave(result(%s, %s, %s));", value, cType(g.function.Type), cPosition(g.function.Position))
+}
+
+// the arm of a case that was met ends the switch, an arm
+// leaving a value returns it
+func (g *CGenerator) arm(output ast.Expression) {
+	armValue := g.armValue
+	g.armValue = interpreter.ArmValue(output)
+
+	g.line("value arm = none();")
+	g.statement(output)
+	g.open("if (arm.type != T_VOID) {")
+	g.returnValue("arm")
+	g.close("}")
+	g.line("switch_ended = true;")
+
+	g.armValue = armValue
+}
+
+func (g *CGenerator) VisitIntExpression(e *ast.IntExpression) {
+	g.value = fmt.Sprintf("int_value(%dLL)", e.Value)
+}
+
+func (g *CGenerator) VisitFloatExpression(e *ast.FloatExpression) {
+	g.value = fmt.Sprintf("float_value(%s)", strconv.FormatFloat(e.Value, 'g', -1, 64))
+}
+
+func (g *CGenerator) VisitStringExpression(e *ast.StringExpression) {
+	g.value = fmt.Sprintf("string_value(%s)", cQuote(e.Value))
+}
+
+func (g *CGenerator) VisitBoolExpression(e *ast.BoolExpression) {
+	g.value = fmt.Sprintf("bool_value(%t)", e.Value)
+}
+
+func (g *CGenerator) VisitIdentifier(e *ast.Identifier) {
+	if variable, ok := g.bound(e.Slot, e.Name); ok {
+		g.value = variable
+		return
+	}
+	candidates := g.candidates(e.Name)
+	if len(candidates) == 0 {
+		g.value = g.evaluate(fmt.Sprintf("undefined_variable(%s, %s)", cPosition(e.Position), cQuote(e.Name)))
+		return
+	}
+	g.value = g.evaluate(fmt.Sprintf("load(%s, %s, %d, %s)", cPosition(e.Position), cQuote(e.Name), len(candidates), strings.Join(candidates, ", ")))
+}
+
+func (g *CGenerator) VisitFunctionCall(fc *ast.FunctionCall) {
+	position := cPosition(fc.Position)
+	fd, user := g.functions[fc.Name]
+	builtin, isBuiltin := cBuiltins[fc.Name]
+
+	var target string
+	parameters := -1
+	switch {
+	case user:
+		target = functionName(fc.Name)
+		parameters = len(fd.Parameters)
+	case isBuiltin:
+		target = builtin
+		if count, ok := builtinParameters[fc.Name]; ok {
+			parameters = count
+		}
+	default:
+		g.value = g.evaluate(fmt.Sprintf("undefined_function(%s, %s)", position, cQuote(fc.Name)))
+		return
+	}
+
+	if parameters >= 0 && parameters != len(fc.Arguments) {
+		g.value = g.evaluate(fmt.Sprintf("wrong_arguments(enter(%s, %s, 0, NULL), %d, %d)", functionID(fc.Name), position, parameters, len(fc.Arguments)))
+		return
+	}
+	if len(fc.Arguments) > g.maxArguments {
+		g.maxArguments = len(fc.Arguments)
+	}
+
+	g.temps++
+	call := fmt.Sprintf("c%d", g.temps)
+	g.line("int %s = enter(%s, %s, %s);", call, functionID(fc.Name), position, argumentPositions(fc, parameters))
+
+	values := make([]string, len(fc.Arguments))
+	for i, arg := range fc.Arguments {
+		values[i] = g.expression(arg)
+	}
+
+	switch {
+	case parameters < 0 && len(values) == 0:
+		g.value = g.evaluate(fmt.Sprintf("%s(%s, 0, NULL)", target, call))
+	case parameters < 0:
+		g.value = g.evaluate(fmt.Sprintf("%s(%s, %d, (value[]){%s})", target, call, len(values), strings.Join(values, ", ")))
+	default:
+		g.value = g.evaluate(strings.Join(append([]string{target + "(" + call}, values...), ", ") + ")")
+	}
+}
+
+// count and positions of the arguments checked against the parameters
+func argumentPositions(fc *ast.FunctionCall, parameters int) string {
+	if parameters <= 0 {
+		return "0, NULL"
+	}
+	positions := make([]string, len(fc.Arguments))
+	for i, arg := range fc.Arguments {
+		positions[i] = cPosition(errorPosition(arg.GetPosition(), arg.GetSpan()))
+	}
+	return fmt.Sprintf("%d, (position[]){%s}", parameters, strings.Join(positions, ", "))
+}
+
+// a call of the function itself in tail position whose arguments fit
+func (g *CGenerator) isTailCall(fc *ast.FunctionCall) bool {
+	return fc.Tail && fc.Name == g.function.Name && len(fc.Arguments) == len(g.function.Parameters)
+}
+
+// the arguments are evaluated in the frame of the call, then the frame
+// replaces the running one and the function starts over with them
+func (g *CGenerator) tailCall(fc *ast.FunctionCall) {
+	g.tailCalls++
+	g.open("{")
+	g.temps++
+	call := fmt.Sprintf("c%d", g.temps)
+	g.line("int %s = enter_tail(%s, %s, %s);", call, functionID(fc.Name), cPosition(fc.Position), argumentPositions(fc, len(fc.Arguments)))
+
+	values := make([]string, len(fc.Arguments))
+	for i, arg := range fc.Arguments {
+		values[i] = g.expression(arg)
+	}
+	for i, value := range values {
+		g.line("a%d = %s;", i, value)
+	}
+	g.line("replace_caller(%s);", call)
+	if g.switchDepth > 0 {
+		g.line("switch_ended = false;")
+	}
+	g.line("goto start;")
+	g.close("}")
+}
+
+func (g *CGenerator) VisitVariable(variable *ast.Variable) {
+	name := g.variable(g.scope, variable.Name)
+	if slot, ok := g.bound(variable.Slot, variable.Name); ok {
+		name = slot
+	}
+	value := g.expression(variable.Value)
+	g.line("%s = declare(%s, %s, %s, %s, %s);", name, name, value, cType(variable.Type), cPosition(errorPosition(variable.Position, variable.Span)), cQuote(variable.Name))
+}
+
+func (g *CGenerator) VisitAssignement(assignment *ast.Assignment) {
+	identifier := assignment.Identifier
+	position := cPosition(identifier.Position)
+	value := g.expression(assignment.Value)
+
+	if variable, ok := g.bound(identifier.Slot, identifier.Name); ok {
+		g.line("%s = assign(%s, %s, %s);", variable, variable, value, position)
+		return
+	}
+
+	keyword := "if"
+	for _, variable := range g.candidates(identifier.Name) {
+		g.open("%s (%s.type != T_VOID) {", keyword, variable)
+		g.line("%s = assign(%s, %s, %s);", variable, variable, value, position)
+		g.indent--
+		keyword = "} else if"
+	}
+	if keyword == "if" {
+		g.line("undefined_variable(%s, %s);", position, cQuote(identifier.Name))
+		return
+	}
+	g.open("} else {")
+	g.line("undefined_variable(%s, %s);", position, cQuote(identifier.Name))
+	g.close("}")
+}
+
+func (g *CGenerator) VisitNegateExpression(e *ast.NegateExpression) {
+	value := g.expression(e.Expression)
+	g.value = g.evaluate(fmt.Sprintf("negate(%s, %s)", value, cPosition(errorPosition(e.Position, e.Span))))
+}
+
+func (g *CGenerator) VisitCastExpression(e *ast.CastExpression) {
+	value := g.expression(e.LeftExpression)
+	g.value = g.evaluate(fmt.Sprintf("cast(%s, %s, %s)", value, cType(e.TypeAnnotation), cPosition(errorPosition(e.Position, e.Span))))
+}
+
+func (g *CGenerator) VisitMultiplyExpression(e *ast.MultiplyExpression) {
+	g.binary("multiply", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *CGenerator) VisitDivideExpression(e *ast.DivideExpression) {
+	g.binary("divide", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *CGenerator) VisitSumExpression(e *ast.SumExpression) {
+	g.binary("sum", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *CGenerator) VisitSubstractExpression(e *ast.SubstractExpression) {
+	g.binary("subtract", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *CGenerator) VisitEqualsExpression(e *ast.EqualsExpression) {
+	g.binary("equals", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *CGenerator) VisitNotEqualsExpression(e *ast.NotEqualsExpression) {
+	g.binary("not_equals", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *CGenerator) VisitGreaterThanExpression(e *ast.GreaterThanExpression) {
+	g.binary("greater_than", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *CGenerator) VisitLessThanExpression(e *ast.LessThanExpression) {
+	g.binary("less_than", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *CGenerator) VisitGreaterOrEqualExpression(e *ast.GreaterOrEqualExpression) {
+	g.binary("greater_or_equal", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *CGenerator) VisitLessOrEqualExpression(e *ast.LessOrEqualExpression) {
+	g.binary("less_or_equal", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+// the right operand is evaluated only when the left one does not decide
+func (g *CGenerator) logical(and bool, left, right ast.Expression, position shared.Position, span shared.Span) {
+	position = errorPosition(position, span)
+	l := g.expression(left)
+	g.temps++
+	result := fmt.Sprintf("t%d", g.temps)
+	g.line("value %s;", result)
+
+	test := "boolean(%s, %s)"
+	if !and {
+		test = "!boolean(%s, %s)"
+	}
+	g.open("if ("+test+") {", l, cPosition(position))
+	r := g.expression(right)
+	g.line("%s = bool_value(boolean(%s, %s));", result, r, cPosition(position))
+	g.close("} else {")
+	g.indent++
+	g.line("%s = bool_value(%t);", result, !and)
+	g.close("}")
+	g.value = result
+}
+
+func (g *CGenerator) VisitAndExpression(e *ast.AndExpression) {
+	g.logical(true, e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *CGenerator) VisitOrExpression(e *ast.OrExpression) {
+	g.logical(false, e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+// blocks do not open a scope, their braces only keep the code readable
+func (g *CGenerator) VisitBlock(block *ast.Block) {
+	g.open("{")
+	g.body(block)
+	g.close("}")
+}
+
+func (g *CGenerator) body(block *ast.Block) {
+	for _, statement := range block.Statements {
+		g.statement(statement)
+	}
+}
+
+func (g *CGenerator) VisitIfStatement(ifStmt *ast.IfStatement) {
+	g.open("{")
+	g.openScope(interpreter.DeclaredNames(ifStmt.InstructionsBlock, ifStmt.ElseInstructionsBlock))
+
+	condition := g.expression(ifStmt.Condition)
+	g.open("if (condition(%s, %s, ERR_EXPECTED_BOOLEAN_EXPRESSION)) {", condition, cPosition(errorPosition(ifStmt.Condition.GetPosition(), ifStmt.Condition.GetSpan())))
+	g.body(ifStmt.InstructionsBlock)
+	if ifStmt.ElseInstructionsBlock != nil {
+		g.close("} else {")
+		g.indent++
+		g.body(ifStmt.ElseInstructionsBlock)
+	}
+	g.close("}")
+
+	g.closeScope()
+	g.close("}")
+}
+
+func (g *CGenerator) VisitReturnStatement(returnStmt *ast.ReturnStatement) {
+	if returnStmt.Value == nil {
+		g.returnValue("none()")
+		return
+	}
+	if fc, ok := returnStmt.Value.(*ast.FunctionCall); ok && g.isTailCall(fc) {
+		g.tailCall(fc)
+		return
+	}
+	g.returnValue(g.expression(returnStmt.Value))
+}
+
+func (g *CGenerator) VisitWhileStatement(whileStmt *ast.WhileStatement) {
+	g.open("{")
+	g.openScope(interpreter.DeclaredNames(whileStmt.InstructionsBlock))
+
+	g.open("while (1) {")
+	condition := g.expression(whileStmt.Condition)
+	g.open("if (!condition(%s, %s, ERR_INVALID_WHILE_CONDITION)) {", condition, cPosition(errorPosition(whileStmt.Condition.GetPosition(), whileStmt.Condition.GetSpan())))
+	g.line("break;")
+	g.close("}")
+	g.body(whileStmt.InstructionsBlock)
+	g.close("}")
+
+	g.closeScope()
+	g.close("}")
+}
+
+func (g *CGenerator) VisitSwitchStatement(s *ast.SwitchStatement) {
+	names := []string{}
+	for _, variable := range s.Variables {
+		names = append(names, variable.Name)
+	}
+	for _, c := range s.Cases {
+		names = append(names, interpreter.CaseDeclaredNames(c)...)
+	}
+
+	g.open("{")
+	g.openScope(names)
+	g.switchDepth++
+
+	for _, variable := range s.Variables {
+		variable.Accept(g)
+	}
+
+	var defaultCase *ast.DefaultSwitchCase
+	for _, c := range s.Cases {
+		switch caseStmt := c.(type) {
+		case *ast.SwitchCase:
+			caseStmt.Accept(g)
+		case *ast.DefaultSwitchCase:
+			if defaultCase != nil {
+				g.line("fail(ERR_MULTIPLE_DEFAULT_CASES, %s, 0);", cPosition(defaultCase.Position))
+			}
+			defaultCase = caseStmt
+		}
+	}
+
+	// run default only after cases did not get executed
+	if defaultCase != nil {
+		defaultCase.Accept(g)
+	}
+
+	g.switchDepth--
+	g.closeScope()
+	g.line("switch_ended = false;")
+	g.close("}")
+}
+
+func (g *CGenerator) VisitSwitchCase(sc *ast.SwitchCase) {
+	condition := g.expression(sc.Condition)
+	g.open("if (condition(%s, %s, ERR_EXPECTED_BOOLEAN_EXPRESSION)) {", condition, cPosition(errorPosition(sc.Condition.GetPosition(), sc.Condition.GetSpan())))
+	g.arm(sc.OutputExpression)
+	g.close("}")
+}
+
+func (g *CGenerator) VisitDefaultSwitchCase(dsc *ast.DefaultSwitchCase) {
+	g.open("if (!switch_ended) {")
+	g.arm(dsc.OutputExpression)
+	g.close("}")
+}
+
+func (g *CGenerator) VisitFunctionDefinition(fd *ast.FunctionDefinition) {
+	g.function = fd
+	g.scope = nil
+	g.scopes = 0
+	g.temps = 0
+	g.tailCalls = 0
+	if len(fd.Parameters) > g.maxArguments {
+		g.maxArguments = len(fd.Parameters)
+	}
+
+	parameters := make([]string, len(fd.Parameters))
+	names := []string{}
+	for i, param := range fd.Parameters {
+		parameters[i] = fmt.Sprintf("a%d", i)
+		names = append(names, param.Name)
+	}
+
+	g.open("%s {", g.signature(fd))
+	start := g.out.Len()
+	if len(parameters) > 0 {
+		g.line("evaluated(call, %d, (value[]){%s});", len(parameters), strings.Join(parameters, ", "))
+	} else {
+		g.line("evaluated(call, 0, NULL);")
+	}
+
+	g.openScope(append(names, interpreter.DeclaredNames(fd.Block)...))
+	for i, param := range fd.Parameters {
+		g.line("%s = parameter(call, %d, %s, %s);", g.variable(g.scope, param.Name), i, parameters[i], cType(param.Type))
+	}
+	g.body(fd.Block)
+	if fd.Type != shared.VOID {
+		g.line("return leave(missing_return(%s, %s));", cType(fd.Type), cPosition(fd.Position))
+	} else {
+		g.line("return leave(none());")
+	}
+	g.closeScope()
+	g.close("}\n")
+
+	// unused labels are reported by the compilers
+	if g.tailCalls > 0 {
+		code := g.out.String()
+		g.out.Reset()
+		g.out.WriteString(code[:start] + "start:\n" + code[start:])
+	}
+}
+
+func (g *CGenerator) VisitProgram(program *ast.Program) {}
+
+func (g *CGenerator) VisitEmbeddedFunction(ef *ast.EmbeddedFunction) {}
@@ -0,0 +1,109 @@
+package transpiler
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// generates the C code of the program and compiles it with the local cc
+func buildCProgram(t *testing.T, fileName, source string) string {
+	t.Helper()
+	code, err := NewCGenerator(fileName, MAX_RECURSION_DEPTH).Generate(parseProgram(t, source))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.c"), code, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("cc", "-std=c99", "-o", "program", "main.c", "-lm")
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("generated code does not compile: %v\n%s", err, output)
+	}
+	return filepath.Join(dir, "program")
+}
+
+func requireCC(t *testing.T) {
+	if _, err := exec.LookPath("cc"); err != nil {
+		t.Skip("cc not found")
+	}
+}
+
+func TestGeneratedCExamplesPrintTheSame(t *testing.T) {
+	requireCC(t)
+	files, err := filepath.Glob("../example_codes/*.fl")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no example codes found: %v", err)
+	}
+
+	for _, file := range files {
+		name := filepath.Base(file)
+		t.Run(name, func(t *testing.T) {
+			source, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			args := exampleArguments(file)
+			expected := interpret(t, string(source), args)
+
+			checkOutput(t, buildCProgram(t, name, string(source)), args, expected)
+		})
+	}
+}
+
+func TestGeneratedCRuntimeErrors(t *testing.T) {
+	requireCC(t)
+	for _, test := range runtimeErrorTests {
+		t.Run(test.name, func(t *testing.T) {
+			binary := buildCProgram(t, "test.fl", test.source)
+			checkRuntimeError(t, binary, test.output, test.expected)
+		})
+	}
+}
+
+// values are printed the way go prints them in the interpreter
+func TestGeneratedCFormatsValues(t *testing.T) {
+	requireCC(t)
+	source := `
+main() {
+    print(100000000.0, " ", 1234567.0, " ", 123456.0, " ", 0.0001, " ", 0.00001, " ", 1.0 / 3.0)
+    print(0.5 + "x", " ", "y" + 12.25, " ", sqrt(-1.0), " ", "3.5" as float, " ", "-7" as int)
+    print(9223372036854775807 + 1, " ", -7 / 2, " ", 2.7 as int, " ", "x" as bool, " ", 1 == 1)
+    println(1, 2.5, "three")
+}
+`
+	expected := interpret(t, source, nil)
+	if !strings.Contains(expected, "1e+08 1.234567e+06 123456 0.0001 1e-05") {
+		t.Fatalf("unexpected output of the interpreter: %s", expected)
+	}
+	checkOutput(t, buildCProgram(t, "test.fl", source), nil, expected)
+}
+
+func TestGeneratedCAssertions(t *testing.T) {
+	requireCC(t)
+	checkOutput(t, buildCProgram(t, "test.fl", assertionsSource), nil, interpret(t, assertionsSource, nil))
+}
+
+func TestCQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"plain", `"plain"`},
+		{"a\"b\\c", `"a\"b\\c"`},
+		{"line\nbreak\ttab", `"line\nbreak\ttab"`},
+		{"what??/", `"what\?\?/"`},
+		{"zażółć", `"za\305\274\303\263\305\202\304\207"`},
+	}
+
+	for _, test := range tests {
+		if actual := cQuote(test.input); actual != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, actual)
+		}
+	}
+}
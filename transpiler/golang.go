@@ -0,0 +1,640 @@
+package transpiler
+
+import (
+	_ "embed"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"tkom/ast"
+	"tkom/interpreter"
+	"tkom/shared"
+)
+
+//go:embed go_runtime.tmpl
+var goRuntime string
+
+// functions of the runtime standing for the builtins of the interpreter
+var goBuiltins = map[string]string{
+	"print":     "builtinPrint",
+	"println":   "builtinPrintln",
+	"modulo":    "builtinModulo",
+	"sqrt":      "builtinSqrt",
+	"power":     "builtinPower",
+	"assert":    "builtinAssert",
+	"assert_eq": "builtinAssertEqual",
+}
+
+// translates a program to a single go file of package main, like the
+// interpreter it walks the tree as a visitor
+//
+// every value of the program is kept as 'any' and every operator is a call
+// of the bundled runtime, which checks types the way the interpreter does
+//
+// variables bound by the resolver become go variables of the scope they are
+// declared in, the other ones are looked up in every scope declaring them
+type GoGenerator struct {
+	out               strings.Builder
+	sourceFile        string
+	maxRecursionDepth int
+	functions         map[string]*ast.FunctionDefinition
+	function          *ast.FunctionDefinition
+	scope             *goScope
+	scopes            int
+	switchDepth       int
+	// expression statement whose value ends the switch arm
+	armValue ast.Node
+	// tail calls of the function jump back to its start
+	tailCalls int
+}
+
+type goScope struct {
+	parent *goScope
+	id     int
+	names  map[string]bool
+}
+
+func NewGoGenerator(sourceFile string, maxRecursionDepth int) *GoGenerator {
+	return &GoGenerator{
+		sourceFile:        sourceFile,
+		maxRecursionDepth: maxRecursionDepth,
+	}
+}
+
+// generates formatted go source of the program, the program should
+// be resolved with interpreter.ResolveProgram first
+func (g *GoGenerator) Generate(program *ast.Program) ([]byte, error) {
+	g.out.Reset()
+	g.functions = program.Functions
+
+	names := make([]string, 0, len(program.Functions))
+	for name := range program.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	g.write("// Code generated by flux build --emit=go from %s. DO NOT EDIT.\n\n", g.sourceFile)
+	g.write("package main\n\n")
+	g.write("%s\n", goRuntime)
+	g.header(names)
+
+	for _, name := range names {
+		program.Functions[name].Accept(g)
+	}
+	g.entry()
+
+	source, err := format.Source([]byte(g.out.String()))
+	if err != nil {
+		return nil, fmt.Errorf("generated go code is invalid: %v", err)
+	}
+	return source, nil
+}
+
+// constants of the program the runtime refers to
+func (g *GoGenerator) header(names []string) {
+	g.write("const sourceFile = %s\n", strconv.Quote(g.sourceFile))
+	g.write("const maxRecursionDepth = %d\n\n", g.maxRecursionDepth)
+
+	g.write("const (\n")
+	for _, e := range runtimeErrors {
+		g.write("%s = %d\n", e.name, int(e.code))
+	}
+	g.write(")\n\n")
+	g.write("var messages = map[int]string{\n")
+	for _, e := range runtimeErrors {
+		g.write("%s: %s,\n", e.name, strconv.Quote(e.code.Template()))
+	}
+	g.write("}\n\n")
+
+	// user functions take precedence over builtins of the same name
+	all := append([]string{}, names...)
+	for _, name := range sortedKeys(goBuiltins) {
+		if _, ok := g.functions[name]; !ok {
+			all = append(all, name)
+		}
+	}
+	g.write("const (\n")
+	for i, name := range all {
+		g.write("%s = %d\n", functionID(name), i)
+	}
+	g.write(")\n\n")
+	g.write("var functionNames = []string{\n")
+	for _, name := range all {
+		g.write("%s,\n", strconv.Quote(name))
+	}
+	g.write("}\n\n")
+}
+
+// calls main with the arguments of the program
+func (g *GoGenerator) entry() {
+	g.write("func run(values []any) {\n")
+	fd, ok := g.functions["main"]
+	if !ok {
+		g.write("undefinedFunction(position{}, \"main\")\n")
+		g.write("}\n")
+		return
+	}
+	g.write("call := enter(%s, position{}, make([]position, len(values))...)\n", functionID("main"))
+	g.write("if len(values) != %d {\n", len(fd.Parameters))
+	g.write("wrongArguments(call, %d, len(values))\n", len(fd.Parameters))
+	g.write("}\n")
+	g.write("%s(call", functionName("main"))
+	for i := range fd.Parameters {
+		g.write(", values[%d]", i)
+	}
+	g.write(")\n")
+	g.write("}\n")
+}
+
+func (g *GoGenerator) write(format string, args ...any) {
+	fmt.Fprintf(&g.out, format, args...)
+}
+
+func goPosition(position shared.Position) string {
+	return fmt.Sprintf("position{%d, %d}", position.Line, position.Column)
+}
+
+func goType(typeAnnotation shared.TypeAnnotation) string {
+	return strconv.Quote(typeAnnotation.String())
+}
+
+// opens a scope declaring go variables for every name declared in it
+func (g *GoGenerator) openScope(names []string) {
+	g.scope = &goScope{parent: g.scope, id: g.scopes, names: map[string]bool{}}
+	g.scopes++
+
+	variables := []string{}
+	for _, name := range names {
+		if !g.scope.names[name] {
+			g.scope.names[name] = true
+			variables = append(variables, g.variable(g.scope, name))
+		}
+	}
+	if len(variables) > 0 {
+		g.write("var %s any\n", strings.Join(variables, ", "))
+		g.write("%s = %s\n", strings.Repeat("_, ", len(variables)-1)+"_", strings.Join(variables, ", "))
+	}
+}
+
+func (g *GoGenerator) closeScope() {
+	g.scope = g.scope.parent
+}
+
+func (g *GoGenerator) variable(scope *goScope, name string) string {
+	return fmt.Sprintf("v%d_%s", scope.id, name)
+}
+
+// go variables of every scope that declares the name, the innermost first
+func (g *GoGenerator) candidates(name string) []string {
+	variables := []string{}
+	for scope := g.scope; scope != nil; scope = scope.parent {
+		if scope.names[name] {
+			variables = append(variables, g.variable(scope, name))
+		}
+	}
+	return variables
+}
+
+// go variable of the identifier bound to a slot
+func (g *GoGenerator) bound(slot *ast.Slot, name string) (string, bool) {
+	if slot == nil {
+		return "", false
+	}
+	scope := g.scope
+	for i := 0; i < slot.Depth && scope != nil; i++ {
+		scope = scope.parent
+	}
+	if scope == nil || !scope.names[name] {
+		return "", false
+	}
+	return g.variable(scope, name), true
+}
+
+func (g *GoGenerator) binary(function string, left, right ast.Expression, position shared.Position, span shared.Span) {
+	g.write("%s(", function)
+	left.Accept(g)
+	g.write(", ")
+	right.Accept(g)
+	g.write(", %s)", goPosition(errorPosition(position, span)))
+}
+
+// statements leave values the way they leave LastResult in the interpreter,
+// only the value of the last statement of a switch arm is used
+func (g *GoGenerator) statement(statement ast.Node) {
+	switch statement.(type) {
+	case *ast.Variable, *ast.Assignment, *ast.Block, *ast.IfStatement,
+		*ast.WhileStatement, *ast.SwitchStatement, *ast.ReturnStatement:
+		statement.Accept(g)
+	default:
+		if fc, ok := statement.(*ast.FunctionCall); ok && statement == g.armValue && g.isTailCall(fc) {
+			g.tailCall(fc)
+			return
+		}
+		if statement == g.armValue {
+			g.write("value = ")
+		} else {
+			g.write("_ = ")
+		}
+		statement.Accept(g)
+		g.write("\n")
+	}
+}
+
+// returning from inside of a switch clears the switch flag,
+// like leaving every switch statement on the way out does
+func (g *GoGenerator) returnValue(value string) {
+	if g.switchDepth > 0 {
+		g.write("switchEnded = false\n")
+	}
+	g.write("return result(%s, %s, %s)\n", value, goType(g.function.Type), goPosition(g.function.Position))
+}
+
+// the arm of a case that was met ends the switch, an arm
+// leaving a value returns it
+func (g *GoGenerator) arm(output ast.Expression) {
+	armValue := g.armValue
+	g.armValue = interpreter.ArmValue(output)
+
+	g.write("var value any\n")
+	g.statement(output)
+	g.write("if value != nil {\n")
+	g.returnValue("value")
+	g.write("}\n")
+	g.write("switchEnded = true\n")
+
+	g.armValue = armValue
+}
+
+// a call of the function itself in tail position whose arguments fit
+func (g *GoGenerator) isTailCall(fc *ast.FunctionCall) bool {
+	return fc.Tail && fc.Name == g.function.Name && len(fc.Arguments) == len(g.function.Parameters)
+}
+
+// the arguments are evaluated in the frame of the call, then the frame
+// replaces the running one and the function starts over with them
+func (g *GoGenerator) tailCall(fc *ast.FunctionCall) {
+	g.tailCalls++
+	g.write("{\ntail := enterTail(%s, %s", functionID(fc.Name), goPosition(fc.Position))
+	for _, arg := range fc.Arguments {
+		g.write(", %s", goPosition(errorPosition(arg.GetPosition(), arg.GetSpan())))
+	}
+	g.write(")\n")
+	if len(fc.Arguments) > 0 {
+		parameters := make([]string, len(fc.Arguments))
+		values := make([]string, len(fc.Arguments))
+		g.write("values := []any{")
+		for i, arg := range fc.Arguments {
+			if i > 0 {
+				g.write(", ")
+			}
+			arg.Accept(g)
+			parameters[i] = fmt.Sprintf("a%d", i)
+			values[i] = fmt.Sprintf("values[%d]", i)
+		}
+		g.write("}\n")
+		g.write("%s = %s\n", strings.Join(parameters, ", "), strings.Join(values, ", "))
+	}
+	g.write("replaceCaller(tail)\n")
+	if g.switchDepth > 0 {
+		g.write("switchEnded = false\n")
+	}
+	g.write("goto start\n}\n")
+}
+
+func (g *GoGenerator) VisitIntExpression(e *ast.IntExpression) {
+	g.write("%d", e.Value)
+}
+
+func (g *GoGenerator) VisitFloatExpression(e *ast.FloatExpression) {
+	g.write("float64(%s)", strconv.FormatFloat(e.Value, 'g', -1, 64))
+}
+
+func (g *GoGenerator) VisitStringExpression(e *ast.StringExpression) {
+	g.write("%s", strconv.Quote(e.Value))
+}
+
+func (g *GoGenerator) VisitBoolExpression(e *ast.BoolExpression) {
+	g.write("%t", e.Value)
+}
+
+func (g *GoGenerator) VisitIdentifier(e *ast.Identifier) {
+	if variable, ok := g.bound(e.Slot, e.Name); ok {
+		g.write("%s", variable)
+		return
+	}
+	candidates := g.candidates(e.Name)
+	if len(candidates) == 0 {
+		g.write("undefinedVariable(%s, %s)", goPosition(e.Position), strconv.Quote(e.Name))
+		return
+	}
+	g.write("load(%s, %s, %s)", goPosition(e.Position), strconv.Quote(e.Name), strings.Join(candidates, ", "))
+}
+
+func (g *GoGenerator) VisitFunctionCall(fc *ast.FunctionCall) {
+	position := goPosition(fc.Position)
+	fd, user := g.functions[fc.Name]
+	builtin, isBuiltin := goBuiltins[fc.Name]
+
+	var target string
+	parameters := -1
+	switch {
+	case user:
+		target = functionName(fc.Name)
+		parameters = len(fd.Parameters)
+	case isBuiltin:
+		target = builtin
+		if count, ok := builtinParameters[fc.Name]; ok {
+			parameters = count
+		}
+	default:
+		g.write("undefinedFunction(%s, %s)", position, strconv.Quote(fc.Name))
+		return
+	}
+
+	if parameters >= 0 && parameters != len(fc.Arguments) {
+		g.write("wrongArguments(enter(%s, %s), %d, %d)", functionID(fc.Name), position, parameters, len(fc.Arguments))
+		return
+	}
+
+	g.write("%s(enter(%s, %s", target, functionID(fc.Name), position)
+	if parameters >= 0 {
+		for _, arg := range fc.Arguments {
+			g.write(", %s", goPosition(errorPosition(arg.GetPosition(), arg.GetSpan())))
+		}
+	}
+	g.write(")")
+	for _, arg := range fc.Arguments {
+		g.write(", ")
+		arg.Accept(g)
+	}
+	g.write(")")
+}
+
+func (g *GoGenerator) VisitVariable(variable *ast.Variable) {
+	name := g.variable(g.scope, variable.Name)
+	if slot, ok := g.bound(variable.Slot, variable.Name); ok {
+		name = slot
+	}
+	g.write("%s = declare(%s, ", name, name)
+	variable.Value.Accept(g)
+	g.write(", %s, %s, %s)\n", goType(variable.Type), goPosition(errorPosition(variable.Position, variable.Span)), strconv.Quote(variable.Name))
+}
+
+func (g *GoGenerator) VisitAssignement(assignment *ast.Assignment) {
+	identifier := assignment.Identifier
+	position := goPosition(identifier.Position)
+
+	if variable, ok := g.bound(identifier.Slot, identifier.Name); ok {
+		g.write("%s = assign(%s, ", variable, variable)
+		assignment.Value.Accept(g)
+		g.write(", %s)\n", position)
+		return
+	}
+
+	g.write("{\nassigned := any(")
+	assignment.Value.Accept(g)
+	g.write(")\n")
+	for _, variable := range g.candidates(identifier.Name) {
+		g.write("if %s != nil {\n%s = assign(%s, assigned, %s)\n} else ", variable, variable, variable, position)
+	}
+	g.write("{\nundefinedVariable(%s, %s)\n}\n}\n", position, strconv.Quote(identifier.Name))
+}
+
+func (g *GoGenerator) VisitNegateExpression(e *ast.NegateExpression) {
+	g.write("negate(")
+	e.Expression.Accept(g)
+	g.write(", %s)", goPosition(errorPosition(e.Position, e.Span)))
+}
+
+func (g *GoGenerator) VisitCastExpression(e *ast.CastExpression) {
+	g.write("cast(")
+	e.LeftExpression.Accept(g)
+	g.write(", %s, %s)", goType(e.TypeAnnotation), goPosition(errorPosition(e.Position, e.Span)))
+}
+
+func (g *GoGenerator) VisitMultiplyExpression(e *ast.MultiplyExpression) {
+	g.binary("multiply", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *GoGenerator) VisitDivideExpression(e *ast.DivideExpression) {
+	g.binary("divide", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *GoGenerator) VisitSumExpression(e *ast.SumExpression) {
+	g.binary("sum", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *GoGenerator) VisitSubstractExpression(e *ast.SubstractExpression) {
+	g.binary("subtract", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *GoGenerator) VisitEqualsExpression(e *ast.EqualsExpression) {
+	g.binary("equals", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *GoGenerator) VisitNotEqualsExpression(e *ast.NotEqualsExpression) {
+	g.binary("notEquals", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *GoGenerator) VisitGreaterThanExpression(e *ast.GreaterThanExpression) {
+	g.binary("greaterThan", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *GoGenerator) VisitLessThanExpression(e *ast.LessThanExpression) {
+	g.binary("lessThan", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *GoGenerator) VisitGreaterOrEqualExpression(e *ast.GreaterOrEqualExpression) {
+	g.binary("greaterOrEqual", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+func (g *GoGenerator) VisitLessOrEqualExpression(e *ast.LessOrEqualExpression) {
+	g.binary("lessOrEqual", e.LeftExpression, e.RightExpression, e.Position, e.Span)
+}
+
+// the right operand is evaluated only when the left one does not decide
+func (g *GoGenerator) VisitAndExpression(e *ast.AndExpression) {
+	position := goPosition(errorPosition(e.Position, e.Span))
+	g.write("any(boolean(")
+	e.LeftExpression.Accept(g)
+	g.write(", %s) && boolean(", position)
+	e.RightExpression.Accept(g)
+	g.write(", %s))", position)
+}
+
+func (g *GoGenerator) VisitOrExpression(e *ast.OrExpression) {
+	position := goPosition(errorPosition(e.Position, e.Span))
+	g.write("any(boolean(")
+	e.LeftExpression.Accept(g)
+	g.write(", %s) || boolean(", position)
+	e.RightExpression.Accept(g)
+	g.write(", %s))", position)
+}
+
+// blocks do not open a scope, their braces only keep the code readable
+func (g *GoGenerator) VisitBlock(block *ast.Block) {
+	g.write("{\n")
+	for _, statement := range block.Statements {
+		g.statement(statement)
+	}
+	g.write("}\n")
+}
+
+func (g *GoGenerator) VisitIfStatement(ifStmt *ast.IfStatement) {
+	g.write("{\n")
+	g.openScope(interpreter.DeclaredNames(ifStmt.InstructionsBlock, ifStmt.ElseInstructionsBlock))
+
+	g.write("if condition(")
+	ifStmt.Condition.Accept(g)
+	g.write(", %s, %s) ", goPosition(errorPosition(ifStmt.Condition.GetPosition(), ifStmt.Condition.GetSpan())), "ERR_EXPECTED_BOOLEAN_EXPRESSION")
+	ifStmt.InstructionsBlock.Accept(g)
+	if ifStmt.ElseInstructionsBlock != nil {
+		g.trimNewline()
+		g.write(" else ")
+		ifStmt.ElseInstructionsBlock.Accept(g)
+	}
+
+	g.closeScope()
+	g.write("}\n")
+}
+
+// joins 'else' with the closing brace of the block before it
+func (g *GoGenerator) trimNewline() {
+	code := strings.TrimSuffix(g.out.String(), "\n")
+	g.out.Reset()
+	g.out.WriteString(code)
+}
+
+func (g *GoGenerator) VisitReturnStatement(returnStmt *ast.ReturnStatement) {
+	if returnStmt.Value == nil {
+		g.returnValue("nil")
+		return
+	}
+	if fc, ok := returnStmt.Value.(*ast.FunctionCall); ok && g.isTailCall(fc) {
+		g.tailCall(fc)
+		return
+	}
+	g.write("{\nreturned := any(")
+	returnStmt.Value.Accept(g)
+	g.write(")\n")
+	g.returnValue("returned")
+	g.write("}\n")
+}
+
+func (g *GoGenerator) VisitWhileStatement(whileStmt *ast.WhileStatement) {
+	g.write("{\n")
+	g.openScope(interpreter.DeclaredNames(whileStmt.InstructionsBlock))
+
+	g.write("for condition(")
+	whileStmt.Condition.Accept(g)
+	g.write(", %s, %s) ", goPosition(errorPosition(whileStmt.Condition.GetPosition(), whileStmt.Condition.GetSpan())), "ERR_INVALID_WHILE_CONDITION")
+	whileStmt.InstructionsBlock.Accept(g)
+
+	g.closeScope()
+	g.write("}\n")
+}
+
+func (g *GoGenerator) VisitSwitchStatement(s *ast.SwitchStatement) {
+	names := []string{}
+	for _, variable := range s.Variables {
+		names = append(names, variable.Name)
+	}
+	for _, c := range s.Cases {
+		names = append(names, interpreter.CaseDeclaredNames(c)...)
+	}
+
+	g.write("{\n")
+	g.openScope(names)
+	g.switchDepth++
+
+	for _, variable := range s.Variables {
+		variable.Accept(g)
+	}
+
+	var defaultCase *ast.DefaultSwitchCase
+	for _, c := range s.Cases {
+		switch caseStmt := c.(type) {
+		case *ast.SwitchCase:
+			caseStmt.Accept(g)
+		case *ast.DefaultSwitchCase:
+			if defaultCase != nil {
+				g.write("fail(ERR_MULTIPLE_DEFAULT_CASES, %s)\n", goPosition(defaultCase.Position))
+			}
+			defaultCase = caseStmt
+		}
+	}
+
+	// run default only after cases did not get executed
+	if defaultCase != nil {
+		defaultCase.Accept(g)
+	}
+
+	g.switchDepth--
+	g.closeScope()
+	g.write("switchEnded = false\n")
+	g.write("}\n")
+}
+
+func (g *GoGenerator) VisitSwitchCase(sc *ast.SwitchCase) {
+	g.write("if condition(")
+	sc.Condition.Accept(g)
+	g.write(", %s, ERR_EXPECTED_BOOLEAN_EXPRESSION) {\n", goPosition(errorPosition(sc.Condition.GetPosition(), sc.Condition.GetSpan())))
+	g.arm(sc.OutputExpression)
+	g.write("}\n")
+}
+
+func (g *GoGenerator) VisitDefaultSwitchCase(dsc *ast.DefaultSwitchCase) {
+	g.write("if !switchEnded {\n")
+	g.arm(dsc.OutputExpression)
+	g.write("}\n")
+}
+
+func (g *GoGenerator) VisitFunctionDefinition(fd *ast.FunctionDefinition) {
+	g.function = fd
+	g.scope = nil
+	g.scopes = 0
+	g.tailCalls = 0
+
+	parameters := make([]string, len(fd.Parameters))
+	names := []string{}
+	for i, param := range fd.Parameters {
+		parameters[i] = fmt.Sprintf("a%d", i)
+		names = append(names, param.Name)
+	}
+
+	g.write("func %s(call *frame", functionName(fd.Name))
+	for _, parameter := range parameters {
+		g.write(", %s any", parameter)
+	}
+	g.write(") any {\n")
+	g.write("defer leave()\n")
+	start := g.out.Len()
+	g.write("call.values = []any{%s}\n", strings.Join(parameters, ", "))
+
+	g.openScope(append(names, interpreter.DeclaredNames(fd.Block)...))
+	for i, param := range fd.Parameters {
+		g.write("%s = parameter(call, %d, %s, %s)\n", g.variable(g.scope, param.Name), i, parameters[i], goType(param.Type))
+	}
+	for _, statement := range fd.Block.Statements {
+		g.statement(statement)
+	}
+	if fd.Type != shared.VOID {
+		g.write("return missingReturn(%s, %s)\n", goType(fd.Type), goPosition(fd.Position))
+	} else {
+		g.write("return nil\n")
+	}
+	g.closeScope()
+	g.write("}\n\n")
+
+	// go does not allow unused labels
+	if g.tailCalls > 0 {
+		code := g.out.String()
+		g.out.Reset()
+		g.out.WriteString(code[:start] + "start:\n" + code[start:])
+	}
+}
+
+func (g *GoGenerator) VisitProgram(program *ast.Program) {}
+
+func (g *GoGenerator) VisitEmbeddedFunction(ef *ast.EmbeddedFunction) {}
@@ -0,0 +1,282 @@
+package transpiler
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"tkom/ast"
+	"tkom/interpreter"
+	"tkom/lexer"
+	"tkom/parser"
+)
+
+const MAX_RECURSION_DEPTH = 200
+
+// arguments the example program is run with, read from the .args file next to it
+func exampleArguments(file string) []string {
+	data, err := os.ReadFile(strings.TrimSuffix(file, ".fl") + ".args")
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(data))
+}
+
+func parseProgram(t *testing.T, source string) *ast.Program {
+	t.Helper()
+	scanner, _ := lexer.NewScanner(strings.NewReader(source))
+	lex := lexer.NewLexer(scanner, 500, 1000, math.MaxInt)
+	errorHandler := func(err error) {
+		t.Fatalf("unexpected syntax error: %v", err)
+	}
+	lex.ErrorHandler = errorHandler
+	program := parser.NewParser(lex, errorHandler).ParseProgram()
+	interpreter.ResolveProgram(program)
+	return program
+}
+
+// generates the go code of the program and builds it into a binary
+func buildProgram(t *testing.T, fileName, source string) string {
+	t.Helper()
+	code, err := NewGoGenerator(fileName, MAX_RECURSION_DEPTH).Generate(parseProgram(t, source))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), code, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", "build", "-o", "program", "main.go")
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("generated code does not build: %v\n%s", err, output)
+	}
+	return filepath.Join(dir, "program")
+}
+
+// runs the program with the interpreter and returns what it printed
+func interpret(t *testing.T, source string, args []string) string {
+	t.Helper()
+	program := parseProgram(t, source)
+	arguments := make([]ast.Expression, len(args))
+	for i, arg := range args {
+		if value, err := strconv.Atoi(arg); err == nil {
+			arguments[i] = &ast.IntExpression{Value: value}
+		} else {
+			arguments[i] = &ast.StringExpression{Value: arg}
+		}
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	output := make(chan string)
+	go func() {
+		text, _ := io.ReadAll(reader)
+		output <- string(text)
+	}()
+
+	visitor := interpreter.NewCodeVisitor(MAX_RECURSION_DEPTH)
+	visitor.Run(program, &ast.FunctionCall{Name: "main", Arguments: arguments})
+
+	writer.Close()
+	os.Stdout = stdout
+	return <-output
+}
+
+// runs the binary expecting it to print the output
+func checkOutput(t *testing.T, binary string, args []string, expected string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(binary, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stderr.String())
+	}
+	if stdout.String() != expected {
+		t.Errorf("expected output:\n%s\ngot:\n%s", expected, stdout.String())
+	}
+}
+
+func requireGo(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+}
+
+func TestGeneratedExamplesPrintTheSame(t *testing.T) {
+	requireGo(t)
+	files, err := filepath.Glob("../example_codes/*.fl")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no example codes found: %v", err)
+	}
+
+	for _, file := range files {
+		name := filepath.Base(file)
+		t.Run(name, func(t *testing.T) {
+			source, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			args := exampleArguments(file)
+			expected := interpret(t, string(source), args)
+
+			checkOutput(t, buildProgram(t, name, string(source)), args, expected)
+		})
+	}
+}
+
+// programs failing at runtime, with what they print before the error
+var runtimeErrorTests = []struct {
+	name     string
+	source   string
+	output   string
+	expected []string
+}{
+	{
+		"division by zero",
+		"divide(a int) int {\n    return a / 0\n}\n\nmain() {\n    print(\"start\")\n    print(divide(3))\n}\n",
+		"start\n",
+		[]string{
+			"error[E0330]: Division by zero",
+			"--> test.fl:2:12",
+			"divide(3) called at [7, 11]",
+		},
+	},
+	{
+		"recursion limit",
+		"loop(n int) int {\n    return 1 + loop(n + 1)\n}\n\nmain() {\n    loop(1)\n}\n",
+		"",
+		[]string{
+			"error[E0328]: maximum recursion depth exceeded for function: loop",
+			"--> test.fl:2:16",
+			"loop(200) called at [2, 16]",
+		},
+	},
+	{
+		"error after tail calls",
+		"down(n int) int {\n    switch {\n        n == 0 => 10 / n,\n        default => down(n - 1)\n    }\n}\n\nmain() {\n    print(down(1000))\n}\n",
+		"",
+		[]string{
+			"error[E0330]: Division by zero",
+			"--> test.fl:3:19",
+			"main()\n            down(0) called at [4, 20]\n",
+		},
+	},
+	{
+		"wrong argument type",
+		"greet(name string) {\n    print(name)\n}\n\nmain() {\n    greet(1)\n}\n",
+		"",
+		[]string{
+			"error[E0327]: cannot use: int as arguments of type: string",
+			"--> test.fl:6:11",
+		},
+	},
+	{
+		"redeclared in loop",
+		"main() {\n    int i := 0\n    while i < 2 {\n        i = i + 1\n        int j := i\n    }\n}\n",
+		"",
+		[]string{
+			"error[E0303]: redeclared variable: j",
+			"--> test.fl:5:9",
+		},
+	},
+	{
+		"mismatched comparison",
+		"main() {\n    bool b := 1 < 1.0\n}\n",
+		"",
+		[]string{
+			"error[E0315]: cannot evaluate '<' operation with instances, mismatched types of int and float64",
+			"--> test.fl:2:15",
+		},
+	},
+	{
+		"failed assertion",
+		"main() {\n    assert(1 < 2)\n    print(\"checked\")\n    assert(2 < 1)\n}\n",
+		"checked\n",
+		[]string{
+			"error[E0336]: assertion failed",
+			"--> test.fl:4:5",
+			"assert(false) called at [4, 5]",
+		},
+	},
+	{
+		"values not equal",
+		"main() {\n    assert_eq(\"a\" + 1, \"a1\")\n    assert_eq(1, 1.0)\n}\n",
+		"",
+		[]string{
+			"error[E0337]: assertion failed: 1 (int) is not equal to 1 (float)",
+			"--> test.fl:3:5",
+		},
+	},
+	{
+		"assert_eq with three arguments",
+		"main() {\n    assert_eq(1, 2, 3)\n}\n",
+		"",
+		[]string{
+			"error[E0305]: function assert_eq expects 2 arguments but got: 3",
+			"--> test.fl:2:5",
+		},
+	},
+}
+
+// a program whose assertions hold prints the same as in the interpreter
+const assertionsSource = `
+main() {
+    assert(1 < 2 and "a" != "b")
+    assert_eq("a" + 1, "a1")
+    assert_eq(0.5 * 2.0, 1.0)
+    assert_eq(modulo(4, 2), true)
+    print("checked")
+}
+`
+
+func TestGeneratedAssertions(t *testing.T) {
+	requireGo(t)
+	expected := interpret(t, assertionsSource, nil)
+	if expected != "checked\n" {
+		t.Fatalf("unexpected output of the interpreter: %q", expected)
+	}
+	checkOutput(t, buildProgram(t, "test.fl", assertionsSource), nil, expected)
+}
+
+func TestGeneratedRuntimeErrors(t *testing.T) {
+	requireGo(t)
+	for _, test := range runtimeErrorTests {
+		t.Run(test.name, func(t *testing.T) {
+			binary := buildProgram(t, "test.fl", test.source)
+			checkRuntimeError(t, binary, test.output, test.expected)
+		})
+	}
+}
+
+// runs the binary expecting it to fail with the error
+func checkRuntimeError(t *testing.T, binary, output string, expected []string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(binary)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+		t.Fatalf("expected exit code 1, got %v", err)
+	}
+	if stdout.String() != output {
+		t.Errorf("expected output %q, got %q", output, stdout.String())
+	}
+	for _, e := range expected {
+		if !strings.Contains(stderr.String(), e) {
+			t.Errorf("expected error containing %q, got:\n%s", e, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package transpiler
+
+import (
+	"fmt"
+	"sort"
+	"tkom/ast"
+	"tkom/diagnostics"
+	"tkom/interpreter"
+	"tkom/shared"
+)
+
+const (
+	LANGUAGE_GO = "go"
+	LANGUAGE_C  = "c"
+)
+
+// translates a resolved program to the source of a standalone program
+// printing the same output and reporting the same errors as the interpreter
+type Generator interface {
+	Generate(program *ast.Program) ([]byte, error)
+}
+
+// number of parameters of the builtins, print and println take any number
+var builtinParameters = map[string]int{
+	"modulo":    2,
+	"sqrt":      1,
+	"power":     2,
+	"assert":    1,
+	"assert_eq": 2,
+}
+
+// error codes the runtime reports, with the messages of the interpreter
+var runtimeErrors = []struct {
+	name string
+	code interpreter.ErrorCode
+}{
+	{"ERR_UNDEFINED_VARIABLE", interpreter.ERR_UNDEFINED_VARIABLE},
+	{"ERR_UNDEFINED_FUNCTION", interpreter.ERR_UNDEFINED_FUNCTION},
+	{"ERR_REDECLARED_VARIABLE", interpreter.ERR_REDECLARED_VARIABLE},
+	{"ERR_TYPE_MISMATCH", interpreter.ERR_TYPE_MISMATCH},
+	{"ERR_WRONG_NUMBER_OF_ARGUMENTS", interpreter.ERR_WRONG_NUMBER_OF_ARGUMENTS},
+	{"ERR_INVALID_NEGATE_EXPRESSION", interpreter.ERR_INVALID_NEGATE_EXPRESSION},
+	{"ERR_INVALID_MULTIPLY_EXPRESSION", interpreter.ERR_INVALID_MULTIPLY_EXPRESSION},
+	{"ERR_INVALID_DIVISION_EXPRESSION", interpreter.ERR_INVALID_DIVISION_EXPRESSION},
+	{"ERR_INVALID_SUM_EXPRESSION", interpreter.ERR_INVALID_SUM_EXPRESSION},
+	{"ERR_INVALID_SUBSTRACT_EXPRESSION", interpreter.ERR_INVALID_SUBSTRACT_EXPRESSION},
+	{"ERR_INVALID_EQUALS_MISSMATCH", interpreter.ERR_INVALID_EQUALS_MISSMATCH},
+	{"ERR_INVALID_NOT_EQUALS_MISSMATCH", interpreter.ERR_INVALID_NOT_EQUALS_MISSMATCH},
+	{"ERR_INVALID_GREATER_THAN_MISSMATCH", interpreter.ERR_INVALID_GREATER_THAN_MISSMATCH},
+	{"ERR_INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH", interpreter.ERR_INVALID_GREATER_OR_EQUALS_THAN_MISSMATCH},
+	{"ERR_INVALID_LESS_THAN_MISSMATCH", interpreter.ERR_INVALID_LESS_THAN_MISSMATCH},
+	{"ERR_INVALID_LESS_OR_EQUALS_THAN_MISSMATCH", interpreter.ERR_INVALID_LESS_OR_EQUALS_THAN_MISSMATCH},
+	{"ERR_INVALID_TYPE_ANNOTATION", interpreter.ERR_INVALID_TYPE_ANNOTATION},
+	{"ERR_INVALID_RETURN_TYPE", interpreter.ERR_INVALID_RETURN_TYPE},
+	{"ERR_MISSING_RETURN", interpreter.ERR_MISSING_RETURN},
+	{"ERR_MULTIPLE_DEFAULT_CASES", interpreter.ERR_MULTIPLE_DEFAULT_CASES},
+	{"ERR_INVALID_WHILE_CONDITION", interpreter.ERR_INVALID_WHILE_CONDITION},
+	{"ERR_WRONG_ARGUMENT_TYPE", interpreter.ERR_WRONG_ARGUMENT_TYPE},
+	{"ERR_MAX_RECURSION_DEPTH_EXCEEDED", interpreter.ERR_MAX_RECURSION_DEPTH_EXCEEDED},
+	{"ERR_EXPECTED_BOOLEAN_EXPRESSION", interpreter.ERR_EXPECTED_BOOLEAN_EXPRESSION},
+	{"ERR_DIVISION_BY_ZERO", interpreter.ERR_DIVISION_BY_ZERO},
+	{"ERR_INVALID_CAST_EXPRESSION", interpreter.ERR_INVALID_CAST_EXPRESSION},
+	{"ERR_ASSERTION_FAILED", interpreter.ERR_ASSERTION_FAILED},
+	{"ERR_ASSERTION_NOT_EQUAL", interpreter.ERR_ASSERTION_NOT_EQUAL},
+}
+
+func NewGenerator(language, sourceFile string, maxRecursionDepth int) (Generator, error) {
+	switch language {
+	case LANGUAGE_GO:
+		return NewGoGenerator(sourceFile, maxRecursionDepth), nil
+	case LANGUAGE_C:
+		return NewCGenerator(sourceFile, maxRecursionDepth), nil
+	default:
+		return nil, fmt.Errorf("unknown language to emit: %s, expected %s or %s", language, LANGUAGE_GO, LANGUAGE_C)
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// position the interpreter shows for an error at the node, the
+// rendered diagnostic starts at the span around it
+func errorPosition(position shared.Position, span shared.Span) shared.Position {
+	d := diagnostics.Diagnostic{Position: position, Span: span}
+	return d.Location()
+}
+
+func functionID(name string) string {
+	return "id_" + name
+}
+
+func functionName(name string) string {
+	return "f_" + name
+}